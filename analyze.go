@@ -0,0 +1,177 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"math/big"
+	"os"
+	"strings"
+)
+
+// Puzzle analysis.
+//
+// "squareroot analyze" builds the puzzle's distance table and summarizes the
+// positions reachable from the start: how many there are, how many are
+// solved or can no longer be solved, and how far the farthest of them is
+// from the goal.
+//
+// With -solutions it also counts the distinct solutions of each length from
+// the optimal L up to L+k, for k given by -extra, which shows how forgiving
+// the puzzle is: how many ways there are to solve it, and how quickly they
+// multiply as the solver strays from the best line. A solution is any
+// sequence of moves that reaches the goal for the first time on its last
+// move, so the longer ones include detours such as a move and its undo.
+//
+// -goals names other goals, separated by semicolons, and -exits adds one
+// for each place along the bottom edge where the goal's piece could leave,
+// for the start's distance from each and how many positions can still
+// reach it. Their tables are built in the same pass as the puzzle's own
+// (see disttable.go), so questions about alternate exits cost one search.
+//
+// "squareroot analyze position" describes a single position instead (see
+// mobility.go).
+
+var analyzeFlags = flag.NewFlagSet("analyze", flag.ContinueOnError)
+
+var (
+	analyzeSolutions = analyzeFlags.Bool("solutions", false, "Count the solutions of each length from the optimal up to -extra moves longer.")
+	analyzeExtra     = analyzeFlags.Int("extra", 4, "How many moves beyond the optimal -solutions counts.")
+	analyzeGoals     = analyzeFlags.String("goals", "", "Also measure distances to these goals, separated by semicolons.")
+	analyzeExits     = analyzeFlags.Bool("exits", false, "Also measure distances to the goal piece at each place along the bottom edge.")
+)
+
+// Runs "analyze" or "analyze position [board]".
+func runAnalyze(start *Board, args []string) {
+	if len(args) > 0 && args[0] == "position" && len(args) <= 2 {
+		runAnalyzePosition(start, args[1:])
+		return
+	}
+	if len(args) > 0 {
+		fmt.Fprintln(os.Stderr, "usage: squareroot analyze [position [board]]")
+		os.Exit(exitInvalid)
+	}
+	goals, err := analysisGoals(start)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(exitInvalid)
+	}
+	fmt.Fprintln(os.Stderr, "Building distance table...")
+	g := buildMoveGraphFor(start, goals)
+	t := g.distanceTable(start)
+	solved, dead, farthest := 0, 0, 0
+	for _, d := range t.dist {
+		switch {
+		case d == 0:
+			solved++
+		case d < 0:
+			dead++
+		}
+		farthest = max(farthest, d)
+	}
+	optimal, _ := t.Distance(start)
+
+	fmt.Printf("Reachable positions:  %d\n", t.Size())
+	fmt.Printf("Solved positions:     %d\n", solved)
+	fmt.Printf("Unsolvable positions: %d\n", dead)
+	if optimal < 0 {
+		fmt.Println("The puzzle can't be solved.")
+	} else {
+		fmt.Printf("Optimal solution:     %d moves\n", optimal)
+		fmt.Printf("Farthest position:    %d moves from the goal\n", farthest)
+	}
+	if len(goals) > 0 {
+		fmt.Printf("%-24s %8s %10s %10s\n", "goal", "optimal", "solved", "unsolvable")
+		for k, gt := range g.distanceTables(start) {
+			solved, dead := 0, 0
+			for _, d := range gt.dist {
+				switch {
+				case d == 0:
+					solved++
+				case d < 0:
+					dead++
+				}
+			}
+			opt := "-"
+			if d, _ := gt.Distance(start); d >= 0 {
+				opt = fmt.Sprint(d)
+			}
+			fmt.Printf("%-24v %8s %10d %10d\n", goals[k], opt, solved, dead)
+		}
+	}
+	if optimal < 0 {
+		return
+	}
+
+	if *analyzeSolutions {
+		if *analyzeExtra < 0 {
+			fmt.Fprintln(os.Stderr, "-extra must not be negative")
+			os.Exit(exitInvalid)
+		}
+		counts := g.countSolutions(t.dist, optimal+*analyzeExtra)
+		fmt.Println("Solutions by length:")
+		for n := optimal; n < len(counts); n++ {
+			fmt.Printf("  %4d moves  %s\n", n, counts[n])
+		}
+	}
+}
+
+// Returns the goals selected by -goals and -exits.
+func analysisGoals(start *Board) ([]Goal, error) {
+	goals := []Goal{}
+	if *analyzeGoals != "" {
+		for _, text := range strings.Split(*analyzeGoals, ";") {
+			goal, err := start.parseGoal(strings.Fields(text))
+			if err != nil {
+				return nil, fmt.Errorf("-goals %q: %v", text, err)
+			}
+			goals = append(goals, goal)
+		}
+	}
+	if *analyzeExits {
+		pids := start.goalPieceList()
+		if len(pids) != 1 {
+			return nil, fmt.Errorf("-exits needs a goal with a single piece")
+		}
+		p := start.ps[pids[0]]
+		for x := 0; x+p.w <= start.w; x++ {
+			goals = append(goals, Condition{pid: p.id, x: x, y: start.h - p.h})
+		}
+	}
+	if len(goals) > maxGraphGoals {
+		return nil, fmt.Errorf("at most %d goals can be measured at once", maxGraphGoals)
+	}
+	return goals, nil
+}
+
+// Counts the solutions from the start of each length up to maxLen: the move
+// sequences that reach a solved node for the first time on their last move.
+// It counts them layer by layer, carrying the number of ways to reach each
+// node in n moves without passing a solved one, and skips the nodes too far
+// from the goal, by the distances in dist, to finish within maxLen.
+func (g *moveGraph) countSolutions(dist []int, maxLen int) []*big.Int {
+	counts := make([]*big.Int, maxLen+1)
+	for n := range counts {
+		counts[n] = new(big.Int)
+	}
+	ways := map[int32]*big.Int{0: big.NewInt(1)}
+	for n := 0; n <= maxLen && len(ways) > 0; n++ {
+		next := map[int32]*big.Int{}
+		for node, w := range ways {
+			if g.solved[node] {
+				counts[n].Add(counts[n], w)
+				continue
+			}
+			for _, nn := range g.succ[node] {
+				if d := dist[nn]; d < 0 || n+1+d > maxLen {
+					continue
+				}
+				if next[nn] == nil {
+					next[nn] = new(big.Int)
+				}
+				next[nn].Add(next[nn], w)
+			}
+		}
+		ways = next
+	}
+	return counts
+}
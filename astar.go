@@ -0,0 +1,83 @@
+package main
+
+import (
+	"container/heap"
+	"flag"
+)
+
+var astar = flag.Bool("astar", false,
+	"Search with A*, guided by the goal's heuristic, instead of breadth-first.")
+
+// Searches for the shortest solution with A*: boards are expanded in order
+// of moves taken plus the goal's estimate of moves remaining. Because the
+// estimate never overestimates, the first solution expanded is optimal.
+func solveAStar(start *Board) (*Board, Stats) {
+	return solveAStarWith(start, func(b *Board) int { return b.goal.Heuristic(b) })
+}
+
+// Searches with A* guided by the given estimate of the moves remaining, which
+// must never overestimate.
+func solveAStarWith(start *Board, h func(*Board) int) (*Board, Stats) {
+	q := &boardQueue{}
+	heap.Push(q, astarNode{start, h(start)})
+	// The fewest moves found so far to reach each configuration.
+	bestMoves := map[string]int{start.Config(): 0}
+	numSkipped, numExpanded := 0, 0
+	for q.Len() > 0 && !searchExpired() {
+		b := heap.Pop(q).(astarNode).b
+		events.snapshot(len(bestMoves), q.Len(), numSkipped)
+		if len(b.mvs) > bestMoves[b.Config()] {
+			// Superseded by a shorter path to the same configuration.
+			continue
+		}
+		if b.goal.IsSatisfied(b) {
+			return b, Stats{len(bestMoves), numSkipped, numExpanded}
+		}
+		numExpanded++
+		tracer.expand(b)
+		depthStats.expand(len(b.mvs))
+		for _, m := range b.orderedMoves() {
+			nbConfig := b.configAfter(m)
+			_, seen := bestMoves[nbConfig]
+			depthStats.generate(len(b.mvs), seen || avoided[nbConfig])
+			if n, ok := bestMoves[nbConfig]; ok && n <= len(b.mvs)+1 || avoided[nbConfig] {
+				numSkipped++
+				continue
+			}
+			nb := b.move(m)
+			bestMoves[nbConfig] = len(nb.mvs)
+			heap.Push(q, astarNode{nb, len(nb.mvs) + h(nb)})
+		}
+	}
+	return nil, Stats{len(bestMoves), numSkipped, numExpanded}
+}
+
+// A board waiting to be expanded, with its estimated total solution length.
+type astarNode struct {
+	b *Board
+	f int
+}
+
+// boardQueue is a priority queue of boards ordered by estimated total
+// solution length, breaking ties in favor of boards further along.
+type boardQueue []astarNode
+
+func (q boardQueue) Len() int { return len(q) }
+
+func (q boardQueue) Less(i, j int) bool {
+	if q[i].f != q[j].f {
+		return q[i].f < q[j].f
+	}
+	return len(q[i].b.mvs) > len(q[j].b.mvs)
+}
+
+func (q boardQueue) Swap(i, j int) { q[i], q[j] = q[j], q[i] }
+
+func (q *boardQueue) Push(x any) { *q = append(*q, x.(astarNode)) }
+
+func (q *boardQueue) Pop() any {
+	old := *q
+	n := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return n
+}
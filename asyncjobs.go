@@ -0,0 +1,209 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"runtime/debug"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Asynchronous jobs.
+//
+// A search of another puzzle's board can take minutes, longer than a
+// client wants to hold a request open or a proxy allows. POST /jobs, with
+// the same board and moves parameters as /solve in the query or a form
+// body, starts the search and answers at once, 202 Accepted, with the job's
+// id and its address in the Location header. GET /jobs/{id} then reports
+// the job's status:
+//
+//	queued    waiting for a turn (see -max-jobs)
+//	running   searching, with the configurations seen and the depth
+//	          reached so far
+//	solved    done, with the solution
+//	failed    done without a solution, with the error saying why
+//
+// With callback=<url>, the server also sends the finished job to that URL
+// (see callbacks.go).
+//
+// Jobs run from the same queue as other searches and under the same limits,
+// but may take as long as -async-timeout. A position of the server's own
+// puzzle, or one in the results cache, is solved at once. A finished job is kept for
+// -job-ttl and then forgotten.
+
+var (
+	asyncTimeout = flag.Duration("async-timeout", 10*time.Minute,
+		"Time a server search started with POST /jobs may take, including time queued.")
+	jobTTL = flag.Duration("job-ttl", time.Hour, "How long the server keeps the result of a finished job.")
+)
+
+// An asyncJob is a search started with POST /jobs.
+type asyncJob struct {
+	id       string
+	started  time.Time
+	callback string // URL to send the job to when it finishes, if any
+	progress searchProgress
+
+	mu       sync.Mutex
+	finished time.Time // the zero time until it's done
+	mvs      []Move
+	err      error
+}
+
+// The jobs started with POST /jobs, by id.
+type jobStore struct {
+	mu   sync.Mutex
+	jobs map[string]*asyncJob
+}
+
+func newJobStore() *jobStore {
+	return &jobStore{jobs: make(map[string]*asyncJob)}
+}
+
+// Adds a new job with the given callback URL, if any, forgetting those
+// finished more than -job-ttl ago.
+func (js *jobStore) add(callback string) *asyncJob {
+	id := make([]byte, 8)
+	rand.Read(id)
+	j := &asyncJob{id: hex.EncodeToString(id), started: time.Now(), callback: callback}
+	js.mu.Lock()
+	defer js.mu.Unlock()
+	for id, old := range js.jobs {
+		if f := old.done(); !f.IsZero() && time.Since(f) > *jobTTL {
+			delete(js.jobs, id)
+		}
+	}
+	js.jobs[j.id] = j
+	return j
+}
+
+func (js *jobStore) get(id string) *asyncJob {
+	js.mu.Lock()
+	defer js.mu.Unlock()
+	return js.jobs[id]
+}
+
+// Records the job's result and sends it to the job's callback, if any.
+func (j *asyncJob) finish(mvs []Move, err error) {
+	j.mu.Lock()
+	j.finished, j.mvs, j.err = time.Now(), mvs, err
+	j.mu.Unlock()
+	if j.callback != "" {
+		go j.notify()
+	}
+}
+
+// Returns when the job finished, or the zero time if it hasn't.
+func (j *asyncJob) done() time.Time {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.finished
+}
+
+type jobResponse struct {
+	ID             string         `json:"id"`
+	Status         string         `json:"status"`
+	Configurations int64          `json:"configurations"`
+	Depth          int64          `json:"depth"`
+	Seconds        float64        `json:"seconds"` // since it started, or how long it took
+	Solution       *solveResponse `json:"solution,omitempty"`
+	Error          string         `json:"error,omitempty"`
+}
+
+// Describes the job as it is now.
+func (j *asyncJob) response() jobResponse {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	resp := jobResponse{ID: j.id, Configurations: j.progress.configs.Load(), Depth: j.progress.depth.Load()}
+	switch {
+	case j.finished.IsZero():
+		resp.Status = "queued"
+		if j.progress.running.Load() {
+			resp.Status = "running"
+		}
+		resp.Seconds = time.Since(j.started).Seconds()
+	case j.err != nil:
+		resp.Status, resp.Error = "failed", j.err.Error()
+		resp.Seconds = j.finished.Sub(j.started).Seconds()
+	default:
+		resp.Status = "solved"
+		resp.Seconds = j.finished.Sub(j.started).Seconds()
+		resp.Solution = &solveResponse{len(j.mvs), []string{}, nil}
+		for _, m := range j.mvs {
+			resp.Solution.Moves = append(resp.Solution.Moves, m.code())
+		}
+	}
+	return resp
+}
+
+func (s *server) handleStartJob(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		writeJSON(w, http.StatusMethodNotAllowed, errorResponse{"jobs are started with POST"})
+		return
+	}
+	b, err := s.position(r)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{err.Error()})
+		return
+	}
+	callback := r.FormValue("callback")
+	if callback != "" {
+		if err := checkCallback(callback); err != nil {
+			writeJSON(w, http.StatusBadRequest, errorResponse{err.Error()})
+			return
+		}
+	}
+	own := s.table.start.samePuzzle(b)
+	var mvs []Move
+	cached := false
+	if !own {
+		mvs, cached, err = s.cachedSolution(b)
+		if !cached {
+			if err := s.admit(b); err != nil {
+				writeSearchError(w, err)
+				return
+			}
+		}
+	}
+	j := s.async.add(callback)
+	switch {
+	case own:
+		_, err := s.table.Distance(b)
+		s.metrics.countLookup(err == nil)
+		j.finish(s.table.Solve(b))
+	case cached:
+		j.finish(mvs, err)
+	default:
+		go func() {
+			// A panic in the search would take the server down with it.
+			// runSearch's deferred leave has given up its place in the queue
+			// by the time it gets here, so fail just the job.
+			defer func() {
+				if v := recover(); v != nil {
+					log.Printf("Job %s: search panicked: %v\n%s", j.id, v, debug.Stack())
+					s.metrics.countSearch("unsolved")
+					j.finish(nil, fmt.Errorf("internal error: %v", v))
+				}
+			}()
+			j.finish(s.runSearch(context.Background(), b, *asyncTimeout, &j.progress))
+		}()
+	}
+	w.Header().Set("Location", "/jobs/"+j.id)
+	writeJSON(w, http.StatusAccepted, j.response())
+}
+
+func (s *server) handleJob(w http.ResponseWriter, r *http.Request) {
+	j := s.async.get(strings.TrimPrefix(r.URL.Path, "/jobs/"))
+	if j == nil {
+		writeJSON(w, http.StatusNotFound, errorResponse{"no such job"})
+		return
+	}
+	writeJSON(w, http.StatusOK, j.response())
+}
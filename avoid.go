@@ -0,0 +1,78 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+)
+
+// Avoided positions.
+//
+// "solve -avoid <board>" finds the shortest solution that never passes
+// through the given position, a puzzle file (whose goal is ignored) or
+// board code with the same pieces as the puzzle. The flag may be repeated to avoid several positions.
+// If the search then comes up empty, every solution goes through one of
+// them: the position is a true bottleneck.
+
+// A flag that may be given more than once, collecting its values.
+type listFlag []string
+
+func (l *listFlag) String() string { return strings.Join(*l, ",") }
+
+func (l *listFlag) Set(s string) error {
+	*l = append(*l, s)
+	return nil
+}
+
+var avoidBoards listFlag
+
+func init() {
+	flag.Var(&avoidBoards, "avoid",
+		"Find a solution that never passes through this position, a puzzle file or board code; may be repeated.")
+}
+
+// The configurations the search must not pass through.
+var avoided = map[string]bool{}
+
+// Loads the -avoid positions of the puzzle with the given starting board.
+func loadAvoided(start *Board) error {
+	for _, arg := range avoidBoards {
+		b, err := loadBoard(arg)
+		if err != nil {
+			return fmt.Errorf("-avoid: %v", err)
+		}
+		if err := start.samePieces(b); err != nil {
+			return fmt.Errorf("-avoid %s: %v", arg, err)
+		}
+		// Compare the positions under the puzzle's goal and links, which
+		// decide which pieces are interchangeable.
+		ab := *start
+		ab.ps = b.ps
+		avoided[ab.Config()] = true
+	}
+	if avoided[start.Config()] {
+		return fmt.Errorf("-avoid: the starting position is avoided")
+	}
+	return nil
+}
+
+// Reports whether the other board has the same size and pieces as this
+// one, though perhaps in different places.
+func (b *Board) samePieces(o *Board) error {
+	if o.w != b.w || o.h != b.h {
+		return fmt.Errorf("the board is %dx%d, not %dx%d", o.w, o.h, b.w, b.h)
+	}
+	if len(o.ps) != len(b.ps) {
+		return fmt.Errorf("the board has %d pieces, not %d", len(o.ps), len(b.ps))
+	}
+	for id, p := range b.ps {
+		op, ok := o.ps[id]
+		if !ok {
+			return fmt.Errorf("the board has no piece %s", id)
+		}
+		if op.w != p.w || op.h != p.h {
+			return fmt.Errorf("piece %s is %dx%d, not %dx%d", id, op.w, op.h, p.w, p.h)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"time"
+)
+
+// Benchmarks.
+//
+// "squareroot bench" times each solver on the puzzle, bypassing the results
+// cache, and prints the fastest and mean time of several runs along with
+// the work each run did.
+
+var benchFlags = flag.NewFlagSet("bench", flag.ContinueOnError)
+
+var benchRuns = benchFlags.Int("runs", 3, "Times to run each solver.")
+
+// Runs "bench".
+func runBench(start *Board) {
+	runs := max(*benchRuns, 1)
+	fmt.Printf("%-9s %6s %10s %12s %12s\n", "solver", "moves", "configs", "fastest", "mean")
+	for _, s := range solvers {
+		var fastest, total time.Duration
+		var end *Board
+		var stats Stats
+		for i := 0; i < runs; i++ {
+			t := time.Now()
+			end, stats = s.solve(start)
+			d := time.Since(t)
+			total += d
+			if i == 0 || d < fastest {
+				fastest = d
+			}
+		}
+		moves := "-"
+		if end != nil {
+			moves = fmt.Sprint(len(end.mvs))
+		}
+		fmt.Printf("%-9s %6s %10d %12s %12s\n", s.name, moves, stats.Configs,
+			fastest.Round(time.Microsecond), (total / time.Duration(runs)).Round(time.Microsecond))
+	}
+}
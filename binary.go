@@ -0,0 +1,294 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// Binary encodings.
+//
+// Boards, moves, search stats and solutions have compact binary encodings,
+// for files and messages where JSON is too bulky: the results cache with
+// -cache-format binary, and anything that stores or sends them through
+// encoding/gob, which uses these encodings (they implement
+// encoding.BinaryMarshaler). Each starts with two bytes, a letter saying
+// what it is and a format version:
+//
+//	board     'B' 1  the board code's bytes (see encode.go), then the moves
+//	                 that led to the board
+//	move      'M' 1  the piece's id and the direction (0 up, 1 down, 2
+//	                 left, 3 right), a byte each
+//	stats     'T' 1  configurations seen, moves skipped and configurations
+//	                 expanded
+//	solution  'S' 1  the starting board, the moves, the search's stats, and
+//	                 a byte of flags: 1 if the puzzle was proven unsolvable
+//
+// Counts and lengths are unsigned varints; a list of moves is its length
+// and then each move's two bytes; and a value inside another (a solution's
+// board or stats) is its length and then its own encoding, version and
+// all. A new version may add fields at the end, and readers read every
+// version up to their own, so files written by an older squareroot stay
+// readable; a reader given a newer version than it knows says so.
+
+// The kinds and current versions of the binary encodings.
+const (
+	binBoard    = 'B'
+	binMove     = 'M'
+	binStats    = 'T'
+	binSolution = 'S'
+
+	binBoardVersion    = 1
+	binMoveVersion     = 1
+	binStatsVersion    = 1
+	binSolutionVersion = 1
+)
+
+var binNames = map[byte]string{binBoard: "board", binMove: "move", binStats: "stats", binSolution: "solution"}
+
+// Solution is a solution to a puzzle, or the finding that there's none,
+// with the stats of the search that found it.
+type Solution struct {
+	Start      *Board // the starting board
+	Moves      []Move // the moves, if solvable
+	Stats      Stats
+	Unsolvable bool // whether an exhaustive search found no solution
+}
+
+// MarshalBinary returns the board's binary encoding, the moves that led to
+// it included.
+func (b *Board) MarshalBinary() ([]byte, error) {
+	code, err := b.codeBytes()
+	if err != nil {
+		return nil, err
+	}
+	bs := []byte{binBoard, binBoardVersion}
+	bs = binary.AppendUvarint(bs, uint64(len(code)))
+	bs = append(bs, code...)
+	return appendMoves(bs, b.mvs)
+}
+
+// UnmarshalBinary sets the board from its binary encoding, checking that
+// the board is valid.
+func (b *Board) UnmarshalBinary(data []byte) error {
+	r := &binReader{bs: data}
+	r.header(binBoard, binBoardVersion)
+	code := r.bytes()
+	mvs := r.moves()
+	if err := r.done(); err != nil {
+		return err
+	}
+	nb, err := decodeBytes(code)
+	if err == nil {
+		err = nb.validate()
+	}
+	if err != nil {
+		return err
+	}
+	nb.mvs = mvs
+	*b = *nb
+	return nil
+}
+
+// MarshalBinary returns the move's binary encoding.
+func (m Move) MarshalBinary() ([]byte, error) {
+	return appendMove([]byte{binMove, binMoveVersion}, m)
+}
+
+// UnmarshalBinary sets the move from its binary encoding.
+func (m *Move) UnmarshalBinary(data []byte) error {
+	r := &binReader{bs: data}
+	r.header(binMove, binMoveVersion)
+	mv := r.move()
+	if err := r.done(); err != nil {
+		return err
+	}
+	*m = mv
+	return nil
+}
+
+// MarshalBinary returns the stats' binary encoding.
+func (s Stats) MarshalBinary() ([]byte, error) {
+	bs := []byte{binStats, binStatsVersion}
+	for _, n := range []int{s.Configs, s.Skipped, s.Expanded} {
+		bs = binary.AppendUvarint(bs, uint64(n))
+	}
+	return bs, nil
+}
+
+// UnmarshalBinary sets the stats from their binary encoding.
+func (s *Stats) UnmarshalBinary(data []byte) error {
+	r := &binReader{bs: data}
+	r.header(binStats, binStatsVersion)
+	st := Stats{r.int(), r.int(), r.int()}
+	if err := r.done(); err != nil {
+		return err
+	}
+	*s = st
+	return nil
+}
+
+// MarshalBinary returns the solution's binary encoding.
+func (s *Solution) MarshalBinary() ([]byte, error) {
+	board, err := s.Start.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	stats, _ := s.Stats.MarshalBinary()
+	bs := []byte{binSolution, binSolutionVersion}
+	bs = binary.AppendUvarint(bs, uint64(len(board)))
+	bs = append(bs, board...)
+	if bs, err = appendMoves(bs, s.Moves); err != nil {
+		return nil, err
+	}
+	bs = binary.AppendUvarint(bs, uint64(len(stats)))
+	bs = append(bs, stats...)
+	flags := byte(0)
+	if s.Unsolvable {
+		flags |= 1
+	}
+	return append(bs, flags), nil
+}
+
+// UnmarshalBinary sets the solution from its binary encoding, checking that
+// its moves are legal.
+func (s *Solution) UnmarshalBinary(data []byte) error {
+	r := &binReader{bs: data}
+	r.header(binSolution, binSolutionVersion)
+	board := r.bytes()
+	mvs := r.moves()
+	stats := r.bytes()
+	flags := r.byte()
+	if err := r.done(); err != nil {
+		return err
+	}
+	var sol Solution
+	sol.Start = &Board{}
+	if err := sol.Start.UnmarshalBinary(board); err != nil {
+		return err
+	}
+	if err := sol.Stats.UnmarshalBinary(stats); err != nil {
+		return err
+	}
+	if _, err := sol.Start.ApplyMoves(mvs); err != nil {
+		return fmt.Errorf("invalid solution encoding: %v", err)
+	}
+	sol.Moves, sol.Unsolvable = mvs, flags&1 != 0
+	*s = sol
+	return nil
+}
+
+func appendMoves(bs []byte, mvs []Move) ([]byte, error) {
+	bs = binary.AppendUvarint(bs, uint64(len(mvs)))
+	for _, m := range mvs {
+		var err error
+		if bs, err = appendMove(bs, m); err != nil {
+			return nil, err
+		}
+	}
+	return bs, nil
+}
+
+func appendMove(bs []byte, m Move) ([]byte, error) {
+	if len(m.pid) != 1 || m.dir < Up || m.dir > Right {
+		return nil, fmt.Errorf("can't encode move %v", m)
+	}
+	return append(bs, m.pid[0], byte(m.dir)), nil
+}
+
+// binReader reads a binary encoding, remembering the first error.
+type binReader struct {
+	bs      []byte
+	pos     int
+	err     error
+	version byte
+}
+
+func (r *binReader) fail(format string, args ...any) {
+	if r.err == nil {
+		r.err = fmt.Errorf(format, args...)
+	}
+}
+
+// Reads the kind and version, checking them.
+func (r *binReader) header(kind, version byte) {
+	k, v := r.byte(), r.byte()
+	switch {
+	case r.err != nil:
+	case k != kind:
+		r.fail("not a %s encoding", binNames[kind])
+	case v < 1 || v > version:
+		r.fail("%s encoding version %d is newer than this squareroot reads (%d)", binNames[kind], v, version)
+	}
+	r.version = v
+}
+
+func (r *binReader) byte() byte {
+	if r.pos >= len(r.bs) {
+		r.fail("encoding too short")
+		return 0
+	}
+	r.pos++
+	return r.bs[r.pos-1]
+}
+
+func (r *binReader) uvarint() uint64 {
+	if r.err != nil {
+		return 0
+	}
+	n, size := binary.Uvarint(r.bs[r.pos:])
+	if size <= 0 {
+		r.fail("invalid number in encoding")
+		return 0
+	}
+	r.pos += size
+	return n
+}
+
+func (r *binReader) int() int {
+	n := r.uvarint()
+	if n > 1<<31 {
+		r.fail("number %d out of range", n)
+		return 0
+	}
+	return int(n)
+}
+
+// Reads a length and that many bytes.
+func (r *binReader) bytes() []byte {
+	n := r.int()
+	if r.err == nil && n > len(r.bs)-r.pos {
+		r.fail("encoding too short")
+	}
+	if r.err != nil {
+		return nil
+	}
+	r.pos += n
+	return r.bs[r.pos-n : r.pos]
+}
+
+func (r *binReader) move() Move {
+	pid, dir := r.byte(), r.byte()
+	if r.err == nil && Direction(dir) > Right {
+		r.fail("invalid direction %d", dir)
+	}
+	return Move{string(pid), Direction(dir)}
+}
+
+func (r *binReader) moves() []Move {
+	mvs := []Move{}
+	for n := r.int(); n > 0 && r.err == nil; n-- {
+		mvs = append(mvs, r.move())
+	}
+	return mvs
+}
+
+// Returns the first error, or an error if bytes are left over.
+func (r *binReader) done() error {
+	if r.err == nil && r.pos != len(r.bs) {
+		r.fail("%d trailing bytes", len(r.bs)-r.pos)
+	}
+	if r.err != nil {
+		return fmt.Errorf("invalid binary encoding: %v", r.err)
+	}
+	return nil
+}
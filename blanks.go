@@ -0,0 +1,137 @@
+package main
+
+import "slices"
+
+// Blank tracking.
+//
+// Only a piece next to an open space can move, and only into it, so rather
+// than trying every piece in every direction, possibleMoves starts from the
+// open spaces: it maps which piece covers each space, and for each open
+// space and direction finds the piece that would slide into it, checking
+// just those moves. A 2x2 piece beside two open spaces is found twice but
+// tried once. On a crowded board such as the classic one, with two open
+// spaces among ten pieces, that's at most eight candidate moves instead of
+// forty, each checked against the map rather than every piece, or on
+// boards small enough, against masks of the spaces (see masks.go).
+
+// Marks a wall in a board's occupancy map.
+const wallOccupant = "#"
+
+// Which piece covers each space of a board.
+type occupancy struct {
+	ids []string // by space, row by row: the piece's id, "" if open or wallOccupant
+	// With masks, the spaces that pieces moving in each direction can't
+	// enter, being covered or one-way against them.
+	masks  *moveMasks
+	closed [4]spaceMask
+}
+
+// Returns the board's occupancy. Walls and one-way cells index the map by
+// position, so the board must have been validated, which keeps them on it;
+// every board parsed, decoded or loaded is.
+func (b *Board) occupants() *occupancy {
+	o := &occupancy{ids: make([]string, b.w*b.h), masks: b.moveMasks()}
+	full := spaceMask(0)
+	for s := range b.walls {
+		o.ids[s.y*b.w+s.x] = wallOccupant
+		if o.masks != nil {
+			full |= spaceBit(b.w, s)
+		}
+	}
+	for _, p := range b.ps {
+		for y := p.y; y < p.y+p.h; y++ {
+			for x := p.x; x < p.x+p.w; x++ {
+				s := Space{x, y}
+				if b.wrap {
+					s = b.wrapSpace(s)
+				}
+				o.ids[s.y*b.w+s.x] = p.id
+			}
+		}
+		switch {
+		case o.masks != nil && !o.masks.fits(p):
+			o.masks = nil
+		case o.masks != nil:
+			full |= o.masks.covers(p)
+		}
+	}
+	if o.masks != nil {
+		for _, d := range Directions {
+			o.closed[d] = full
+		}
+		for s, ds := range b.oneway {
+			for _, d := range Directions {
+				if !ds.has(d) {
+					o.closed[d] |= spaceBit(b.w, s)
+				}
+			}
+		}
+	}
+	return o
+}
+
+// Returns the id of the piece covering a space, "" if it's open, or
+// wallOccupant if it's a wall or off the board.
+func (b *Board) occupant(o *occupancy, s Space) string {
+	if b.wrap {
+		s = b.wrapSpace(s)
+	} else if s.x < 0 || s.y < 0 || s.x >= b.w || s.y >= b.h {
+		return wallOccupant
+	}
+	return o.ids[s.y*b.w+s.x]
+}
+
+// Returns the moves of pieces into the open spaces, before constraints and
+// move rules are applied.
+func (b *Board) blankMoves() []Move {
+	o := b.occupants()
+	mvs := []Move{}
+	tried := []Move{}
+	for i, id := range o.ids {
+		if id != "" {
+			continue
+		}
+		s := Space{i % b.w, i / b.w}
+		for _, d := range Directions {
+			// A piece moving in d into s lies just behind it.
+			dx, dy := d.delta()
+			pid := b.occupant(o, Space{s.x - dx, s.y - dy})
+			if pid == "" || pid == wallOccupant {
+				continue
+			}
+			if g := b.links[pid]; g != nil {
+				pid = g[0]
+			}
+			m := Move{pid, d}
+			if slices.Contains(tried, m) {
+				continue
+			}
+			tried = append(tried, m)
+			if b.canSlide(o, b.ps[pid], d) {
+				mvs = append(mvs, m)
+			}
+		}
+	}
+	return mvs
+}
+
+// Can the piece, or the group it leads, move in the given direction, given
+// the board's occupancy.
+func (b *Board) canSlide(o *occupancy, p Piece, d Direction) bool {
+	if p.held(b) || b.rails && !p.slidesAlong(d) {
+		return false
+	}
+	if g := b.links[p.id]; g != nil {
+		return b.canMoveGroup(g, d)
+	}
+	if o.masks != nil {
+		t := o.masks.targets(p, d)
+		return t != 0 && t&o.closed[d] == 0
+	}
+	for _, ts := range p.targetSpaces(d) {
+		if b.occupant(o, ts) != "" || !b.canEnter(ts, d) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+)
+
+// Blunder checks.
+//
+// With -blunders, play watches the moves the player makes and, like a chess
+// program's blunder alert, speaks up about one that takes the board further
+// from the goal than it was, or leaves the puzzle unsolvable:
+//
+//	warn     make the move and say what it cost
+//	confirm  hold the move back and say what it would cost; entering the
+//	         same move again plays it anyway
+//
+// Moves that only keep the distance to the goal aren't blunders. The check
+// uses the puzzle's distance table, built on the first move as for a hint,
+// and is off when hints are, in challenge and campaign modes.
+
+var blunders = flag.String("blunders", "",
+	"In play, point out moves that take the board further from the goal: warn, or confirm to ask before making them.")
+
+// Checks a move the player is about to make for a blunder, reporting
+// whether to make it. With -blunders warn it warns about a blunder that's
+// made, and with -blunders confirm it holds one back unless it was just
+// held back.
+func (g *game) checkBlunder(m Move, out io.Writer) bool {
+	held := g.held
+	g.held = Move{}
+	if *blunders == "" || g.noHelp || !g.b.isLegal(m) {
+		return true
+	}
+	if g.table == nil {
+		fmt.Fprintln(out, "Thinking...")
+	}
+	g.ensureTable()
+	d, _ := g.table.Distance(g.b)
+	nd, _ := g.table.Distance(g.b.move(m))
+	if d < 0 || nd >= 0 && nd <= d {
+		return true
+	}
+	if *blunders == "confirm" {
+		if m == held {
+			return true
+		}
+		g.held = m
+		if nd < 0 {
+			fmt.Fprintf(out, "%s would leave the puzzle unsolvable. Enter it again to play it anyway.\n", m.code())
+		} else {
+			fmt.Fprintf(out, "%s would add %s (%d from the goal instead of %d). Enter it again to play it anyway.\n",
+				m.code(), countOf(nd-d+1, "move"), nd, d-1)
+		}
+		return false
+	}
+	if nd < 0 {
+		fmt.Fprintf(out, "Blunder: %s leaves the puzzle unsolvable; undo to take it back.\n", m.code())
+	} else {
+		fmt.Fprintf(out, "Blunder: %s adds %s (%d from the goal instead of %d); undo to take it back.\n",
+			m.code(), countOf(nd-d+1, "move"), nd, d-1)
+	}
+	return true
+}
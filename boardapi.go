@@ -0,0 +1,96 @@
+package main
+
+import (
+	"fmt"
+	"hash/fnv"
+	"maps"
+	"reflect"
+	"slices"
+)
+
+// Board API.
+//
+// Front ends (the GUI, the web server, bots) keep track of a game through
+// these methods rather than the solver's internals, so the rules live in
+// one place. Boards are values: ApplyMove and ApplyMoves return new boards
+// and leave the one they're called on as it was, and Clone makes a copy
+// that shares nothing with the original. Predecessors, for searching
+// backward, is in predecessors.go.
+
+// LegalMoves returns the moves that can be made on the board.
+func (b *Board) LegalMoves() []Move {
+	return b.possibleMoves()
+}
+
+// ApplyMove returns the board after the given move, or an error saying why
+// the move can't be made.
+func (b *Board) ApplyMove(m Move) (*Board, error) {
+	if _, ok := b.ps[m.pid]; !ok {
+		return nil, fmt.Errorf("no piece %s", m.pid)
+	}
+	if !slices.Contains(Directions, m.dir) {
+		return nil, fmt.Errorf("invalid direction %d", m.dir)
+	}
+	if !b.isLegal(m) {
+		return nil, fmt.Errorf("%s can't move %s", m.pid, m.dir)
+	}
+	return b.move(m), nil
+}
+
+// ApplyMoves returns the board after the given moves, or an error saying
+// which move can't be made and why.
+func (b *Board) ApplyMoves(mvs []Move) (*Board, error) {
+	for i, m := range mvs {
+		nb, err := b.ApplyMove(m)
+		if err != nil {
+			return nil, fmt.Errorf("move %d: %v", i+1, err)
+		}
+		b = nb
+	}
+	return b, nil
+}
+
+// Clone returns a copy of the board, moves included, that shares nothing
+// with the original.
+func (b *Board) Clone() *Board {
+	nb := *b
+	nb.ps = maps.Clone(b.ps)
+	nb.mvs = slices.Clone(b.mvs)
+	nb.filters = slices.Clone(b.filters)
+	nb.oneway = maps.Clone(b.oneway)
+	nb.walls = maps.Clone(b.walls)
+	nb.frozen = maps.Clone(b.frozen)
+	if b.links != nil {
+		nb.links = make(map[string][]string)
+		for pid, g := range b.links {
+			nb.links[pid] = slices.Clone(g)
+		}
+	}
+	return &nb
+}
+
+// Equal reports whether two boards have the same size, rules and goal and
+// the same pieces in the same places. The moves that led to them don't
+// matter.
+func (b *Board) Equal(o *Board) bool {
+	return b.w == o.w && b.h == o.h && b.wrap == o.wrap && b.rails == o.rails &&
+		maps.Equal(b.ps, o.ps) &&
+		maps.Equal(b.walls, o.walls) &&
+		maps.Equal(b.oneway, o.oneway) &&
+		maps.Equal(b.frozen, o.frozen) &&
+		maps.EqualFunc(b.links, o.links, slices.Equal[[]string]) &&
+		reflect.DeepEqual(b.goal, o.goal) &&
+		reflect.DeepEqual(b.constraint, o.constraint) &&
+		reflect.DeepEqual(b.filters, o.filters)
+}
+
+// Hash returns a hash of the board's size and the places of its pieces.
+// Equal boards have equal hashes.
+func (b *Board) Hash() uint64 {
+	h := fnv.New64a()
+	fmt.Fprintf(h, "%dx%d", b.w, b.h)
+	for _, pid := range b.pieceIDs() {
+		fmt.Fprintf(h, ";%s:%s", pid, b.ps[pid].Config())
+	}
+	return h.Sum64()
+}
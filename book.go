@@ -0,0 +1,189 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Opening books.
+//
+// Hints in play come from the puzzle's distance table, which takes seconds
+// to build for a puzzle like Square Root. A book holds the answers for the
+// positions players are most likely to ask about, so those hints are
+// instant. "squareroot book <file>" writes the book of a puzzle: every
+// position on an optimal solution within -depth moves of the start, the
+// common openings, or of a key position, one every optimal solution passes
+// through (see lines.go), each with an optimal move and its distance to the
+// goal:
+//
+//	{
+//	  "format": "squareroot-book",
+//	  "version": 1,
+//	  "puzzle": "AgQF...",
+//	  "length": 116,
+//	  "depth": 10,
+//	  "positions": ["5f2a9c1e0b3d4e77 iR 116", ...]
+//	}
+//
+// Positions are given by the hash of their configuration, in hex. With
+// -book <file>, play's hints look the position up in the book first, and
+// only off the book do they build the distance table.
+
+var bookFlags = flag.NewFlagSet("book", flag.ContinueOnError)
+
+var bookDepth = bookFlags.Int("depth", 10,
+	"How many moves after the start and after each key position the book covers.")
+
+var bookFile = flag.String("book", "",
+	"In play, take hints from this book, written by the book command, before building the distance table.")
+
+// The format name and version of book files.
+const (
+	bookFormat  = "squareroot-book"
+	bookVersion = 1
+)
+
+type bookDoc struct {
+	Format    string   `json:"format"`
+	Version   int      `json:"version"`
+	Puzzle    string   `json:"puzzle"`
+	Length    int      `json:"length"`
+	Depth     int      `json:"depth"`
+	Positions []string `json:"positions"`
+}
+
+// An optimal move from a position in a book, and the position's distance
+// to the goal.
+type bookEntry struct {
+	m    Move
+	dist int
+}
+
+// A book's positions, by configuration hash.
+type book map[uint64]bookEntry
+
+// Runs "book <file>".
+func runBook(start *Board, args []string) {
+	if len(args) != 1 || *bookDepth < 1 {
+		fmt.Fprintln(os.Stderr, "usage: squareroot book [-depth n] <file>, with a positive depth")
+		os.Exit(exitInvalid)
+	}
+	code, err := start.Encode()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(exitInvalid)
+	}
+	fmt.Fprintln(os.Stderr, "Building distance table...")
+	g := buildMoveGraph(start)
+	t := g.distanceTable(start)
+	if t.dist[0] < 0 {
+		fmt.Println("The puzzle can't be solved.")
+		os.Exit(exitUnsolvable)
+	}
+	m := buildSolutionMap(g, t.dist)
+	doc := bookDoc{bookFormat, bookVersion, code, m.length, *bookDepth, []string{}}
+
+	// Walk the optimal solutions layer by layer to find each position's
+	// board, noting those within the depth of the start or a key position.
+	boards := map[int32]*Board{0: start}
+	sinceKey := 0
+	for l := 0; l < m.length; l++ {
+		if len(m.layers[l]) == 1 {
+			sinceKey = 0
+		}
+		for _, n := range m.layers[l] {
+			b := boards[n]
+			var best Move
+			for _, mv := range b.possibleMoves() {
+				nn := int32(g.index[b.configAfter(mv)])
+				if m.layer[nn] != l+1 {
+					continue
+				}
+				if best.pid == "" {
+					best = mv
+				}
+				if boards[nn] == nil {
+					nb := b.move(mv)
+					nb.mvs = nil
+					boards[nn] = nb
+				}
+			}
+			if sinceKey < *bookDepth {
+				doc.Positions = append(doc.Positions,
+					fmt.Sprintf("%016x %s %d", configHash(b), best.code(), t.dist[n]))
+			}
+		}
+		sinceKey++
+	}
+
+	f, err := createOutput(args[0])
+	if err == nil {
+		enc := json.NewEncoder(f)
+		enc.SetIndent("", "  ")
+		if err = enc.Encode(doc); err == nil {
+			err = f.Close()
+		}
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(exitError)
+	}
+	fmt.Fprintf(os.Stderr, "Wrote %d positions to %s\n", len(doc.Positions), args[0])
+}
+
+// Reads a book for the puzzle of the given board.
+func readBook(path string, b *Board) (book, error) {
+	data, err := readInput(path)
+	if err != nil {
+		return nil, err
+	}
+	var doc bookDoc
+	if err := json.Unmarshal(data, &doc); err != nil || doc.Format != bookFormat {
+		return nil, fmt.Errorf("%s: not a book", path)
+	}
+	if doc.Version < 1 || doc.Version > bookVersion {
+		return nil, fmt.Errorf("%s: book version %d is newer than this squareroot reads", path, doc.Version)
+	}
+	start, err := Decode(doc.Puzzle)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	if !start.samePuzzle(b) {
+		return nil, fmt.Errorf("%s: the book is for another puzzle", path)
+	}
+	bk := book{}
+	for i, p := range doc.Positions {
+		fields := strings.Fields(p)
+		if len(fields) != 3 {
+			return nil, fmt.Errorf("%s: position %d: %q isn't a hash, move and distance", path, i+1, p)
+		}
+		h, err := strconv.ParseUint(fields[0], 16, 64)
+		var m Move
+		if err == nil {
+			m, err = parseMove(fields[1])
+		}
+		var d int
+		if err == nil {
+			d, err = strconv.Atoi(fields[2])
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: position %d: %v", path, i+1, err)
+		}
+		bk[h] = bookEntry{m, d}
+	}
+	return bk, nil
+}
+
+// Looks up the board in the book, returning its move and distance if it's
+// there. A nil book has no positions.
+func (bk book) lookup(b *Board) (Move, int, bool) {
+	e, ok := bk[configHash(b)]
+	if !ok || !b.isLegal(e.m) {
+		return Move{}, 0, false
+	}
+	return e.m, e.dist, true
+}
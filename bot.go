@@ -0,0 +1,357 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"image/png"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Chat bot.
+//
+// "squareroot bot" answers a chat command, "/squareroot <board code>", in
+// Slack or Discord with the board's picture and a summary of its shortest
+// solution. It talks to both over HTTP, so it needs an address they can
+// reach (-addr, behind a proxy if need be) and to be told where that is
+// (-public-url), which is also where the board pictures are served from:
+//
+//	POST /slack      Slack slash command requests
+//	POST /discord    Discord interactions
+//	GET /board.png   the picture of a board (code=<board code>)
+//	GET /metrics     Prometheus metrics
+//
+// For Slack, make a slash command whose request URL is <public-url>/slack
+// and set SLACK_SIGNING_SECRET to the app's signing secret. For Discord,
+// make a command with a string option for the board, set the app's
+// interactions endpoint to <public-url>/discord and give its public key
+// with -discord-key. Requests not signed by the platform are refused.
+//
+// Boards are searched from a queue under the same limits as the server's
+// searches (see jobs.go), and since both platforms want an answer within
+// seconds, the bot acknowledges a command at once and posts the solution
+// when the search is done.
+
+var (
+	discordKey = flag.String("discord-key", "", "Public key of the Discord app the bot answers, in hex.")
+	publicURL  = flag.String("public-url", "", "URL at which chat platforms reach the bot, e.g. https://bot.example.com.")
+)
+
+// Where the bot sends Discord replies.
+const discordAPI = "https://discord.com/api/v10"
+
+// How old a signed request may be before it's refused as a replay.
+const botRequestAge = 5 * time.Minute
+
+// The most moves a solution summary lists.
+const botMaxMoves = 200
+
+type bot struct {
+	search      *server // searches boards, with no table of its own
+	slackSecret string
+	discordKey  ed25519.PublicKey
+	client      *http.Client
+}
+
+// Runs the bot until the process is killed.
+func runBot() {
+	bt := &bot{
+		search:      &server{metrics: newMetrics(), jobs: newJobQueue(max(*maxJobs, 1), max(*queueLen, 0))},
+		slackSecret: os.Getenv("SLACK_SIGNING_SECRET"),
+		client:      &http.Client{Timeout: 30 * time.Second},
+	}
+	bt.search.metrics.jobs = bt.search.jobs
+	if *discordKey != "" {
+		key, err := hex.DecodeString(*discordKey)
+		if err != nil || len(key) != ed25519.PublicKeySize {
+			fmt.Fprintln(os.Stderr, "-discord-key must be a public key in hex")
+			os.Exit(exitInvalid)
+		}
+		bt.discordKey = key
+	}
+	if bt.slackSecret == "" && bt.discordKey == nil {
+		fmt.Fprintln(os.Stderr, "Set SLACK_SIGNING_SECRET, -discord-key or both to say which platforms to answer")
+		os.Exit(exitInvalid)
+	}
+	if *publicURL == "" {
+		log.Printf("No -public-url, so replies will draw boards as text")
+	}
+
+	mux := http.NewServeMux()
+	if bt.slackSecret != "" {
+		mux.HandleFunc("/slack", bt.search.metrics.instrument("/slack", bt.handleSlack))
+	}
+	if bt.discordKey != nil {
+		mux.HandleFunc("/discord", bt.search.metrics.instrument("/discord", bt.handleDiscord))
+	}
+	mux.HandleFunc("/board.png", bt.search.metrics.instrument("/board.png", handleBoardImage))
+	mux.HandleFunc("/metrics", bt.search.metrics.handleMetrics)
+	log.Printf("Listening on %s", *addr)
+	log.Fatal(http.ListenAndServe(*addr, mux))
+}
+
+// Reads a request's body, up to a size no command needs to exceed.
+func readBody(r *http.Request) ([]byte, error) {
+	return io.ReadAll(io.LimitReader(r.Body, 1<<16))
+}
+
+// Reports whether a request's timestamp, in Unix seconds, is recent.
+func recent(ts string) bool {
+	secs, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil {
+		return false
+	}
+	age := time.Since(time.Unix(secs, 0))
+	return age < botRequestAge && age > -botRequestAge
+}
+
+// A reply to a command: text, and the address of a picture, if any.
+type botReply struct {
+	text     string
+	imageURL string
+}
+
+// Reads the board code a command gives, or returns a reply saying what's
+// wrong with it.
+func parseBotBoard(text string) (*Board, *botReply) {
+	fields := strings.Fields(text)
+	if len(fields) == 0 {
+		return nil, &botReply{text: "Give a board code, e.g. `/squareroot AgMDAAhhAQEC...`."}
+	}
+	b, err := Decode(fields[0])
+	if err == nil {
+		err = b.validate()
+	}
+	if err == nil {
+		err = checkSize(b)
+	}
+	if err != nil {
+		return nil, &botReply{text: fmt.Sprintf("Can't solve that board: %v", err)}
+	}
+	return b, nil
+}
+
+// Solves the board and describes the solution.
+func (bt *bot) solve(b *Board) botReply {
+	code, _ := b.Encode()
+	var sb strings.Builder
+	img := ""
+	if *publicURL != "" {
+		img = strings.TrimRight(*publicURL, "/") + "/board.png?code=" + url.QueryEscape(code)
+	} else {
+		fmt.Fprintf(&sb, "```\n%s```\n", b)
+	}
+	fmt.Fprintf(&sb, "Goal: %v\n", b.goal)
+	mvs, err := bt.search.search(context.Background(), b, *jobTimeout)
+	switch {
+	case err != nil:
+		fmt.Fprintf(&sb, "No solution found: %v", err)
+	case len(mvs) == 0:
+		sb.WriteString("Already solved!")
+	default:
+		fmt.Fprintf(&sb, "Solved in %d moves: ", len(mvs))
+		for i, m := range mvs {
+			if i == botMaxMoves {
+				fmt.Fprintf(&sb, "… and %d more", len(mvs)-i)
+				break
+			}
+			if i > 0 {
+				sb.WriteString(" ")
+			}
+			sb.WriteString(m.code())
+		}
+	}
+	return botReply{sb.String(), img}
+}
+
+// Sends JSON to a chat platform with the given method. The URL holds the
+// reply's token, so only the platform's name is logged.
+func (bt *bot) send(platform, method, u string, v any) {
+	body, err := json.Marshal(v)
+	if err != nil {
+		log.Printf("Reply to %s: %v", platform, err)
+		return
+	}
+	req, err := http.NewRequest(method, u, bytes.NewReader(body))
+	if err != nil {
+		log.Printf("Reply to %s: %v", platform, err)
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := bt.client.Do(req)
+	if err != nil {
+		log.Printf("Reply to %s: %v", platform, errors.Unwrap(err)) // without the URL
+		return
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		log.Printf("Reply to %s: status %s", platform, resp.Status)
+	}
+}
+
+func (bt *bot) handleSlack(w http.ResponseWriter, r *http.Request) {
+	body, err := readBody(r)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{err.Error()})
+		return
+	}
+	ts := r.Header.Get("X-Slack-Request-Timestamp")
+	mac := hmac.New(sha256.New, []byte(bt.slackSecret))
+	fmt.Fprintf(mac, "v0:%s:%s", ts, body)
+	want := "v0=" + hex.EncodeToString(mac.Sum(nil))
+	if !recent(ts) || !hmac.Equal([]byte(want), []byte(r.Header.Get("X-Slack-Signature"))) {
+		writeJSON(w, http.StatusUnauthorized, errorResponse{"bad signature"})
+		return
+	}
+	form, err := url.ParseQuery(string(body))
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{err.Error()})
+		return
+	}
+	type slackMessage struct {
+		ResponseType string `json:"response_type"`
+		Text         string `json:"text"`
+		Blocks       []any  `json:"blocks,omitempty"`
+	}
+	b, bad := parseBotBoard(form.Get("text"))
+	if bad != nil {
+		writeJSON(w, http.StatusOK, slackMessage{"ephemeral", bad.text, nil})
+		return
+	}
+	respURL := form.Get("response_url")
+	if !strings.HasPrefix(respURL, "https://hooks.slack.com/") {
+		writeJSON(w, http.StatusBadRequest, errorResponse{"no Slack response_url"})
+		return
+	}
+	go func() {
+		reply := bt.solve(b)
+		msg := slackMessage{ResponseType: "in_channel", Text: reply.text}
+		msg.Blocks = append(msg.Blocks, map[string]any{
+			"type": "section", "text": map[string]string{"type": "mrkdwn", "text": reply.text}})
+		if reply.imageURL != "" {
+			msg.Blocks = append(msg.Blocks, map[string]any{
+				"type": "image", "image_url": reply.imageURL, "alt_text": "The board"})
+		}
+		bt.send("Slack", http.MethodPost, respURL, msg)
+	}()
+	writeJSON(w, http.StatusOK, slackMessage{"ephemeral", "Solving…", nil})
+}
+
+// Discord interaction and response types.
+const (
+	discordPing             = 1
+	discordCommand          = 2
+	discordPong             = 1
+	discordMessage          = 4
+	discordDeferredMessage  = 5
+	discordEphemeral        = 1 << 6
+	discordMaxMessageLength = 2000
+	discordStringOption     = 3
+)
+
+func (bt *bot) handleDiscord(w http.ResponseWriter, r *http.Request) {
+	body, err := readBody(r)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{err.Error()})
+		return
+	}
+	ts := r.Header.Get("X-Signature-Timestamp")
+	sig, err := hex.DecodeString(r.Header.Get("X-Signature-Ed25519"))
+	if err != nil || !recent(ts) || !ed25519.Verify(bt.discordKey, append([]byte(ts), body...), sig) {
+		writeJSON(w, http.StatusUnauthorized, errorResponse{"bad signature"})
+		return
+	}
+	var in struct {
+		Type          int    `json:"type"`
+		Token         string `json:"token"`
+		ApplicationID string `json:"application_id"`
+		Data          struct {
+			Options []struct {
+				Type  int    `json:"type"`
+				Value any    `json:"value"`
+				Name  string `json:"name"`
+			} `json:"options"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(body, &in); err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{err.Error()})
+		return
+	}
+	type discordData struct {
+		Content string `json:"content"`
+		Flags   int    `json:"flags,omitempty"`
+		Embeds  []any  `json:"embeds,omitempty"`
+	}
+	type discordResponse struct {
+		Type int          `json:"type"`
+		Data *discordData `json:"data,omitempty"`
+	}
+	switch in.Type {
+	case discordPing:
+		writeJSON(w, http.StatusOK, discordResponse{Type: discordPong})
+		return
+	case discordCommand:
+	default:
+		writeJSON(w, http.StatusBadRequest, errorResponse{"unexpected interaction"})
+		return
+	}
+	text := ""
+	for _, o := range in.Data.Options {
+		if s, ok := o.Value.(string); ok && o.Type == discordStringOption {
+			text = s
+			break
+		}
+	}
+	b, bad := parseBotBoard(text)
+	if bad != nil {
+		writeJSON(w, http.StatusOK, discordResponse{discordMessage, &discordData{Content: bad.text, Flags: discordEphemeral}})
+		return
+	}
+	go func() {
+		reply := bt.solve(b)
+		msg := discordData{Content: reply.text}
+		if len(msg.Content) > discordMaxMessageLength {
+			msg.Content = msg.Content[:discordMaxMessageLength-1] + "…"
+		}
+		if reply.imageURL != "" {
+			msg.Embeds = []any{map[string]any{"image": map[string]string{"url": reply.imageURL}}}
+		}
+		bt.send("Discord", http.MethodPatch, fmt.Sprintf("%s/webhooks/%s/%s/messages/@original",
+			discordAPI, url.PathEscape(in.ApplicationID), url.PathEscape(in.Token)), msg)
+	}()
+	writeJSON(w, http.StatusOK, discordResponse{Type: discordDeferredMessage})
+}
+
+// Serves the picture of a board given by code.
+func handleBoardImage(w http.ResponseWriter, r *http.Request) {
+	b, err := Decode(r.FormValue("code"))
+	if err == nil {
+		err = b.validate()
+	}
+	if err == nil {
+		err = checkSize(b)
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Type", "image/png")
+	w.Header().Set("Cache-Control", "public, max-age=86400")
+	if err := png.Encode(w, renderBoard(b, nil, *cellSize)); err != nil {
+		log.Printf("Writing board image: %v", err)
+	}
+}
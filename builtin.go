@@ -0,0 +1,80 @@
+package main
+
+import (
+	"embed"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Built-in puzzles.
+//
+// The puzzle files and packs in the puzzles directory are built into the
+// program, so the catalog, campaign packs and the default puzzle don't
+// depend on where it's run from. They're read by the same parsers as any
+// other file, under names starting with "builtin:", such as
+// "builtin:corners.txt", which work anywhere a file name does. Adding a
+// built-in puzzle is just adding a file.
+
+//go:embed puzzles
+var builtinFS embed.FS
+
+// The prefix of built-in file names, which is also the name of the
+// built-in directory in the catalog.
+const builtinPrefix = "builtin:"
+
+// Reads a file, built-in or not.
+func readPuzzleData(name string) ([]byte, error) {
+	if rest, ok := strings.CutPrefix(name, builtinPrefix); ok {
+		return builtinFS.ReadFile(path.Join("puzzles", rest))
+	}
+	return os.ReadFile(name)
+}
+
+// Reports whether a file, built-in or not, exists and isn't a directory.
+func puzzleFileExists(name string) bool {
+	var fi fs.FileInfo
+	var err error
+	if rest, ok := strings.CutPrefix(name, builtinPrefix); ok {
+		fi, err = fs.Stat(builtinFS, path.Join("puzzles", rest))
+	} else {
+		fi, err = os.Stat(name)
+	}
+	return err == nil && !fi.IsDir()
+}
+
+// Returns the name of a file in a directory, where the directory may be
+// the built-in one.
+func catalogPath(dir, file string) string {
+	if dir == builtinPrefix {
+		return builtinPrefix + file
+	}
+	return filepath.Join(dir, file)
+}
+
+// Returns the directory of a file, built-in or not.
+func catalogDir(name string) string {
+	if strings.HasPrefix(name, builtinPrefix) {
+		return builtinPrefix
+	}
+	return filepath.Dir(name)
+}
+
+// Returns a file's name without its directory.
+func catalogBase(name string) string {
+	return filepath.Base(strings.TrimPrefix(name, builtinPrefix))
+}
+
+// Returns the names of the built-in files matching a pattern, sorted.
+func builtinFiles(pattern string) []string {
+	ms, _ := fs.Glob(builtinFS, path.Join("puzzles", pattern))
+	names := []string{}
+	for _, m := range ms {
+		names = append(names, builtinPrefix+path.Base(m))
+	}
+	sort.Strings(names)
+	return names
+}
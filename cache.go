@@ -0,0 +1,257 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Results cache.
+//
+// With -cache, optimal solutions are saved in a cache directory, one JSON
+// file per puzzle named by the puzzle's hash: the SHA-256 of its board code,
+// which covers the pieces, rules and goal. Solving the same puzzle again
+// replays the cached solution instead of searching. Only sequential-move
+// solutions are cached; BFS and A* find solutions of the same length, so
+// either may serve the other.
+//
+// With -cache-format binary, solutions are saved in the binary solution
+// encoding (see binary.go), in a .bin file instead, a fraction of the size.
+// Either kind of file is read whatever the -cache-format.
+//
+// "squareroot cache list" lists the cached solutions and "squareroot cache
+// clear" removes them.
+
+var useCache = flag.Bool("cache", false,
+	"Look up and save solutions in the results cache.")
+
+var cacheDir = flag.String("cache-dir", defaultCacheDir(),
+	"Directory holding the results cache.")
+
+var cacheFormat = flag.String("cache-format", "json",
+	"Format in which to save solutions in the results cache: json or binary.")
+
+func defaultCacheDir() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "squareroot")
+}
+
+var cacheFormats = []string{"json", "binary"}
+
+// A cached solution.
+type cacheEntry struct {
+	Code     string    `json:"code"`
+	Moves    []string  `json:"moves"`
+	Stats    Stats     `json:"stats"`
+	SolvedAt time.Time `json:"solved_at"`
+
+	// Whether the search exhausted every reachable configuration without
+	// finding a solution, proving the puzzle unsolvable.
+	Unsolvable bool `json:"unsolvable,omitempty"`
+}
+
+// Returns the hash identifying a puzzle in the cache and the puzzle's board
+// code, or empty strings if the puzzle can't be encoded (e.g. it has a custom
+// goal).
+func puzzleHash(b *Board) (string, string) {
+	code, err := b.Encode()
+	if err != nil {
+		return "", ""
+	}
+	sum := sha256.Sum256([]byte(code))
+	return hex.EncodeToString(sum[:]), code
+}
+
+// Returns the path of a puzzle's file in the cache, in the given format.
+func cachePath(hash, format string) string {
+	ext := ".json"
+	if format == "binary" {
+		ext = ".bin"
+	}
+	return filepath.Join(*cacheDir, hash+ext)
+}
+
+// Reads a cache file of either format.
+func readCacheEntry(path string) (cacheEntry, error) {
+	var e cacheEntry
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return e, err
+	}
+	if filepath.Ext(path) == ".json" {
+		return e, json.Unmarshal(data, &e)
+	}
+	var sol Solution
+	if err := sol.UnmarshalBinary(data); err != nil {
+		return e, err
+	}
+	if e.Code, err = sol.Start.Encode(); err != nil {
+		return e, err
+	}
+	e.Moves = []string{}
+	for _, m := range sol.Moves {
+		e.Moves = append(e.Moves, m.code())
+	}
+	e.Stats, e.Unsolvable = sol.Stats, sol.Unsolvable
+	if fi, err := os.Stat(path); err == nil {
+		e.SolvedAt = fi.ModTime().UTC()
+	}
+	return e, nil
+}
+
+// Looks up the solution for the given puzzle in the results cache, returning
+// the solved board and the stats of the search that found it. The board is
+// nil if the cache records that the puzzle has no solution.
+func lookupSolution(start *Board) (*Board, Stats, bool) {
+	// The cache is keyed by the puzzle alone, so -avoid searches bypass it,
+	// as do -depth-stats and -mem-stats searches, which need a search to
+	// measure, and -ordering searches, which may find another solution.
+	if !*useCache || len(avoided) > 0 || depthStats != nil || memStats != nil || *ordering != "" {
+		return nil, Stats{}, false
+	}
+	hash, code := puzzleHash(start)
+	if hash == "" {
+		return nil, Stats{}, false
+	}
+	for _, format := range cacheFormats {
+		path := cachePath(hash, format)
+		e, err := readCacheEntry(path)
+		if err != nil || e.Code != code {
+			continue
+		}
+		if e.Unsolvable {
+			return nil, e.Stats, true
+		}
+		end, err := start.replay(e.Moves)
+		if err != nil || !end.goal.IsSatisfied(end) {
+			fmt.Fprintf(os.Stderr, "Ignoring invalid cached solution %s\n", path)
+			continue
+		}
+		return end, e.Stats, true
+	}
+	return nil, Stats{}, false
+}
+
+// Saves the solution for the given puzzle in the results cache, or with a
+// nil end board, that an exhaustive search found none.
+func storeSolution(start, end *Board, stats Stats) {
+	if !*useCache || len(avoided) > 0 {
+		return
+	}
+	hash, code := puzzleHash(start)
+	if hash == "" {
+		return
+	}
+	var data []byte
+	var err error
+	if *cacheFormat == "binary" {
+		sol := Solution{Start: start, Moves: []Move{}, Stats: stats, Unsolvable: end == nil}
+		if end != nil {
+			sol.Moves = end.mvs
+		}
+		data, err = sol.MarshalBinary()
+	} else {
+		e := cacheEntry{code, []string{}, stats, time.Now().UTC(), end == nil}
+		if end != nil {
+			for _, m := range end.mvs {
+				e.Moves = append(e.Moves, m.code())
+			}
+		}
+		data, err = json.MarshalIndent(e, "", "  ")
+	}
+	if err == nil {
+		err = os.MkdirAll(*cacheDir, 0o755)
+	}
+	if err == nil {
+		err = os.WriteFile(cachePath(hash, *cacheFormat), data, 0o644)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Couldn't cache solution: %v\n", err)
+	}
+}
+
+// Applies moves in compact notation to this board, checking that each is
+// legal.
+func (b *Board) replay(codes []string) (*Board, error) {
+	for i, c := range codes {
+		m, err := parseMove(c)
+		if err != nil {
+			return nil, err
+		}
+		if !b.isLegal(m) {
+			return nil, fmt.Errorf("move %d (%s) is illegal", i+1, c)
+		}
+		b = b.move(m)
+	}
+	return b, nil
+}
+
+// Is the given move one of the legal moves on this board.
+func (b *Board) isLegal(m Move) bool {
+	for _, lm := range b.possibleMoves() {
+		if lm == m {
+			return true
+		}
+	}
+	return false
+}
+
+// Runs "cache list" or "cache clear".
+func runCacheCommand(args []string) {
+	if len(args) != 1 || args[0] != "list" && args[0] != "clear" {
+		fmt.Fprintln(os.Stderr, "usage: squareroot cache [-cache-dir dir] list|clear")
+		os.Exit(exitInvalid)
+	}
+	names := []string{}
+	for _, format := range cacheFormats {
+		ns, err := filepath.Glob(cachePath("*", format))
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		names = append(names, ns...)
+	}
+	if args[0] == "clear" {
+		for _, name := range names {
+			if err := os.Remove(name); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(1)
+			}
+		}
+		fmt.Printf("Removed %d cached solutions from %s\n", len(names), *cacheDir)
+		return
+	}
+
+	type listing struct {
+		hash string
+		e    cacheEntry
+	}
+	ls := []listing{}
+	for _, name := range names {
+		e, err := readCacheEntry(name)
+		if err != nil {
+			continue
+		}
+		ls = append(ls, listing{strings.TrimSuffix(filepath.Base(name), filepath.Ext(name)), e})
+	}
+	sort.Slice(ls, func(i, j int) bool { return ls[i].e.SolvedAt.Before(ls[j].e.SolvedAt) })
+	for _, l := range ls {
+		result := fmt.Sprintf("%4d moves", len(l.e.Moves))
+		if l.e.Unsolvable {
+			result = "unsolvable"
+		}
+		fmt.Printf("%s  %s  %8d configurations  %s  %s\n", l.hash[:12], result,
+			l.e.Stats.Configs, l.e.SolvedAt.Local().Format("2006-01-02 15:04"), l.e.Code)
+	}
+	fmt.Printf("%d cached solutions in %s\n", len(ls), *cacheDir)
+}
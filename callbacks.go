@@ -0,0 +1,88 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Job callbacks.
+//
+// Rather than poll GET /jobs/{id}, a bot or script can give POST /jobs a
+// callback=<url>, and when the job finishes, solved or not, the server
+// POSTs the job, as GET /jobs/{id} would return it, to that URL. A delivery
+// that fails or gets an error status is retried a few times, a little
+// later each time, then given up on; the job's result stays available to
+// GET /jobs/{id} either way.
+//
+// Callbacks have the server make requests on others' behalf, so they must
+// be allowed: -callback-hosts lists the host names callbacks may go to, or
+// "*" for any. Without it, a request with a callback is refused.
+
+var callbackHosts = flag.String("callback-hosts", "",
+	`Comma-separated host names POST /jobs callbacks may go to, or "*" for any; if empty, callbacks are refused.`)
+
+// How many times a callback is tried, and how long each try may take.
+const (
+	callbackTries   = 3
+	callbackTimeout = 10 * time.Second
+)
+
+// Checks that a callback URL is one the server may call.
+func checkCallback(callback string) error {
+	u, err := url.Parse(callback)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("callback %q isn't an http or https URL", callback)
+	}
+	hosts := strings.Split(*callbackHosts, ",")
+	if *callbackHosts == "" || !slices.Contains(hosts, "*") && !slices.Contains(hosts, u.Hostname()) {
+		return fmt.Errorf("callbacks to %s aren't allowed", u.Hostname())
+	}
+	return nil
+}
+
+// Sends the finished job to its callback URL, trying again if that fails.
+func (j *asyncJob) notify() {
+	body, err := json.Marshal(j.response())
+	if err != nil {
+		log.Printf("Job %s callback: %v", j.id, err)
+		return
+	}
+	// Redirects aren't followed, as they could lead to hosts not allowed.
+	client := &http.Client{
+		Timeout: callbackTimeout,
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	for try := 1; ; try++ {
+		err = postCallback(client, j.callback, body)
+		if err == nil {
+			return
+		}
+		if try == callbackTries {
+			log.Printf("Job %s callback to %s failed, giving up: %v", j.id, j.callback, err)
+			return
+		}
+		time.Sleep(time.Duration(try) * 5 * time.Second)
+	}
+}
+
+func postCallback(client *http.Client, callback string, body []byte) error {
+	resp, err := client.Post(callback, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("status %s", resp.Status)
+	}
+	return nil
+}
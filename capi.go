@@ -0,0 +1,162 @@
+//go:build capi
+
+package main
+
+// #include <stdlib.h>
+import "C"
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+	"unsafe"
+)
+
+// C API.
+//
+// Built with the "capi" tag as a shared library,
+//
+//	go build -tags capi -buildmode=c-shared -o libsquareroot.so .
+//
+// the solver can be embedded in programs in other languages. The library
+// exports a few C functions, declared in the libsquareroot.h the build also
+// writes, that take and return JSON strings, so the ABI stays the same as
+// the API grows: new request and response fields are added, never changed
+// or removed, and incompatible changes would come with a new
+// squareroot_abi_version.
+//
+//	int   squareroot_abi_version(void);
+//	char *squareroot_solve(char *request);
+//	char *squareroot_hint(char *request);
+//	void  squareroot_free(char *response);
+//
+// A request names a position the way the server does:
+//
+//	{"board": "<board code>", "moves": ["jL", "fD"], "timeout_ms": 10000, "max_states": 1000000}
+//
+// Only board is needed; moves are made from it first, and the search gives
+// up after timeout_ms (default 10 seconds; 0 for no limit) or after visiting
+// max_states configurations (default 1000000; 0 for no limit). solve
+// answers {"length": 31, "moves": ["eD", ...]} and hint {"distance": 31,
+// "move": "eD", "board": "<board code after the move>"}, without move once
+// solved; either answers {"error": "..."} if the request is bad or the
+// search finds no solution. Responses are allocated by the library and
+// must be freed with squareroot_free. The functions may be called from
+// several threads at once.
+
+// The version of the library's ABI.
+const capiVersion = 1
+
+type capiRequest struct {
+	Board     string   `json:"board"`
+	Moves     []string `json:"moves"`
+	TimeoutMS *int     `json:"timeout_ms"`
+	MaxStates *int     `json:"max_states"`
+}
+
+type capiSolution struct {
+	Length int      `json:"length"`
+	Moves  []string `json:"moves"`
+}
+
+type capiHint struct {
+	Distance int    `json:"distance"`
+	Move     string `json:"move,omitempty"`
+	Board    string `json:"board,omitempty"`
+}
+
+//export squareroot_abi_version
+func squareroot_abi_version() C.int {
+	return capiVersion
+}
+
+//export squareroot_solve
+func squareroot_solve(request *C.char) *C.char {
+	return capiCall(request, func(b *Board, mvs []Move) any {
+		s := capiSolution{len(mvs), []string{}}
+		for _, m := range mvs {
+			s.Moves = append(s.Moves, m.code())
+		}
+		return s
+	})
+}
+
+//export squareroot_hint
+func squareroot_hint(request *C.char) *C.char {
+	return capiCall(request, func(b *Board, mvs []Move) any {
+		if len(mvs) == 0 {
+			return capiHint{}
+		}
+		h := capiHint{Distance: len(mvs), Move: mvs[0].code()}
+		h.Board, _ = b.move(mvs[0]).Encode()
+		return h
+	})
+}
+
+//export squareroot_free
+func squareroot_free(response *C.char) {
+	C.free(unsafe.Pointer(response))
+}
+
+// Reads a request, solves its position and returns the answer made from
+// the solution.
+func capiCall(request *C.char, answer func(b *Board, mvs []Move) any) *C.char {
+	var resp any
+	b, mvs, err := capiSolve(C.GoString(request))
+	if err != nil {
+		resp = errorResponse{err.Error()}
+	} else {
+		resp = answer(b, mvs)
+	}
+	data, err := json.Marshal(resp)
+	if err != nil {
+		data, _ = json.Marshal(errorResponse{err.Error()})
+	}
+	return C.CString(string(data))
+}
+
+// Solves the position a request names, returning it and its solution.
+func capiSolve(request string) (*Board, []Move, error) {
+	var req capiRequest
+	if err := json.Unmarshal([]byte(request), &req); err != nil {
+		return nil, nil, fmt.Errorf("bad request: %v", err)
+	}
+	b, err := Decode(req.Board)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := b.validate(); err != nil {
+		return nil, nil, err
+	}
+	if b, err = b.replay(req.Moves); err != nil {
+		return nil, nil, err
+	}
+	b.mvs = []Move{}
+	timeout, maxStates := 10*time.Second, 1000000
+	if req.TimeoutMS != nil {
+		timeout = time.Duration(*req.TimeoutMS) * time.Millisecond
+	}
+	if req.MaxStates != nil {
+		maxStates = *req.MaxStates
+	}
+	ctx := context.Background()
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	if maxStates <= 0 {
+		maxStates = int(^uint(0) >> 1)
+	}
+	end, stats := solveWithin(ctx, b, maxStates, nil)
+	switch {
+	case end != nil:
+		return b, end.mvs, nil
+	case ctx.Err() != nil:
+		return nil, nil, fmt.Errorf("gave up after %v (%d configurations searched)", timeout, stats.Configs)
+	case stats.Configs > maxStates:
+		return nil, nil, fmt.Errorf("gave up after searching %d configurations", maxStates)
+	}
+	return nil, nil, fmt.Errorf("no solution: searched all %d configurations reachable from the position", stats.Configs)
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Puzzle catalog.
+//
+// "squareroot catalog [list] [dir]" lists the puzzle files in a directory,
+// by default the built-in puzzles (see builtin.go) followed by the
+// installed ones (see install.go), with each puzzle's size, piece count, known
+// optimal length and description: the first sentence of the comment at the
+// top of the file. "squareroot catalog show <name>" describes one puzzle
+// and draws its starting board; the name is a built-in or installed puzzle
+// file, with or without its extension (.txt preferred), or a path.
+//
+// A puzzle's optimal length is known if the results cache has it (whether
+// or not -cache is on), or if the file's comment gives it as "Best possible:
+// N moves", as the built-in puzzles and the files gen and remix write do.
+
+// Runs "catalog [list] [dir]" or "catalog show <name>".
+func runCatalog(args []string) {
+	cmd := "list"
+	if len(args) > 0 && (args[0] == "list" || args[0] == "show") {
+		cmd, args = args[0], args[1:]
+	}
+	switch {
+	case cmd == "show" && len(args) == 1:
+		showCatalogPuzzle(args[0])
+	case cmd == "list" && len(args) == 1:
+		listCatalog(args[0])
+	case cmd == "list" && len(args) == 0:
+		listCatalog(builtinPrefix)
+		if dir := userPuzzleDir(); dir != "" && len(catalogFiles(dir)) > 0 {
+			fmt.Printf("\nInstalled in %s:\n", dir)
+			listCatalog(dir)
+		}
+	default:
+		fmt.Fprintln(os.Stderr, "usage: squareroot catalog [list] [dir] | show <name>")
+		os.Exit(exitInvalid)
+	}
+}
+
+// Lists the puzzle files in a directory.
+func listCatalog(dir string) {
+	names := catalogFiles(dir)
+	if len(names) == 0 {
+		fmt.Printf("No puzzle files in %s\n", dir)
+		return
+	}
+	for _, name := range names {
+		b, err := readBoardFile(name)
+		if err != nil {
+			fmt.Printf("%-16s %v\n", catalogBase(name), err)
+			continue
+		}
+		line := fmt.Sprintf("%-16s %dx%d %2d pieces  %-10s  %s", catalogBase(name), b.w, b.h, len(b.ps),
+			knownOptimal(name, b), puzzleDescription(name))
+		fmt.Println(strings.TrimRight(line, " "))
+	}
+}
+
+// Describes a puzzle and draws its starting board.
+func showCatalogPuzzle(arg string) {
+	name, ok := findCatalogPuzzle(arg)
+	if !ok {
+		fmt.Fprintf(os.Stderr, "No puzzle %q in the catalog\n", arg)
+		os.Exit(exitInvalid)
+	}
+	b, err := readBoardFile(name)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", name, err)
+		os.Exit(exitInvalid)
+	}
+	fmt.Println(name)
+	if d := puzzleDescription(name); d != "" {
+		fmt.Println(d)
+	}
+	fmt.Printf("Size: %dx%d, %s\n", b.w, b.h, countOf(len(b.ps), "piece"))
+	optimal := knownOptimal(name, b)
+	if optimal == "-" {
+		optimal = "unknown"
+	}
+	fmt.Printf("Optimal: %s\n", optimal)
+	fmt.Printf("Goal: %v\n", b.goal)
+	if code, err := b.Encode(); err == nil {
+		fmt.Printf("Board code: %s\n", code)
+	}
+	fmt.Print(b.display())
+}
+
+// Returns the path of the named puzzle: the path itself if there's such a
+// file, or else the built-in or installed puzzle file with that name, with
+// or without its extension.
+func findCatalogPuzzle(arg string) (string, bool) {
+	return findInCatalog(arg, ".txt", ".sbp")
+}
+
+// Returns the path of the named file: the path itself if there's such a
+// file, or else the first of the catalog directories' files with that name,
+// or that name and one of the given extensions.
+func findInCatalog(arg string, exts ...string) (string, bool) {
+	if puzzleFileExists(arg) {
+		return arg, true
+	}
+	for _, dir := range catalogDirs() {
+		for _, ext := range append([]string{""}, exts...) {
+			if name := catalogPath(dir, arg+ext); puzzleFileExists(name) {
+				return name, true
+			}
+		}
+	}
+	return "", false
+}
+
+// Returns the paths of the puzzle files in a directory, which may be the
+// built-in one, sorted.
+func catalogFiles(dir string) []string {
+	names := []string{}
+	for _, pattern := range []string{"*.txt", "*.sbp"} {
+		if dir == builtinPrefix {
+			names = append(names, builtinFiles(pattern)...)
+			continue
+		}
+		ms, _ := filepath.Glob(filepath.Join(dir, pattern))
+		names = append(names, ms...)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Returns the comment at the top of a puzzle file as a single line, or ""
+// if it has none.
+func puzzleComment(name string) string {
+	data, err := readPuzzleData(name)
+	if err != nil {
+		return ""
+	}
+	words := []string{}
+	for _, line := range strings.Split(string(data), "\n") {
+		c, ok := strings.CutPrefix(strings.TrimSpace(line), "//")
+		if !ok {
+			break
+		}
+		words = append(words, strings.Fields(c)...)
+	}
+	return strings.Join(words, " ")
+}
+
+// Returns the first sentence of the comment at the top of a puzzle file, or
+// "" if it has none.
+func puzzleDescription(name string) string {
+	text := puzzleComment(name)
+	if i := strings.Index(text, ". "); i >= 0 {
+		text = text[:i+1]
+	}
+	return text
+}
+
+// Matches an optimal length given in a puzzle file's comment.
+var bestPossible = regexp.MustCompile(`Best possible: (\d+) moves`)
+
+// Returns a puzzle's optimal length, such as "116 moves", from the results
+// cache or the puzzle file's comment, or "-" if it isn't known.
+func knownOptimal(name string, b *Board) string {
+	if hash, code := puzzleHash(b); hash != "" {
+		for _, format := range cacheFormats {
+			if e, err := readCacheEntry(cachePath(hash, format)); err == nil && e.Code == code {
+				if e.Unsolvable {
+					return "unsolvable"
+				}
+				return countOf(len(e.Moves), "move")
+			}
+		}
+	}
+	if m := bestPossible.FindStringSubmatch(puzzleComment(name)); m != nil {
+		if n, err := strconv.Atoi(m[1]); err == nil {
+			return countOf(n, "move")
+		}
+	}
+	return "-"
+}
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+)
+
+// Optimality certificates.
+//
+// With -certify, "solve" backs up a solution of L moves with a complete
+// breadth-first search of every configuration within L-1 moves of the start,
+// confirming that none of them is solved, and writes a certificate to
+// -cert-out:
+//
+//	{
+//	  "version": 1,
+//	  "puzzle": "AgQF...",
+//	  "length": 116,
+//	  "moves": ["iR", "dD", ...],
+//	  "layers": [1, 4, 11, ...],
+//	  "digest": "9f2c..."
+//	}
+//
+// Layers counts the distinct configurations first reached at each depth
+// below the solution length, and the digest is the SHA-256 of the rest of
+// the certificate, which catches accidental changes. "squareroot check-cert
+// <file>" re-verifies a certificate from scratch: it checks the digest,
+// replays the moves, and repeats the search, which must find the same layers
+// and no solved configuration.
+
+var certify = flag.Bool("certify", false,
+	"Prove the solution optimal by searching all shorter move sequences, and write a certificate to -cert-out.")
+
+var certOut = flag.String("cert-out", "certificate.json",
+	"File to write the -certify certificate to.")
+
+const certVersion = 1
+
+type certificate struct {
+	Version int      `json:"version"`
+	Puzzle  string   `json:"puzzle"`
+	Length  int      `json:"length"`
+	Moves   []string `json:"moves"`
+	Layers  []int    `json:"layers"`
+	Digest  string   `json:"digest"`
+}
+
+// Returns the SHA-256 of the certificate's contents other than its digest.
+func (c certificate) digest() string {
+	c.Digest = ""
+	data, _ := json.Marshal(c)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// Searches breadth-first from the start to the given depth, returning the
+// number of distinct configurations first reached at each depth, and the
+// depth of the first solved configuration, or -1 if none is that close.
+func searchLayers(start *Board, maxDepth int) ([]int, int) {
+	root := *start
+	root.mvs = nil
+	seen := map[string]bool{root.Config(): true}
+	layer := []*Board{&root}
+	layers := []int{}
+	for depth := 0; depth <= maxDepth && len(layer) > 0; depth++ {
+		layers = append(layers, len(layer))
+		next := []*Board{}
+		for _, b := range layer {
+			if b.goal.IsSatisfied(b) {
+				return layers, depth
+			}
+			if depth == maxDepth {
+				continue
+			}
+			for _, m := range b.possibleMoves() {
+				if c := b.configAfter(m); !seen[c] {
+					seen[c] = true
+					nb := b.move(m)
+					nb.mvs = nil
+					next = append(next, nb)
+				}
+			}
+		}
+		layer = next
+	}
+	return layers, -1
+}
+
+// Proves a solution optimal and returns its certificate.
+func certifySolution(start, end *Board) (certificate, error) {
+	code, err := start.Encode()
+	if err != nil {
+		return certificate{}, fmt.Errorf("can't certify: %v", err)
+	}
+	c := certificate{Version: certVersion, Puzzle: code, Length: len(end.mvs), Moves: []string{}}
+	for _, m := range end.mvs {
+		c.Moves = append(c.Moves, m.code())
+	}
+	layers, found := searchLayers(start, len(end.mvs)-1)
+	if found >= 0 {
+		return certificate{}, fmt.Errorf("a solution of %d moves exists, so %d isn't optimal", found, len(end.mvs))
+	}
+	c.Layers = layers
+	c.Digest = c.digest()
+	return c, nil
+}
+
+// Certifies the solution and writes the certificate to -cert-out.
+func writeCertificate(start, end *Board) {
+	c, err := certifySolution(start, end)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(exitError)
+	}
+	f, err := createOutput(*certOut)
+	if err == nil {
+		enc := json.NewEncoder(f)
+		enc.SetIndent("", "  ")
+		if err = enc.Encode(c); err == nil {
+			err = f.Close()
+		}
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(exitError)
+	}
+	total := 0
+	for _, n := range c.Layers {
+		total += n
+	}
+	fmt.Fprintf(os.Stderr, "Certified optimal: no solution within %d moves among %d configurations; wrote %s\n",
+		c.Length-1, total, *certOut)
+}
+
+// Checks a certificate, returning an error saying why it doesn't hold.
+func checkCertificate(c certificate) error {
+	if c.Version != certVersion {
+		return fmt.Errorf("unknown certificate version %d", c.Version)
+	}
+	if c.Digest != c.digest() {
+		return fmt.Errorf("digest mismatch: the certificate has been changed")
+	}
+	b, err := Decode(c.Puzzle)
+	if err != nil {
+		return err
+	}
+	if err := b.validate(); err != nil {
+		return err
+	}
+	mvs, err := parseMoveList(c.Moves)
+	if err != nil {
+		return err
+	}
+	if len(mvs) != c.Length {
+		return fmt.Errorf("certificate claims %d moves but lists %d", c.Length, len(mvs))
+	}
+	start := b
+	for i, m := range mvs {
+		if !b.isLegal(m) {
+			return fmt.Errorf("move %d (%s) is illegal", i+1, m.code())
+		}
+		b = b.move(m)
+	}
+	if !b.goal.IsSatisfied(b) {
+		return fmt.Errorf("the moves don't reach the goal")
+	}
+	layers, found := searchLayers(start, c.Length-1)
+	if found >= 0 {
+		return fmt.Errorf("a solution of %d moves exists", found)
+	}
+	if len(layers) != len(c.Layers) {
+		return fmt.Errorf("search reached %d depths, certificate lists %d", len(layers), len(c.Layers))
+	}
+	for d, n := range layers {
+		if c.Layers[d] != n {
+			return fmt.Errorf("depth %d has %d configurations, certificate lists %d", d, n, c.Layers[d])
+		}
+	}
+	return nil
+}
+
+// Runs "check-cert <file>".
+func runCheckCert(args []string) {
+	if len(args) != 1 {
+		fmt.Fprintln(os.Stderr, "usage: squareroot check-cert <file>")
+		os.Exit(exitInvalid)
+	}
+	data, err := readInput(args[0])
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(exitInvalid)
+	}
+	var c certificate
+	if err := json.Unmarshal(data, &c); err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", args[0], err)
+		os.Exit(exitInvalid)
+	}
+	if err := checkCertificate(c); err != nil {
+		fmt.Printf("Certificate invalid: %v\n", err)
+		os.Exit(exitError)
+	}
+	fmt.Printf("Certificate valid: %d moves is optimal for puzzle %s\n", c.Length, c.Puzzle)
+}
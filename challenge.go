@@ -0,0 +1,59 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Challenge mode.
+//
+// With -challenge, play allows only a limited number of moves, set relative
+// to the puzzle's optimal solution length, and hints and solving are off.
+// The game is lost as soon as the move counter goes over the budget.
+
+var challenge = flag.String("challenge", "",
+	"Play with a move budget: easy, medium, hard, expert, or +N moves over optimal.")
+
+// Budgets for the difficulty presets, as a function of the optimal solution
+// length.
+var challengePresets = map[string]func(int) int{
+	"easy":   func(n int) int { return n + n/2 },
+	"medium": func(n int) int { return n + n/5 },
+	"hard":   func(n int) int { return n + 5 },
+	"expert": func(n int) int { return n },
+}
+
+// Returns the move budget for the given difficulty and optimal solution
+// length.
+func challengeBudget(difficulty string, optimal int) (int, error) {
+	if preset, ok := challengePresets[difficulty]; ok {
+		return preset(optimal), nil
+	}
+	if extra, ok := strings.CutPrefix(difficulty, "+"); ok {
+		if n, err := strconv.Atoi(extra); err == nil && n >= 0 {
+			return optimal + n, nil
+		}
+	}
+	return 0, fmt.Errorf("invalid -challenge %q: want easy, medium, hard, expert or +N", difficulty)
+}
+
+// Sets up challenge mode for a game, finding the optimal solution length
+// from the distance table.
+func (g *game) startChallenge(difficulty string) error {
+	if _, err := challengeBudget(difficulty, 0); err != nil {
+		return err
+	}
+	g.ensureTable()
+	optimal, err := g.table.Distance(g.start)
+	if err != nil {
+		return err
+	}
+	if optimal < 0 {
+		return fmt.Errorf("the puzzle can't be solved")
+	}
+	g.budget, err = challengeBudget(difficulty, optimal)
+	g.noHelp = true
+	return err
+}
@@ -0,0 +1,198 @@
+// Package client is a Go client for the squareroot server's REST API, as
+// described by its OpenAPI document (served at /openapi.json and kept in
+// api/openapi.json). Its types are generated from the document.
+//
+//	c := client.New("http://localhost:8080")
+//	h, err := c.Hint(ctx, client.Position{Moves: []string{"jL", "fD"}})
+package client
+
+//go:generate go run gen.go
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// Client calls a squareroot server.
+type Client struct {
+	// BaseURL is the server's address, e.g. "http://localhost:8080".
+	BaseURL string
+
+	// HTTPClient makes the requests. If nil, http.DefaultClient is used.
+	HTTPClient *http.Client
+}
+
+// New returns a client for the server at the given base URL.
+func New(baseURL string) *Client {
+	return &Client{BaseURL: strings.TrimRight(baseURL, "/")}
+}
+
+// Done reports whether the job has finished, solved or not.
+func (j *Job) Done() bool {
+	return j.Status == "solved" || j.Status == "failed"
+}
+
+// Position identifies a position by board code or stored puzzle id, by
+// moves in compact notation from that board or the server's starting
+// board, or both. The zero Position is the starting board. Boards of other
+// puzzles than the server's are searched, within the server's limits.
+type Position struct {
+	Board  string
+	Puzzle string // a stored puzzle's id, in place of Board
+	Moves  []string
+}
+
+// Error is an error response from the server.
+type Error struct {
+	StatusCode int
+	Message    string `json:"error"`
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("squareroot server: %s (HTTP %d)", e.Message, e.StatusCode)
+}
+
+// Puzzle returns the server's puzzle.
+func (c *Client) Puzzle(ctx context.Context) (*Puzzle, error) {
+	var p Puzzle
+	if err := c.get(ctx, "/puzzle", nil, &p); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// Hint returns an optimal next move from the given position.
+func (c *Client) Hint(ctx context.Context, pos Position) (*Hint, error) {
+	var h Hint
+	if err := c.get(ctx, "/hint", pos.query(), &h); err != nil {
+		return nil, err
+	}
+	return &h, nil
+}
+
+// RankedHint returns an optimal next move from the given position, along
+// with the best top legal moves ranked by the distance to the goal each
+// leaves.
+func (c *Client) RankedHint(ctx context.Context, pos Position, top int) (*Hint, error) {
+	q := pos.query()
+	q.Set("top", strconv.Itoa(top))
+	var h Hint
+	if err := c.get(ctx, "/hint", q, &h); err != nil {
+		return nil, err
+	}
+	return &h, nil
+}
+
+// Solve returns an optimal solution from the given position.
+func (c *Client) Solve(ctx context.Context, pos Position) (*Solution, error) {
+	var s Solution
+	if err := c.get(ctx, "/solve", pos.query(), &s); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// StorePuzzle stores the puzzle with the given board code and optional
+// name, returning it with the id by which positions can refer to it.
+func (c *Client) StorePuzzle(ctx context.Context, code, name string) (*StoredPuzzle, error) {
+	q := url.Values{"board": {code}}
+	if name != "" {
+		q.Set("name", name)
+	}
+	var p StoredPuzzle
+	if err := c.do(ctx, http.MethodPost, "/puzzles", q, http.StatusCreated, &p); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// StoredPuzzle returns the stored puzzle with the given id.
+func (c *Client) StoredPuzzle(ctx context.Context, id string) (*StoredPuzzle, error) {
+	var p StoredPuzzle
+	if err := c.get(ctx, "/puzzles/"+url.PathEscape(id), nil, &p); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// StartJob starts solving the given position in the background and returns
+// the new job, whose progress Job reports.
+func (c *Client) StartJob(ctx context.Context, pos Position) (*Job, error) {
+	return c.StartJobCallback(ctx, pos, "")
+}
+
+// StartJobCallback is like StartJob, but the server also POSTs the job to
+// the callback URL, if it isn't empty, when the job finishes.
+func (c *Client) StartJobCallback(ctx context.Context, pos Position, callback string) (*Job, error) {
+	q := pos.query()
+	if callback != "" {
+		q.Set("callback", callback)
+	}
+	var j Job
+	if err := c.do(ctx, http.MethodPost, "/jobs", q, http.StatusAccepted, &j); err != nil {
+		return nil, err
+	}
+	return &j, nil
+}
+
+// Job returns the status of the job with the given id.
+func (c *Client) Job(ctx context.Context, id string) (*Job, error) {
+	var j Job
+	if err := c.get(ctx, "/jobs/"+url.PathEscape(id), nil, &j); err != nil {
+		return nil, err
+	}
+	return &j, nil
+}
+
+func (pos Position) query() url.Values {
+	q := url.Values{}
+	if pos.Board != "" {
+		q.Set("board", pos.Board)
+	}
+	if pos.Puzzle != "" {
+		q.Set("puzzle", pos.Puzzle)
+	}
+	if len(pos.Moves) > 0 {
+		q.Set("moves", strings.Join(pos.Moves, ","))
+	}
+	return q
+}
+
+func (c *Client) get(ctx context.Context, path string, q url.Values, v any) error {
+	return c.do(ctx, http.MethodGet, path, q, http.StatusOK, v)
+}
+
+// Makes a request, expecting the given status, and decodes the response
+// into v.
+func (c *Client) do(ctx context.Context, method, path string, q url.Values, status int, v any) error {
+	u := c.BaseURL + path
+	if len(q) > 0 {
+		u += "?" + q.Encode()
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u, nil)
+	if err != nil {
+		return err
+	}
+	hc := c.HTTPClient
+	if hc == nil {
+		hc = http.DefaultClient
+	}
+	resp, err := hc.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != status {
+		e := &Error{StatusCode: resp.StatusCode}
+		if json.NewDecoder(resp.Body).Decode(e) != nil || e.Message == "" {
+			e.Message = http.StatusText(resp.StatusCode)
+		}
+		return e
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
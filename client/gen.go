@@ -0,0 +1,288 @@
+//go:build ignore
+
+// Generates types.go, the client's types for the API's schemas, from the
+// server's OpenAPI document, and checks that the client calls every path in
+// the document and no others. Run by go generate.
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"go/format"
+	"log"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+const (
+	specFile   = "../api/openapi.json"
+	clientFile = "client.go"
+	outFile    = "types.go"
+)
+
+// Schemas the client declares itself: Error carries the status code too.
+var handWritten = map[string]bool{"Error": true}
+
+// Paths the client doesn't call, and why.
+var uncalled = map[string]string{
+	"/metrics": "Prometheus text for scrapers, not JSON",
+}
+
+// A schema object, as far as the API's schemas use them.
+type schema struct {
+	Type        string
+	Format      string
+	Description string
+	Ref         string `json:"$ref"`
+	Items       *schema
+	Enum        []string
+	Required    []string
+	Properties  map[string]*schema
+
+	order []string // the properties' names, in the document's order
+}
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("gen: ")
+	spec, err := os.ReadFile(specFile)
+	if err != nil {
+		log.Fatal(err)
+	}
+	var doc struct {
+		Paths      map[string]json.RawMessage
+		Components struct {
+			Schemas json.RawMessage
+		}
+	}
+	if err := json.Unmarshal(spec, &doc); err != nil {
+		log.Fatalf("%s: %v", specFile, err)
+	}
+	names, err := keys(doc.Components.Schemas)
+	if err != nil {
+		log.Fatalf("%s: %v", specFile, err)
+	}
+	var schemas map[string]json.RawMessage
+	if err := json.Unmarshal(doc.Components.Schemas, &schemas); err != nil {
+		log.Fatalf("%s: %v", specFile, err)
+	}
+
+	var types bytes.Buffer
+	for _, name := range names {
+		if handWritten[name] {
+			continue
+		}
+		s, err := parseSchema(schemas[name])
+		if err != nil {
+			log.Fatalf("%s: schema %s: %v", specFile, name, err)
+		}
+		if err := writeType(&types, name, s); err != nil {
+			log.Fatalf("%s: schema %s: %v", specFile, name, err)
+		}
+	}
+	var out bytes.Buffer
+	fmt.Fprintf(&out, "// Code generated by gen.go from %s; DO NOT EDIT.\n\npackage client\n", strings.TrimPrefix(specFile, "../"))
+	if bytes.Contains(types.Bytes(), []byte("time.Time")) {
+		fmt.Fprintf(&out, "\nimport \"time\"\n")
+	}
+	out.Write(types.Bytes())
+	src, err := format.Source(out.Bytes())
+	if err != nil {
+		log.Fatalf("formatting %s: %v", outFile, err)
+	}
+	if err := os.WriteFile(outFile, src, 0o644); err != nil {
+		log.Fatal(err)
+	}
+
+	if err := checkPaths(doc.Paths); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// Parses a schema, remembering the order of its properties.
+func parseSchema(raw json.RawMessage) (*schema, error) {
+	var s schema
+	if err := json.Unmarshal(raw, &s); err != nil {
+		return nil, err
+	}
+	if s.Properties != nil {
+		var props struct{ Properties json.RawMessage }
+		json.Unmarshal(raw, &props)
+		order, err := keys(props.Properties)
+		if err != nil {
+			return nil, err
+		}
+		s.order = order
+	}
+	return &s, nil
+}
+
+// Returns the keys of a JSON object in order.
+func keys(raw json.RawMessage) ([]string, error) {
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	if t, err := dec.Token(); err != nil || t != json.Delim('{') {
+		return nil, fmt.Errorf("expected an object")
+	}
+	var ks []string
+	for dec.More() {
+		t, err := dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		ks = append(ks, t.(string))
+		var skip json.RawMessage
+		if err := dec.Decode(&skip); err != nil {
+			return nil, err
+		}
+	}
+	return ks, nil
+}
+
+// Writes an object schema as a struct type.
+func writeType(out *bytes.Buffer, name string, s *schema) error {
+	if s.Type != "object" {
+		return fmt.Errorf("type %q isn't an object", s.Type)
+	}
+	fmt.Fprintln(out)
+	comment(out, "", name+" is "+lowerFirst(s.Description))
+	fmt.Fprintf(out, "type %s struct {\n", name)
+	lastDoc := false
+	for i, prop := range s.order {
+		p := s.Properties[prop]
+		t, err := goType(p, true)
+		if err != nil {
+			return fmt.Errorf("property %s: %v", prop, err)
+		}
+		doc := p.Description
+		if len(p.Enum) > 0 {
+			doc = strings.TrimSpace(doc + " One of " + quotedList(p.Enum) + ".")
+		}
+		if i > 0 && (doc != "" || lastDoc) {
+			fmt.Fprintln(out)
+		}
+		lastDoc = doc != ""
+		comment(out, "\t", doc)
+		tag := prop
+		if !slices.Contains(s.Required, prop) {
+			tag += ",omitempty"
+		}
+		fmt.Fprintf(out, "\t%s %s `json:%q`\n", fieldName(prop), t, tag)
+	}
+	fmt.Fprintln(out, "}")
+	return nil
+}
+
+// Returns the Go type of a schema; a referenced object outside a slice is
+// a pointer, so that it can be omitted.
+func goType(s *schema, field bool) (string, error) {
+	if s.Ref != "" {
+		name, ok := strings.CutPrefix(s.Ref, "#/components/schemas/")
+		if !ok {
+			return "", fmt.Errorf("unsupported reference %q", s.Ref)
+		}
+		if field {
+			return "*" + name, nil
+		}
+		return name, nil
+	}
+	switch s.Type {
+	case "string":
+		if s.Format == "date-time" {
+			return "time.Time", nil
+		}
+		return "string", nil
+	case "integer":
+		return "int", nil
+	case "number":
+		return "float64", nil
+	case "boolean":
+		return "bool", nil
+	case "array":
+		if s.Items == nil {
+			return "", fmt.Errorf("array without items")
+		}
+		t, err := goType(s.Items, false)
+		return "[]" + t, err
+	}
+	return "", fmt.Errorf("unsupported type %q", s.Type)
+}
+
+// Writes text as a comment wrapped to fit the line.
+func comment(out *bytes.Buffer, indent, text string) {
+	line := ""
+	for _, w := range strings.Fields(text) {
+		if line != "" && len(indent)*4+len(line)+1+len(w) > 74 {
+			fmt.Fprintf(out, "%s// %s\n", indent, line)
+			line = ""
+		}
+		if line != "" {
+			line += " "
+		}
+		line += w
+	}
+	if line != "" {
+		fmt.Fprintf(out, "%s// %s\n", indent, line)
+	}
+}
+
+// Returns a property's Go field name: "id" is ID, "name" Name.
+func fieldName(prop string) string {
+	if prop == "id" {
+		return "ID"
+	}
+	return strings.ToUpper(prop[:1]) + prop[1:]
+}
+
+func lowerFirst(s string) string {
+	return strings.ToLower(s[:1]) + s[1:]
+}
+
+func quotedList(vs []string) string {
+	q := make([]string, len(vs))
+	for i, v := range vs {
+		q[i] = fmt.Sprintf("%q", v)
+	}
+	if len(q) == 1 {
+		return q[0]
+	}
+	return strings.Join(q[:len(q)-1], ", ") + " or " + q[len(q)-1]
+}
+
+// Paths in the client's requests: string literals starting with a slash.
+var clientPath = regexp.MustCompile(`"(/[a-z][a-z/]*)"`)
+
+// Checks that the client calls every path in the document but the
+// uncalled ones, and none that isn't in it. A path with parameters is
+// matched by its literal prefix, e.g. "/jobs/" for "/jobs/{id}".
+func checkPaths(paths map[string]json.RawMessage) error {
+	src, err := os.ReadFile(clientFile)
+	if err != nil {
+		return err
+	}
+	called := map[string]bool{}
+	for _, m := range clientPath.FindAllStringSubmatch(string(src), -1) {
+		called[m[1]] = true
+	}
+	inSpec := map[string]bool{}
+	var problems []string
+	for path := range paths {
+		prefix, _, _ := strings.Cut(path, "{")
+		inSpec[prefix] = true
+		if _, ok := uncalled[path]; !ok && !called[prefix] {
+			problems = append(problems, fmt.Sprintf("%s doesn't call %s", clientFile, path))
+		}
+	}
+	for path := range called {
+		if !inSpec[path] {
+			problems = append(problems, fmt.Sprintf("%s calls %s, which %s doesn't describe", clientFile, path, specFile))
+		}
+	}
+	if len(problems) > 0 {
+		slices.Sort(problems)
+		return fmt.Errorf("%s", strings.Join(problems, "\n"))
+	}
+	return nil
+}
@@ -0,0 +1,114 @@
+// Code generated by gen.go from api/openapi.json; DO NOT EDIT.
+
+package client
+
+import "time"
+
+// Puzzle is the server's puzzle.
+type Puzzle struct {
+	// Board code of the starting board.
+	Code string `json:"code"`
+
+	Width  int `json:"width"`
+	Height int `json:"height"`
+
+	// The starting board drawn as text.
+	Board string `json:"board"`
+
+	// Description of the goal.
+	Goal string `json:"goal"`
+
+	// Moves in an optimal solution from the start, or -1 if unsolvable.
+	Distance int `json:"distance"`
+
+	// Configurations in the distance table.
+	Configurations int `json:"configurations"`
+}
+
+// Hint is an optimal next move from a position.
+type Hint struct {
+	// Moves in an optimal solution from the position.
+	Distance int `json:"distance"`
+
+	// An optimal next move in compact notation, e.g. "bD".
+	Move string `json:"move,omitempty"`
+
+	// The board after the move, drawn as text.
+	Board string `json:"board"`
+
+	// With top, the best legal moves, best first. Omitted if the position is
+	// already solved.
+	Moves []RankedMove `json:"moves,omitempty"`
+}
+
+// RankedMove is a legal move from a position with the distance to the goal
+// it leaves.
+type RankedMove struct {
+	// The move in compact notation.
+	Move string `json:"move"`
+
+	// Moves in an optimal solution after the move, or -1 if the goal can't
+	// be reached from there.
+	Distance int `json:"distance"`
+
+	// Whether the move brings the position closer to the goal, keeps the
+	// distance, or adds to it or leaves the goal out of reach. One of
+	// "best", "neutral" or "harmful".
+	Quality string `json:"quality"`
+
+	// The board after the move, drawn as text.
+	Board string `json:"board"`
+}
+
+// Solution is an optimal solution from a position.
+type Solution struct {
+	Length int `json:"length"`
+
+	// Moves in compact notation.
+	Moves []string `json:"moves"`
+
+	// With boards=true, the position and the board after each move, drawn as
+	// text.
+	Boards []string `json:"boards,omitempty"`
+}
+
+// StoredPuzzle is a puzzle stored on the server.
+type StoredPuzzle struct {
+	ID   string `json:"id"`
+	Name string `json:"name,omitempty"`
+
+	// Board code of the puzzle's board.
+	Code string `json:"code"`
+
+	Width  int `json:"width"`
+	Height int `json:"height"`
+
+	// The board drawn as text.
+	Board string `json:"board"`
+
+	Goal    string    `json:"goal"`
+	Created time.Time `json:"created"`
+}
+
+// Job is a solve running in the background on the server.
+type Job struct {
+	ID string `json:"id"`
+
+	// One of "queued", "running", "solved" or "failed".
+	Status string `json:"status"`
+
+	// Configurations the search has seen.
+	Configurations int `json:"configurations"`
+
+	// Moves from the position of the positions the search has reached.
+	Depth int `json:"depth"`
+
+	// Time since the job started, or, once done, how long it took.
+	Seconds float64 `json:"seconds"`
+
+	// The solution, once solved.
+	Solution *Solution `json:"solution,omitempty"`
+
+	// Why a failed job found no solution.
+	Error string `json:"error,omitempty"`
+}
@@ -0,0 +1,322 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Commands.
+//
+// squareroot is driven by commands: "squareroot solve", "squareroot play
+// saved.json", "squareroot gen -seed 7" and so on. Each command takes its own
+// flags after its name, and "squareroot help <command>" describes them. Flags
+// given before the command name are accepted too, so "squareroot -torus" and
+// "squareroot -puzzle p.txt play" keep working. With no command, squareroot
+// solves the puzzle.
+
+// A command of the squareroot binary.
+type subcommand struct {
+	name    string
+	args    string // synopsis of the arguments following the flags
+	summary string
+
+	// The command's own flags, or nil if it has none.
+	flags *flag.FlagSet
+
+	// The names of the global flags the command also takes.
+	shared []string
+
+	// Whether the command works on the puzzle selected by -puzzle, -goal and
+	// -torus, which is then passed to run.
+	board bool
+
+	run func(start *Board, args []string)
+}
+
+// Flags selecting the puzzle.
+var puzzleFlags = []string{"puzzle", "goal", "torus"}
+
+// Flags choosing how boards are drawn in the terminal.
+var displayFlags = []string{"theme", "color", "glyphs"}
+
+// Flags choosing how solutions are found.
+var searchFlags = []string{"astar", "cache", "cache-dir", "cache-format", "timeout", "workers", "flat", "piece-moves", "ordering", "ordering-seed"}
+
+// The commands, in the order help lists them.
+var commands []*subcommand
+
+func init() {
+	commands = []*subcommand{
+		{name: "solve", summary: "Find and print a shortest solution.", board: true,
+			shared: flagNames(puzzleFlags, searchFlags, []string{"avoid", "events", "events-out", "trace", "depth-stats", "mem-stats", "certify", "cert-out", "sbs-out", "parallel", "format",
+				"layout", "render", "render-every", "diagram-every", "fps"}, displayFlags),
+			run: runSolve},
+		{name: "play", args: "[saved game]", summary: "Play the puzzle in the terminal.", board: true,
+			shared: flagNames(puzzleFlags, []string{"challenge", "blunders", "book"}, displayFlags),
+			run:    runPlay},
+		{name: "tutorial", summary: "Step through the solution with an explanation of each move.", board: true,
+			shared: flagNames(puzzleFlags, searchFlags, displayFlags),
+			run:    func(start *Board, _ []string) { runTutorial(start) }},
+		{name: "edit", summary: "Change the board and see at once whether it can still be solved, and how.", board: true,
+			shared: flagNames(puzzleFlags, searchFlags, displayFlags),
+			run:    func(start *Board, _ []string) { runEdit(start) }},
+		{name: "gen", summary: "Generate a random puzzle.",
+			flags: genFlags,
+			run:   func(_ *Board, _ []string) { runGen() }},
+		{name: "remix", args: "[dir]", summary: "Make solvable variations of the puzzle.", board: true,
+			flags: remixFlags, shared: puzzleFlags,
+			run: runRemix},
+		{name: "hardest", summary: "Find the arrangement of a set of pieces that takes the most moves to solve.",
+			flags: hardestFlags,
+			run:   func(_ *Board, _ []string) { runHardest() }},
+		{name: "analyze", args: "[position [board]]", summary: "Describe every position reachable from the start, or one position's pieces.", board: true,
+			flags: analyzeFlags, shared: flagNames(puzzleFlags, []string{"timeout"}),
+			run: runAnalyze},
+		{name: "render", args: "<file.png> [move]", summary: "Draw the board as a PNG image, optionally highlighting a move.", board: true,
+			shared: flagNames(puzzleFlags, []string{"cell", "theme"}),
+			run:    runRender},
+		{name: "overview", args: "<file.png>", summary: "Draw the whole solution as numbered arrows on one image.", board: true,
+			shared: flagNames(puzzleFlags, searchFlags, []string{"cell", "theme"}),
+			run:    runOverview},
+		{name: "heatmap", args: "[file.png | file.csv]", summary: "Count how often each space and piece is used by the solution.", board: true,
+			shared: flagNames(puzzleFlags, searchFlags, []string{"all-optimal", "cell", "theme", "color"}),
+			run:    runHeatmap},
+		{name: "video", args: "<file>", summary: "Record the solution as a video, or as PNG frames without ffmpeg.", board: true,
+			shared: flagNames(puzzleFlags, searchFlags, []string{"cell", "fps", "theme"}),
+			run:    runVideo},
+		{name: "serve", summary: "Serve the solver over HTTP.", board: true,
+			shared: flagNames(puzzleFlags, []string{"addr", "ui", "max-cells", "max-states", "job-timeout", "max-jobs", "queue", "async-timeout", "job-ttl", "callback-hosts", "store", "cache", "cache-dir", "cache-format"}),
+			run:    func(start *Board, _ []string) { runServer(start) }},
+		{name: "bot", summary: "Answer /squareroot <board code> in Slack or Discord with the board and its solution.",
+			shared: []string{"addr", "public-url", "discord-key", "cell", "theme", "max-cells", "max-states", "job-timeout", "max-jobs", "queue", "cache", "cache-dir", "cache-format"},
+			run:    func(_ *Board, _ []string) { runBot() }},
+		{name: "verify", args: "<file> | <move>...", summary: "Check that a solution is legal and reaches the goal.", board: true,
+			shared: puzzleFlags,
+			run:    runVerify},
+		{name: "trace", args: "inspect <file> | replay <file> [first [last]] | explore <file>", summary: "Summarize, step through or browse a search recorded with -trace.",
+			run: func(_ *Board, args []string) { runTrace(args) }},
+		{name: "check-cert", args: "<file>", summary: "Re-verify a -certify optimality certificate.",
+			run: func(_ *Board, args []string) { runCheckCert(args) }},
+		{name: "grade", args: "<file> | <move>...", summary: "Compare a solution with the optimal one, move by move.", board: true,
+			shared: puzzleFlags,
+			run:    runGrade},
+		{name: "bench", summary: "Time the solvers on the puzzle.", board: true,
+			flags: benchFlags, shared: puzzleFlags,
+			run: func(start *Board, _ []string) { runBench(start) }},
+		{name: "compare", args: "[solver...]", summary: "Run several solvers on the puzzle and compare their work.", board: true,
+			shared: flagNames(puzzleFlags, []string{"timeout"}),
+			run:    runCompare},
+		{name: "eval-heuristic", args: "[heuristic...]", summary: "Compare heuristics' estimates with the exact distances, and their effect on A*.", board: true,
+			flags: evalFlags, shared: flagNames(puzzleFlags, []string{"timeout"}),
+			run: runEvalHeuristic},
+		{name: "selfcheck", args: "[name...]", summary: "Check that the solvers still find the known optimal lengths of the built-in puzzles.",
+			shared: []string{"timeout"},
+			run:    func(_ *Board, args []string) { runSelfcheck(args) }},
+		{name: "selftest", summary: "Solve random small puzzles with breadth-first search and IDA*, checking that they agree.",
+			flags: selftestFlags, shared: []string{"timeout"},
+			run: func(*Board, []string) { runSelftest() }},
+		{name: "sample", summary: "Pick several solutions that differ as much as possible.", board: true,
+			flags: sampleFlags, shared: puzzleFlags,
+			run: func(start *Board, _ []string) { runSample(start) }},
+		{name: "lines", summary: "Map the optimal solutions: key positions, families of lines and branch points.", board: true,
+			shared: puzzleFlags,
+			run:    func(start *Board, _ []string) { runLines(start) }},
+		{name: "book", args: "<file>", summary: "Write a book of optimal moves from the openings and key positions, for instant hints.", board: true,
+			flags: bookFlags, shared: puzzleFlags,
+			run: runBook},
+		{name: "freeze", args: "[file.csv]", summary: "Solve with each piece frozen in turn to see how much it matters.", board: true,
+			shared: puzzleFlags,
+			run:    runFreeze},
+		{name: "dedupe", args: "<file or dir>...", summary: "Delete puzzle files that are reflections or rotations of others.",
+			flags: dedupeFlags, shared: []string{"torus"},
+			run: func(_ *Board, args []string) { runDedupe(args) }},
+		{name: "catalog", args: "[list] [dir] | show <name>", summary: "List the built-in and installed puzzles, or the puzzle files in a directory, or show one.",
+			shared: flagNames(displayFlags, []string{"cache-dir"}),
+			run:    func(_ *Board, args []string) { runCatalog(args) }},
+		{name: "install", args: "<file>...", summary: "Install puzzle files and packs for catalog, campaign and daily.",
+			flags: installFlags,
+			run:   func(_ *Board, args []string) { runInstall(args) }},
+		{name: "uninstall", args: "<name>...", summary: "Remove installed puzzle files and packs.",
+			run: func(_ *Board, args []string) { runUninstall(args) }},
+		{name: "daily", args: "[show] [YYYY-MM-DD]", summary: "Play the puzzle of the day.",
+			flags: dailyFlags, shared: flagNames([]string{"blunders"}, displayFlags),
+			run: func(_ *Board, args []string) { runDaily(args) }},
+		{name: "campaign", args: "<pack> [n]", summary: "Play through a puzzle pack.",
+			shared: displayFlags,
+			run:    func(_ *Board, args []string) { runCampaign(args) }},
+		{name: "cache", args: "list | clear", summary: "List or clear the results cache.",
+			shared: []string{"cache-dir"},
+			run:    func(_ *Board, args []string) { runCacheCommand(args) }},
+		{name: "gui", summary: "Play the puzzle in a desktop window.", board: true,
+			shared: flagNames(puzzleFlags, []string{"theme"}),
+			run:    func(start *Board, _ []string) { runGUI(start) }},
+		{name: "touch", summary: "Play the puzzle with the touch interface.", board: true,
+			shared: flagNames(puzzleFlags, []string{"theme"}),
+			run:    func(start *Board, _ []string) { runTouchUI(start) }},
+		{name: "completion", args: "bash | zsh | fish", summary: "Print a shell completion script.",
+			run: func(_ *Board, args []string) { runCompletion(args) }},
+	}
+}
+
+// Concatenates lists of flag names.
+func flagNames(lists ...[]string) []string {
+	names := []string{}
+	for _, l := range lists {
+		names = append(names, l...)
+	}
+	return names
+}
+
+// Returns the named command, or nil if there is none.
+func lookupCommand(name string) *subcommand {
+	for _, c := range commands {
+		if c.name == name {
+			return c
+		}
+	}
+	return nil
+}
+
+// Returns the command's flag set: its own flags plus the global flags it
+// shares, which set the same variables as when given before the command.
+func (c *subcommand) flagSet() *flag.FlagSet {
+	fs := c.flags
+	if fs == nil {
+		fs = flag.NewFlagSet(c.name, flag.ContinueOnError)
+		c.flags = fs
+	}
+	fs.Init(c.name, flag.ContinueOnError)
+	for _, name := range c.shared {
+		if f := flag.Lookup(name); f != nil && fs.Lookup(name) == nil {
+			fs.Var(f.Value, f.Name, f.Usage)
+		}
+	}
+	fs.Usage = c.usage
+	return fs
+}
+
+// Prints the command's help text.
+func (c *subcommand) usage() {
+	out := flag.CommandLine.Output()
+	fmt.Fprintf(out, "usage: %s\n\n%s\n", strings.TrimSpace("squareroot "+c.name+" [flags] "+c.args), c.summary)
+	fs := c.flagSet()
+	n := 0
+	fs.VisitAll(func(*flag.Flag) { n++ })
+	if n > 0 {
+		fmt.Fprintln(out, "\nFlags:")
+		fs.PrintDefaults()
+	}
+}
+
+// Prints the list of commands.
+func usage() {
+	out := flag.CommandLine.Output()
+	fmt.Fprintln(out, "usage: squareroot [flags] [command] [command flags] [args]")
+	fmt.Fprintln(out, "\nCommands:")
+	width := 0
+	for _, c := range commands {
+		width = max(width, len(c.name))
+	}
+	for _, c := range commands {
+		fmt.Fprintf(out, "  %-*s %s\n", width, c.name, c.summary)
+	}
+	fmt.Fprintln(out, "\nWith no command, squareroot solves the puzzle.")
+	fmt.Fprintln(out, "Run \"squareroot help <command>\" for a command's flags.")
+}
+
+// Runs "help [command]".
+func runHelp(args []string) {
+	if len(args) == 0 {
+		flag.CommandLine.SetOutput(os.Stdout)
+		usage()
+		return
+	}
+	c := lookupCommand(args[0])
+	if c == nil {
+		fmt.Fprintf(os.Stderr, "Unknown command %q\n", args[0])
+		os.Exit(exitInvalid)
+	}
+	flag.CommandLine.SetOutput(os.Stdout)
+	c.flagSet().SetOutput(os.Stdout)
+	c.usage()
+}
+
+// Runs the command named by the command line, parsing its flags and loading
+// its puzzle.
+func runCommand(args []string) {
+	name := "solve"
+	if touchByDefault {
+		name = "touch"
+	}
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		name, args = args[0], args[1:]
+	}
+	if name == "help" {
+		runHelp(args)
+		return
+	}
+	c := lookupCommand(name)
+	if c == nil {
+		fmt.Fprintf(os.Stderr, "Unknown command %q\n", name)
+		usage()
+		os.Exit(exitInvalid)
+	}
+	fs := c.flagSet()
+	exitOnFlagError(fs.Parse(args))
+	if c.args == "" && fs.NArg() > 0 {
+		fmt.Fprintf(os.Stderr, "%s takes no arguments\n", c.name)
+		os.Exit(exitInvalid)
+	}
+
+	err := loadTheme(*themeFlag)
+	if err == nil {
+		err = loadGlyphs(*glyphsFlag)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(exitInvalid)
+	}
+	var start *Board
+	if c.board {
+		start = loadStart()
+	}
+	c.run(start, fs.Args())
+}
+
+// Exits if parsing flags failed: successfully if help was asked for, and
+// otherwise with the invalid input status. The flag package has already
+// reported the problem.
+func exitOnFlagError(err error) {
+	if err == flag.ErrHelp {
+		os.Exit(exitSolved)
+	}
+	if err != nil {
+		os.Exit(exitInvalid)
+	}
+}
+
+// Loads the puzzle selected by -puzzle, -goal and -torus.
+func loadStart() *Board {
+	start := makeStartingBoard()
+	if *puzzleFile != "" {
+		var err error
+		if start, err = loadBoard(*puzzleFile); err != nil {
+			fmt.Fprintf(os.Stderr, "Couldn't read puzzle: %v\n", err)
+			os.Exit(exitInvalid)
+		}
+	}
+	if *torus {
+		start.wrap = true
+	}
+	if *goalFlag != "" {
+		g, err := start.parseGoal(strings.Fields(*goalFlag))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Invalid -goal: %v\n", err)
+			os.Exit(exitInvalid)
+		}
+		start.goal = g
+	}
+	return start
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	rtmetrics "runtime/metrics"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Solver comparison.
+//
+// "squareroot compare [solver...]" runs several solvers on the same puzzle
+// and prints a table of the solution length, configurations expanded, peak
+// heap memory and wall time of each, then checks that the optimal solvers
+// all found solutions of the same length. With no solvers named it runs them
+// all.
+
+// A solver that can be compared or benchmarked.
+type namedSolver struct {
+	name    string
+	about   string
+	solve   func(*Board) (*Board, Stats)
+	optimal bool // finds solutions with the fewest moves
+}
+
+var solvers = []namedSolver{
+	{"bfs", "breadth-first search", boardSolver{}.Solve, true},
+	{"astar", "A* with the goal's heuristic", solveAStar, true},
+	{"dijkstra", "A* without a heuristic", func(b *Board) (*Board, Stats) {
+		return solveAStarWith(b, func(*Board) int { return 0 })
+	}, true},
+	{"table", "distance table lookup", solveByTable, true},
+	{"workers", "breadth-first search on -workers goroutines", concurrentSolver{}.Solve, true},
+	{"flat", "breadth-first search over packed positions", flatSolver{}.Solve, true},
+	{"piece-moves", "fewest piece moves, then moves", solvePieceMoves, false},
+	{"parallel", "fewest parallel steps", func(b *Board) (*Board, Stats) {
+		end, _, stats := searchParallel(b)
+		return end, stats
+	}, false},
+}
+
+// Solves by building the puzzle's distance table and following it from the
+// start.
+func solveByTable(start *Board) (*Board, Stats) {
+	t := buildDistanceTable(start)
+	stats := Stats{Configs: t.Size(), Expanded: t.Size()}
+	mvs, err := t.Solve(start)
+	if err != nil {
+		return nil, stats
+	}
+	b := start
+	for _, m := range mvs {
+		b = b.move(m)
+	}
+	return b, stats
+}
+
+// Returns the named solver.
+func lookupSolver(name string) (namedSolver, bool) {
+	for _, s := range solvers {
+		if s.name == name {
+			return s, true
+		}
+	}
+	return namedSolver{}, false
+}
+
+// Runs a solver, returning its result, how long it took and the most heap
+// memory it used at once.
+func measureSolver(s namedSolver, start *Board) (*Board, Stats, time.Duration, uint64) {
+	const heapMetric = "/memory/classes/heap/objects:bytes"
+	sample := []rtmetrics.Sample{{Name: heapMetric}}
+	heapBytes := func() uint64 {
+		rtmetrics.Read(sample)
+		return sample[0].Value.Uint64()
+	}
+	runtime.GC()
+	base := heapBytes()
+	peak := base
+	done := make(chan bool)
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		tick := time.NewTicker(2 * time.Millisecond)
+		defer tick.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-tick.C:
+				peak = max(peak, heapBytes())
+			}
+		}
+	}()
+
+	startDeadline()
+	t := time.Now()
+	end, stats := s.solve(start)
+	elapsed := time.Since(t)
+	close(done)
+	wg.Wait()
+	peak = max(peak, heapBytes())
+	return end, stats, elapsed, peak - base
+}
+
+// Formats a byte count in megabytes.
+func formatMB(n uint64) string {
+	return fmt.Sprintf("%.1f MB", float64(n)/(1<<20))
+}
+
+// Runs "compare [solver...]".
+func runCompare(start *Board, args []string) {
+	chosen := solvers
+	if len(args) > 0 {
+		chosen = nil
+		for _, name := range args {
+			s, ok := lookupSolver(name)
+			if !ok {
+				names := []string{}
+				for _, s := range solvers {
+					names = append(names, s.name)
+				}
+				fmt.Fprintf(os.Stderr, "Unknown solver %q: want %s\n", name, strings.Join(names, ", "))
+				os.Exit(exitInvalid)
+			}
+			chosen = append(chosen, s)
+		}
+	}
+
+	fmt.Printf("%-9s %7s %9s %9s %11s %10s\n", "solver", "moves", "expanded", "configs", "peak memory", "time")
+	// The solution lengths found by the optimal solvers that finished, -1
+	// for none.
+	type result struct {
+		name   string
+		length int
+	}
+	results := []result{}
+	for _, s := range chosen {
+		end, stats, elapsed, peak := measureSolver(s, start)
+		moves, length := "-", -1
+		if end != nil {
+			length = len(end.mvs)
+			moves = fmt.Sprint(length)
+		} else if searchExpired() {
+			moves = "timeout"
+		}
+		fmt.Printf("%-9s %7s %9d %9d %11s %10s\n", s.name, moves, stats.Expanded, stats.Configs,
+			formatMB(peak), elapsed.Round(time.Millisecond))
+		if s.optimal && moves != "timeout" {
+			results = append(results, result{s.name, length})
+		}
+	}
+
+	if len(results) == 0 {
+		return
+	}
+	for _, r := range results {
+		if r.length != results[0].length {
+			fmt.Println("The optimal solvers disagree on the solution length:")
+			for _, r := range results {
+				fmt.Printf("  %-9s %d\n", r.name, r.length)
+			}
+			os.Exit(exitError)
+		}
+	}
+	if results[0].length < 0 {
+		fmt.Println("The optimal solvers agree: no solution.")
+	} else {
+		fmt.Printf("The optimal solvers agree: %d moves.\n", results[0].length)
+	}
+}
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// Shell completion.
+//
+// "squareroot completion bash|zsh|fish" prints a completion script for the
+// shell, generated from the command table: it completes command names, each
+// command's flags, the values of flags with a fixed set of choices, and the
+// puzzle files in the catalog for -puzzle. For example:
+//
+//	squareroot completion bash > /etc/bash_completion.d/squareroot
+//	squareroot completion fish > ~/.config/fish/completions/squareroot.fish
+//
+// The zsh script loads the bash one through bashcompinit.
+
+// Returns the choices for a flag's value, or nil if it takes any value.
+func flagChoices(name string) []string {
+	switch name {
+	case "format":
+		return formats
+	case "layout":
+		return []string{"stacked", "side-by-side"}
+	case "render":
+		return []string{"all", "first-last", "none"}
+	case "theme":
+		return sortedKeys(themes)
+	case "glyphs":
+		return []string{"emoji"}
+	case "challenge":
+		return sortedKeys(challengePresets)
+	case "puzzle":
+		names := []string{}
+		for _, dir := range catalogDirs() {
+			names = append(names, catalogFiles(dir)...)
+		}
+		return names
+	}
+	return nil
+}
+
+// Returns a map's keys, sorted.
+func sortedKeys[V any](m map[string]V) []string {
+	keys := []string{}
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Reports whether a flag is boolean, so it takes no value.
+func isBoolFlag(f *flag.Flag) bool {
+	b, ok := f.Value.(interface{ IsBoolFlag() bool })
+	return ok && b.IsBoolFlag()
+}
+
+// Returns the flags of a flag set.
+func flagList(fs *flag.FlagSet) []*flag.Flag {
+	fl := []*flag.Flag{}
+	fs.VisitAll(func(f *flag.Flag) { fl = append(fl, f) })
+	return fl
+}
+
+// Returns the names of the commands completed after "squareroot".
+func commandNames() []string {
+	names := []string{}
+	for _, c := range commands {
+		names = append(names, c.name)
+	}
+	return append(names, "help")
+}
+
+// Runs "completion bash|zsh|fish".
+func runCompletion(args []string) {
+	if len(args) != 1 {
+		fmt.Fprintln(os.Stderr, "usage: squareroot completion bash|zsh|fish")
+		os.Exit(exitInvalid)
+	}
+	switch args[0] {
+	case "bash":
+		fmt.Print(bashCompletion())
+	case "zsh":
+		fmt.Print("autoload -U +X bashcompinit && bashcompinit\n\n" + bashCompletion())
+	case "fish":
+		fmt.Print(fishCompletion())
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown shell %q: want bash, zsh or fish\n", args[0])
+		os.Exit(exitInvalid)
+	}
+}
+
+// Returns "-name" for each of the flags.
+func dashed(fl []*flag.Flag) string {
+	ns := []string{}
+	for _, f := range fl {
+		ns = append(ns, "-"+f.Name)
+	}
+	return strings.Join(ns, " ")
+}
+
+func bashCompletion() string {
+	var sb strings.Builder
+	global := flagList(flag.CommandLine)
+	valueFlags := []string{}
+	for _, f := range global {
+		if !isBoolFlag(f) {
+			valueFlags = append(valueFlags, "-"+f.Name)
+		}
+	}
+	for _, c := range commands {
+		for _, f := range flagList(c.flagSet()) {
+			if !isBoolFlag(f) && flag.Lookup(f.Name) == nil {
+				valueFlags = append(valueFlags, "-"+f.Name)
+			}
+		}
+	}
+
+	sb.WriteString("# bash completion for squareroot\n")
+	sb.WriteString("_squareroot() {\n")
+	sb.WriteString("\tlocal cur=\"${COMP_WORDS[COMP_CWORD]}\" prev=\"${COMP_WORDS[COMP_CWORD-1]}\"\n")
+	sb.WriteString("\tlocal cmd=\"\" skip=\"\" i\n")
+	sb.WriteString("\tfor ((i = 1; i < COMP_CWORD; i++)); do\n")
+	sb.WriteString("\t\tlocal w=\"${COMP_WORDS[i]}\"\n")
+	sb.WriteString("\t\tif [[ -n $skip ]]; then skip=\"\"; continue; fi\n")
+	fmt.Fprintf(&sb, "\t\tcase \"$w\" in\n\t\t%s) skip=1 ;;\n", strings.Join(valueFlags, "|"))
+	sb.WriteString("\t\t-*) ;;\n\t\t*) cmd=\"$w\"; break ;;\n\t\tesac\n\tdone\n\n")
+
+	sb.WriteString("\tcase \"$prev\" in\n")
+	seen := map[string]bool{}
+	for _, name := range valueFlags {
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+		if cs := flagChoices(name[1:]); cs != nil {
+			fmt.Fprintf(&sb, "\t%s) COMPREPLY=($(compgen -f -W %q -- \"$cur\")); return ;;\n", name, strings.Join(cs, " "))
+		}
+	}
+	fmt.Fprintf(&sb, "\t%s) COMPREPLY=($(compgen -f -- \"$cur\")); return ;;\n", strings.Join(valueFlags, "|"))
+	sb.WriteString("\tesac\n\n")
+
+	sb.WriteString("\tlocal words\n\tcase \"$cmd\" in\n")
+	fmt.Fprintf(&sb, "\t\"\") words=%q ;;\n", strings.Join(commandNames(), " ")+" "+dashed(global))
+	fmt.Fprintf(&sb, "\thelp) words=%q ;;\n", strings.Join(commandNames(), " "))
+	for _, c := range commands {
+		fmt.Fprintf(&sb, "\t%s) words=%q ;;\n", c.name, dashed(flagList(c.flagSet())))
+	}
+	sb.WriteString("\tesac\n")
+	sb.WriteString("\tif [[ -z $cmd || $cmd == help || $cur == -* ]]; then\n")
+	sb.WriteString("\t\tCOMPREPLY=($(compgen -W \"$words\" -- \"$cur\"))\n")
+	sb.WriteString("\telse\n\t\tCOMPREPLY=($(compgen -f -- \"$cur\"))\n\tfi\n")
+	sb.WriteString("}\ncomplete -F _squareroot squareroot\n")
+	return sb.String()
+}
+
+func fishCompletion() string {
+	var sb strings.Builder
+	sb.WriteString("# fish completion for squareroot\n")
+	names := strings.Join(commandNames(), " ")
+	flagLines := func(cond string, fl []*flag.Flag) {
+		for _, f := range fl {
+			fmt.Fprintf(&sb, "complete -c squareroot -n %s -o %s", fishQuote(cond), f.Name)
+			if !isBoolFlag(f) {
+				if cs := flagChoices(f.Name); cs != nil {
+					fmt.Fprintf(&sb, " -r -a %s", fishQuote(strings.Join(cs, " ")))
+				} else {
+					sb.WriteString(" -r")
+				}
+			}
+			fmt.Fprintf(&sb, " -d %s\n", fishQuote(f.Usage))
+		}
+	}
+
+	for _, c := range commands {
+		fmt.Fprintf(&sb, "complete -c squareroot -f -n %s -a %s -d %s\n",
+			fishQuote("not __fish_seen_subcommand_from "+names), c.name, fishQuote(c.summary))
+	}
+	fmt.Fprintf(&sb, "complete -c squareroot -f -n %s -a help -d %s\n",
+		fishQuote("not __fish_seen_subcommand_from "+names), fishQuote("Describe a command."))
+	fmt.Fprintf(&sb, "complete -c squareroot -f -n %s -a %s\n",
+		fishQuote("__fish_seen_subcommand_from help"), fishQuote(names))
+	flagLines("not __fish_seen_subcommand_from "+names, flagList(flag.CommandLine))
+	for _, c := range commands {
+		flagLines("__fish_seen_subcommand_from "+c.name, flagList(c.flagSet()))
+	}
+	return sb.String()
+}
+
+// Quotes a string for fish.
+func fishQuote(s string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, "'", `\'`).Replace(s) + "'"
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Configuration file.
+//
+// Defaults for the flags can be kept in config.toml or config.yaml in the
+// squareroot directory of the user config directory (~/.config/squareroot on
+// Linux). Each line sets a flag by name, in TOML or YAML style:
+//
+//	# config.toml
+//	algorithm = "astar"
+//	theme = "colorblind"
+//	color = true
+//	format = "words"
+//	cache-dir = "/var/cache/squareroot"
+//
+//	# config.yaml
+//	algorithm: astar
+//	color: true
+//
+// Underscores may stand for the hyphens in flag names, and "algorithm"
+// (bfs or astar) sets -astar. Flags on the command line override the file.
+// Only flat key/value lines are read; tables, lists and nesting aren't
+// supported.
+
+// The config file names, in the order they're looked for.
+var configNames = []string{"config.toml", "config.yaml", "config.yml"}
+
+// Returns the path of the user's config file, or "" if there's none.
+func configPath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	for _, name := range configNames {
+		path := filepath.Join(dir, "squareroot", name)
+		if fileExists(path) {
+			return path
+		}
+	}
+	return ""
+}
+
+// Sets flag defaults from the user's config file, if there is one.
+func loadConfig() error {
+	path := configPath()
+	if path == "" {
+		return nil
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	yaml := filepath.Ext(path) != ".toml"
+	s := bufio.NewScanner(f)
+	for n := 1; s.Scan(); n++ {
+		if err := applyConfigLine(s.Text(), yaml); err != nil {
+			return fmt.Errorf("%s:%d: %v", path, n, err)
+		}
+	}
+	return s.Err()
+}
+
+// Applies a "key = value" (TOML) or "key: value" (YAML) line of a config
+// file.
+func applyConfigLine(line string, yaml bool) error {
+	line = strings.TrimSpace(line)
+	if line == "" || strings.HasPrefix(line, "#") || yaml && line == "---" {
+		return nil
+	}
+	sep := "="
+	if yaml {
+		sep = ":"
+	}
+	key, value, ok := strings.Cut(line, sep)
+	if !ok {
+		return fmt.Errorf("want key %s value", sep)
+	}
+	key = strings.ReplaceAll(strings.TrimSpace(key), "_", "-")
+	value, err := configValue(strings.TrimSpace(value))
+	if err != nil {
+		return err
+	}
+	if key == "algorithm" {
+		if value != "bfs" && value != "astar" {
+			return fmt.Errorf("unknown algorithm %q: want bfs or astar", value)
+		}
+		key, value = "astar", strconv.FormatBool(value == "astar")
+	}
+	if flag.Lookup(key) == nil {
+		return fmt.Errorf("unknown setting %q", key)
+	}
+	if err := flag.Set(key, value); err != nil {
+		return fmt.Errorf("%s: %v", key, err)
+	}
+	return nil
+}
+
+// Returns a config value without its quotes or trailing comment.
+func configValue(v string) (string, error) {
+	if strings.HasPrefix(v, `"`) {
+		end := strings.LastIndex(v, `"`)
+		if end == 0 {
+			return "", fmt.Errorf("unterminated string %s", v)
+		}
+		return strconv.Unquote(v[:end+1])
+	}
+	if strings.HasPrefix(v, "'") {
+		end := strings.LastIndex(v, "'")
+		if end == 0 {
+			return "", fmt.Errorf("unterminated string %s", v)
+		}
+		return v[1:end], nil
+	}
+	v, _, _ = strings.Cut(v, "#")
+	return strings.TrimSpace(v), nil
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Daily puzzles.
+//
+// "squareroot daily" plays the puzzle of the day: a generated puzzle seeded
+// by the date (in UTC), so everyone playing on the same day gets the same
+// board and can compare move counts. "squareroot daily show" prints the
+// board, its code (for -puzzle) and its optimal solution length instead. A
+// date given as YYYY-MM-DD picks another day's puzzle.
+//
+// With -installed, the day's puzzle is one of the installed puzzles (see
+// install.go) instead, taken in turn by name, a new one each day.
+
+var dailyFlags = flag.NewFlagSet("daily", flag.ContinueOnError)
+
+var dailyInstalled = dailyFlags.Bool("installed", false, "Pick the day's puzzle from the installed puzzles.")
+
+var dailyOptions = genOptions{w: 4, h: 5, minMoves: 50, maxMoves: 90, attempts: 200}
+
+// Generates the puzzle for a date and returns it with its optimal solution
+// length.
+func dailyPuzzle(date time.Time) (*Board, int) {
+	seed := int64(date.Year()*10000 + int(date.Month())*100 + date.Day())
+	return generate(rand.New(rand.NewSource(seed)), dailyOptions)
+}
+
+// Runs "daily [show] [date]".
+func runDaily(args []string) {
+	show := len(args) > 0 && args[0] == "show"
+	if show {
+		args = args[1:]
+	}
+	date := time.Now().UTC()
+	if len(args) == 1 {
+		var err error
+		if date, err = time.Parse(time.DateOnly, args[0]); err != nil {
+			fmt.Fprintf(os.Stderr, "invalid date %q: want YYYY-MM-DD\n", args[0])
+			os.Exit(exitInvalid)
+		}
+	} else if len(args) > 1 {
+		fmt.Fprintln(os.Stderr, "usage: squareroot daily [show] [YYYY-MM-DD]")
+		os.Exit(exitInvalid)
+	}
+
+	var b *Board
+	if *dailyInstalled {
+		var name string
+		b, name = installedDailyPuzzle(date)
+		optimal := knownOptimal(name, b)
+		if optimal == "-" {
+			optimal = "unknown"
+		}
+		fmt.Printf("Puzzle for %s: %s (best possible: %s)\n", date.Format(time.DateOnly), filepath.Base(name), optimal)
+	} else {
+		var optimal int
+		b, optimal = dailyPuzzle(date)
+		fmt.Printf("Puzzle for %s (best possible: %d moves)\n", date.Format(time.DateOnly), optimal)
+	}
+	if !show {
+		play(newGame(b), os.Stdin, os.Stdout)
+		return
+	}
+	fmt.Print(b.display())
+	if code, err := b.Encode(); err == nil {
+		fmt.Printf("Board code: %s\n", code)
+	}
+}
+
+// Returns the installed puzzle for a date and its file name, exiting if
+// there are none.
+func installedDailyPuzzle(date time.Time) (*Board, string) {
+	names := catalogFiles(userPuzzleDir())
+	if len(names) == 0 {
+		fmt.Fprintln(os.Stderr, "No installed puzzles; see squareroot install.")
+		os.Exit(exitInvalid)
+	}
+	day := int(date.Unix() / (24 * 60 * 60))
+	name := names[day%len(names)]
+	b, err := readBoardFile(name)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", name, err)
+		os.Exit(exitInvalid)
+	}
+	return b, name
+}
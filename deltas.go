@@ -0,0 +1,83 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+)
+
+// Delta stream output.
+//
+// The deltas format writes the solution as JSON Lines for animation front
+// ends, which can slide each piece from its old place to its new one
+// instead of redrawing the whole board. The first line describes the
+// starting board, each following line one move, with just the pieces it
+// moves (several when pieces are linked), and the last the goal reached:
+//
+//	{"type":"start","state":"#56eb21a3","code":"AgQF...","width":4,"height":5,"pieces":[{"id":"a","w":1,"h":2,"x":0,"y":0}, ...]}
+//	{"type":"move","state":"#28791125","step":1,"move":"iR","dir":"R","changes":[{"id":"i","from":{"x":0,"y":4},"to":{"x":1,"y":4}}]}
+//	...
+//	{"type":"end","goal":"b at 1,3","length":116}
+//
+// On a toroidal board a piece sliding off an edge has "to" on the opposite
+// edge; "dir" says which way it went.
+
+type deltaPlace struct {
+	X int `json:"x"`
+	Y int `json:"y"`
+}
+
+type deltaPiece struct {
+	ID string `json:"id"`
+	W  int    `json:"w"`
+	H  int    `json:"h"`
+	X  int    `json:"x"`
+	Y  int    `json:"y"`
+}
+
+type deltaChange struct {
+	ID   string     `json:"id"`
+	From deltaPlace `json:"from"`
+	To   deltaPlace `json:"to"`
+}
+
+type deltaLine struct {
+	Type    string        `json:"type"`
+	State   string        `json:"state,omitempty"` // the position's id, at the start or after the move
+	Code    string        `json:"code,omitempty"`
+	Width   int           `json:"width,omitempty"`
+	Height  int           `json:"height,omitempty"`
+	Pieces  []deltaPiece  `json:"pieces,omitempty"`
+	Step    int           `json:"step,omitempty"`
+	Move    string        `json:"move,omitempty"`
+	Dir     string        `json:"dir,omitempty"`
+	Changes []deltaChange `json:"changes,omitempty"`
+	Goal    string        `json:"goal,omitempty"`
+	Length  int           `json:"length,omitempty"`
+}
+
+// Prints a solution in the deltas format.
+func printDeltasSolution(start, end *Board) {
+	enc := json.NewEncoder(os.Stdout)
+	head := deltaLine{Type: "start", State: start.stateID(), Width: start.w, Height: start.h}
+	head.Code, _ = start.Encode()
+	for _, pid := range start.pieceIDs() {
+		p := start.ps[pid]
+		head.Pieces = append(head.Pieces, deltaPiece{pid, p.w, p.h, p.x, p.y})
+	}
+	enc.Encode(head)
+	b := start
+	for i, m := range end.mvs {
+		nb := b.move(m)
+		line := deltaLine{Type: "move", State: nb.stateID(), Step: i + 1, Move: m.code(), Dir: string(m.dir.letter())}
+		for _, pid := range b.pieceIDs() {
+			if !b.movesPiece(m, pid) {
+				continue
+			}
+			p, np := b.ps[pid], nb.ps[pid]
+			line.Changes = append(line.Changes, deltaChange{pid, deltaPlace{p.x, p.y}, deltaPlace{np.x, np.y}})
+		}
+		enc.Encode(line)
+		b = nb
+	}
+	enc.Encode(deltaLine{Type: "end", Goal: describeReached(end.goal, end), Length: len(end.mvs)})
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"encoding/csv"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+)
+
+// Duplicate statistics by depth.
+//
+// A search's skipped count says how many generated positions were
+// duplicates, but not where. With -depth-stats, "solve" also counts for
+// each depth the positions expanded there, the moves generated from them,
+// how many of those reached new positions and how many duplicates (or
+// positions avoided with -avoid), and from those the branching factor
+// before and after removing duplicates. "-depth-stats table" prints them
+// as a table on standard error after the search; any other value names a
+// CSV file to write them to, "-" for standard output. Such searches bypass
+// the results cache.
+
+var depthStatsFlag = flag.String("depth-stats", "",
+	"After the search, print duplicate statistics by depth as a table (\"table\") or write them to this CSV file.")
+
+// Counts by depth, or nil if they're off.
+type depthStatsTable struct {
+	rows []depthRow
+}
+
+// The counts for positions expanded at one depth.
+type depthRow struct {
+	expanded   int
+	generated  int // moves generated from the expanded positions
+	duplicates int // of which reached positions already seen
+}
+
+// The counts of the current run.
+var depthStats *depthStatsTable
+
+// Turns on the counts if -depth-stats is given.
+func openDepthStats() {
+	if *depthStatsFlag != "" {
+		depthStats = &depthStatsTable{}
+	}
+}
+
+// Returns the row for a depth, adding rows as needed.
+func (t *depthStatsTable) row(depth int) *depthRow {
+	for len(t.rows) <= depth {
+		t.rows = append(t.rows, depthRow{})
+	}
+	return &t.rows[depth]
+}
+
+// Counts the expansion of a position at the given depth.
+func (t *depthStatsTable) expand(depth int) {
+	if t != nil {
+		t.row(depth).expanded++
+	}
+}
+
+// Counts a move generated from a position at the given depth.
+func (t *depthStatsTable) generate(depth int, duplicate bool) {
+	if t == nil {
+		return
+	}
+	r := t.row(depth)
+	r.generated++
+	if duplicate {
+		r.duplicates++
+	}
+}
+
+// Returns the ratio of two counts, or 0 if there's nothing to divide by.
+func ratio(n, d int) float64 {
+	if d == 0 {
+		return 0
+	}
+	return float64(n) / float64(d)
+}
+
+// Writes the counts, exiting if that fails.
+func writeDepthStats() {
+	if err := depthStats.write(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(exitError)
+	}
+}
+
+// Writes the counts as selected by -depth-stats.
+func (t *depthStatsTable) write() error {
+	if t == nil {
+		return nil
+	}
+	if *depthStatsFlag == "table" {
+		t.writeTable(os.Stderr)
+		return nil
+	}
+	f, err := createOutput(*depthStatsFlag)
+	if err != nil {
+		return err
+	}
+	w := csv.NewWriter(f)
+	w.Write([]string{"depth", "expanded", "generated", "new", "duplicates", "duplicate_rate", "branching", "effective_branching"})
+	for d, r := range t.rows {
+		w.Write([]string{strconv.Itoa(d), strconv.Itoa(r.expanded), strconv.Itoa(r.generated),
+			strconv.Itoa(r.generated - r.duplicates), strconv.Itoa(r.duplicates),
+			strconv.FormatFloat(ratio(r.duplicates, r.generated), 'f', 3, 64),
+			strconv.FormatFloat(ratio(r.generated, r.expanded), 'f', 3, 64),
+			strconv.FormatFloat(ratio(r.generated-r.duplicates, r.expanded), 'f', 3, 64)})
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Prints the counts as a table.
+func (t *depthStatsTable) writeTable(out io.Writer) {
+	fmt.Fprintf(out, "%5s %9s %10s %9s %10s %6s %9s %9s\n",
+		"depth", "expanded", "generated", "new", "duplicate", "dup%", "branching", "effective")
+	total := depthRow{}
+	for d, r := range t.rows {
+		fmt.Fprintf(out, "%5d %9d %10d %9d %10d %5.1f%% %9.2f %9.2f\n", d, r.expanded, r.generated,
+			r.generated-r.duplicates, r.duplicates, 100*ratio(r.duplicates, r.generated),
+			ratio(r.generated, r.expanded), ratio(r.generated-r.duplicates, r.expanded))
+		total.expanded += r.expanded
+		total.generated += r.generated
+		total.duplicates += r.duplicates
+	}
+	fmt.Fprintf(out, "%5s %9d %10d %9d %10d %5.1f%% %9.2f %9.2f\n", "all", total.expanded, total.generated,
+		total.generated-total.duplicates, total.duplicates, 100*ratio(total.duplicates, total.generated),
+		ratio(total.generated, total.expanded), ratio(total.generated-total.duplicates, total.expanded))
+}
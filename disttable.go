@@ -0,0 +1,325 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"math"
+	"slices"
+)
+
+// Distance tables.
+//
+// A distance table records, for every configuration reachable from a
+// puzzle's starting board, the fewest moves needed to reach the goal. It is
+// built once with a breadth-first search forward from the start, recording
+// each move between configurations, followed by a breadth-first search
+// backward from all of the solved configurations. After that, hints and
+// optimal solutions from any reachable position are simple table lookups.
+//
+// Tables for other goals, such as the goal piece leaving by another exit,
+// can be built alongside, up to 64 of them: the forward search checks each
+// configuration against every goal, and a single backward sweep carries a
+// bit per goal, spreading each node's newly reached goals to its
+// predecessors level by level.
+
+// DistanceTable holds the distance to the goal of every configuration
+// reachable from a starting board.
+type DistanceTable struct {
+	start *Board
+
+	// The node number of each configuration.
+	index map[string]int
+
+	// The fewest moves from each node to the goal, or -1 if the goal can't
+	// be reached from it.
+	dist []int
+}
+
+// The graph of moves between the configurations reachable from a starting
+// board. Nodes are numbered in the order a breadth-first search meets them,
+// so the start is node 0, and there's an edge for every legal move, so two
+// moves between the same configurations make two edges.
+type moveGraph struct {
+	// The node number of each configuration.
+	index map[string]int
+
+	// The nodes each node's moves lead to.
+	succ [][]int32
+
+	// Whether each node satisfies the goal.
+	solved []bool
+
+	// The other goals, if any, and the bits of those each node satisfies.
+	goals     []Goal
+	satisfies []uint64
+}
+
+// The most goals a move graph can track besides the puzzle's own.
+const maxGraphGoals = 64
+
+// Builds the move graph of the puzzle with the given starting board.
+func buildMoveGraph(start *Board) *moveGraph {
+	return buildMoveGraphFor(start, nil)
+}
+
+// Builds the move graph of the puzzle with the given starting board,
+// recording which nodes satisfy each of up to maxGraphGoals other goals.
+func buildMoveGraphFor(start *Board, goals []Goal) *moveGraph {
+	return buildMoveGraphWithin(start, goals, 0)
+}
+
+// Builds the move graph like buildMoveGraphFor, but gives up and returns
+// nil if more than limit configurations are reachable, unless limit is 0.
+func buildMoveGraphWithin(start *Board, goals []Goal, limit int) *moveGraph {
+	g := &moveGraph{index: make(map[string]int), goals: goals}
+	addNode := func(b *Board) int {
+		n := len(g.succ)
+		g.index[b.Config()] = n
+		g.succ = append(g.succ, nil)
+		g.solved = append(g.solved, b.goal.IsSatisfied(b))
+		if len(goals) > 0 {
+			bits := uint64(0)
+			for k, goal := range goals {
+				if goal.IsSatisfied(b) {
+					bits |= 1 << uint(k)
+				}
+			}
+			g.satisfies = append(g.satisfies, bits)
+		}
+		return n
+	}
+
+	root := *start
+	root.mvs = nil
+	bs := []*Board{&root}
+	addNode(&root)
+	for len(bs) > 0 {
+		b := bs[0]
+		bs = bs[1:]
+		n := g.index[b.Config()]
+		for _, m := range b.possibleMoves() {
+			nn, ok := g.index[b.configAfter(m)]
+			if !ok {
+				if limit > 0 && len(g.succ) >= limit {
+					return nil
+				}
+				nb := b.move(m)
+				nb.mvs = nil
+				nn = addNode(nb)
+				bs = append(bs, nb)
+			}
+			g.succ[n] = append(g.succ[n], int32(nn))
+		}
+	}
+	return g
+}
+
+// Builds the distance table for the puzzle with the given starting board.
+func buildDistanceTable(start *Board) *DistanceTable {
+	// Forward: find every reachable configuration and the moves between them.
+	return buildMoveGraph(start).distanceTable(start)
+}
+
+// Builds the distance table for the puzzle with the given starting board,
+// or returns nil if more than limit configurations are reachable.
+func buildDistanceTableWithin(start *Board, limit int) *DistanceTable {
+	g := buildMoveGraphWithin(start, nil, limit)
+	if g == nil {
+		return nil
+	}
+	return g.distanceTable(start)
+}
+
+// Builds the distance table from a move graph of the puzzle with the given
+// starting board.
+func (g *moveGraph) distanceTable(start *Board) *DistanceTable {
+	t := &DistanceTable{start: start, index: g.index, dist: make([]int, len(g.succ))}
+	// The nodes with a move into each node.
+	preds := make([][]int32, len(g.succ))
+	goals := []int{}
+	for n, ns := range g.succ {
+		t.dist[n] = -1
+		for _, nn := range ns {
+			preds[nn] = append(preds[nn], int32(n))
+		}
+		if g.solved[n] {
+			goals = append(goals, n)
+		}
+	}
+
+	// Backward: distances from the goal.
+	q := goals
+	for _, n := range goals {
+		t.dist[n] = 0
+	}
+	for len(q) > 0 {
+		n := q[0]
+		q = q[1:]
+		for _, pn := range preds[n] {
+			if t.dist[pn] < 0 {
+				t.dist[pn] = t.dist[n] + 1
+				q = append(q, int(pn))
+			}
+		}
+	}
+	return t
+}
+
+// Builds the distance tables to the graph's other goals in one backward
+// sweep, in the order of its goals. Each table's boards keep the puzzle's
+// own goal; only the distances differ.
+func (g *moveGraph) distanceTables(start *Board) []*DistanceTable {
+	preds := make([][]int32, len(g.succ))
+	for n, ns := range g.succ {
+		for _, nn := range ns {
+			preds[nn] = append(preds[nn], int32(n))
+		}
+	}
+	ts := make([]*DistanceTable, len(g.goals))
+	for k := range ts {
+		ts[k] = &DistanceTable{start: start, index: g.index, dist: make([]int, len(g.succ))}
+		for n := range ts[k].dist {
+			ts[k].dist[n] = -1
+		}
+	}
+	// The goals each node has been reached from, and of those, the ones
+	// first reached at the current distance and at the next.
+	reached := make([]uint64, len(g.succ))
+	fresh := make([]uint64, len(g.succ))
+	nextFresh := make([]uint64, len(g.succ))
+	level := []int32{}
+	for n, bits := range g.satisfies {
+		if bits != 0 {
+			reached[n], fresh[n] = bits, bits
+			level = append(level, int32(n))
+		}
+	}
+	for d := 0; len(level) > 0; d++ {
+		next := []int32{}
+		for _, n := range level {
+			bits := fresh[n]
+			fresh[n] = 0
+			for k := range ts {
+				if bits&(1<<uint(k)) != 0 {
+					ts[k].dist[n] = d
+				}
+			}
+			for _, pn := range preds[n] {
+				if nb := bits &^ reached[pn]; nb != 0 {
+					if nextFresh[pn] == 0 {
+						next = append(next, pn)
+					}
+					reached[pn] |= nb
+					nextFresh[pn] |= nb
+				}
+			}
+		}
+		level = next
+		fresh, nextFresh = nextFresh, fresh
+	}
+	return ts
+}
+
+// The number of configurations in the table.
+func (t *DistanceTable) Size() int {
+	return len(t.dist)
+}
+
+// The fewest moves needed to solve the given board, or -1 if it can't be
+// solved. The board must be reachable from the table's starting board.
+func (t *DistanceTable) Distance(b *Board) (int, error) {
+	n, ok := t.index[b.Config()]
+	if !ok {
+		return 0, fmt.Errorf("position isn't reachable from the puzzle's start")
+	}
+	return t.dist[n], nil
+}
+
+// Hint returns an optimal next move for the given board along with the
+// board's distance to the goal.
+func (t *DistanceTable) Hint(b *Board) (Move, int, error) {
+	d, err := t.Distance(b)
+	if err != nil {
+		return Move{}, 0, err
+	}
+	if d < 0 {
+		return Move{}, d, fmt.Errorf("position can't be solved")
+	}
+	if d == 0 {
+		return Move{}, d, fmt.Errorf("position is already solved")
+	}
+	for _, m := range b.possibleMoves() {
+		if nd, err := t.Distance(b.move(m)); err == nil && nd == d-1 {
+			return m, d, nil
+		}
+	}
+	// Unreachable for a consistent table.
+	return Move{}, d, fmt.Errorf("no move brings the position closer to the goal")
+}
+
+// A legal move from a position, with the distance to the goal it leaves.
+type RankedMove struct {
+	Move     Move
+	Distance int // after the move, or -1 if the goal can't be reached
+}
+
+// Quality says how a move from a position at distance d to the goal
+// changes that distance: "best" if it brings the position closer,
+// "neutral" if it keeps the distance, and "harmful" if it adds to it or
+// leaves the goal out of reach.
+func (r RankedMove) Quality(d int) string {
+	switch {
+	case r.Distance < 0 || r.Distance > d:
+		return "harmful"
+	case r.Distance == d:
+		return "neutral"
+	}
+	return "best"
+}
+
+// RankMoves returns every legal move from the given board ranked by the
+// distance to the goal it leaves, best first, and moves that leave the goal
+// out of reach last.
+func (t *DistanceTable) RankMoves(b *Board) ([]RankedMove, error) {
+	if _, err := t.Distance(b); err != nil {
+		return nil, err
+	}
+	rms := []RankedMove{}
+	for _, m := range b.possibleMoves() {
+		d, err := t.Distance(b.move(m))
+		if err != nil {
+			return nil, err
+		}
+		rms = append(rms, RankedMove{m, d})
+	}
+	sortRankedMoves(rms)
+	return rms, nil
+}
+
+// Sorts moves best first, keeping moves that leave the goal at the same
+// distance in their order.
+func sortRankedMoves(rms []RankedMove) {
+	key := func(r RankedMove) int {
+		if r.Distance < 0 {
+			return math.MaxInt
+		}
+		return r.Distance
+	}
+	slices.SortStableFunc(rms, func(a, b RankedMove) int { return cmp.Compare(key(a), key(b)) })
+}
+
+// Solve returns an optimal sequence of moves solving the given board.
+func (t *DistanceTable) Solve(b *Board) ([]Move, error) {
+	mvs := []Move{}
+	for {
+		if d, err := t.Distance(b); err != nil || d == 0 {
+			return mvs, err
+		}
+		m, _, err := t.Hint(b)
+		if err != nil {
+			return nil, err
+		}
+		mvs = append(mvs, m)
+		b = b.move(m)
+	}
+}
@@ -0,0 +1,372 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+)
+
+// Puzzle editing.
+//
+// "squareroot edit" shows the board and reads editing commands, one per
+// line, and after every change says whether the puzzle can still be solved
+// and in how few moves:
+//
+//	add <id> <w>x<h> <x> <y>  add a piece
+//	rm <id>                   remove a piece
+//	resize <id> <w>x<h>       change a piece's size, keeping its top left
+//	place <id> <x> <y>        put a piece somewhere else
+//	wall <x> <y>              add or remove a wall
+//	goal <goal>               set the goal, e.g. "goal b 1 3"
+//	u, undo                   undo the last change
+//	save <file>               save the puzzle as a puzzle file
+//	?, help                   list the commands
+//	q, quit                   stop editing
+//
+// Changes that would leave the board invalid, such as overlapping pieces,
+// are refused. The analysis runs the pre-checks and then the solver, which
+// honors -timeout; results are remembered by board code, so undoing a
+// change or returning to an earlier layout is instant, and with -cache they
+// also come from and go to the results cache.
+//
+// While the user thinks about the next change, the editor builds the
+// distance table of the layout just analyzed in the background, if it has
+// at most editTableLimit reachable configurations. A table answers for
+// every configuration reachable from its layout, so once it's built,
+// moving a piece somewhere the puzzle's moves could also have put it, such
+// as a 1x1 piece around the open space, is a table lookup rather than a new
+// search. Only a table already covering the new layout is reused: any
+// other edit, such as removing a piece or moving one where the moves can't
+// take it, changes which configurations connect, so none of a table's
+// distances can be trusted for it and the layout is searched afresh. The
+// last few tables are kept.
+
+const editHelp = `Commands:
+  add <id> <w>x<h> <x> <y>  add a piece
+  rm <id>                   remove a piece
+  resize <id> <w>x<h>       change a piece's size, keeping its top left
+  place <id> <x> <y>        put a piece somewhere else
+  wall <x> <y>              add or remove a wall
+  goal <goal>               set the goal, e.g. "goal b 1 3"
+  u, undo                   undo the last change
+  save <file>               save the puzzle as a puzzle file
+  ?, help                   list the commands
+  q, quit                   stop editing
+`
+
+// An editing session.
+type editor struct {
+	b *Board
+
+	// The boards before each change, for undo.
+	history []*Board
+
+	// The analysis of each layout seen, by board code.
+	results map[string]string
+
+	// The distance tables of recently analyzed layouts, newest first, and
+	// whether one is being built.
+	mu       sync.Mutex
+	tables   []*DistanceTable
+	building bool
+}
+
+// The most configurations a layout may have for the editor to build its
+// distance table, and the number of tables kept.
+const (
+	editTableLimit = 200000
+	editTables     = 8
+)
+
+// Runs "edit".
+func runEdit(start *Board) {
+	e := &editor{b: start.clone(), results: make(map[string]string)}
+	edit(e, os.Stdin, os.Stdout)
+}
+
+// Edits a puzzle, reading commands from in and writing boards to out.
+func edit(e *editor, in io.Reader, out io.Writer) {
+	scanner := bufio.NewScanner(in)
+	fmt.Fprint(out, editHelp)
+	for {
+		fmt.Fprint(out, e.b.display())
+		fmt.Fprintf(out, "Goal: %v\n", e.b.goal)
+		fmt.Fprintln(out, e.analysis())
+		fmt.Fprint(out, "> ")
+		if !scanner.Scan() {
+			fmt.Fprintln(out)
+			return
+		}
+		args := strings.Fields(scanner.Text())
+		if len(args) == 0 {
+			continue
+		}
+		switch cmd := strings.ToLower(args[0]); cmd {
+		case "q", "quit", "exit":
+			return
+		case "?", "help":
+			fmt.Fprint(out, editHelp)
+		case "u", "undo":
+			if len(e.history) == 0 {
+				fmt.Fprintln(out, "Nothing to undo.")
+				continue
+			}
+			e.b = e.history[len(e.history)-1]
+			e.history = e.history[:len(e.history)-1]
+		case "save":
+			if len(args) != 2 {
+				fmt.Fprintln(out, "usage: save <file>")
+				continue
+			}
+			if err := e.save(args[1]); err != nil {
+				fmt.Fprintf(out, "Couldn't save: %v\n", err)
+			} else {
+				fmt.Fprintf(out, "Saved to %s\n", args[1])
+			}
+		default:
+			if err := e.change(cmd, args[1:]); err != nil {
+				fmt.Fprintf(out, "%v. Type ? for help.\n", err)
+			}
+		}
+	}
+}
+
+// Applies an editing command to a copy of the board, and keeps the copy if
+// it's valid.
+func (e *editor) change(cmd string, args []string) error {
+	b := e.b.clone()
+	var err error
+	switch cmd {
+	case "add":
+		err = b.editAdd(args)
+	case "rm":
+		err = b.editRemove(args)
+	case "resize":
+		err = b.editResize(args)
+	case "place":
+		err = b.editPlace(args)
+	case "wall":
+		err = b.editWall(args)
+	case "goal":
+		var g Goal
+		if g, err = b.parseGoal(args); err == nil {
+			b.goal = g
+		}
+	default:
+		return fmt.Errorf("unknown command %q", cmd)
+	}
+	if err == nil {
+		err = b.validate()
+	}
+	if err != nil {
+		return err
+	}
+	e.history = append(e.history, e.b)
+	e.b = b
+	return nil
+}
+
+func (b *Board) editAdd(args []string) error {
+	if len(args) != 4 {
+		return fmt.Errorf("usage: add <id> <w>x<h> <x> <y>")
+	}
+	id := args[0]
+	if len(id) != 1 || !isPieceID(id[0]) {
+		return fmt.Errorf("piece ids are single letters or digits, not %q", id)
+	}
+	if _, ok := b.ps[id]; ok {
+		return fmt.Errorf("there's already a piece %s", id)
+	}
+	p := Piece{id: id}
+	if _, err := fmt.Sscanf(args[1], "%dx%d", &p.w, &p.h); err != nil {
+		return fmt.Errorf("invalid size %q", args[1])
+	}
+	s, err := b.parseSpace(args[2], args[3])
+	if err != nil {
+		return err
+	}
+	p.x, p.y = s.x, s.y
+	b.ps[id] = p
+	return nil
+}
+
+func (b *Board) editRemove(args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: rm <id>")
+	}
+	if _, ok := b.ps[args[0]]; !ok {
+		return fmt.Errorf("no piece %s", args[0])
+	}
+	if b.isGoalPiece(args[0]) {
+		return fmt.Errorf("the goal names piece %s; change the goal first", args[0])
+	}
+	if b.links[args[0]] != nil {
+		return fmt.Errorf("piece %s is linked to others", args[0])
+	}
+	delete(b.ps, args[0])
+	return nil
+}
+
+func (b *Board) editResize(args []string) error {
+	if len(args) != 2 {
+		return fmt.Errorf("usage: resize <id> <w>x<h>")
+	}
+	p, ok := b.ps[args[0]]
+	if !ok {
+		return fmt.Errorf("no piece %s", args[0])
+	}
+	if _, err := fmt.Sscanf(args[1], "%dx%d", &p.w, &p.h); err != nil {
+		return fmt.Errorf("invalid size %q", args[1])
+	}
+	b.ps[p.id] = p
+	return nil
+}
+
+func (b *Board) editPlace(args []string) error {
+	if len(args) != 3 {
+		return fmt.Errorf("usage: place <id> <x> <y>")
+	}
+	p, ok := b.ps[args[0]]
+	if !ok {
+		return fmt.Errorf("no piece %s", args[0])
+	}
+	s, err := b.parseSpace(args[1], args[2])
+	if err != nil {
+		return err
+	}
+	p.x, p.y = s.x, s.y
+	b.ps[p.id] = p
+	return nil
+}
+
+func (b *Board) editWall(args []string) error {
+	if len(args) != 2 {
+		return fmt.Errorf("usage: wall <x> <y>")
+	}
+	s, err := b.parseSpace(args[0], args[1])
+	if err != nil {
+		return err
+	}
+	if b.walls[s] {
+		delete(b.walls, s)
+	} else {
+		b.walls[s] = true
+	}
+	return nil
+}
+
+// Returns a copy of the board with its own pieces and walls, and no moves,
+// that can be edited without changing the original.
+func (b *Board) clone() *Board {
+	nb := *b
+	nb.ps = make(map[string]Piece)
+	for pid, p := range b.ps {
+		nb.ps[pid] = p
+	}
+	nb.walls = make(map[Space]bool)
+	for s := range b.walls {
+		nb.walls[s] = true
+	}
+	nb.mvs = []Move{}
+	return &nb
+}
+
+// Returns whether the board can be solved and in how few moves, from the
+// remembered results if the layout has been seen before, or from a kept
+// distance table that covers it.
+func (e *editor) analysis() string {
+	code, err := e.b.Encode()
+	if err == nil {
+		if r, ok := e.results[code]; ok {
+			return r
+		}
+		if r, ok := e.tableAnalysis(); ok {
+			return r
+		}
+	}
+	r, final := analyzeEdit(e.b)
+	if err == nil && final {
+		e.results[code] = r
+		e.buildTable(e.b)
+	}
+	return r
+}
+
+// Analyzes the board with a kept distance table that covers it, reporting
+// false if there's none.
+func (e *editor) tableAnalysis() (string, bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	var t *DistanceTable
+	for i, kt := range e.tables {
+		if _, err := kt.Distance(e.b); err == nil && kt.start.samePuzzle(e.b) {
+			t = kt
+			e.tables = append(e.tables[:i], e.tables[i+1:]...)
+			break
+		}
+	}
+	if t == nil {
+		return "", false
+	}
+	e.tables = append([]*DistanceTable{t}, e.tables...)
+	switch d, _ := t.Distance(e.b); d {
+	case -1:
+		return "Unsolvable: no solved configuration can be reached.", true
+	case 0:
+		return "Already solved.", true
+	default:
+		return fmt.Sprintf("Solvable in %d moves.", d), true
+	}
+}
+
+// Starts building the board's distance table in the background, unless
+// another is being built or the board has pre-check findings.
+func (e *editor) buildTable(b *Board) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.building || precheck(b) != nil {
+		return
+	}
+	e.building = true
+	go func() {
+		t := buildDistanceTableWithin(b, editTableLimit)
+		e.mu.Lock()
+		defer e.mu.Unlock()
+		if t != nil {
+			e.tables = append([]*DistanceTable{t}, e.tables[:min(len(e.tables), editTables-1)]...)
+		}
+		e.building = false
+	}()
+}
+
+// Analyzes a board, returning the verdict and whether it's final rather
+// than cut short by -timeout.
+func analyzeEdit(b *Board) (string, bool) {
+	if r := precheck(b); r != nil {
+		if r.solved {
+			return "Already solved.", true
+		}
+		return fmt.Sprintf("Unsolvable: pre-check %s fired: %s.", r.check, r.message), true
+	}
+	startDeadline()
+	end, stats := findSolution(b)
+	switch {
+	case end != nil:
+		return fmt.Sprintf("Solvable in %d moves.", len(end.mvs)), true
+	case searchExpired():
+		return fmt.Sprintf("Unknown: gave up after %v (%d configurations searched).", *timeout, stats.Configs), false
+	}
+	return fmt.Sprintf("Unsolvable: no solution among the %d reachable configurations.", stats.Configs), true
+}
+
+// Saves the puzzle as a puzzle file.
+func (e *editor) save(name string) error {
+	text, err := e.b.puzzleFile()
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(name, []byte(text), 0644)
+}
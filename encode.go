@@ -0,0 +1,296 @@
+package main
+
+import (
+	"encoding/base64"
+	"fmt"
+	"sort"
+)
+
+// Board codes.
+//
+// A board code is a short URL-safe string encoding a board position and its
+// rules (size, pieces, one-way cells, linked pieces, goal, walls, rails and
+// constraint) but not the moves taken to reach it. Codes are printed with
+// solutions and accepted anywhere a puzzle file is, so positions can be
+// shared in chat or issue reports.
+//
+// The code is the unpadded URL-safe base64 of a byte string starting with a
+// format version. All numbers are single bytes.
+
+// Version 2 added walls, version 3 rails, and version 4 goal expressions and
+// constraints. Boards are written with the oldest version that holds them,
+// so the codes of boards without the newer rules are unchanged.
+const boardCodeVersion = 4
+
+// Goal encoding tags.
+const (
+	goalPiece byte = iota
+	goalShape
+	goalAll
+	goalAny
+	goalExpr
+)
+
+// Encode returns the board code for this board.
+func (b *Board) Encode() (string, error) {
+	bs, err := b.codeBytes()
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(bs), nil
+}
+
+// Returns the bytes of the board's code, before base64 encoding.
+func (b *Board) codeBytes() ([]byte, error) {
+	if len(b.filters) > 0 {
+		return nil, fmt.Errorf("can't encode move rules")
+	}
+	bs := []byte{2, byte(b.w), byte(b.h), 0}
+	// Board flags: 1 for a torus, 2 for rails, 4 for a constraint.
+	if b.wrap {
+		bs[3] |= 1
+	}
+	if b.rails {
+		bs[0], bs[3] = 3, bs[3]|2
+	}
+	if b.constraint != nil {
+		bs[3] |= 4
+	}
+	if b.constraint != nil || hasExpr(b.goal) {
+		bs[0] = 4
+	}
+
+	pids := b.pieceIDs()
+	bs = append(bs, byte(len(pids)))
+	for _, pid := range pids {
+		p := b.ps[pid]
+		bs = append(bs, p.id[0], byte(p.w), byte(p.h), byte(p.x), byte(p.y))
+	}
+
+	ss := []Space{}
+	for s := range b.oneway {
+		ss = append(ss, s)
+	}
+	sortSpaces(ss)
+	bs = append(bs, byte(len(ss)))
+	for _, s := range ss {
+		bs = append(bs, byte(s.x), byte(s.y), byte(b.oneway[s]))
+	}
+
+	gs := [][]string{}
+	for _, pid := range pids {
+		if g := b.links[pid]; g != nil && g[0] == pid {
+			gs = append(gs, g)
+		}
+	}
+	bs = append(bs, byte(len(gs)))
+	for _, g := range gs {
+		bs = append(bs, byte(len(g)))
+		for _, pid := range g {
+			bs = append(bs, pid[0])
+		}
+	}
+
+	bs, err := appendGoal(bs, b.goal)
+	if err != nil {
+		return nil, err
+	}
+
+	ws := []Space{}
+	for s := range b.walls {
+		ws = append(ws, s)
+	}
+	sortSpaces(ws)
+	bs = append(bs, byte(len(ws)))
+	for _, s := range ws {
+		bs = append(bs, byte(s.x), byte(s.y))
+	}
+
+	if b.constraint != nil {
+		if bs, err = appendGoal(bs, b.constraint); err != nil {
+			return nil, err
+		}
+	}
+	return bs, nil
+}
+
+func appendGoal(bs []byte, g Goal) ([]byte, error) {
+	var gs []Goal
+	switch g := g.(type) {
+	case Condition:
+		if g.pid != "" {
+			return append(bs, goalPiece, g.pid[0], byte(g.x), byte(g.y)), nil
+		}
+		return append(bs, goalShape, byte(g.w), byte(g.h), byte(g.x), byte(g.y)), nil
+	case Expr:
+		// The expression's text, which is read back against the board.
+		t := g.String()
+		if len(t) > 255 {
+			return nil, fmt.Errorf("can't encode goal %v: too long", g)
+		}
+		return append(append(bs, goalExpr, byte(len(t))), t...), nil
+	case AllOf:
+		bs = append(bs, goalAll, byte(len(g)))
+		gs = g
+	case AnyOf:
+		bs = append(bs, goalAny, byte(len(g)))
+		gs = g
+	default:
+		return nil, fmt.Errorf("can't encode goal %v", g)
+	}
+	for _, sg := range gs {
+		var err error
+		if bs, err = appendGoal(bs, sg); err != nil {
+			return nil, err
+		}
+	}
+	return bs, nil
+}
+
+// Decode returns the board described by a board code.
+func Decode(code string) (*Board, error) {
+	bs, err := base64.RawURLEncoding.DecodeString(code)
+	if err != nil {
+		return nil, fmt.Errorf("invalid board code: %v", err)
+	}
+	return decodeBytes(bs)
+}
+
+// Returns the board described by the bytes of a board code.
+func decodeBytes(bs []byte) (*Board, error) {
+	d := &decoder{bs: bs}
+	v := d.next()
+	if v < 1 || v > boardCodeVersion {
+		return nil, fmt.Errorf("unsupported board code version %d", v)
+	}
+	b := &Board{w: d.int(), h: d.int(), ps: make(map[string]Piece), mvs: []Move{}}
+	flags := d.next()
+	b.wrap = flags&1 != 0
+	b.rails = flags&2 != 0
+	hasConstraint := flags&4 != 0
+
+	for n := d.int(); n > 0 && d.err == nil; n-- {
+		p := Piece{string(d.next()), d.int(), d.int(), d.int(), d.int()}
+		b.ps[p.id] = p
+	}
+
+	for n := d.int(); n > 0 && d.err == nil; n-- {
+		if b.oneway == nil {
+			b.oneway = make(map[Space]DirSet)
+		}
+		s := Space{d.int(), d.int()}
+		b.oneway[s] = DirSet(d.next())
+	}
+
+	for n := d.int(); n > 0 && d.err == nil; n-- {
+		g := []string{}
+		for m := d.int(); m > 0 && d.err == nil; m-- {
+			g = append(g, string(d.next()))
+		}
+		if d.err == nil {
+			if err := b.link(g); err != nil {
+				return nil, fmt.Errorf("invalid board code: %v", err)
+			}
+		}
+	}
+
+	b.goal = d.goal(b)
+
+	if v >= 2 {
+		for n := d.int(); n > 0 && d.err == nil; n-- {
+			if b.walls == nil {
+				b.walls = make(map[Space]bool)
+			}
+			b.walls[Space{d.int(), d.int()}] = true
+		}
+	}
+
+	if hasConstraint {
+		b.constraint = d.goal(b)
+	}
+
+	if d.err == nil && d.pos != len(d.bs) {
+		d.err = fmt.Errorf("%d trailing bytes", len(d.bs)-d.pos)
+	}
+	if d.err != nil {
+		return nil, fmt.Errorf("invalid board code: %v", d.err)
+	}
+	return b, nil
+}
+
+// decoder reads the bytes of a board code, remembering the first error.
+type decoder struct {
+	bs  []byte
+	pos int
+	err error
+}
+
+func (d *decoder) next() byte {
+	if d.pos >= len(d.bs) {
+		if d.err == nil {
+			d.err = fmt.Errorf("code too short")
+		}
+		return 0
+	}
+	d.pos++
+	return d.bs[d.pos-1]
+}
+
+func (d *decoder) int() int {
+	return int(d.next())
+}
+
+// Reads a goal, naming the pieces of the given board.
+func (d *decoder) goal(b *Board) Goal {
+	switch tag := d.next(); tag {
+	case goalPiece:
+		return Condition{pid: string(d.next()), x: d.int(), y: d.int()}
+	case goalShape:
+		return Condition{w: d.int(), h: d.int(), x: d.int(), y: d.int()}
+	case goalAll, goalAny:
+		gs := []Goal{}
+		for n := d.int(); n > 0 && d.err == nil; n-- {
+			gs = append(gs, d.goal(b))
+		}
+		if tag == goalAll {
+			return AllOf(gs)
+		}
+		return AnyOf(gs)
+	case goalExpr:
+		n := d.int()
+		if d.err == nil && d.pos+n > len(d.bs) {
+			d.err = fmt.Errorf("code too short")
+		}
+		if d.err != nil {
+			return nil
+		}
+		e, err := b.parseExpr(string(d.bs[d.pos : d.pos+n]))
+		d.pos += n
+		if err != nil && d.err == nil {
+			d.err = err
+		}
+		return e
+	default:
+		if d.err == nil {
+			d.err = fmt.Errorf("unknown goal type %d", tag)
+		}
+		return nil
+	}
+}
+
+// Sorts spaces in reading order.
+func sortSpaces(ss []Space) {
+	sort.Slice(ss, func(i, j int) bool {
+		return ss[i].y < ss[j].y || ss[i].y == ss[j].y && ss[i].x < ss[j].x
+	})
+}
+
+// The ids of this board's pieces, in order.
+func (b *Board) pieceIDs() []string {
+	pids := []string{}
+	for pid := range b.ps {
+		pids = append(pids, pid)
+	}
+	sort.Strings(pids)
+	return pids
+}
@@ -0,0 +1,205 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"math/rand"
+	"os"
+	"time"
+)
+
+// Heuristic evaluation.
+//
+// A* finds optimal solutions only if its heuristic never overestimates the
+// moves left, and its speed depends on how close the estimates come.
+// "squareroot eval-heuristic [heuristic...]" measures both against the
+// truth: it builds the puzzle's distance table and compares each
+// heuristic's estimate with the exact distance over every reachable
+// configuration, or a random -samples of them, reporting
+//
+//   - the mean estimate and the mean and largest error (exact minus
+//     estimate) over the solvable configurations,
+//   - overestimates, configurations where the estimate exceeds the exact
+//     distance, which make A* miss optimal solutions,
+//   - inconsistent configurations, with a move that lowers the estimate by
+//     more than one, after which A* may have to expand a configuration
+//     again,
+//   - and the configurations A* expands solving from the start, and the
+//     length it finds.
+//
+// It exits with status 1 if any heuristic overestimates. A new heuristic
+// goes in the heuristics list and must pass here before A* relies on it.
+
+var evalFlags = flag.NewFlagSet("eval-heuristic", flag.ContinueOnError)
+
+var (
+	evalSamples = evalFlags.Int("samples", 0, "Number of random configurations to compare; 0 compares them all.")
+	evalSeed    = evalFlags.Int64("seed", 0, "Random seed for -samples; 0 picks one from the clock.")
+)
+
+// A heuristic estimate of the moves left to solve a board.
+type namedHeuristic struct {
+	name     string
+	about    string
+	estimate func(*Board) int
+}
+
+var heuristics = []namedHeuristic{
+	{"goal", "the goal's estimate, used by -astar", func(b *Board) int { return b.goal.Heuristic(b) }},
+	{"zero", "no estimate, as a baseline", func(*Board) int { return 0 }},
+}
+
+// How a heuristic compares with the exact distances.
+type heuristicEval struct {
+	h                     namedHeuristic
+	solvable              int
+	sumEstimate, sumErr   int
+	maxErr                int
+	over, inconsistent    int
+	worstOver             string // the configuration overestimated the most
+	worstEstimate, worstD int
+}
+
+// Compares the heuristic's estimate for a board with its exact distance.
+func (e *heuristicEval) add(b *Board, d int) {
+	h := e.h.estimate(b)
+	for _, m := range b.possibleMoves() {
+		if h-e.h.estimate(b.move(m)) > 1 {
+			e.inconsistent++
+			break
+		}
+	}
+	if d < 0 {
+		return
+	}
+	e.solvable++
+	e.sumEstimate += h
+	e.sumErr += d - h
+	e.maxErr = max(e.maxErr, d-h)
+	if h > d {
+		if e.over == 0 || h-d > e.worstEstimate-e.worstD {
+			e.worstOver, e.worstEstimate, e.worstD = b.stateID(), h, d
+		}
+		e.over++
+	}
+}
+
+// Runs "eval-heuristic [heuristic...]".
+func runEvalHeuristic(start *Board, args []string) {
+	chosen := heuristics
+	if len(args) > 0 {
+		chosen = nil
+		for _, name := range args {
+			h, ok := lookupHeuristic(name)
+			if !ok {
+				fmt.Fprintf(os.Stderr, "Unknown heuristic %q; choose from:\n", name)
+				for _, h := range heuristics {
+					fmt.Fprintf(os.Stderr, "  %-6s %s\n", h.name, h.about)
+				}
+				os.Exit(exitInvalid)
+			}
+			chosen = append(chosen, h)
+		}
+	}
+	if *evalSamples < 0 {
+		fmt.Fprintln(os.Stderr, "-samples can't be negative")
+		os.Exit(exitInvalid)
+	}
+
+	fmt.Fprintln(os.Stderr, "Building distance table...")
+	g := buildMoveGraph(start)
+	t := g.distanceTable(start)
+	sampled := func(int) bool { return true }
+	if n := *evalSamples; n > 0 && n < t.Size() {
+		seed := *evalSeed
+		if seed == 0 {
+			seed = time.Now().UnixNano()
+		}
+		in := make([]bool, t.Size())
+		for _, node := range rand.New(rand.NewSource(seed)).Perm(t.Size())[:n] {
+			in[node] = true
+		}
+		sampled = func(node int) bool { return in[node] }
+	}
+
+	// Visit the configurations again, breadth-first, to have their boards.
+	evals := make([]*heuristicEval, len(chosen))
+	for i, h := range chosen {
+		evals[i] = &heuristicEval{h: h}
+	}
+	compared := 0
+	seen := make([]bool, t.Size())
+	root := *start
+	root.mvs = nil
+	seen[0] = true
+	for bs := []*Board{&root}; len(bs) > 0; bs = bs[1:] {
+		b := bs[0]
+		n := g.index[b.Config()]
+		if sampled(n) {
+			compared++
+			for _, e := range evals {
+				e.add(b, t.dist[n])
+			}
+		}
+		for _, nn := range g.succ[n] {
+			if !seen[nn] {
+				seen[nn] = true
+				for _, m := range b.possibleMoves() {
+					if g.index[b.configAfter(m)] == int(nn) {
+						nb := b.move(m)
+						nb.mvs = nil
+						bs = append(bs, nb)
+						break
+					}
+				}
+			}
+		}
+	}
+
+	what := fmt.Sprintf("all %d reachable configurations", t.Size())
+	if compared < t.Size() {
+		what = fmt.Sprintf("a sample of %d of the %d reachable configurations", compared, t.Size())
+	}
+	fmt.Printf("Compared with the exact distances of %s.\n", what)
+	if t.dist[0] >= 0 {
+		fmt.Printf("The start is %s from the goal.\n", countOf(t.dist[0], "move"))
+	}
+	fmt.Printf("%-9s %8s %10s %9s %13s %12s %12s %9s\n",
+		"heuristic", "mean h", "mean error", "max error", "overestimates", "inconsistent", "A* expanded", "A* length")
+	failed := false
+	for _, e := range evals {
+		meanH, meanErr := "-", "-"
+		if e.solvable > 0 {
+			meanH = fmt.Sprintf("%.2f", float64(e.sumEstimate)/float64(e.solvable))
+			meanErr = fmt.Sprintf("%.2f", float64(e.sumErr)/float64(e.solvable))
+		}
+		startDeadline()
+		end, stats := solveAStarWith(start, e.h.estimate)
+		length := "-"
+		if end != nil {
+			length = fmt.Sprint(len(end.mvs) - len(start.mvs))
+		}
+		fmt.Printf("%-9s %8s %10s %9d %13d %12d %12d %9s\n",
+			e.h.name, meanH, meanErr, e.maxErr, e.over, e.inconsistent, stats.Expanded, length)
+		failed = failed || e.over > 0
+	}
+	for _, e := range evals {
+		if e.over > 0 {
+			fmt.Printf("%s overestimates %d configurations, by the most at %s: estimate %d, exact %d.\n",
+				e.h.name, e.over, e.worstOver, e.worstEstimate, e.worstD)
+		}
+	}
+	if failed {
+		os.Exit(exitError)
+	}
+}
+
+// Returns the named heuristic.
+func lookupHeuristic(name string) (namedHeuristic, bool) {
+	for _, h := range heuristics {
+		if h.name == name {
+			return h, true
+		}
+	}
+	return namedHeuristic{}, false
+}
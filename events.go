@@ -0,0 +1,107 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// Search events.
+//
+// With -events jsonl, "solve" writes one JSON object per line to standard
+// error, or to the -events-out file, as the search goes, so dashboards can
+// follow long batch runs:
+//
+//	{"event":"start","algorithm":"bfs","puzzle":"AgQF...",...}
+//	{"event":"depth","depth":12,"configurations":851,"frontier":120,"skipped":1290,...}
+//	{"event":"stats","configurations":90210,"frontier":5120,"skipped":200117,...}
+//	{"event":"solution","length":116,"configurations":24037,"skipped":53799,...}
+//
+// Every event also has the time and the seconds elapsed since the start.
+// Breadth-first searches report each depth as they complete it, all searches
+// report a stats snapshot every second, and the run ends with a solution,
+// unsolvable or timeout event.
+
+var eventsFlag = flag.String("events", "",
+	"Emit search events in this format: jsonl (one JSON object per line).")
+
+var eventsOut = flag.String("events-out", "",
+	"File to write -events to, instead of standard error.")
+
+// How often searches report a stats snapshot.
+const statsInterval = time.Second
+
+// An event stream, or nil if events are off.
+type eventLog struct {
+	w         io.Writer
+	start     time.Time
+	lastStats time.Time
+}
+
+// The event stream of the current run.
+var events *eventLog
+
+// Opens the event stream selected by -events and -events-out.
+func openEvents() error {
+	switch *eventsFlag {
+	case "":
+		return nil
+	case "jsonl":
+	default:
+		return fmt.Errorf("unknown -events format %q: want jsonl", *eventsFlag)
+	}
+	var w io.Writer = os.Stderr
+	if *eventsOut != "" {
+		f, err := createOutput(*eventsOut)
+		if err != nil {
+			return err
+		}
+		w = f
+	}
+	now := time.Now()
+	events = &eventLog{w: w, start: now, lastStats: now}
+	return nil
+}
+
+// Writes an event with the given fields.
+func (l *eventLog) emit(kind string, fields map[string]any) {
+	if l == nil {
+		return
+	}
+	now := time.Now()
+	e := map[string]any{
+		"event":   kind,
+		"time":    now.UTC().Format(time.RFC3339Nano),
+		"elapsed": now.Sub(l.start).Seconds(),
+	}
+	for k, v := range fields {
+		e[k] = v
+	}
+	json.NewEncoder(l.w).Encode(e)
+}
+
+// Writes a stats event if a snapshot is due.
+func (l *eventLog) snapshot(configs, frontier, skipped int) {
+	if l == nil || time.Since(l.lastStats) < statsInterval {
+		return
+	}
+	l.lastStats = time.Now()
+	l.emit("stats", searchFields(configs, frontier, skipped))
+}
+
+// Writes the event for a completed search depth.
+func (l *eventLog) depth(depth, configs, frontier, skipped int) {
+	if l == nil {
+		return
+	}
+	f := searchFields(configs, frontier, skipped)
+	f["depth"] = depth
+	l.emit("depth", f)
+}
+
+func searchFields(configs, frontier, skipped int) map[string]any {
+	return map[string]any{"configurations": configs, "frontier": frontier, "skipped": skipped}
+}
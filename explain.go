@@ -0,0 +1,86 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Move explanations.
+//
+// Explanations describe a move in words and say what it achieves: which
+// pieces it frees to move in new directions, and whether it brings a goal
+// piece closer to where it has to go. The tutorial shows one for each step
+// of a solution.
+
+// Names a piece by its shape, e.g. "the tall piece a".
+func (b *Board) pieceName(pid string) string {
+	p, ok := b.ps[pid]
+	if !ok {
+		return "piece " + pid
+	}
+	switch {
+	case p.w == 1 && p.h == 1:
+		return "the small piece " + pid
+	case p.w == 1:
+		return "the tall piece " + pid
+	case p.h == 1:
+		return "the wide piece " + pid
+	case p.w == p.h:
+		return fmt.Sprintf("the big square piece %s", pid)
+	}
+	return fmt.Sprintf("the %dx%d piece %s", p.w, p.h, pid)
+}
+
+// Explains what the given move does on this board.
+func (b *Board) explainMove(m Move) string {
+	nb := b.move(m)
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Move %s %s.", b.pieceName(m.pid), strings.ToLower(m.dir.String()))
+
+	if b.isGoalPiece(m.pid) {
+		before, after := b.goal.Heuristic(b), nb.goal.Heuristic(nb)
+		switch {
+		case nb.goal.IsSatisfied(nb):
+			sb.WriteString(" That reaches the goal!")
+		case after < before:
+			sb.WriteString(" That brings it closer to the goal.")
+		case after > before:
+			sb.WriteString(" It has to move away from the goal for now.")
+		}
+	}
+
+	// Pieces the move lets move in directions they couldn't before.
+	was := make(map[Move]bool)
+	for _, pm := range b.possibleMoves() {
+		was[pm] = true
+	}
+	freed := make(map[string][]string)
+	for _, pm := range nb.possibleMoves() {
+		if !was[pm] && pm.pid != m.pid {
+			freed[pm.pid] = append(freed[pm.pid], strings.ToLower(pm.dir.String()))
+		}
+	}
+	if len(freed) > 0 {
+		pids := []string{}
+		for pid := range freed {
+			pids = append(pids, pid)
+		}
+		sort.Strings(pids)
+		parts := []string{}
+		for _, pid := range pids {
+			sort.Strings(freed[pid])
+			parts = append(parts, fmt.Sprintf("%s move %s", pid, strings.Join(freed[pid], " or ")))
+		}
+		fmt.Fprintf(&sb, " This lets %s.", joinWords(parts))
+	}
+	return sb.String()
+}
+
+// Joins words as in a sentence: "a", "a and b", "a, b and c".
+func joinWords(ws []string) string {
+	if len(ws) <= 1 {
+		return strings.Join(ws, "")
+	}
+	return strings.Join(ws[:len(ws)-1], ", ") + " and " + ws[len(ws)-1]
+}
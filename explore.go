@@ -0,0 +1,248 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Search tree explorer.
+//
+// "squareroot trace explore <file>" browses the search tree recorded with
+// -trace (see trace.go), to see how a search actually went. It shows one
+// expanded position at a time with its children, each marked as expanded
+// (with the expansion's number), a duplicate of a position the search had
+// already reached, not expanded because the search stopped first, or the
+// goal, each with its state id (see stateid.go). It reads commands, one per
+// line:
+//
+//	<n>             go to expansion n
+//	#<id>           go to the first expansion of the position with that id
+//	c <k>           go to the kth child, if it was expanded
+//	p, parent       go to the expansion this one came from
+//	n, next         go to the next expansion in search order
+//	b, back         go to the previous one
+//	s, solution     list the solution's path through the tree and go to its
+//	                last expansion
+//	?, help         list the commands
+//	q, quit         stop exploring
+
+const exploreHelp = `Commands:
+  <n>          go to expansion n
+  #<id>        go to the first expansion of the position with that id
+  c <k>        go to the kth child, if it was expanded
+  p, parent    go to the expansion this one came from
+  n, next      go to the next expansion in search order
+  b, back      go to the previous one
+  s, solution  show the solution's path and go to its last expansion
+  ?, help      list the commands
+  q, quit      stop exploring
+`
+
+// A recorded search tree.
+type searchTree struct {
+	start *Board
+	rs    []traceRecord
+	first map[uint64]int // the first expansion of each configuration
+}
+
+func newSearchTree(start *Board, rs []traceRecord) *searchTree {
+	t := &searchTree{start, rs, make(map[uint64]int)}
+	for i, r := range rs {
+		if _, ok := t.first[r.Hash]; !ok {
+			t.first[r.Hash] = i
+		}
+	}
+	return t
+}
+
+// A child of an expanded position.
+type treeChild struct {
+	m         Move
+	id        string // its state id
+	expansion int    // its expansion, or -1 if it wasn't expanded
+	duplicate bool
+	parent    bool // the move undoes the one that reached the position
+	goal      bool
+}
+
+// Returns the children of expansion i.
+func (t *searchTree) children(i int) []treeChild {
+	b := traceBoard(t.start, t.rs, i)
+	cs := []treeChild{}
+	for _, m := range b.possibleMoves() {
+		nb := b.move(m)
+		h := configHash(nb)
+		c := treeChild{m: m, id: hashStateID(h), expansion: -1, goal: nb.goal.IsSatisfied(nb)}
+		if j, ok := t.first[h]; ok {
+			c.expansion = j
+			c.duplicate = int(t.rs[j].Parent) != i
+			c.parent = uint32(j) == t.rs[i].Parent
+		}
+		cs = append(cs, c)
+	}
+	return cs
+}
+
+// Returns the first expansion of the position with the given state id.
+func (t *searchTree) expansionOf(id string) (int, bool) {
+	for i, r := range t.rs {
+		if hashStateID(r.Hash) == id {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+// Describes a child.
+func (t *searchTree) describeChild(c treeChild) string {
+	switch {
+	case c.parent:
+		return fmt.Sprintf("back to expansion %d", c.expansion)
+	case c.duplicate:
+		return fmt.Sprintf("duplicate of expansion %d, reached first from expansion %d", c.expansion, t.rs[c.expansion].Parent)
+	case c.expansion >= 0 && c.goal:
+		return fmt.Sprintf("expansion %d, the goal", c.expansion)
+	case c.expansion >= 0:
+		return fmt.Sprintf("expansion %d", c.expansion)
+	case c.goal:
+		return "the goal"
+	}
+	return "not expanded"
+}
+
+// Returns the expansions on the path to the goal, from the start, or nil if
+// the search didn't reach it. A search stops on reaching the goal, either
+// by expanding it or by generating it from its last expansion.
+func (t *searchTree) solutionPath() []int {
+	last := len(t.rs) - 1
+	if last < 0 {
+		return nil
+	}
+	b := traceBoard(t.start, t.rs, last)
+	reached := b.goal.IsSatisfied(b)
+	for _, c := range t.children(last) {
+		reached = reached || c.goal
+	}
+	if !reached {
+		return nil
+	}
+	path := []int{}
+	for j := last; j >= 0 && j < len(t.rs); j = int(t.rs[j].Parent) {
+		path = append([]int{j}, path...)
+		if t.rs[j].Dir == 255 {
+			break
+		}
+	}
+	return path
+}
+
+// Browses a search tree, reading commands from in.
+func explore(t *searchTree, in io.Reader, out io.Writer) {
+	if len(t.rs) == 0 {
+		fmt.Fprintln(out, "The trace has no expansions.")
+		return
+	}
+	scanner := bufio.NewScanner(in)
+	fmt.Fprint(out, exploreHelp)
+	fmt.Fprintf(out, "Goal: %v. The search expanded %d positions.\n", t.start.goal, len(t.rs))
+	at := 0
+	show := true
+	for {
+		cs := t.children(at)
+		if show {
+			r := t.rs[at]
+			fmt.Fprintf(out, "Expansion %d %s, depth %d", at, hashStateID(r.Hash), r.Depth)
+			if r.Dir != 255 {
+				fmt.Fprintf(out, ", after %s", Move{string(r.Piece), Direction(r.Dir)}.code())
+				if r.Parent != traceNoParent {
+					fmt.Fprintf(out, " from expansion %d", r.Parent)
+				}
+			}
+			fmt.Fprintln(out)
+			fmt.Fprint(out, traceBoard(t.start, t.rs, at).display())
+			for k, c := range cs {
+				fmt.Fprintf(out, "  %d. %-3s %s %s\n", k+1, c.m.code(), c.id, t.describeChild(c))
+			}
+		}
+		show = true
+		fmt.Fprint(out, "> ")
+		if !scanner.Scan() {
+			fmt.Fprintln(out)
+			return
+		}
+		args := strings.Fields(scanner.Text())
+		if len(args) == 0 {
+			show = false
+			continue
+		}
+		to := at
+		switch cmd := strings.ToLower(args[0]); cmd {
+		case "q", "quit", "exit":
+			return
+		case "?", "help":
+			fmt.Fprint(out, exploreHelp)
+			show = false
+		case "p", "parent":
+			if p := t.rs[at].Parent; p != traceNoParent {
+				to = int(p)
+			} else {
+				fmt.Fprintln(out, "This is the start.")
+				show = false
+			}
+		case "n", "next":
+			to = min(at+1, len(t.rs)-1)
+		case "b", "back":
+			to = max(at-1, 0)
+		case "c", "child":
+			k, err := 0, error(nil)
+			if len(args) == 2 {
+				k, err = strconv.Atoi(args[1])
+			}
+			switch {
+			case len(args) != 2 || err != nil || k < 1 || k > len(cs):
+				fmt.Fprintf(out, "usage: c <k>, with k from 1 to %d\n", len(cs))
+				show = false
+			case cs[k-1].expansion < 0:
+				fmt.Fprintln(out, "That child wasn't expanded.")
+				show = false
+			default:
+				to = cs[k-1].expansion
+			}
+		case "s", "solution":
+			path := t.solutionPath()
+			if path == nil {
+				fmt.Fprintln(out, "The search didn't reach the goal.")
+				show = false
+				break
+			}
+			ss := []string{}
+			for _, j := range path {
+				ss = append(ss, strconv.Itoa(j))
+			}
+			fmt.Fprintf(out, "Solution path: %s\n", strings.Join(ss, " "))
+			to = path[len(path)-1]
+		default:
+			if isStateID(cmd) {
+				j, ok := t.expansionOf(cmd)
+				if !ok {
+					fmt.Fprintf(out, "The search didn't expand %s.\n", cmd)
+					show = false
+					break
+				}
+				to = j
+				break
+			}
+			n, err := strconv.Atoi(cmd)
+			if err != nil || n < 0 || n >= len(t.rs) {
+				fmt.Fprintf(out, "Unknown command %q; type ? for help.\n", args[0])
+				show = false
+				break
+			}
+			to = n
+		}
+		at = to
+	}
+}
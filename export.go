@@ -0,0 +1,289 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+var format = flag.String("format", "text",
+	"Solution output format: text (boards after every move), sbp (SBP grid and move list), "+
+		"words (sentences, for screen readers), markdown (document with board diagrams), "+
+		"tikz or tikz-panels (LaTeX pictures), cast (asciinema recording), json (for scripts), "+
+		"or deltas (JSON Lines of piece moves, for animation).")
+
+// The solution output formats.
+var formats = []string{"text", "sbp", "words", "markdown", "tikz", "tikz-panels", "cast", "json", "deltas"}
+
+func validFormat(f string) bool {
+	for _, vf := range formats {
+		if f == vf {
+			return true
+		}
+	}
+	return false
+}
+
+// JSON solution output.
+//
+// The json format writes the solution as a single JSON object, for scripts
+// and pipelines:
+//
+//	{"verdict": "solved", "code": "AgQF...", "goal": "b at 1,3",
+//	 "length": 116, "piece_moves": 100, "moves": ["iR", "dD", ...],
+//	 "states": ["#56eb21a3", "#28791125", ...],
+//	 "configurations": 24037, "skipped": 53799}
+//
+// When there's no solution the verdict is "unsolvable", with configurations
+// counting every configuration reachable from the start, or "timeout" if
+// the search ran out of time, and there's no goal, length or moves. A
+// puzzle found unsolvable by a pre-check (see precheck.go) names the check.
+
+type jsonSolution struct {
+	Verdict        string   `json:"verdict"`
+	Check          string   `json:"check,omitempty"` // the pre-check that settled the puzzle
+	Code           string   `json:"code,omitempty"`
+	Goal           string   `json:"goal,omitempty"`
+	Length         int      `json:"length,omitempty"`
+	PieceMoves     int      `json:"piece_moves,omitempty"` // runs of moves of one piece
+	Moves          []string `json:"moves,omitempty"`
+	States         []string `json:"states,omitempty"` // the ids of the start and each move's position
+	Configurations int      `json:"configurations"`
+	Skipped        int      `json:"skipped"`
+}
+
+// Prints a solution in the json format.
+func printJSONSolution(start, end *Board, stats Stats) {
+	s := jsonSolution{
+		Verdict:        "solved",
+		Goal:           describeReached(end.goal, end),
+		Length:         len(end.mvs),
+		PieceMoves:     len(superMoves(end.mvs)),
+		Configurations: stats.Configs,
+		Skipped:        stats.Skipped,
+	}
+	s.Code, _ = start.Encode()
+	for _, m := range end.mvs {
+		s.Moves = append(s.Moves, m.code())
+	}
+	s.States = stateIDs(start, end.mvs)
+	writeJSONSolution(s)
+}
+
+// Prints the json format's verdict for a search that found no solution.
+func printJSONVerdict(start *Board, verdict string, stats Stats) {
+	s := jsonSolution{Verdict: verdict, Configurations: stats.Configs, Skipped: stats.Skipped}
+	s.Code, _ = start.Encode()
+	writeJSONSolution(s)
+}
+
+func writeJSONSolution(s jsonSolution) {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	enc.Encode(s)
+}
+
+// SBP solution output.
+//
+// The sbp format writes the puzzle as an SBP file (see sbp.go), the start
+// grid and the goal grid, followed by the moves in the compact notation used
+// by other sliding block solvers: each piece letter is followed by the
+// directions (U, D, L, R) of its consecutive single-space moves, e.g. "jL fD
+// eR gUL". Each such group is one move in the traditional Klotski count. A
+// -parallel solution is written one step per line instead, each step's moves
+// separated by spaces. Everything else is a ';' comment, so the output reads
+// back as a puzzle file, and grade reads its moves.
+//
+// The goal grid draws each piece the goal names where the goal wants it, and
+// a piece of each shape it names with an unused letter. Goals that aren't
+// simply conditions that must all hold, such as alternatives or
+// expressions, can't be drawn; for those the grid shows the goal's pieces
+// where the solution leaves them, and a comment says so.
+
+// Prints a solution in the sbp format, with the steps of a -parallel
+// solution, or nil.
+func printSBPSolution(start, end *Board, stats Stats, sts []Step) {
+	groups := groupMoves(end.mvs)
+	if sts != nil {
+		fmt.Printf("; Found solution (%d steps, %d moves, %d configurations, %d skipped)\n",
+			len(sts), len(end.mvs), stats.Configs, stats.Skipped)
+	} else {
+		fmt.Printf("; Found solution (%d moves, %d piece moves, %d configurations, %d skipped)\n",
+			len(end.mvs), len(groups), stats.Configs, stats.Skipped)
+	}
+	fmt.Printf("; Reached goal: %s\n", describeReached(end.goal, end))
+	if code, err := start.Encode(); err == nil {
+		fmt.Printf("; Board code: %s\n", code)
+	}
+	fmt.Printf("; States: start %s, end %s\n", start.stateID(), end.stateID())
+	if len(start.links) > 0 || len(start.oneway) > 0 || len(start.frozen) > 0 || start.rails || start.wrap ||
+		start.constraint != nil || len(start.filters) > 0 {
+		fmt.Println("; The grids leave out rules SBP has no notation for; the board code has them all.")
+	}
+	goal, exact := start.sbpGoalBoard(end)
+	if !exact {
+		fmt.Printf("; The goal %v can't be drawn as a grid; it shows the goal pieces as the solution leaves them.\n", start.goal)
+	}
+	fmt.Print(start.sbpGrid())
+	fmt.Println()
+	fmt.Print(goal.sbpGrid())
+	fmt.Println()
+	if sts != nil {
+		for _, st := range sts {
+			codes := []string{}
+			for _, m := range st {
+				codes = append(codes, m.code())
+			}
+			fmt.Println(strings.Join(codes, " "))
+		}
+		return
+	}
+	for i := 0; i < len(groups); i += 10 {
+		fmt.Println(strings.Join(groups[i:min(i+10, len(groups))], " "))
+	}
+}
+
+// Returns a board with the board's walls and the pieces of its goal grid,
+// and whether the grid gives the goal exactly rather than as the end board
+// reached it.
+func (b *Board) sbpGoalBoard(end *Board) (*Board, bool) {
+	gb := &Board{w: b.w, h: b.h, ps: make(map[string]Piece), wrap: b.wrap, walls: b.walls}
+	cs, ok := goalConditions(b.goal)
+	unused := []string{}
+	for _, c := range "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789" {
+		if _, used := b.ps[string(c)]; !used {
+			unused = append(unused, string(c))
+		}
+	}
+	for _, c := range cs {
+		pid := c.pid
+		w, h := c.w, c.h
+		if pid != "" {
+			w, h = b.ps[pid].w, b.ps[pid].h
+		} else if len(unused) > 0 {
+			pid, unused = unused[0], unused[1:]
+		} else {
+			ok = false
+		}
+		if _, dup := gb.ps[pid]; dup {
+			ok = false
+		}
+		gb.ps[pid] = Piece{pid, w, h, c.x, c.y}
+	}
+	if ok && gb.checkPieces() == nil {
+		return gb, true
+	}
+
+	gb.ps = make(map[string]Piece)
+	pids := []string{}
+	if gp, ok := b.goal.(GoalPieces); ok {
+		pids = gp.Pieces()
+	}
+	if len(pids) == 0 {
+		pids = end.pieceIDs()
+	}
+	for _, pid := range pids {
+		gb.ps[pid] = end.ps[pid]
+	}
+	return gb, false
+}
+
+// Returns the conditions of a goal that only requires conditions to hold,
+// and whether it's such a goal.
+func goalConditions(g Goal) ([]Condition, bool) {
+	switch g := g.(type) {
+	case Condition:
+		return []Condition{g}, true
+	case AllOf:
+		cs := []Condition{}
+		for _, sg := range g {
+			scs, ok := goalConditions(sg)
+			if !ok {
+				return nil, false
+			}
+			cs = append(cs, scs...)
+		}
+		return cs, true
+	}
+	return nil, false
+}
+
+// Groups consecutive moves of the same piece, writing each group as the
+// piece id followed by its direction letters.
+func groupMoves(mvs []Move) []string {
+	groups := []string{}
+	for _, sm := range superMoves(mvs) {
+		groups = append(groups, superMoveCode(sm))
+	}
+	return groups
+}
+
+// Prints a solution as an asciinema v2 cast replaying it at -fps moves per
+// second, for embedding the animation in web pages.
+func printCastSolution(start, end *Board) {
+	step := time.Second / time.Duration(max(*fps, 1))
+	t0 := time.Now()
+	frames := []sessionFrame{{t0, start}}
+	b := start
+	for i, m := range end.mvs {
+		b = b.move(m)
+		frames = append(frames, sessionFrame{t0.Add(time.Duration(i+1) * step), b})
+	}
+	// Hold the solved board for a moment before the cast ends.
+	frames = append(frames, sessionFrame{t0.Add(time.Duration(len(end.mvs)+4) * step), b})
+	fmt.Print(castOf(frames, func(i int, b *Board) string {
+		switch {
+		case i == 0:
+			return fmt.Sprintf("Start %s (%d moves to go)", b.stateID(), len(end.mvs))
+		case i > len(end.mvs):
+			return fmt.Sprintf("Solved in %d moves", len(end.mvs))
+		}
+		return fmt.Sprintf("Move %d of %d: %s %s", i, len(end.mvs), b.mvs[i-1].code(), b.stateID())
+	}))
+}
+
+// Splits moves into super-moves: runs of consecutive moves of the same piece.
+func superMoves(mvs []Move) [][]Move {
+	sms := [][]Move{}
+	for i, m := range mvs {
+		if i == 0 || mvs[i-1].pid != m.pid {
+			sms = append(sms, nil)
+		}
+		sms[len(sms)-1] = append(sms[len(sms)-1], m)
+	}
+	return sms
+}
+
+// Writes a super-move in compact notation, e.g. "gUL".
+func superMoveCode(sm []Move) string {
+	code := sm[0].pid
+	for _, m := range sm {
+		code += string(m.dir.letter())
+	}
+	return code
+}
+
+// Returns the board drawn as an SBP grid, with a wall border.
+func (b *Board) sbpGrid() string {
+	grid := makeGrid(b.w, b.h)
+	grid.wrap = b.wrap
+	for s := range b.walls {
+		grid.set(s.x, s.y, '#')
+	}
+	for _, p := range b.ps {
+		p.drawInto(grid)
+	}
+	border := strings.Repeat("#", b.w+2) + "\n"
+	var sb strings.Builder
+	sb.WriteString(border)
+	for y := 0; y < b.h; y++ {
+		sb.WriteString("#")
+		sb.WriteString(strings.ReplaceAll(grid.row(y), " ", "."))
+		sb.WriteString("#\n")
+	}
+	sb.WriteString(border)
+	return sb.String()
+}
@@ -0,0 +1,596 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Goal expressions.
+//
+// Goals and constraints that "<piece> <x> <y>" conditions can't say are
+// written as expressions, e.g.
+//
+//	goal piece(b).at(1,3) && moved(c) == 0
+//	constraint !piece(a).at(0,0) || open(3,3)
+//
+// The operands are
+//
+//	piece(b).at(x,y)   piece b has its upper-left square at x,y
+//	shape(2x2).at(x,y) some 2x2 piece does
+//	piece(b).x         the column of piece b's upper-left square, and .y its row
+//	open(x,y)          no piece covers x,y
+//	moved(b)           the number of moves of piece b so far
+//	moves()            the number of moves so far
+//	numbers
+//
+// combined with ==, !=, <, <=, > and >= between numbers and !, && and ||
+// (loosest) between conditions, with parentheses for grouping. A goal line
+// is read as an expression when it uses any of these operators or
+// parentheses.
+//
+// A board's constraint must hold in every position: moves that would break
+// it aren't made. The move counts depend on the path taken to a position,
+// and searches keep only the first path to each configuration, so a board
+// whose goal or constraint counts moves adds the counts to its
+// configurations (see moveCountState). Each is capped at one more than the
+// number it's compared with, beyond which it can't change the outcome, so
+// moves may only be compared with numbers.
+
+// Expr is a goal given by an expression.
+type Expr struct {
+	root exprNode
+}
+
+func (e Expr) IsSatisfied(b *Board) bool {
+	return e.root.eval(b) != 0
+}
+
+// The estimate of the "at" conditions the expression needs, combined as
+// AllOf and AnyOf combine theirs; every other condition counts as 0.
+func (e Expr) Heuristic(b *Board) int {
+	return e.root.heuristic(b)
+}
+
+func (e Expr) Pieces() []string {
+	pids := []string{}
+	e.root.walk(func(n exprNode) {
+		switch n := n.(type) {
+		case atNode:
+			if n.c.pid != "" {
+				pids = append(pids, n.c.pid)
+			}
+		case coordNode:
+			pids = append(pids, n.pid)
+		}
+	})
+	return pids
+}
+
+func (e Expr) String() string {
+	return e.root.String()
+}
+
+// A node of an expression. Conditions evaluate to 1 when they hold and 0
+// when they don't.
+type exprNode interface {
+	eval(b *Board) int
+	heuristic(b *Board) int
+	walk(f func(exprNode))
+	String() string
+}
+
+// piece(b).at(x,y) or shape(wxh).at(x,y).
+type atNode struct{ c Condition }
+
+// piece(b).x or piece(b).y.
+type coordNode struct {
+	pid string
+	y   bool
+}
+
+// open(x,y).
+type openNode struct{ s Space }
+
+// moved(b), or moves() when pid is empty.
+type movedNode struct{ pid string }
+
+type numberNode struct{ n int }
+
+type notNode struct{ n exprNode }
+
+// A comparison, or && or ||.
+type binaryNode struct {
+	op   string
+	l, r exprNode
+}
+
+func (n atNode) eval(b *Board) int { return boolInt(n.c.IsSatisfied(b)) }
+
+func (n coordNode) eval(b *Board) int {
+	if n.y {
+		return b.ps[n.pid].y
+	}
+	return b.ps[n.pid].x
+}
+
+func (n openNode) eval(b *Board) int { return boolInt(b.isOpen(n.s)) }
+
+func (n movedNode) eval(b *Board) int {
+	if n.pid == "" {
+		return len(b.mvs)
+	}
+	count := 0
+	for _, m := range b.mvs {
+		if b.movesPiece(m, n.pid) {
+			count++
+		}
+	}
+	return count
+}
+
+func (n numberNode) eval(b *Board) int { return n.n }
+
+func (n notNode) eval(b *Board) int { return 1 - n.n.eval(b) }
+
+func (n binaryNode) eval(b *Board) int {
+	switch n.op {
+	case "&&":
+		return boolInt(n.l.eval(b) != 0 && n.r.eval(b) != 0)
+	case "||":
+		return boolInt(n.l.eval(b) != 0 || n.r.eval(b) != 0)
+	}
+	l, r := n.l.eval(b), n.r.eval(b)
+	switch n.op {
+	case "==":
+		return boolInt(l == r)
+	case "!=":
+		return boolInt(l != r)
+	case "<":
+		return boolInt(l < r)
+	case "<=":
+		return boolInt(l <= r)
+	case ">":
+		return boolInt(l > r)
+	}
+	return boolInt(l >= r)
+}
+
+func boolInt(v bool) int {
+	if v {
+		return 1
+	}
+	return 0
+}
+
+func (n atNode) heuristic(b *Board) int     { return n.c.Heuristic(b) }
+func (n coordNode) heuristic(b *Board) int  { return 0 }
+func (n openNode) heuristic(b *Board) int   { return 0 }
+func (n movedNode) heuristic(b *Board) int  { return 0 }
+func (n numberNode) heuristic(b *Board) int { return 0 }
+func (n notNode) heuristic(b *Board) int    { return 0 }
+
+func (n binaryNode) heuristic(b *Board) int {
+	switch n.op {
+	case "&&":
+		return max(n.l.heuristic(b), n.r.heuristic(b))
+	case "||":
+		return min(n.l.heuristic(b), n.r.heuristic(b))
+	}
+	return 0
+}
+
+func (n atNode) walk(f func(exprNode))     { f(n) }
+func (n coordNode) walk(f func(exprNode))  { f(n) }
+func (n openNode) walk(f func(exprNode))   { f(n) }
+func (n movedNode) walk(f func(exprNode))  { f(n) }
+func (n numberNode) walk(f func(exprNode)) { f(n) }
+
+func (n notNode) walk(f func(exprNode)) {
+	f(n)
+	n.n.walk(f)
+}
+
+func (n binaryNode) walk(f func(exprNode)) {
+	f(n)
+	n.l.walk(f)
+	n.r.walk(f)
+}
+
+func (n atNode) String() string {
+	if n.c.pid == "" {
+		return fmt.Sprintf("shape(%dx%d).at(%d,%d)", n.c.w, n.c.h, n.c.x, n.c.y)
+	}
+	return fmt.Sprintf("piece(%s).at(%d,%d)", n.c.pid, n.c.x, n.c.y)
+}
+
+func (n coordNode) String() string {
+	if n.y {
+		return fmt.Sprintf("piece(%s).y", n.pid)
+	}
+	return fmt.Sprintf("piece(%s).x", n.pid)
+}
+
+func (n openNode) String() string { return fmt.Sprintf("open(%d,%d)", n.s.x, n.s.y) }
+
+func (n movedNode) String() string {
+	if n.pid == "" {
+		return "moves()"
+	}
+	return fmt.Sprintf("moved(%s)", n.pid)
+}
+
+func (n numberNode) String() string { return strconv.Itoa(n.n) }
+
+func (n notNode) String() string {
+	if _, ok := n.n.(binaryNode); ok {
+		return "!(" + n.n.String() + ")"
+	}
+	return "!" + n.n.String()
+}
+
+func (n binaryNode) String() string {
+	l, r := n.l.String(), n.r.String()
+	// Only || inside && needs parentheses; comparisons bind tighter than
+	// both and only compare numbers.
+	if n.op == "&&" {
+		if ln, ok := n.l.(binaryNode); ok && ln.op == "||" {
+			l = "(" + l + ")"
+		}
+		if rn, ok := n.r.(binaryNode); ok && rn.op == "||" {
+			r = "(" + r + ")"
+		}
+	}
+	return l + " " + n.op + " " + r
+}
+
+// Returns the caps on the move counts a goal compares, by piece id, with ""
+// for moves(): one more than the largest number each is compared with.
+func moveCountCaps(g Goal, caps map[string]int) {
+	switch g := g.(type) {
+	case Expr:
+		g.root.walk(func(n exprNode) {
+			c, ok := n.(binaryNode)
+			if !ok {
+				return
+			}
+			m, ok := c.l.(movedNode)
+			num, isNum := c.r.(numberNode)
+			if !ok {
+				m, ok = c.r.(movedNode)
+				num, isNum = c.l.(numberNode)
+			}
+			if ok && isNum {
+				caps[m.pid] = max(caps[m.pid], num.n+1)
+			}
+		})
+	case AllOf:
+		for _, sg := range g {
+			moveCountCaps(sg, caps)
+		}
+	case AnyOf:
+		for _, sg := range g {
+			moveCountCaps(sg, caps)
+		}
+	}
+}
+
+// Returns the caps on the move counts the board's goal and constraint
+// compare, or nil if they don't count moves.
+func (b *Board) moveCounts() map[string]int {
+	if !hasExpr(b.goal) && (b.constraint == nil || !hasExpr(b.constraint)) {
+		return nil
+	}
+	caps := map[string]int{}
+	moveCountCaps(b.goal, caps)
+	if b.constraint != nil {
+		moveCountCaps(b.constraint, caps)
+	}
+	if len(caps) == 0 {
+		return nil
+	}
+	return caps
+}
+
+// Returns the capped move counts the board's goal and constraint compare,
+// or "" if they don't count moves. Boards whose counts differ can satisfy
+// them differently, so their configurations differ too.
+func (b *Board) moveCountState() string {
+	caps := b.moveCounts()
+	if caps == nil {
+		return ""
+	}
+	pids := make([]string, 0, len(caps))
+	for pid := range caps {
+		pids = append(pids, pid)
+	}
+	sort.Strings(pids)
+	ss := make([]string, len(pids))
+	for i, pid := range pids {
+		ss[i] = fmt.Sprintf("%s=%d", pid, min(movedNode{pid}.eval(b), caps[pid]))
+	}
+	return strings.Join(ss, ",")
+}
+
+// Reports whether a goal line is an expression rather than conditions.
+func isExpr(s string) bool {
+	return strings.ContainsAny(s, "()=!<>&|")
+}
+
+// Parses an expression, which must be a condition, naming pieces and spaces
+// of this board.
+func (b *Board) parseExpr(s string) (Expr, error) {
+	p := &exprParser{b: b, src: s}
+	p.tokenize()
+	n, isBool := p.or()
+	if p.err == nil && p.pos < len(p.toks) {
+		p.fail("unexpected %q", p.toks[p.pos])
+	}
+	if p.err == nil && !isBool {
+		p.err = fmt.Errorf("%q is a number, not a condition", s)
+	}
+	if p.err != nil {
+		return Expr{}, p.err
+	}
+	return Expr{n}, nil
+}
+
+// Returns the text of a goal as an expression, including goals made of
+// conditions, so that it can be combined with expressions.
+func exprText(g Goal) (string, error) {
+	switch g := g.(type) {
+	case Expr:
+		return g.String(), nil
+	case Condition:
+		return atNode{g}.String(), nil
+	case AllOf, AnyOf:
+		var gs []Goal
+		sep := " && "
+		if all, ok := g.(AllOf); ok {
+			gs = all
+		} else {
+			gs, sep = g.(AnyOf), " || "
+		}
+		ts := []string{}
+		for _, sg := range gs {
+			t, err := exprText(sg)
+			if err != nil {
+				return "", err
+			}
+			if _, ok := sg.(Condition); !ok && len(gs) > 1 {
+				t = "(" + t + ")"
+			}
+			ts = append(ts, t)
+		}
+		return strings.Join(ts, sep), nil
+	}
+	return "", fmt.Errorf("can't write goal %v", g)
+}
+
+// Reports whether a goal contains an expression.
+func hasExpr(g Goal) bool {
+	switch g := g.(type) {
+	case Expr:
+		return true
+	case AllOf:
+		return hasExprIn(g)
+	case AnyOf:
+		return hasExprIn(g)
+	}
+	return false
+}
+
+func hasExprIn(gs []Goal) bool {
+	for _, g := range gs {
+		if hasExpr(g) {
+			return true
+		}
+	}
+	return false
+}
+
+// exprParser reads an expression by recursive descent, remembering the
+// first error.
+type exprParser struct {
+	b    *Board
+	src  string
+	toks []string
+	pos  int
+	err  error
+}
+
+// The operators, longest first so that "<=" isn't read as "<".
+var exprOps = []string{"&&", "||", "==", "!=", "<=", ">=", "<", ">", "!", "(", ")", ".", ","}
+
+func (p *exprParser) tokenize() {
+	s := p.src
+	for s != "" && p.err == nil {
+		if s[0] == ' ' || s[0] == '\t' {
+			s = s[1:]
+			continue
+		}
+		if isPieceID(s[0]) {
+			n := 1
+			for n < len(s) && isPieceID(s[n]) {
+				n++
+			}
+			p.toks, s = append(p.toks, s[:n]), s[n:]
+			continue
+		}
+		found := false
+		for _, op := range exprOps {
+			if strings.HasPrefix(s, op) {
+				p.toks, s, found = append(p.toks, op), s[len(op):], true
+				break
+			}
+		}
+		if !found {
+			p.fail("invalid character %q", s[0])
+		}
+	}
+}
+
+func (p *exprParser) fail(format string, args ...any) {
+	if p.err == nil {
+		p.err = fmt.Errorf("%s in %q", fmt.Sprintf(format, args...), p.src)
+	}
+}
+
+// Returns the next token, or "" at the end.
+func (p *exprParser) peek() string {
+	if p.pos < len(p.toks) {
+		return p.toks[p.pos]
+	}
+	return ""
+}
+
+func (p *exprParser) next() string {
+	t := p.peek()
+	if t == "" {
+		p.fail("unexpected end")
+	} else {
+		p.pos++
+	}
+	return t
+}
+
+func (p *exprParser) expect(t string) {
+	if got := p.next(); got != t && p.err == nil {
+		p.fail("expected %q, got %q", t, got)
+	}
+}
+
+// Reads "x,y" as a space on the board.
+func (p *exprParser) space() Space {
+	x := p.next()
+	p.expect(",")
+	y := p.next()
+	if p.err != nil {
+		return Space{}
+	}
+	s, err := p.b.parseSpace(x, y)
+	if err != nil {
+		p.fail("%v", err)
+	}
+	return s
+}
+
+// Reads the id of a piece on the board.
+func (p *exprParser) piece() string {
+	pid := p.next()
+	if _, ok := p.b.ps[pid]; !ok && p.err == nil {
+		p.fail("no piece %s", pid)
+	}
+	return pid
+}
+
+// Each level returns its node and whether it's a condition rather than a
+// number.
+
+func (p *exprParser) or() (exprNode, bool) {
+	return p.logic("||", p.and)
+}
+
+func (p *exprParser) and() (exprNode, bool) {
+	return p.logic("&&", p.comparison)
+}
+
+// Reads operands joined by a logical operator.
+func (p *exprParser) logic(op string, operand func() (exprNode, bool)) (exprNode, bool) {
+	n, isBool := operand()
+	for p.err == nil && p.peek() == op {
+		p.pos++
+		r, rBool := operand()
+		if !isBool || !rBool {
+			p.fail("%s needs conditions on both sides", op)
+		}
+		n, isBool = binaryNode{op, n, r}, true
+	}
+	return n, isBool
+}
+
+func (p *exprParser) comparison() (exprNode, bool) {
+	n, isBool := p.unary()
+	switch op := p.peek(); op {
+	case "==", "!=", "<", "<=", ">", ">=":
+		p.pos++
+		r, rBool := p.unary()
+		if isBool || rBool {
+			p.fail("%s needs numbers on both sides", op)
+		}
+		_, lMoved := n.(movedNode)
+		_, rMoved := r.(movedNode)
+		_, lNumber := n.(numberNode)
+		_, rNumber := r.(numberNode)
+		if lMoved && !rNumber || rMoved && !lNumber {
+			p.fail("moved() and moves() can only be compared with a number")
+		}
+		return binaryNode{op, n, r}, true
+	}
+	return n, isBool
+}
+
+func (p *exprParser) unary() (exprNode, bool) {
+	switch t := p.next(); {
+	case p.err != nil:
+		return nil, false
+	case t == "!":
+		n, isBool := p.unary()
+		if !isBool {
+			p.fail("! needs a condition")
+		}
+		return notNode{n}, true
+	case t == "(":
+		n, isBool := p.or()
+		p.expect(")")
+		return n, isBool
+	case t == "piece" || t == "shape":
+		p.expect("(")
+		c := Condition{}
+		if t == "piece" {
+			c.pid = p.piece()
+		} else if shape := p.next(); p.err == nil {
+			if _, err := fmt.Sscanf(shape, "%dx%d", &c.w, &c.h); err != nil {
+				p.fail("invalid shape %q", shape)
+			} else if !p.b.hasShape(c.w, c.h) {
+				p.fail("no %s piece", shape)
+			}
+		}
+		p.expect(")")
+		p.expect(".")
+		switch f := p.next(); {
+		case f == "at":
+			p.expect("(")
+			s := p.space()
+			p.expect(")")
+			c.x, c.y = s.x, s.y
+			return atNode{c}, true
+		case (f == "x" || f == "y") && t == "piece":
+			return coordNode{c.pid, f == "y"}, false
+		default:
+			p.fail("unknown %s property %q", t, f)
+			return nil, false
+		}
+	case t == "open":
+		p.expect("(")
+		s := p.space()
+		p.expect(")")
+		return openNode{s}, true
+	case t == "moved":
+		p.expect("(")
+		pid := p.piece()
+		p.expect(")")
+		return movedNode{pid}, false
+	case t == "moves":
+		p.expect("(")
+		p.expect(")")
+		return movedNode{}, false
+	default:
+		n, err := strconv.Atoi(t)
+		if err != nil {
+			p.fail("unexpected %q", t)
+		}
+		return numberNode{n}, false
+	}
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Move filters.
+//
+// A MoveFilter vetoes moves the board's own rules allow, so variants can add
+// rules of their own without changing the solvers: every solver gets its
+// moves from the board, which asks each of its filters about each move. A
+// filter is added with WithFilter, or in a puzzle file with a "rule"
+// directive for the built-in ones:
+//
+//	rule norepeat                 no piece moves twice in a row
+//	rule cooldown <n>             a piece that moves must then wait n moves
+//	rule zone <piece> <x1> <y1> <x2> <y2>
+//	                              the piece stays within the rectangle
+//
+// Filters that look at the moves made so far, like the first two, also say
+// what of that history matters (MoveFilterState), so that searches tell
+// apart positions reached with different histories and stay exact.
+//
+// Boards with filters can't be written as board codes, so they aren't
+// cached, and can't be made into Positions when a filter has state.
+
+// MoveFilter decides which moves are allowed.
+type MoveFilter interface {
+	// Allow reports whether the move, which the board's rules allow, may be
+	// made.
+	Allow(b *Board, m Move) bool
+}
+
+// MoveFilterState is implemented by filters that depend on the moves made
+// so far.
+type MoveFilterState interface {
+	// State returns what the filter remembers of the board's moves, the
+	// same for any two histories it treats alike.
+	State(b *Board) string
+}
+
+// WithFilter returns a copy of the board with the filter added.
+func (b *Board) WithFilter(f MoveFilter) *Board {
+	nb := *b
+	nb.filters = append(slices.Clip(b.filters), f)
+	return &nb
+}
+
+// Reports whether the board's filters allow a move.
+func (b *Board) filtersAllow(m Move) bool {
+	for _, f := range b.filters {
+		if !f.Allow(b, m) {
+			return false
+		}
+	}
+	return true
+}
+
+// Returns what the board's filters remember of its moves, or "" if none of
+// them remember anything.
+func (b *Board) filterState() string {
+	ss := []string{}
+	for _, f := range b.filters {
+		if fs, ok := f.(MoveFilterState); ok {
+			ss = append(ss, fs.State(b))
+		}
+	}
+	if len(ss) == 0 {
+		return ""
+	}
+	return strings.Join(ss, "|")
+}
+
+// NoRepeat forbids moving the same piece twice in a row.
+type NoRepeat struct{}
+
+func (NoRepeat) Allow(b *Board, m Move) bool {
+	return len(b.mvs) == 0 || b.mvs[len(b.mvs)-1].pid != m.pid
+}
+
+func (NoRepeat) State(b *Board) string {
+	if len(b.mvs) == 0 {
+		return ""
+	}
+	return b.mvs[len(b.mvs)-1].pid
+}
+
+func (NoRepeat) String() string { return "norepeat" }
+
+// Cooldown makes a piece wait the given number of moves by other pieces
+// after it moves. Cooldown{1} is the same as NoRepeat.
+type Cooldown struct {
+	Turns int
+}
+
+func (c Cooldown) Allow(b *Board, m Move) bool {
+	return !slices.ContainsFunc(c.recent(b), func(r Move) bool { return r.pid == m.pid })
+}
+
+func (c Cooldown) State(b *Board) string {
+	ps := []string{}
+	for _, m := range c.recent(b) {
+		ps = append(ps, m.pid)
+	}
+	return strings.Join(ps, ",")
+}
+
+// The moves still cooling down.
+func (c Cooldown) recent(b *Board) []Move {
+	return b.mvs[max(len(b.mvs)-c.Turns, 0):]
+}
+
+func (c Cooldown) String() string { return fmt.Sprintf("cooldown %d", c.Turns) }
+
+// Zone keeps a piece within the rectangle from X1,Y1 to X2,Y2.
+type Zone struct {
+	Piece          string
+	X1, Y1, X2, Y2 int
+}
+
+func (z Zone) Allow(b *Board, m Move) bool {
+	if !b.movesPiece(m, z.Piece) {
+		return true
+	}
+	p := b.ps[z.Piece].move(m.dir)
+	if b.wrap {
+		s := b.wrapSpace(Space{p.x, p.y})
+		p.x, p.y = s.x, s.y
+	}
+	return z.holds(p)
+}
+
+// Reports whether the piece lies within the zone.
+func (z Zone) holds(p Piece) bool {
+	return p.x >= z.X1 && p.y >= z.Y1 && p.x+p.w-1 <= z.X2 && p.y+p.h-1 <= z.Y2
+}
+
+func (z Zone) String() string {
+	return fmt.Sprintf("zone %s %d %d %d %d", z.Piece, z.X1, z.Y1, z.X2, z.Y2)
+}
+
+// Returns the "rule" directive arguments for a built-in filter.
+func ruleText(f MoveFilter) (string, error) {
+	switch f.(type) {
+	case NoRepeat, Cooldown, Zone:
+		return fmt.Sprint(f), nil
+	}
+	return "", fmt.Errorf("can't write move rule %v", f)
+}
+
+// Parses the arguments of a "rule" directive into a built-in filter.
+func (b *Board) parseRule(args []string) (MoveFilter, error) {
+	switch {
+	case len(args) == 1 && args[0] == "norepeat":
+		return NoRepeat{}, nil
+	case len(args) == 2 && args[0] == "cooldown":
+		n, err := strconv.Atoi(args[1])
+		if err != nil || n < 1 {
+			return nil, fmt.Errorf("invalid cooldown %q", args[1])
+		}
+		return Cooldown{n}, nil
+	case len(args) == 6 && args[0] == "zone":
+		if _, ok := b.ps[args[1]]; !ok {
+			return nil, fmt.Errorf("no piece %s", args[1])
+		}
+		s1, err := b.parseSpace(args[2], args[3])
+		if err != nil {
+			return nil, err
+		}
+		s2, err := b.parseSpace(args[4], args[5])
+		if err != nil {
+			return nil, err
+		}
+		z := Zone{args[1], s1.x, s1.y, s2.x, s2.y}
+		if !z.holds(b.ps[z.Piece]) {
+			return nil, fmt.Errorf("piece %s starts outside its zone", z.Piece)
+		}
+		return z, nil
+	}
+	return nil, fmt.Errorf("usage: rule norepeat | cooldown <n> | zone <piece> <x1> <y1> <x2> <y2>")
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+	"strconv"
+)
+
+// Piece contributions.
+//
+// "squareroot freeze [file.csv]" solves the puzzle once for each piece with
+// that piece frozen in place, and reports the optimal length of each, or
+// that freezing the piece makes the puzzle unsolvable. This shows how much
+// each piece's mobility matters: pieces whose freezing costs nothing are
+// bystanders, and those that break the puzzle are essential. With a file it
+// also writes the results as CSV, one row per piece, for charting; with -
+// it writes only the CSV, to standard output. A puzzle that is already
+// solved needs no piece to move, so there is nothing to report or write.
+
+// The outcome of solving with one piece frozen.
+type freezeResult struct {
+	pid    string
+	w, h   int
+	length int // -1 if unsolvable
+}
+
+// Runs "freeze [file.csv]".
+func runFreeze(start *Board, args []string) {
+	if len(args) > 1 {
+		fmt.Fprintln(os.Stderr, "usage: squareroot freeze [file.csv]")
+		os.Exit(exitInvalid)
+	}
+	if start.goal.IsSatisfied(start) {
+		out := os.Stdout
+		if len(args) == 1 && args[0] == "-" {
+			out = os.Stderr
+		}
+		fmt.Fprintln(out, "The puzzle is already solved; no piece needs to move.")
+		return
+	}
+	base := -1
+	if end, _ := solve(start); end != nil {
+		base = len(end.mvs)
+	}
+	results := []freezeResult{}
+	for _, pid := range start.pieceIDs() {
+		fmt.Fprintf(os.Stderr, "Solving with %s frozen...\n", pid)
+		p := start.ps[pid]
+		results = append(results, freezeResult{pid, p.w, p.h, frozenLength(start, pid)})
+	}
+
+	if len(args) == 1 {
+		if err := writeFreezeCSV(args[0], base, results); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(exitError)
+		}
+		if args[0] == "-" {
+			return
+		}
+	}
+	if base < 0 {
+		fmt.Println("The puzzle can't be solved even with every piece free.")
+	} else {
+		fmt.Printf("Optimal solution with every piece free: %d moves.\n", base)
+	}
+	fmt.Printf("%-6s %-5s %10s %7s\n", "frozen", "size", "moves", "change")
+	for _, r := range results {
+		moves, change := "unsolvable", "-"
+		if r.length >= 0 {
+			moves = strconv.Itoa(r.length)
+			if base >= 0 {
+				change = fmt.Sprintf("%+d", r.length-base)
+			}
+		}
+		fmt.Printf("%-6s %-5s %10s %7s\n", r.pid, fmt.Sprintf("%dx%d", r.w, r.h), moves, change)
+	}
+}
+
+// Returns the optimal solution length of the puzzle with the given piece
+// frozen, or -1 if it can't be solved.
+func frozenLength(start *Board, pid string) int {
+	b := *start
+	b.frozen = map[string]bool{pid: true}
+	for fpid := range start.frozen {
+		b.frozen[fpid] = true
+	}
+	if b.goal.IsSatisfied(&b) {
+		return 0
+	}
+	if end, _ := solve(&b); end != nil {
+		return len(end.mvs)
+	}
+	return -1
+}
+
+// Writes the results as CSV: a row for each piece with its size, the
+// optimal length with it frozen and the change from the puzzle's optimal
+// length, left empty when either is unsolvable.
+func writeFreezeCSV(path string, base int, results []freezeResult) error {
+	f, err := createOutput(path)
+	if err != nil {
+		return err
+	}
+	w := csv.NewWriter(f)
+	w.Write([]string{"piece", "width", "height", "solvable", "moves", "change"})
+	for _, r := range results {
+		moves, change := "", ""
+		if r.length >= 0 {
+			moves = strconv.Itoa(r.length)
+			if base >= 0 {
+				change = strconv.Itoa(r.length - base)
+			}
+		}
+		w.Write([]string{r.pid, strconv.Itoa(r.w), strconv.Itoa(r.h), strconv.FormatBool(r.length >= 0), moves, change})
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
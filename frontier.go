@@ -0,0 +1,164 @@
+package main
+
+import "flag"
+
+// Flat frontiers.
+//
+// A breadth-first search over boards keeps a slice of pointers to boards,
+// each with its own map of pieces and list of moves, scattered about the
+// heap. A flatFrontier keeps the positions a search reaches in three
+// contiguous arrays instead: the places of the pieces, packed two bytes to
+// a piece in id order; the index of the position each was reached from;
+// and the move that reached it, packed in two bytes. Expanding a position
+// reads a run of bytes rather than chasing pointers, the search keeps no
+// move lists, and the solution's moves are read back through the parents.
+//
+// With -flat, breadth-first searches keep their positions this way, as
+// -workers searches always do. Both are Solvers, like the search over
+// boards, taking a start board and returning a solved board with its moves,
+// so the layout is theirs alone; breadthFirstSolver picks one by the flags.
+
+var flat = flag.Bool("flat", false,
+	"Search breadth-first keeping positions packed in flat arrays.")
+
+// Solver finds a shortest solution from a start board, returning the solved
+// board with the moves reaching it, or nil if there's no solution.
+type Solver interface {
+	Solve(start *Board) (*Board, Stats)
+}
+
+// The breadth-first solvers, which differ in how they keep the positions
+// they reach.
+type (
+	boardSolver      struct{} // boards, with solve
+	flatSolver       struct{} // a flatFrontier, with solveFlat
+	concurrentSolver struct{} // a flatFrontier shared by -workers goroutines
+)
+
+func (boardSolver) Solve(start *Board) (*Board, Stats)      { return solve(start) }
+func (flatSolver) Solve(start *Board) (*Board, Stats)       { return solveFlat(start) }
+func (concurrentSolver) Solve(start *Board) (*Board, Stats) { return solveConcurrent(start) }
+
+// Returns the breadth-first solver selected by -workers and -flat.
+func breadthFirstSolver() Solver {
+	switch {
+	case *workers > 1:
+		return concurrentSolver{}
+	case *flat:
+		return flatSolver{}
+	}
+	return boardSolver{}
+}
+
+// The positions a search has reached, packed.
+type flatFrontier struct {
+	// A position giving the ids and sizes of the pieces.
+	template Position
+	places   []byte   // x and y of each piece of each position
+	parents  []int32  // the index of the position each was reached from, or -1
+	moves    []uint16 // the move that reached each: piece index<<2 | direction
+}
+
+// Returns a frontier holding just the start position.
+func newFlatFrontier(start Position) *flatFrontier {
+	f := &flatFrontier{template: start}
+	f.add(start, -1, Move{})
+	return f
+}
+
+// Adds a position reached from the parent by a move, returning its index.
+func (f *flatFrontier) add(p Position, parent int, m Move) int {
+	code := uint16(0)
+	for i, q := range p.pieces[:p.n] {
+		f.places = append(f.places, q.x, q.y)
+		if m.pid != "" && q.id == m.pid[0] {
+			code = uint16(i)<<2 | uint16(m.dir)
+		}
+	}
+	f.parents = append(f.parents, int32(parent))
+	f.moves = append(f.moves, code)
+	return len(f.parents) - 1
+}
+
+// Returns the number of positions held.
+func (f *flatFrontier) len() int {
+	return len(f.parents)
+}
+
+// Returns position i.
+func (f *flatFrontier) position(i int) Position {
+	p := f.template
+	places := f.places[i*2*p.n : (i+1)*2*p.n]
+	for k := range p.pieces[:p.n] {
+		p.pieces[k].x, p.pieces[k].y = places[2*k], places[2*k+1]
+	}
+	return p
+}
+
+// Returns the moves from the start to position i.
+func (f *flatFrontier) path(i int) []Move {
+	mvs := []Move{}
+	for ; f.parents[i] >= 0; i = int(f.parents[i]) {
+		code := f.moves[i]
+		mvs = append(mvs, Move{string(f.template.pieces[code>>2].id), Direction(code & 3)})
+	}
+	for j, k := 0, len(mvs)-1; j < k; j, k = j+1, k-1 {
+		mvs[j], mvs[k] = mvs[k], mvs[j]
+	}
+	return mvs
+}
+
+// Returns the start board with the moves to position i made.
+func (f *flatFrontier) board(start *Board, i int) *Board {
+	b := start
+	for _, m := range f.path(i) {
+		b = b.move(m)
+	}
+	return b
+}
+
+// Searches breadth-first like solve, keeping the positions reached in a
+// flatFrontier. Its queue is the run of positions after the one being
+// expanded, since a breadth-first search expands them in the order it adds
+// them.
+func solveFlat(start *Board) (*Board, Stats) {
+	startPos, err := start.Position()
+	if err != nil {
+		return solve(start)
+	}
+	f := newFlatFrontier(startPos)
+	seen := map[string]bool{startPos.Config(): true}
+	stats := Stats{Configs: 1}
+	depth, levelEnd := 0, 1 // positions from levelEnd on are one move deeper
+	for i := 0; i < f.len(); i++ {
+		if searchExpired() {
+			return nil, stats
+		}
+		if i == levelEnd {
+			events.depth(depth, len(seen), f.len()-i, stats.Skipped)
+			depth, levelEnd = depth+1, f.len()
+		}
+		events.snapshot(len(seen), f.len()-i, stats.Skipped)
+		stats.Expanded++
+		depthStats.expand(depth)
+		p := f.position(i)
+		b := p.Board()
+		for _, m := range b.orderedMoves() {
+			config := b.configAfter(m)
+			dup := seen[config] || avoided[config]
+			depthStats.generate(depth, dup)
+			if dup {
+				stats.Skipped++
+				continue
+			}
+			np := p.with(b.move(m))
+			seen[config] = true
+			stats.Configs++
+			j := f.add(np, i, m)
+			if np.Solved() {
+				return f.board(start, j), stats
+			}
+		}
+	}
+	return nil, stats
+}
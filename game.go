@@ -0,0 +1,213 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+)
+
+// game tracks an interactive game of a puzzle: the current board, reached
+// from the starting board by the moves made so far. Front ends (the GUI and
+// others) drive it and render its board.
+type game struct {
+	start *Board
+	b     *Board
+
+	// Earlier boards, most recent last, for undo, and undone boards, most
+	// recently undone last, for redo.
+	past, future []*Board
+
+	// Every board shown, for exporting the session.
+	session []sessionFrame
+
+	// The most moves allowed in challenge mode, or 0 for no limit.
+	budget int
+
+	// Whether hints, solving and loading other games are off, so that
+	// solving the puzzle counts (in challenge and campaign modes).
+	noHelp bool
+
+	// Built on first use, for hints and solving.
+	table *DistanceTable
+
+	// The book hints come from first, if any.
+	book book
+
+	// A blunder held back by -blunders confirm, played if it's entered
+	// again next.
+	held Move
+}
+
+func newGame(start *Board) *game {
+	g := &game{start: start, b: start}
+	g.record(start)
+	return g
+}
+
+// Makes the given move if it's legal.
+func (g *game) move(m Move) error {
+	b, err := g.b.ApplyMove(m)
+	if err != nil {
+		return err
+	}
+	g.jump(b)
+	return nil
+}
+
+// Changes the current board, recording the previous one for undo.
+func (g *game) jump(b *Board) {
+	g.past = append(g.past, g.b)
+	g.future = nil
+	g.b = b
+	g.record(b)
+}
+
+// Returns to the previous board. It reports false if there's nothing to undo.
+func (g *game) undo() bool {
+	if len(g.past) == 0 {
+		return false
+	}
+	g.future = append(g.future, g.b)
+	g.b = g.past[len(g.past)-1]
+	g.past = g.past[:len(g.past)-1]
+	g.record(g.b)
+	return true
+}
+
+// Returns to the most recently undone board. It reports false if there's
+// nothing to redo.
+func (g *game) redo() bool {
+	if len(g.future) == 0 {
+		return false
+	}
+	g.past = append(g.past, g.b)
+	g.b = g.future[len(g.future)-1]
+	g.future = g.future[:len(g.future)-1]
+	g.record(g.b)
+	return true
+}
+
+// Returns to the starting board. Restarting can itself be undone.
+func (g *game) restart() {
+	if g.b != g.start {
+		g.jump(g.start)
+	}
+}
+
+// Slides a piece n spaces in the given direction, one space at a time,
+// returning how many spaces it actually moved before being blocked.
+func (g *game) slide(pid string, d Direction, n int) int {
+	for i := 0; i < n; i++ {
+		if g.move(Move{pid, d}) != nil {
+			return i
+		}
+	}
+	return n
+}
+
+// How many spaces the given piece could slide in the given direction.
+func (g *game) reach(pid string, d Direction) int {
+	b := g.b
+	n := 0
+	for b.isLegal(Move{pid, d}) && n < b.w*b.h {
+		b = b.move(Move{pid, d})
+		n++
+	}
+	return n
+}
+
+// Limits a drag of the given piece by (dx, dy) on a board drawn with the
+// given cell size to the drag's main axis and to as far as the piece can
+// legally slide.
+func (g *game) clampDrag(pid string, dx, dy, cell float64) (float64, float64) {
+	limit := func(v float64, neg, pos Direction) float64 {
+		return max(-float64(g.reach(pid, neg))*cell, min(v, float64(g.reach(pid, pos))*cell))
+	}
+	if abs(dx) >= abs(dy) {
+		return limit(dx, Left, Right), 0
+	}
+	return 0, limit(dy, Up, Down)
+}
+
+// Ends a drag of the given piece by (dx, dy), as limited by clampDrag, by
+// snapping the piece to the nearest space and making the moves.
+func (g *game) drop(pid string, dx, dy, cell float64) {
+	n := func(offset float64) int { return int(abs(offset)/cell + 0.5) }
+	switch {
+	case dx > 0:
+		g.slide(pid, Right, n(dx))
+	case dx < 0:
+		g.slide(pid, Left, n(dx))
+	case dy > 0:
+		g.slide(pid, Down, n(dy))
+	case dy < 0:
+		g.slide(pid, Up, n(dy))
+	}
+}
+
+// Describes the game's progress.
+func (g *game) status() string {
+	switch {
+	case g.solved():
+		return fmt.Sprintf("Solved in %d moves!", len(g.b.mvs))
+	case g.overBudget():
+		return fmt.Sprintf("Over the budget of %d moves: challenge failed", g.budget)
+	case g.budget > 0:
+		return fmt.Sprintf("%d of %d moves", len(g.b.mvs), g.budget)
+	}
+	return fmt.Sprintf("%d moves", len(g.b.mvs))
+}
+
+// The piece covering the given space, or "" if the space is open.
+func (g *game) pieceAt(s Space) string {
+	for pid, p := range g.b.ps {
+		if g.b.covers(p, s) {
+			return pid
+		}
+	}
+	return ""
+}
+
+// Returns the directions each piece can move on the current board, for the
+// pieces that can move at all.
+func (g *game) movable() map[string][]Direction {
+	dirs := make(map[string][]Direction)
+	for _, m := range g.b.possibleMoves() {
+		dirs[m.pid] = append(dirs[m.pid], m.dir)
+	}
+	for _, ds := range dirs {
+		sort.Slice(ds, func(i, j int) bool { return ds[i] < ds[j] })
+	}
+	return dirs
+}
+
+// Reports whether the current board is over the move budget.
+func (g *game) overBudget() bool {
+	return g.budget > 0 && len(g.b.mvs) > g.budget
+}
+
+func (g *game) solved() bool {
+	return g.b.goal.IsSatisfied(g.b)
+}
+
+// Builds the distance table if it hasn't been built yet.
+func (g *game) ensureTable() {
+	if g.table == nil {
+		g.table = buildDistanceTable(g.start)
+	}
+}
+
+// Returns an optimal next move from the current board and the number of
+// moves left in an optimal solution, from the book if the board is in it.
+func (g *game) hint() (Move, int, error) {
+	if m, d, ok := g.book.lookup(g.b); ok {
+		return m, d, nil
+	}
+	g.ensureTable()
+	return g.table.Hint(g.b)
+}
+
+// Returns the moves of an optimal solution from the current board.
+func (g *game) solution() ([]Move, error) {
+	g.ensureTable()
+	return g.table.Solve(g.b)
+}
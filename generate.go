@@ -0,0 +1,472 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"math/rand"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Puzzle generation.
+//
+// The generator makes Square Root style puzzles: a 2x2 piece that must reach
+// the bottom middle of the board, placed at random along with randomly
+// shaped small pieces (1x1, 1x2 and 2x1) filling all but two spaces. Each
+// candidate is solved, and the first whose optimal solution length lies in
+// the wanted range is kept. Generation is deterministic for a given random
+// source, so a seed identifies a puzzle. With -pieces, the generator uses
+// exactly the pieces given instead, the first being the goal piece.
+//
+// With -rushhour, the generator makes Rush Hour puzzles instead: a board
+// with rails (see rails.go) filled at random with cars (2x1 and 1x2) and
+// trucks (3x1 and 1x3), where a horizontal car must drive out along its row
+// to the right edge. No other horizontal piece shares that row, so none can
+// block the exit for good. Random layouts are rarely hard, so rather than
+// being solved, each candidate's family of positions (see hardest.go) is
+// searched for the one whose optimal solution length is best for the wanted
+// range, and the puzzle starts from there. The board is 6x6 and the range
+// 15 to 40 moves unless the flags say otherwise; lengths count single-space
+// moves, as everywhere else, rather than Rush Hour's slides of any distance.
+//
+// "squareroot gen -pack n" generates a curriculum instead: a pack of n
+// puzzles whose optimal lengths rise evenly from -min-moves to -max-moves,
+// printed as a pack file that "campaign" plays, with the puzzles given by
+// board code and their optimal lengths as par.
+//
+// "squareroot gen" prints a generated puzzle as a puzzle file, with its seed,
+// optimal solution length and board code in comments.
+
+// The shapes of the small pieces, as width and height.
+var smallShapes = [][2]int{{1, 1}, {1, 2}, {2, 1}}
+
+// Options for generating a puzzle.
+type genOptions struct {
+	w, h               int
+	minMoves, maxMoves int // wanted range of optimal solution lengths
+	attempts           int // candidates to try before settling for the closest
+
+	// The shapes of the pieces to use, the goal piece first, or nil for a
+	// 2x2 goal piece and random small pieces.
+	inventory [][2]int
+
+	// Whether to make Rush Hour puzzles.
+	rushHour bool
+}
+
+// Generates a puzzle, returning it and its optimal solution length. If no
+// candidate within the given number of attempts has an optimal solution
+// length in range, it returns the closest one found, searching on for up to
+// a hundred times as many attempts if none has been solvable, and then
+// giving up with a nil board.
+func generate(rng *rand.Rand, o genOptions) (*Board, int) {
+	var best *Board
+	bestMoves, bestMiss := 0, -1
+	for i := 0; i < o.attempts || best == nil && i < 100*o.attempts; i++ {
+		var b *Board
+		switch {
+		case o.rushHour:
+			b = rushHourBoard(rng, o.w, o.h)
+		case o.inventory != nil:
+			b = inventoryBoard(rng, o.w, o.h, o.inventory)
+		default:
+			b = randomBoard(rng, o.w, o.h)
+		}
+		if b == nil || b.goal.IsSatisfied(b) {
+			continue
+		}
+		var n int
+		if o.rushHour {
+			if b, n = familyStart(b, o.minMoves, o.maxMoves); b == nil {
+				continue
+			}
+		} else {
+			end, _ := solve(b)
+			if end == nil {
+				continue
+			}
+			n = len(end.mvs)
+		}
+		miss := max(o.minMoves-n, n-o.maxMoves, 0)
+		if best == nil || miss < bestMiss {
+			best, bestMoves, bestMiss = b, n, miss
+		}
+		if miss == 0 {
+			break
+		}
+	}
+	return best, bestMoves
+}
+
+// Returns the position reachable from the board whose optimal solution
+// length is in the given range, the longest if several are, or else the
+// closest to it, along with that length. It returns nil if the goal can't be
+// reached.
+func familyStart(b *Board, minMoves, maxMoves int) (*Board, int) {
+	g := buildMoveGraph(b)
+	t := g.distanceTable(b)
+	best, bestMiss := -1, 0
+	for n, d := range t.dist {
+		if d <= 0 {
+			continue
+		}
+		miss := max(minMoves-d, d-maxMoves, 0)
+		if best < 0 || miss < bestMiss || miss == bestMiss && d > t.dist[best] {
+			best, bestMiss = n, miss
+		}
+	}
+	if best < 0 {
+		return nil, 0
+	}
+	return g.board(b, best), t.dist[best]
+}
+
+// A piece placed by the generator.
+type placedPiece struct{ x, y, w, h int }
+
+// Makes a random w by h board with a 2x2 goal piece and small pieces
+// covering all but two spaces. Pieces are labeled in reading order.
+func randomBoard(rng *rand.Rand, w, h int) *Board {
+	// Which piece covers each space, by index into pieces, or -1 if open.
+	cover := newCover(w, h)
+	pieces := []placedPiece{}
+	place := func(p placedPiece) {
+		for y := p.y; y < p.y+p.h; y++ {
+			for x := p.x; x < p.x+p.w; x++ {
+				cover[y][x] = len(pieces)
+			}
+		}
+		pieces = append(pieces, p)
+	}
+	place(placedPiece{rng.Intn(w - 1), rng.Intn(h - 1), 2, 2})
+	for open := 0; open < 2; {
+		x, y := rng.Intn(w), rng.Intn(h)
+		if cover[y][x] == -2 {
+			cover[y][x] = -1
+			open++
+		}
+	}
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			if cover[y][x] != -2 {
+				continue
+			}
+			for {
+				s := smallShapes[rng.Intn(len(smallShapes))]
+				if fits(cover, x, y, s[0], s[1]) {
+					place(placedPiece{x, y, s[0], s[1]})
+					break
+				}
+			}
+		}
+	}
+	return labeledBoard(w, h, pieces)
+}
+
+// Makes a random w by h board holding exactly the given pieces, the first
+// of which must reach the bottom middle. The pieces are placed one at a
+// time, largest first, each at a random space where it fits, starting over
+// if one doesn't fit anywhere. It returns nil if the pieces don't fit after
+// many tries.
+func inventoryBoard(rng *rand.Rand, w, h int, inventory [][2]int) *Board {
+	// The order to place the pieces in: the goal piece, then the rest by
+	// decreasing area, in random order among equals.
+	order := rng.Perm(len(inventory) - 1)
+	sort.SliceStable(order, func(i, j int) bool {
+		si, sj := inventory[order[i]+1], inventory[order[j]+1]
+		return si[0]*si[1] > sj[0]*sj[1]
+	})
+	for try := 0; try < 1000; try++ {
+		cover := newCover(w, h)
+		pieces := make([]placedPiece, len(inventory))
+		ok := true
+		for k := -1; k < len(order) && ok; k++ {
+			i := 0
+			if k >= 0 {
+				i = order[k] + 1
+			}
+			s := inventory[i]
+			spots := []placedPiece{}
+			for y := 0; y+s[1] <= h; y++ {
+				for x := 0; x+s[0] <= w; x++ {
+					if fits(cover, x, y, s[0], s[1]) {
+						spots = append(spots, placedPiece{x, y, s[0], s[1]})
+					}
+				}
+			}
+			if len(spots) == 0 {
+				ok = false
+				break
+			}
+			p := spots[rng.Intn(len(spots))]
+			setCover(cover, p.x, p.y, p.w, p.h, i)
+			pieces[i] = p
+		}
+		if ok {
+			return labeledBoard(w, h, pieces)
+		}
+	}
+	return nil
+}
+
+// Makes a random w by h Rush Hour board: a car in the exit row, which is
+// the middle row rounded up, and random cars and trucks covering 60 to 85
+// percent of the board, none of them horizontal in the exit row. Its goal
+// is to bring the car to the right edge, and it has rails.
+func rushHourBoard(rng *rand.Rand, w, h int) *Board {
+	exit := (h - 1) / 2
+	cover := newCover(w, h)
+	pieces := []placedPiece{{rng.Intn(w - 2), exit, 2, 1}}
+	setCover(cover, pieces[0].x, exit, 2, 1, 0)
+	area, want := 2, w*h*(75+rng.Intn(16))/100
+	for misses := 0; area < want && misses < 100 && len(pieces) < len(pieceLetters); {
+		n := 2
+		if rng.Intn(4) == 0 {
+			n = 3
+		}
+		p := placedPiece{rng.Intn(w), rng.Intn(h), n, 1}
+		if rng.Intn(2) == 0 {
+			p.w, p.h = 1, n
+		}
+		if p.h == 1 && p.y == exit || !fits(cover, p.x, p.y, p.w, p.h) {
+			misses++
+			continue
+		}
+		setCover(cover, p.x, p.y, p.w, p.h, len(pieces))
+		pieces = append(pieces, p)
+		area += n
+	}
+	b := labeledBoard(w, h, pieces)
+	c := b.goal.(Condition)
+	c.x, c.y = w-2, exit
+	b.goal, b.rails = c, true
+	return b
+}
+
+// Returns a w by h grid of undecided spaces.
+func newCover(w, h int) [][]int {
+	cover := make([][]int, h)
+	for y := range cover {
+		cover[y] = make([]int, w)
+		for x := range cover[y] {
+			cover[y][x] = -2 // not yet decided
+		}
+	}
+	return cover
+}
+
+// Makes a board of the given pieces, labeled in reading order of their
+// upper-left squares, whose goal is to bring the first to the bottom
+// middle.
+func labeledBoard(w, h int, pieces []placedPiece) *Board {
+	order := make([]int, len(pieces))
+	for i := range order {
+		order[i] = i
+	}
+	sort.Slice(order, func(i, j int) bool {
+		p, q := pieces[order[i]], pieces[order[j]]
+		return p.y < q.y || p.y == q.y && p.x < q.x
+	})
+	ps := make(map[string]Piece)
+	goalID := ""
+	for n, i := range order {
+		p := pieces[i]
+		id := pieceLetters[n : n+1]
+		ps[id] = Piece{id, p.w, p.h, p.x, p.y}
+		if i == 0 {
+			goalID = id
+		}
+	}
+	g := pieces[0]
+	goal := Condition{pid: goalID, x: (w - g.w) / 2, y: h - g.h}
+	return &Board{w: w, h: h, ps: ps, mvs: []Move{}, goal: goal}
+}
+
+// Parses a piece inventory: comma-separated shapes such as "2x2" or "4*1x2"
+// for four 1x2 pieces. The first shape is the goal piece's.
+func parseInventory(s string) ([][2]int, error) {
+	inventory := [][2]int{}
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		n := 1
+		if count, shape, ok := strings.Cut(part, "*"); ok {
+			var err error
+			if n, err = strconv.Atoi(count); err != nil || n < 1 {
+				return nil, fmt.Errorf("invalid piece count in %q", part)
+			}
+			part = shape
+		}
+		var w, h int
+		if _, err := fmt.Sscanf(part, "%dx%d", &w, &h); err != nil || w < 1 || h < 1 {
+			return nil, fmt.Errorf("invalid piece shape %q", part)
+		}
+		for i := 0; i < n; i++ {
+			inventory = append(inventory, [2]int{w, h})
+		}
+	}
+	return inventory, nil
+}
+
+// Checks that the pieces of an inventory fit on a w by h board with at
+// least one space to spare.
+func checkInventory(w, h int, inventory [][2]int) error {
+	if len(inventory) > len(pieceLetters) {
+		return fmt.Errorf("at most %d pieces fit in a puzzle file", len(pieceLetters))
+	}
+	area := 0
+	for _, s := range inventory {
+		if s[0] > w || s[1] > h {
+			return fmt.Errorf("a %dx%d piece doesn't fit on a %dx%d board", s[0], s[1], w, h)
+		}
+		area += s[0] * s[1]
+	}
+	if area >= w*h {
+		return fmt.Errorf("the pieces cover %d spaces, leaving none of the board's %d open", area, w*h)
+	}
+	return nil
+}
+
+// Reports whether a w by h piece fits at x, y on spaces not yet decided.
+func fits(cover [][]int, x, y, w, h int) bool {
+	if y+h > len(cover) || x+w > len(cover[0]) {
+		return false
+	}
+	for yy := y; yy < y+h; yy++ {
+		for xx := x; xx < x+w; xx++ {
+			if cover[yy][xx] != -2 {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+var genFlags = flag.NewFlagSet("gen", flag.ContinueOnError)
+
+var (
+	genSeed     = genFlags.Int64("seed", 0, "Random seed; 0 picks one from the clock.")
+	genWidth    = genFlags.Int("width", 4, "Board width.")
+	genHeight   = genFlags.Int("height", 5, "Board height.")
+	genMin      = genFlags.Int("min-moves", 30, "Fewest moves the optimal solution may take.")
+	genMax      = genFlags.Int("max-moves", 60, "Most moves the optimal solution may take.")
+	genAttempts = genFlags.Int("attempts", 100, "Candidates to try before settling for the closest.")
+	genPack     = genFlags.Int("pack", 0, "Generate a pack of this many puzzles of increasing length.")
+	genPieces   = genFlags.String("pieces", "", "Use exactly these pieces, goal piece first, e.g. \"2x2,4*1x2,2x1,4*1x1\".")
+	genRushHour = genFlags.Bool("rushhour", false, "Generate Rush Hour puzzles: cars and trucks on rails, 6x6 and 15 to 40 moves by default.")
+)
+
+// Runs "gen".
+func runGen() {
+	w, h := *genWidth, *genHeight
+	minMoves, maxMoves := *genMin, *genMax
+	var inventory [][2]int
+	if *genRushHour {
+		// Rush Hour has its own defaults.
+		set := map[string]bool{}
+		genFlags.Visit(func(f *flag.Flag) { set[f.Name] = true })
+		if !set["width"] {
+			w = 6
+		}
+		if !set["height"] {
+			h = 6
+		}
+		if !set["min-moves"] {
+			minMoves = 15
+		}
+		if !set["max-moves"] {
+			maxMoves = 40
+		}
+		if *genPieces != "" {
+			fmt.Fprintln(os.Stderr, "-pieces can't be used with -rushhour.")
+			os.Exit(exitInvalid)
+		}
+		if w < 3 || h < 2 {
+			fmt.Fprintln(os.Stderr, "A Rush Hour board must be at least 3x2.")
+			os.Exit(exitInvalid)
+		}
+	} else if *genPieces != "" {
+		var err error
+		if inventory, err = parseInventory(*genPieces); err == nil {
+			err = checkInventory(w, h, inventory)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "-pieces: %v\n", err)
+			os.Exit(exitInvalid)
+		}
+	} else if w < 2 || h < 2 || w*h < 6 {
+		fmt.Fprintln(os.Stderr, "The board must be at least 2x2 with room for the 2x2 piece and two open spaces.")
+		os.Exit(exitInvalid)
+	}
+	seed := *genSeed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	o := genOptions{
+		w: w, h: h,
+		minMoves: minMoves, maxMoves: maxMoves,
+		attempts:  max(*genAttempts, 1),
+		inventory: inventory,
+		rushHour:  *genRushHour,
+	}
+	if *genPack > 0 {
+		genCurriculum(rand.New(rand.NewSource(seed)), seed, *genPack, o)
+		return
+	}
+	b, optimal := generate(rand.New(rand.NewSource(seed)), o)
+	if b == nil {
+		fmt.Fprintln(os.Stderr, "Couldn't arrange the pieces into a solvable puzzle.")
+		os.Exit(exitError)
+	}
+	text, err := b.puzzleFile()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(exitError)
+	}
+	fmt.Printf("// Generated puzzle, seed %d. Best possible: %d moves.\n", seed, optimal)
+	if code, err := b.Encode(); err == nil {
+		fmt.Printf("// Board code: %s\n", code)
+	}
+	fmt.Print(text)
+}
+
+// Generates and prints a pack of n puzzles whose optimal lengths rise
+// evenly over the options' range. Each puzzle aims at its share of the
+// range, and the pack is sorted by length in case the aims overlap.
+func genCurriculum(rng *rand.Rand, seed int64, n int, o genOptions) {
+	type entry struct {
+		code  string
+		moves int
+	}
+	entries := []entry{}
+	lo, hi := o.minMoves, max(o.maxMoves, o.minMoves)
+	step := float64(hi-lo) / float64(max(n-1, 1))
+	for i := 0; i < n; i++ {
+		target := float64(lo) + step*float64(i)
+		po := o
+		po.minMoves = int(target - step/2 + 0.5)
+		po.maxMoves = int(target + step/2)
+		fmt.Fprintf(os.Stderr, "Generating puzzle %d of %d (%d-%d moves)...\n", i+1, n, po.minMoves, po.maxMoves)
+		b, moves := generate(rng, po)
+		if b == nil {
+			fmt.Fprintln(os.Stderr, "Couldn't arrange the pieces into a solvable puzzle.")
+			os.Exit(exitError)
+		}
+		code, err := b.Encode()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(exitError)
+		}
+		entries = append(entries, entry{code, moves})
+	}
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].moves < entries[j].moves })
+
+	fmt.Printf("// Generated curriculum, seed %d: %d puzzles of %dx%d from %d to %d moves.\n",
+		seed, n, o.w, o.h, entries[0].moves, entries[len(entries)-1].moves)
+	fmt.Printf("name Curriculum %d\n", seed)
+	for i, e := range entries {
+		fmt.Printf("puzzle %s %d Puzzle %d\n", e.code, e.moves, i+1)
+	}
+}
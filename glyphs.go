@@ -0,0 +1,208 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"unicode/utf8"
+)
+
+// Custom glyphs.
+//
+// With -glyphs, boards in the terminal draw each space with a glyph instead
+// of the piece's letter, which reads better when boards are pasted into chat
+// apps and social posts. "-glyphs emoji" uses colored squares; a glyph file
+// has lines of the form
+//
+//	goal 🟥       glyph of goal pieces
+//	piece 🟦      glyph of other pieces
+//	open ⬜       glyph of open spaces
+//	wall ⬛       glyph of walls
+//	a 🐱          glyph of piece a
+//
+// with "//" comments. Emoji and East Asian characters take two columns in a
+// terminal, so if any glyph does, every space is drawn two columns wide.
+
+var glyphsFlag = flag.String("glyphs", "",
+	"Draw board spaces with glyphs: emoji, or a glyph file.")
+
+type glyphSet struct {
+	goal, piece, open, wall string
+
+	// If set, pieces other than goal pieces take these glyphs in turn.
+	palette []string
+
+	// Glyphs of particular pieces, overriding the others.
+	pieces map[string]string
+}
+
+var emojiGlyphs = glyphSet{
+	goal:    "🟥",
+	piece:   "🟦",
+	open:    "⬜",
+	wall:    "⬛",
+	palette: []string{"🟦", "🟩", "🟨", "🟪", "🟧", "🟫"},
+}
+
+// The glyphs chosen with -glyphs, or nil to draw pieces with their letters.
+var activeGlyphs *glyphSet
+
+// Sets the active glyphs from "emoji" or a glyph file.
+func loadGlyphs(arg string) error {
+	if arg == "" {
+		return nil
+	}
+	if arg == "emoji" {
+		activeGlyphs = &emojiGlyphs
+		return nil
+	}
+	f, err := os.Open(arg)
+	if err != nil {
+		return fmt.Errorf("%q is neither emoji nor a glyph file", arg)
+	}
+	defer f.Close()
+	gs := &glyphSet{open: " ", wall: "#", pieces: make(map[string]string)}
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "//") {
+			continue
+		}
+		args := strings.Fields(line)
+		if len(args) != 2 || textWidth(args[1]) > 2 {
+			return fmt.Errorf("%s:%d: want \"<name> <glyph>\"", arg, n)
+		}
+		switch args[0] {
+		case "goal":
+			gs.goal = args[1]
+		case "piece":
+			gs.piece = args[1]
+		case "open":
+			gs.open = args[1]
+		case "wall":
+			gs.wall = args[1]
+		default:
+			if len(args[0]) != 1 || !isPieceID(args[0][0]) {
+				return fmt.Errorf("%s:%d: unknown name %q", arg, n, args[0])
+			}
+			gs.pieces[args[0]] = args[1]
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	activeGlyphs = gs
+	return nil
+}
+
+// Returns the glyph for a piece on a board, or "" to use its letter.
+func (gs *glyphSet) pieceGlyph(b *Board, pid string) string {
+	if g, ok := gs.pieces[pid]; ok {
+		return g
+	}
+	if b.isGoalPiece(pid) {
+		return gs.goal
+	}
+	if len(gs.palette) == 0 {
+		return gs.piece
+	}
+	i := 0
+	for _, id := range b.pieceIDs() {
+		if id == pid {
+			break
+		}
+		if !b.isGoalPiece(id) {
+			i++
+		}
+	}
+	return gs.palette[i%len(gs.palette)]
+}
+
+// Returns the text drawn for each character of the board's grid, all padded
+// to the same width, and that width in terminal columns.
+func (b *Board) cellTexts() (map[byte]string, int) {
+	cells := make(map[byte]string)
+	for pid := range b.ps {
+		cells[pid[0]] = pid
+	}
+	cells['#'] = "#"
+	cells[' '] = " "
+	for _, ds := range b.oneway {
+		cells[ds.symbol()] = string(ds.symbol())
+	}
+	if gs := activeGlyphs; gs != nil {
+		for pid := range b.ps {
+			if g := gs.pieceGlyph(b, pid); g != "" {
+				cells[pid[0]] = g
+			}
+		}
+		cells['#'] = gs.wall
+		cells[' '] = gs.open
+	}
+	width := 1
+	for _, t := range cells {
+		width = max(width, textWidth(t))
+	}
+	for c, t := range cells {
+		cells[c] = t + strings.Repeat(" ", width-textWidth(t))
+	}
+	return cells, width
+}
+
+// Draws the board like String, with each space drawn by cellTexts.
+func (b *Board) glyphString() string {
+	cells, width := b.cellTexts()
+	grid := b.grid()
+	var sb strings.Builder
+	sb.WriteString(" " + strings.Repeat("_", b.w*width) + "\n")
+	for y := 0; y < b.h; y++ {
+		sb.WriteString("|")
+		for _, c := range []byte(grid.row(y)) {
+			sb.WriteString(cells[c])
+		}
+		sb.WriteString("|\n")
+	}
+	sb.WriteString(" " + strings.Repeat("~", b.w*width) + "\n")
+	return sb.String()
+}
+
+// Returns the number of terminal columns text takes, counting emoji and
+// East Asian wide characters as two columns, and joiners, variation
+// selectors and combining marks as none.
+func textWidth(s string) int {
+	w := 0
+	for _, r := range s {
+		switch {
+		case r == 0x200d:
+			// A zero width joiner makes one glyph of an emoji sequence.
+			return 2
+		case r >= 0x0300 && r <= 0x036f, r >= 0xfe00 && r <= 0xfe0f, r >= 0x1f3fb && r <= 0x1f3ff:
+		case isWide(r):
+			w += 2
+		default:
+			w++
+		}
+	}
+	if w == 0 && utf8.RuneCountInString(s) > 0 {
+		return 1
+	}
+	return w
+}
+
+func isWide(r rune) bool {
+	return r >= 0x1100 && r <= 0x115f ||
+		r >= 0x2e80 && r <= 0xa4cf ||
+		r >= 0xac00 && r <= 0xd7a3 ||
+		r >= 0xf900 && r <= 0xfaff ||
+		r >= 0xfe30 && r <= 0xfe4f ||
+		r >= 0xff00 && r <= 0xff60 ||
+		r >= 0xffe0 && r <= 0xffe6 ||
+		r >= 0x2b1b && r <= 0x2b1c || // ⬛ ⬜
+		r >= 0x25fd && r <= 0x25fe ||
+		r >= 0x2614 && r <= 0x2615 ||
+		r >= 0x26aa && r <= 0x26ab ||
+		r >= 0x1f000 && r <= 0x1faff ||
+		r >= 0x20000 && r <= 0x3fffd
+}
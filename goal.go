@@ -0,0 +1,245 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Goals.
+//
+// A board's Goal decides when the board is solved. The built-in goals are
+// conditions on a single piece's position, combined with AllOf (e.g. both
+// tall pieces in the bottom corners) and AnyOf (e.g. piece b at either of two
+// exits). Custom goals only need to implement the Goal interface.
+
+// Goal is a win condition for a board.
+type Goal interface {
+	// IsSatisfied reports whether the board is solved.
+	IsSatisfied(b *Board) bool
+
+	// Heuristic estimates the number of moves still needed to solve the
+	// board. It must never overestimate; returning 0 is always safe.
+	Heuristic(b *Board) int
+}
+
+// GoalPieces is implemented by goals that refer to specific pieces by id.
+// Those pieces are told apart from other pieces of the same shape when
+// recording which configurations have been seen.
+type GoalPieces interface {
+	Pieces() []string
+}
+
+// Condition requires a piece to have its upper-left square at a position.
+// The piece is named either by id or, when pid is empty, by shape: any piece
+// of the given size satisfies the condition. Shape conditions treat pieces of
+// the same shape as equivalent, just as Board.Config() does.
+type Condition struct {
+	pid  string
+	w, h int // shape, used when pid is empty
+	x, y int
+}
+
+// Whether a matching piece is at the target position. A condition naming a
+// piece or shape the board doesn't have is never satisfied.
+func (c Condition) IsSatisfied(b *Board) bool {
+	if c.pid != "" {
+		p, ok := b.ps[c.pid]
+		return ok && p.x == c.x && p.y == c.y
+	}
+	for _, p := range b.ps {
+		if p.w == c.w && p.h == c.h && p.x == c.x && p.y == c.y {
+			return true
+		}
+	}
+	return false
+}
+
+// The fewest single-space moves that could bring a matching piece to the
+// target position.
+func (c Condition) Heuristic(b *Board) int {
+	if c.pid != "" {
+		p, ok := b.ps[c.pid]
+		if !ok {
+			return 0
+		}
+		return b.distance(Space{p.x, p.y}, Space{c.x, c.y})
+	}
+	best := -1
+	for _, p := range b.ps {
+		if p.w == c.w && p.h == c.h {
+			if d := b.distance(Space{p.x, p.y}, Space{c.x, c.y}); best < 0 || d < best {
+				best = d
+			}
+		}
+	}
+	return max(best, 0)
+}
+
+func (c Condition) Pieces() []string {
+	if c.pid == "" {
+		return nil
+	}
+	return []string{c.pid}
+}
+
+func (c Condition) String() string {
+	if c.pid == "" {
+		return fmt.Sprintf("%dx%d piece at %d,%d", c.w, c.h, c.x, c.y)
+	}
+	return fmt.Sprintf("%s at %d,%d", c.pid, c.x, c.y)
+}
+
+// AllOf is satisfied when all of its goals are.
+type AllOf []Goal
+
+func (gs AllOf) IsSatisfied(b *Board) bool {
+	for _, g := range gs {
+		if !g.IsSatisfied(b) {
+			return false
+		}
+	}
+	return true
+}
+
+// The largest estimate of any of the goals. (Their sum could overestimate
+// when one move brings several goals closer.)
+func (gs AllOf) Heuristic(b *Board) int {
+	h := 0
+	for _, g := range gs {
+		h = max(h, g.Heuristic(b))
+	}
+	return h
+}
+
+func (gs AllOf) Pieces() []string {
+	return goalPieces([]Goal(gs))
+}
+
+func (gs AllOf) String() string {
+	return joinGoals([]Goal(gs), " and ")
+}
+
+// AnyOf is satisfied when any of its goals is.
+type AnyOf []Goal
+
+func (gs AnyOf) IsSatisfied(b *Board) bool {
+	for _, g := range gs {
+		if g.IsSatisfied(b) {
+			return true
+		}
+	}
+	return false
+}
+
+// The smallest estimate of any of the goals.
+func (gs AnyOf) Heuristic(b *Board) int {
+	h := -1
+	for _, g := range gs {
+		if gh := g.Heuristic(b); h < 0 || gh < h {
+			h = gh
+		}
+	}
+	return max(h, 0)
+}
+
+func (gs AnyOf) Pieces() []string {
+	return goalPieces([]Goal(gs))
+}
+
+func (gs AnyOf) String() string {
+	return joinGoals([]Goal(gs), " or ")
+}
+
+// Returns the goal requiring both a and b, either of which may be nil.
+func allOf(a, b Goal) Goal {
+	if a == nil {
+		return b
+	}
+	if b == nil {
+		return a
+	}
+	as, ok := a.(AllOf)
+	if !ok {
+		as = AllOf{a}
+	}
+	return append(append(AllOf{}, as...), b)
+}
+
+// Describes the part of the goal that holds on the given board, e.g. which
+// of several exits the goal piece reached.
+func describeReached(g Goal, b *Board) string {
+	switch g := g.(type) {
+	case AllOf:
+		ds := []string{}
+		for _, sg := range g {
+			ds = append(ds, describeReached(sg, b))
+		}
+		return strings.Join(ds, " and ")
+	case AnyOf:
+		for _, sg := range g {
+			if sg.IsSatisfied(b) {
+				return describeReached(sg, b)
+			}
+		}
+	}
+	return fmt.Sprint(g)
+}
+
+// Is the given piece named by this board's goal.
+func (b *Board) isGoalPiece(pid string) bool {
+	gp, ok := b.goal.(GoalPieces)
+	if !ok {
+		return false
+	}
+	for _, gpid := range gp.Pieces() {
+		if gpid == pid {
+			return true
+		}
+	}
+	return false
+}
+
+// Does this board have a piece of the given shape.
+func (b *Board) hasShape(w, h int) bool {
+	for _, p := range b.ps {
+		if p.w == w && p.h == h {
+			return true
+		}
+	}
+	return false
+}
+
+// The number of single-space moves between two spaces, allowing for
+// wrapping on a toroidal board.
+func (b *Board) distance(s1, s2 Space) int {
+	dx, dy := abs(s1.x-s2.x), abs(s1.y-s2.y)
+	if b.wrap {
+		dx, dy = min(dx, b.w-dx), min(dy, b.h-dy)
+	}
+	return dx + dy
+}
+
+func abs[T int | float64](a T) T {
+	if a < 0 {
+		return -a
+	}
+	return a
+}
+
+func goalPieces(gs []Goal) []string {
+	pids := []string{}
+	for _, g := range gs {
+		if gp, ok := g.(GoalPieces); ok {
+			pids = append(pids, gp.Pieces()...)
+		}
+	}
+	return pids
+}
+
+func joinGoals(gs []Goal, sep string) string {
+	ss := []string{}
+	for _, g := range gs {
+		ss = append(ss, fmt.Sprint(g))
+	}
+	return strings.Join(ss, sep)
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Grading.
+//
+// "squareroot grade <moves>" plays a sequence of moves from the starting
+// board and compares it with an optimal solution using the puzzle's distance
+// table. A move costs the number of moves it adds to the shortest solution:
+// 0 for a move toward the goal, and 1 or 2 for one that keeps the distance
+// or moves away. The moves can be given on the command line or in a file,
+// either a saved game or text in compact notation (such as a solution file
+// exported from play), where a piece letter may
+// be followed by several directions (e.g. "jL fD gUL").
+
+type mistake struct {
+	n    int // 1-based move number
+	m    Move
+	cost int
+}
+
+func runGrade(start *Board, args []string) {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, "usage: squareroot grade [-puzzle file] <file> | <move>...")
+		os.Exit(exitInvalid)
+	}
+	start, mvs, err := readGradedMoves(start, args)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(exitInvalid)
+	}
+
+	fmt.Fprintln(os.Stderr, "Building distance table...")
+	t := buildDistanceTable(start)
+	optimal, _ := t.Distance(start)
+	if optimal < 0 {
+		fmt.Println("The puzzle can't be solved.")
+		os.Exit(exitUnsolvable)
+	}
+
+	b := start
+	d := optimal
+	mistakes := []mistake{}
+	for i, m := range mvs {
+		if b, err = b.ApplyMove(m); err != nil {
+			fmt.Fprintf(os.Stderr, "move %d (%s) is illegal: %v\n", i+1, m.code(), err)
+			os.Exit(exitInvalid)
+		}
+		nd, _ := t.Distance(b)
+		if cost := nd - d + 1; cost > 0 {
+			mistakes = append(mistakes, mistake{i + 1, m, cost})
+		}
+		d = nd
+	}
+
+	fmt.Printf("Your moves: %d\n", len(mvs))
+	fmt.Printf("Optimal:    %d\n", optimal)
+	if d == 0 {
+		fmt.Printf("Reached the goal with %d extra moves.\n", len(mvs)-optimal)
+	} else {
+		fmt.Printf("Stopped %d moves from the goal.\n", d)
+	}
+	if len(mistakes) == 0 {
+		fmt.Println("Every move was optimal.")
+		return
+	}
+	fmt.Printf("First suboptimal move: %d (%s)\n", mistakes[0].n, mistakes[0].m.code())
+	fmt.Println("Mistakes:")
+	for _, mk := range mistakes {
+		fmt.Printf("  move %4d  %-3s costs %d\n", mk.n, mk.m.code(), mk.cost)
+	}
+}
+
+// Reads the moves to grade from the command line or a file. A saved game
+// or solution file also gives the puzzle, replacing start.
+func readGradedMoves(start *Board, args []string) (*Board, []Move, error) {
+	tokens := args
+	if len(args) == 1 {
+		if data, err := readInput(args[0]); err == nil {
+			var s savedGame
+			if b, moves, ok, err := readSolutionFile(data); ok {
+				if err != nil {
+					return nil, nil, fmt.Errorf("%s: %v", args[0], err)
+				}
+				start, tokens = b, moves
+			} else if json.Unmarshal(data, &s) == nil {
+				if start, err = decodeValid(s.Code); err != nil {
+					return nil, nil, fmt.Errorf("%s: %v", args[0], err)
+				}
+				tokens = s.Moves
+			} else {
+				tokens = moveTokens(string(data))
+				if code := solutionPuzzle(string(data)); code != "" {
+					if start, err = decodeValid(code); err != nil {
+						return nil, nil, fmt.Errorf("%s: %v", args[0], err)
+					}
+				}
+			}
+		}
+	}
+	mvs, err := parseMoveList(tokens)
+	return start, mvs, err
+}
+
+// Returns the board code given by a "; Puzzle: <code>" comment line in an
+// exported solution file, or "" if there's none.
+func solutionPuzzle(text string) string {
+	for _, line := range strings.Split(text, "\n") {
+		if code, ok := strings.CutPrefix(strings.TrimSpace(line), "; Puzzle: "); ok {
+			return strings.TrimSpace(code)
+		}
+	}
+	return ""
+}
+
+// Splits text into move tokens, skipping comments starting with ';' as in
+// SBP and solution files, comment lines starting with "//" as in puzzle
+// files, and the rows of the walled grids -format sbp writes.
+func moveTokens(text string) []string {
+	tokens := []string{}
+	for _, line := range strings.Split(text, "\n") {
+		line, _, _ = strings.Cut(line, ";")
+		if trimmed := strings.TrimSpace(line); strings.HasPrefix(trimmed, "//") || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		tokens = append(tokens, strings.Fields(line)...)
+	}
+	return tokens
+}
+
+// Parses moves in compact notation, where a piece letter may be followed by
+// the directions of several consecutive moves.
+func parseMoveList(tokens []string) ([]Move, error) {
+	mvs := []Move{}
+	for _, t := range tokens {
+		if m, err := parseMove(t); err == nil {
+			mvs = append(mvs, m)
+			continue
+		}
+		if len(t) < 2 {
+			return nil, fmt.Errorf("invalid move %q", t)
+		}
+		for _, c := range t[1:] {
+			d, err := parseDirection(string(c))
+			if err != nil {
+				return nil, fmt.Errorf("invalid move %q", t)
+			}
+			mvs = append(mvs, Move{t[:1], d})
+		}
+	}
+	return mvs, nil
+}
@@ -0,0 +1,205 @@
+//go:build gui
+
+package main
+
+// Desktop GUI, built with Ebiten (go build -tags gui).
+//
+// "squareroot gui" opens a window showing the board. Drag a piece with the
+// mouse to slide it: it follows the mouse along one axis as far as it can
+// legally slide, and snaps to the nearest space when released. The Hint
+// button makes the next move of an optimal solution, and Solve animates the
+// rest of an optimal solution.
+
+import (
+	"image/color"
+	"log"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/ebitenutil"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+	"github.com/hajimehoshi/ebiten/v2/vector"
+)
+
+const (
+	guiCell    = 64 // size of a board space in pixels
+	guiMargin  = 16
+	guiButtonH = 32
+	guiButtonW = 96
+
+	// Updates between moves of the solve animation (at 60 updates per second).
+	guiAnimationDelay = 15
+)
+
+var (
+	guiBackground = color.RGBA{0x30, 0x30, 0x30, 0xff}
+	guiButton     = color.RGBA{0x60, 0x60, 0x60, 0xff}
+)
+
+type guiGame struct {
+	g *game
+
+	// The piece being dragged, where the drag started, and the drag's
+	// current offset in pixels along its axis.
+	dragPID                string
+	dragStartX, dragStartY int
+	dragDX, dragDY         int
+
+	// Remaining moves of the solve animation, and updates until the next.
+	animation []Move
+	countdown int
+
+	message string
+}
+
+func runGUI(start *Board) {
+	gg := &guiGame{g: newGame(start), message: "Drag pieces to move them."}
+	w, h := gg.Layout(0, 0)
+	ebiten.SetWindowSize(w, h)
+	ebiten.SetWindowTitle("Square Root")
+	if err := ebiten.RunGame(gg); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func (gg *guiGame) Layout(outsideWidth, outsideHeight int) (int, int) {
+	b := gg.g.b
+	w := max(b.w*guiCell, 2*guiButtonW+guiMargin) + 2*guiMargin
+	h := b.h*guiCell + 3*guiMargin + guiButtonH + 16
+	return w, h
+}
+
+// Screen positions of the board and buttons.
+func (gg *guiGame) boardOrigin() (int, int) { return guiMargin, guiMargin }
+
+func (gg *guiGame) buttonOrigin(i int) (int, int) {
+	return guiMargin + i*(guiButtonW+guiMargin), 2*guiMargin + gg.g.b.h*guiCell
+}
+
+func inRect(x, y, rx, ry, rw, rh int) bool {
+	return x >= rx && y >= ry && x < rx+rw && y < ry+rh
+}
+
+func (gg *guiGame) Update() error {
+	if len(gg.animation) > 0 {
+		gg.countdown--
+		if gg.countdown <= 0 {
+			gg.g.move(gg.animation[0])
+			gg.animation = gg.animation[1:]
+			gg.countdown = guiAnimationDelay
+			gg.updateMessage()
+		}
+		return nil
+	}
+
+	x, y := ebiten.CursorPosition()
+	switch {
+	case inpututil.IsMouseButtonJustPressed(ebiten.MouseButtonLeft):
+		gg.press(x, y)
+	case gg.dragPID != "" && ebiten.IsMouseButtonPressed(ebiten.MouseButtonLeft):
+		gg.drag(x, y)
+	case gg.dragPID != "" && inpututil.IsMouseButtonJustReleased(ebiten.MouseButtonLeft):
+		gg.release()
+	}
+	return nil
+}
+
+func (gg *guiGame) press(x, y int) {
+	if bx, by := gg.buttonOrigin(0); inRect(x, y, bx, by, guiButtonW, guiButtonH) {
+		gg.hint()
+		return
+	}
+	if bx, by := gg.buttonOrigin(1); inRect(x, y, bx, by, guiButtonW, guiButtonH) {
+		gg.solve()
+		return
+	}
+	ox, oy := gg.boardOrigin()
+	if x < ox || y < oy {
+		return
+	}
+	if pid := gg.g.pieceAt(Space{(x - ox) / guiCell, (y - oy) / guiCell}); pid != "" {
+		gg.dragPID = pid
+		gg.dragStartX, gg.dragStartY = x, y
+		gg.dragDX, gg.dragDY = 0, 0
+	}
+}
+
+// Moves the dragged piece with the mouse along the drag's main axis, no
+// further than the piece can legally slide.
+func (gg *guiGame) drag(x, y int) {
+	dx, dy := gg.g.clampDrag(gg.dragPID, float64(x-gg.dragStartX), float64(y-gg.dragStartY), guiCell)
+	gg.dragDX, gg.dragDY = int(dx), int(dy)
+}
+
+// Snaps the dragged piece to the nearest space and makes the moves.
+func (gg *guiGame) release() {
+	gg.g.drop(gg.dragPID, float64(gg.dragDX), float64(gg.dragDY), guiCell)
+	gg.dragPID = ""
+	gg.updateMessage()
+}
+
+func (gg *guiGame) hint() {
+	m, _, err := gg.g.hint()
+	if err != nil {
+		gg.message = err.Error()
+		return
+	}
+	gg.g.move(m)
+	gg.updateMessage()
+}
+
+func (gg *guiGame) solve() {
+	mvs, err := gg.g.solution()
+	if err != nil {
+		gg.message = err.Error()
+		return
+	}
+	gg.animation = mvs
+	gg.countdown = 0
+}
+
+func (gg *guiGame) updateMessage() {
+	gg.message = gg.g.status()
+}
+
+func (gg *guiGame) Draw(screen *ebiten.Image) {
+	screen.Fill(guiBackground)
+	b := gg.g.b
+	ox, oy := gg.boardOrigin()
+	fillRect(screen, ox-4, oy-4, b.w*guiCell+8, b.h*guiCell+8, activeTheme.frame)
+	fillRect(screen, ox, oy, b.w*guiCell, b.h*guiCell, activeTheme.open)
+	for s := range b.walls {
+		fillRect(screen, ox+s.x*guiCell, oy+s.y*guiCell, guiCell, guiCell, activeTheme.wall)
+	}
+	for _, pid := range b.pieceIDs() {
+		p := b.ps[pid]
+		px, py := ox+p.x*guiCell, oy+p.y*guiCell
+		if pid == gg.dragPID {
+			px, py = px+gg.dragDX, py+gg.dragDY
+		}
+		c := activeTheme.pieceColor(b, pid)
+		// Draw each space of the piece, wrapping on a toroidal board.
+		for dy := 0; dy < p.h; dy++ {
+			for dx := 0; dx < p.w; dx++ {
+				sx, sy := px+dx*guiCell, py+dy*guiCell
+				if b.wrap {
+					sx = ox + mod(sx-ox, b.w*guiCell)
+					sy = oy + mod(sy-oy, b.h*guiCell)
+				}
+				fillRect(screen, sx+2, sy+2, guiCell-4, guiCell-4, c)
+			}
+		}
+		ebitenutil.DebugPrintAt(screen, pid, px+guiCell/2-3, py+guiCell/2-8)
+	}
+
+	for i, label := range []string{"Hint", "Solve"} {
+		bx, by := gg.buttonOrigin(i)
+		fillRect(screen, bx, by, guiButtonW, guiButtonH, guiButton)
+		ebitenutil.DebugPrintAt(screen, label, bx+guiButtonW/2-3*len(label), by+guiButtonH/2-8)
+	}
+	_, by := gg.buttonOrigin(0)
+	ebitenutil.DebugPrintAt(screen, gg.message, guiMargin, by+guiButtonH+4)
+}
+
+func fillRect(dst *ebiten.Image, x, y, w, h int, c color.Color) {
+	vector.DrawFilledRect(dst, float32(x), float32(y), float32(w), float32(h), c, false)
+}
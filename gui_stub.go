@@ -0,0 +1,15 @@
+//go:build !gui
+
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+// The desktop GUI needs Ebiten, so it's only included in builds with the
+// "gui" tag (go build -tags gui).
+func runGUI(start *Board) {
+	fmt.Fprintln(os.Stderr, "This squareroot was built without the GUI. Rebuild with: go build -tags gui")
+	os.Exit(1)
+}
@@ -0,0 +1,221 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"math/rand"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Hardest arrangements.
+//
+// "squareroot hardest -pieces <inventory>" looks for the arrangement of a
+// set of pieces on a -width by -height board whose optimal solution is the
+// longest, the goal being to bring the first piece to the bottom middle as
+// in "gen". Every arrangement belongs to a family of arrangements reachable
+// from one another by moves, and one distance table gives the optimal
+// solution length of the whole family, so the search explores families
+// rather than single arrangements: a family's hardest arrangement is its
+// farthest from the goal.
+//
+// When the pieces have at most -limit arrangements, the search is
+// exhaustive: it explores the family of every arrangement, so the champion
+// it reports is the hardest there is. Otherwise it explores the families of
+// -samples random arrangements, and the champion is only the hardest found.
+
+var hardestFlags = flag.NewFlagSet("hardest", flag.ContinueOnError)
+
+var (
+	hardestPieces  = hardestFlags.String("pieces", "2x2,4*1x2,2x1,4*1x1", "The pieces, goal piece first, as for gen -pieces.")
+	hardestWidth   = hardestFlags.Int("width", 4, "Board width.")
+	hardestHeight  = hardestFlags.Int("height", 5, "Board height.")
+	hardestLimit   = hardestFlags.Int("limit", 1000000, "Most arrangements to search exhaustively.")
+	hardestSamples = hardestFlags.Int("samples", 200, "Random arrangements whose families to explore when there are too many to search exhaustively.")
+	hardestSeed    = hardestFlags.Int64("seed", 0, "Random seed; 0 picks one from the clock.")
+)
+
+// Runs "hardest".
+func runHardest() {
+	w, h := *hardestWidth, *hardestHeight
+	inventory, err := parseInventory(*hardestPieces)
+	if err == nil {
+		err = checkInventory(w, h, inventory)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "-pieces: %v\n", err)
+		os.Exit(exitInvalid)
+	}
+
+	s := &hardestSearch{explored: make(map[string]bool)}
+	n := enumerateArrangements(w, h, inventory, *hardestLimit+1, nil)
+	if n <= *hardestLimit {
+		fmt.Fprintf(os.Stderr, "Searching all %d arrangements...\n", n)
+		enumerateArrangements(w, h, inventory, n, func(pieces []placedPiece) {
+			s.explore(labeledBoard(w, h, pieces))
+		})
+	} else {
+		seed := *hardestSeed
+		if seed == 0 {
+			seed = time.Now().UnixNano()
+		}
+		fmt.Fprintf(os.Stderr, "More than %d arrangements; exploring %d random ones, seed %d...\n", *hardestLimit, *hardestSamples, seed)
+		rng := rand.New(rand.NewSource(seed))
+		for i := 0; i < *hardestSamples; i++ {
+			if b := inventoryBoard(rng, w, h, inventory); b != nil {
+				s.explore(b)
+			}
+		}
+	}
+
+	if s.best == nil {
+		fmt.Println("No arrangement of the pieces can be solved.")
+		os.Exit(exitUnsolvable)
+	}
+	text, err := s.best.puzzleFile()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(exitError)
+	}
+	claim := "Hardest found"
+	if n <= *hardestLimit {
+		claim = "Hardest possible"
+	}
+	fmt.Printf("// %s: %d moves. Explored %d arrangements in %d families.\n",
+		claim, s.bestMoves, len(s.explored), s.families)
+	if code, err := s.best.Encode(); err == nil {
+		fmt.Printf("// Board code: %s\n", code)
+	}
+	fmt.Print(text)
+}
+
+// The state of a search for the hardest arrangement.
+type hardestSearch struct {
+	// The arrangements in the families explored so far, by arrangementKey.
+	explored map[string]bool
+
+	families  int
+	best      *Board
+	bestMoves int
+}
+
+// Explores the family of the given arrangement, unless it's been explored
+// already, keeping its hardest arrangement if it's the best so far.
+func (s *hardestSearch) explore(b *Board) {
+	goalID := b.goal.(Condition).pid
+	if s.explored[arrangementKey(b.Config(), goalID)] {
+		return
+	}
+	g := buildMoveGraph(b)
+	for config := range g.index {
+		s.explored[arrangementKey(config, goalID)] = true
+	}
+	s.families++
+	t := g.distanceTable(b)
+	far := -1
+	for n, d := range t.dist {
+		if far < 0 || d > t.dist[far] {
+			far = n
+		}
+	}
+	if t.dist[far] > s.bestMoves {
+		s.best, s.bestMoves = g.board(b, far), t.dist[far]
+		fmt.Fprintf(os.Stderr, "Found an arrangement needing %d moves\n", s.bestMoves)
+	}
+}
+
+// Returns a configuration without the goal piece's id, which depends on how
+// the arrangement's pieces were labeled rather than where they are.
+func arrangementKey(config, goalID string) string {
+	return strings.Replace(config, "@"+goalID, "@", 1)
+}
+
+// Returns the board with the given node, found by searching the moves from
+// the graph's starting board. The board has no moves.
+func (g *moveGraph) board(start *Board, n int) *Board {
+	root := *start
+	root.mvs = []Move{}
+	seen := map[string]bool{root.Config(): true}
+	bs := []*Board{&root}
+	for len(bs) > 0 {
+		b := bs[0]
+		bs = bs[1:]
+		if g.index[b.Config()] == n {
+			b.mvs = []Move{}
+			return b
+		}
+		for _, m := range b.possibleMoves() {
+			nb := b.move(m)
+			nb.mvs = nil
+			if !seen[nb.Config()] {
+				seen[nb.Config()] = true
+				bs = append(bs, nb)
+			}
+		}
+	}
+	return nil
+}
+
+// Calls f, if not nil, with each arrangement of the pieces on a w by h
+// board, stopping after limit of them, and returns the number. Pieces of
+// the same shape other than the goal piece are interchangeable, so each
+// arrangement of them is produced once.
+func enumerateArrangements(w, h int, inventory [][2]int, limit int, f func([]placedPiece)) int {
+	// Place the goal piece first, then the others grouped by shape.
+	order := make([]int, len(inventory)-1)
+	for i := range order {
+		order[i] = i + 1
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		si, sj := inventory[order[i]], inventory[order[j]]
+		return si[0]*si[1] > sj[0]*sj[1] || si[0]*si[1] == sj[0]*sj[1] && si[0] > sj[0]
+	})
+	order = append([]int{0}, order...)
+
+	cover := newCover(w, h)
+	pieces := make([]placedPiece, len(inventory))
+	count := 0
+	var place func(k, from int)
+	place = func(k, from int) {
+		if count >= limit {
+			return
+		}
+		if k == len(order) {
+			count++
+			if f != nil {
+				f(append([]placedPiece{}, pieces...))
+			}
+			return
+		}
+		i := order[k]
+		s := inventory[i]
+		for pos := from; pos < w*h; pos++ {
+			x, y := pos%w, pos/w
+			if !fits(cover, x, y, s[0], s[1]) {
+				continue
+			}
+			setCover(cover, x, y, s[0], s[1], i)
+			pieces[i] = placedPiece{x, y, s[0], s[1]}
+			// The next piece of the same shape goes after this one.
+			next := 0
+			if k+1 < len(order) && k > 0 && inventory[order[k+1]] == s {
+				next = pos + 1
+			}
+			place(k+1, next)
+			setCover(cover, x, y, s[0], s[1], -2)
+		}
+	}
+	place(0, 0)
+	return count
+}
+
+// Marks the spaces of a w by h piece at x, y in cover.
+func setCover(cover [][]int, x, y, w, h, v int) {
+	for yy := y; yy < y+h; yy++ {
+		for xx := x; xx < x+w; xx++ {
+			cover[yy][xx] = v
+		}
+	}
+}
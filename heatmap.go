@@ -0,0 +1,257 @@
+package main
+
+import (
+	"encoding/csv"
+	"flag"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Activity heatmaps.
+//
+// "squareroot heatmap [file]" counts how often pieces move into each space
+// over a solution, and how many moves each piece makes, and shows them as a
+// colored grid in the terminal, or writes them to a PNG or CSV file. With
+// -all-optimal, the counts are averaged over all optimal solutions instead
+// (those that differ only in which of two same-shaped pieces moves count as
+// one), showing which spaces and pieces every solution has to work through.
+
+var allOptimal = flag.Bool("all-optimal", false,
+	"Average heatmap counts over all optimal solutions.")
+
+type heatmap struct {
+	w, h   int
+	cells  [][]float64        // moves into each space, by row and column
+	pieces map[string]float64 // moves of each piece
+	pids   []string
+}
+
+func newHeatmap(b *Board) *heatmap {
+	hm := &heatmap{w: b.w, h: b.h, pieces: make(map[string]float64), pids: b.pieceIDs()}
+	hm.cells = make([][]float64, b.h)
+	for y := range hm.cells {
+		hm.cells[y] = make([]float64, b.w)
+	}
+	return hm
+}
+
+// Counts a move on the given board, with the given weight.
+func (hm *heatmap) add(b *Board, m Move, weight float64) {
+	for _, s := range b.targetSpaces(m) {
+		hm.cells[s.y][s.x] += weight
+	}
+	hm.pieces[m.pid] += weight
+}
+
+// Counts the moves of one solution.
+func solutionHeatmap(start *Board, mvs []Move) *heatmap {
+	hm := newHeatmap(start)
+	b := start
+	for _, m := range mvs {
+		hm.add(b, m, 1)
+		b = b.move(m)
+	}
+	return hm
+}
+
+// Counts the moves of all optimal solutions, each weighted by the fraction
+// of optimal solutions that make it. It returns nil if the puzzle can't be
+// solved.
+func optimalHeatmap(start *Board) *heatmap {
+	t := buildDistanceTable(start)
+	d, _ := t.Distance(start)
+	if d < 0 {
+		return nil
+	}
+
+	// The optimal moves from each configuration, found layer by layer, with
+	// the number of optimal paths from the start to each configuration.
+	type edge struct {
+		b  *Board
+		m  Move
+		to string
+	}
+	boards := map[string]*Board{start.Config(): start}
+	paths := map[string]float64{start.Config(): 1}
+	edges := map[string][]edge{}
+	layers := [][]string{{start.Config()}}
+	for ; d > 0; d-- {
+		next := []string{}
+		for _, c := range layers[len(layers)-1] {
+			b := boards[c]
+			for _, m := range b.possibleMoves() {
+				nb := b.move(m)
+				if nd, _ := t.Distance(nb); nd != d-1 {
+					continue
+				}
+				nc := nb.Config()
+				if _, ok := boards[nc]; !ok {
+					boards[nc] = nb
+					next = append(next, nc)
+				}
+				paths[nc] += paths[c]
+				edges[c] = append(edges[c], edge{b, m, nc})
+			}
+		}
+		layers = append(layers, next)
+	}
+
+	// The number of optimal paths from each configuration to the goal.
+	toGoal := map[string]float64{}
+	for _, c := range layers[len(layers)-1] {
+		toGoal[c] = 1
+	}
+	for i := len(layers) - 2; i >= 0; i-- {
+		for _, c := range layers[i] {
+			for _, e := range edges[c] {
+				toGoal[c] += toGoal[e.to]
+			}
+		}
+	}
+
+	hm := newHeatmap(start)
+	total := toGoal[start.Config()]
+	for _, layer := range layers {
+		for _, c := range layer {
+			for _, e := range edges[c] {
+				hm.add(e.b, e.m, paths[c]*toGoal[e.to]/total)
+			}
+		}
+	}
+	return hm
+}
+
+func (hm *heatmap) maxCell() float64 {
+	most := 0.0
+	for _, row := range hm.cells {
+		for _, n := range row {
+			most = max(most, n)
+		}
+	}
+	return most
+}
+
+// Formats a count, with a decimal place if it's fractional.
+func formatCount(n float64) string {
+	n = math.Round(n*10) / 10
+	if n == math.Trunc(n) {
+		return strconv.Itoa(int(n))
+	}
+	return strconv.FormatFloat(n, 'f', 1, 64)
+}
+
+// Returns a color from pale yellow (t = 0) through orange to dark red (t = 1).
+func heatColor(t float64) color.RGBA {
+	stops := []color.RGBA{rgb(0xffffcc), rgb(0xfd8d3c), rgb(0xb10026)}
+	t = min(max(t, 0), 1) * float64(len(stops)-1)
+	i := min(int(t), len(stops)-2)
+	f := t - float64(i)
+	mix := func(a, b uint8) uint8 { return uint8(float64(a) + f*(float64(b)-float64(a))) }
+	a, b := stops[i], stops[i+1]
+	return color.RGBA{mix(a.R, b.R), mix(a.G, b.G), mix(a.B, b.B), 0xff}
+}
+
+// Formats the heatmap for the terminal, with each space colored by its count.
+func (hm *heatmap) String() string {
+	var sb strings.Builder
+	most := hm.maxCell()
+	sb.WriteString("Moves into each space:\n")
+	for _, row := range hm.cells {
+		for _, n := range row {
+			c := heatColor(n / max(most, 1))
+			fmt.Fprintf(&sb, "%s%6s %s", ansiColors(labelColor(c), c), formatCount(n), ansiReset)
+		}
+		sb.WriteString("\n")
+	}
+	sb.WriteString("Moves of each piece:\n")
+	for _, pid := range hm.pids {
+		fmt.Fprintf(&sb, "  %s %6s\n", pid, formatCount(hm.pieces[pid]))
+	}
+	return sb.String()
+}
+
+// Draws the heatmap of spaces, each labeled with its count.
+func (hm *heatmap) image(cell int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, hm.w*cell, hm.h*cell))
+	most := hm.maxCell()
+	for y, row := range hm.cells {
+		for x, n := range row {
+			c := heatColor(n / max(most, 1))
+			r := image.Rect(x*cell, y*cell, (x+1)*cell, (y+1)*cell)
+			draw.Draw(img, r, image.NewUniform(c), image.Point{}, draw.Src)
+			// Whole counts only: the font has no decimal point.
+			drawText(img, strconv.Itoa(int(n+0.5)), r.Min.X+cell/2, r.Min.Y+cell/2, max(cell/32, 1), labelColor(c))
+		}
+	}
+	return img
+}
+
+// Writes the heatmap as CSV: a row for each space and then each piece.
+func (hm *heatmap) writeCSV(path string) error {
+	f, err := createOutput(path)
+	if err != nil {
+		return err
+	}
+	w := csv.NewWriter(f)
+	w.Write([]string{"kind", "piece", "x", "y", "moves"})
+	for y, row := range hm.cells {
+		for x, n := range row {
+			w.Write([]string{"space", "", strconv.Itoa(x), strconv.Itoa(y), formatCount(n)})
+		}
+	}
+	for _, pid := range hm.pids {
+		w.Write([]string{"piece", pid, "", "", formatCount(hm.pieces[pid])})
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Runs "heatmap [file.png | file.csv]".
+func runHeatmap(start *Board, args []string) {
+	if len(args) > 1 {
+		fmt.Fprintln(os.Stderr, "usage: squareroot heatmap [-all-optimal] [file.png | file.csv]")
+		os.Exit(exitInvalid)
+	}
+	var hm *heatmap
+	if *allOptimal {
+		hm = optimalHeatmap(start)
+	} else if end, _ := findSolution(start); end != nil {
+		hm = solutionHeatmap(start, end.mvs)
+	}
+	if hm == nil {
+		fmt.Fprintln(os.Stderr, "Couldn't find solution")
+		os.Exit(1)
+	}
+	if len(args) == 0 {
+		fmt.Print(hm)
+		return
+	}
+	var err error
+	ext := strings.ToLower(filepath.Ext(args[0]))
+	if args[0] == "-" {
+		ext = ".csv"
+	}
+	switch ext {
+	case ".png":
+		err = writePNG(args[0], hm.image(max(*cellSize, 16)))
+	case ".csv":
+		err = hm.writeCSV(args[0])
+	default:
+		err = fmt.Errorf("%s: want a .png or .csv file, or - for CSV on standard output", args[0])
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
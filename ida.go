@@ -0,0 +1,62 @@
+package main
+
+import "math"
+
+// Searches for the shortest solution with IDA*: depth-first searches for a
+// solution whose moves taken plus the goal's estimate of moves remaining
+// stay within a bound, starting from the start's estimate and raising the
+// bound to the smallest total that went over it each round. It remembers
+// only the boards on the current line, which it doesn't return to, so it
+// needs little memory but repeats work wherever lines meet again; it's for
+// small puzzles and for checking the other solvers. The stats count the
+// boards generated, repeats included. An unsolvable puzzle is proven so
+// only once every line without repeats has been searched.
+func solveIDAStar(start *Board) (*Board, Stats) {
+	h := func(b *Board) int { return b.goal.Heuristic(b) }
+	stats := Stats{Configs: 1}
+	onLine := map[string]bool{start.Config(): true}
+
+	// Searches below b within the bound, returning the solution or else the
+	// smallest total over the bound, math.MaxInt if there's none, or -1 if
+	// the search ran out of time.
+	var search func(b *Board, bound int) (*Board, int)
+	search = func(b *Board, bound int) (*Board, int) {
+		f := len(b.mvs) - len(start.mvs) + h(b)
+		if f > bound {
+			return nil, f
+		}
+		if b.goal.IsSatisfied(b) {
+			return b, f
+		}
+		if searchExpired() {
+			return nil, -1
+		}
+		stats.Expanded++
+		tracer.expand(b)
+		next := math.MaxInt
+		for _, m := range b.orderedMoves() {
+			config := b.configAfter(m)
+			if onLine[config] || avoided[config] {
+				stats.Skipped++
+				continue
+			}
+			stats.Configs++
+			onLine[config] = true
+			end, over := search(b.move(m), bound)
+			delete(onLine, config)
+			if end != nil || over < 0 {
+				return end, over
+			}
+			next = min(next, over)
+		}
+		return nil, next
+	}
+
+	for bound := h(start); ; {
+		end, over := search(start, bound)
+		if end != nil || over < 0 || over == math.MaxInt {
+			return end, stats
+		}
+		bound = over
+	}
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// Installed puzzles.
+//
+// Users keep their own puzzle files and packs in the squareroot/puzzles
+// directory under $XDG_DATA_HOME, ~/.local/share by default. "catalog"
+// lists installed puzzles after the built-in ones and "catalog show" finds
+// them by name, "campaign" finds installed packs by name, packs can name
+// installed puzzles, and "daily -installed" picks the day's puzzle from
+// them.
+//
+// "squareroot install <file>..." checks that each file is a valid puzzle
+// file or, ending in .pack, a pack whose puzzles can all be found once it's
+// installed, and copies it there; packs are installed after the puzzle
+// files given with them. A file is refused if a built-in puzzle or pack has
+// its name, or an installed one with different contents does, unless
+// -replace is given; installing the same file again does nothing.
+// "squareroot uninstall <name>..." removes installed files, named with or
+// without their extensions.
+
+var installFlags = flag.NewFlagSet("install", flag.ContinueOnError)
+
+var installReplace = installFlags.Bool("replace", false, "Replace installed files of the same name.")
+
+// The extensions of installable files.
+var installExts = []string{".txt", ".sbp", ".pack"}
+
+// Returns the directory holding installed puzzles, or "" if there's no home
+// directory to put it in.
+func userPuzzleDir() string {
+	dir := os.Getenv("XDG_DATA_HOME")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return ""
+		}
+		dir = filepath.Join(home, ".local", "share")
+	}
+	return filepath.Join(dir, "squareroot", "puzzles")
+}
+
+// Returns the directories puzzles are found in by name: the built-in
+// puzzles, then the installed puzzles directory.
+func catalogDirs() []string {
+	dirs := []string{builtinPrefix}
+	if dir := userPuzzleDir(); dir != "" {
+		dirs = append(dirs, dir)
+	}
+	return dirs
+}
+
+// Runs "install <file>...".
+func runInstall(args []string) {
+	dir := userPuzzleDir()
+	if len(args) == 0 || dir == "" {
+		fmt.Fprintln(os.Stderr, "usage: squareroot install [-replace] <file>...")
+		os.Exit(exitInvalid)
+	}
+	// Install packs last, so they can name the puzzles installed with them.
+	sort.SliceStable(args, func(i, j int) bool {
+		return filepath.Ext(args[i]) != ".pack" && filepath.Ext(args[j]) == ".pack"
+	})
+	failed := false
+	for _, src := range args {
+		msg, err := install(src, dir)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", src, err)
+			failed = true
+			continue
+		}
+		fmt.Printf("%s: %s\n", src, msg)
+	}
+	if failed {
+		os.Exit(exitInvalid)
+	}
+}
+
+// Installs a file in dir, returning what was done.
+func install(src, dir string) (string, error) {
+	ext := filepath.Ext(src)
+	if !slices.Contains(installExts, ext) {
+		return "", fmt.Errorf("not a puzzle file or pack: want a name ending in %s", strings.Join(installExts, ", "))
+	}
+	data, err := os.ReadFile(src)
+	if err != nil {
+		return "", err
+	}
+	if ext == ".pack" {
+		_, err = readPack(src)
+	} else {
+		_, err = readBoardFile(src)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	dst := filepath.Join(dir, filepath.Base(src))
+	stem := strings.TrimSuffix(filepath.Base(src), ext)
+	if taken := findNamed(builtinPrefix, stem); taken != "" {
+		return "", fmt.Errorf("the built-in %s has that name; rename the file", catalogBase(taken))
+	}
+	if taken := findNamed(dir, stem); taken != "" {
+		if old, err := os.ReadFile(taken); err == nil && taken == dst && bytes.Equal(old, data) {
+			return "already installed", nil
+		}
+		if !*installReplace {
+			return "", fmt.Errorf("%s is already installed; use -replace to replace it", filepath.Base(taken))
+		}
+		if err := os.Remove(taken); err != nil {
+			return "", err
+		}
+	}
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(dst, data, 0o644); err != nil {
+		return "", err
+	}
+	if ext == ".pack" {
+		// The pack's puzzles are now found relative to its new home.
+		if _, err := readPack(dst); err != nil {
+			os.Remove(dst)
+			return "", fmt.Errorf("%v once installed; install the puzzle files it names too", err)
+		}
+	}
+	return "installed as " + dst, nil
+}
+
+// Returns the installable file in dir, which may be the built-in one, with
+// the given name, with or without its extension, or "" if there's none.
+func findNamed(dir, name string) string {
+	for _, ext := range append([]string{""}, installExts...) {
+		path := catalogPath(dir, name+ext)
+		if slices.Contains(installExts, filepath.Ext(path)) && puzzleFileExists(path) {
+			return path
+		}
+	}
+	return ""
+}
+
+// Runs "uninstall <name>...".
+func runUninstall(args []string) {
+	dir := userPuzzleDir()
+	if len(args) == 0 || dir == "" {
+		fmt.Fprintln(os.Stderr, "usage: squareroot uninstall <name>...")
+		os.Exit(exitInvalid)
+	}
+	failed := false
+	for _, name := range args {
+		path := findNamed(dir, name)
+		if path == "" {
+			fmt.Fprintf(os.Stderr, "%s: not installed\n", name)
+			failed = true
+			continue
+		}
+		if err := os.Remove(path); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			failed = true
+			continue
+		}
+		fmt.Printf("Removed %s\n", path)
+	}
+	if failed {
+		os.Exit(exitInvalid)
+	}
+}
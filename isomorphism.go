@@ -0,0 +1,280 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// Puzzle isomorphism.
+//
+// Two puzzles are isomorphic when one is the other turned over or around,
+// with its pieces perhaps lettered differently: they have the same
+// solutions, move for move. A puzzle's canonical form is the same for every
+// puzzle isomorphic to it. It's found by trying each of the eight ways of
+// reflecting and rotating the board, relettering the pieces of each in
+// reading order, and keeping the smallest puzzle file text; walls, one-way
+// cells, links and the goal are carried along.
+//
+// "squareroot dedupe <file or dir>..." finds isomorphic puzzle files among
+// those given (and the puzzle files in the directories given) and deletes
+// all but the first of each set, in the order given, listing what it
+// deletes. With -n it only lists them.
+
+var dedupeFlags = flag.NewFlagSet("dedupe", flag.ContinueOnError)
+
+var dedupeDryRun = dedupeFlags.Bool("n", false, "List the duplicates without deleting them.")
+
+// Returns the board reflected and rotated by one of the eight symmetries of
+// a rectangle: first swapping rows and columns if transpose is set, then
+// mirroring left to right and top to bottom as asked.
+func (b *Board) transformed(transpose, flipX, flipY bool) *Board {
+	nb := *b
+	nb.mvs = []Move{}
+	if transpose {
+		nb.w, nb.h = b.h, b.w
+	}
+	// Maps a rectangle's top left and size onto the new board.
+	rect := func(x, y, w, h int) (int, int, int, int) {
+		if transpose {
+			x, y, w, h = y, x, h, w
+		}
+		if flipX {
+			x = nb.w - x - w
+		}
+		if flipY {
+			y = nb.h - y - h
+		}
+		if b.wrap {
+			x, y = mod(x, nb.w), mod(y, nb.h)
+		}
+		return x, y, w, h
+	}
+	space := func(s Space) Space {
+		x, y, _, _ := rect(s.x, s.y, 1, 1)
+		return Space{x, y}
+	}
+	dir := func(d Direction) Direction {
+		if transpose {
+			d = map[Direction]Direction{Up: Left, Left: Up, Down: Right, Right: Down}[d]
+		}
+		if flipX && (d == Left || d == Right) {
+			d = Left + Right - d
+		}
+		if flipY && (d == Up || d == Down) {
+			d = Up + Down - d
+		}
+		return d
+	}
+
+	nb.ps = make(map[string]Piece)
+	for pid, p := range b.ps {
+		x, y, w, h := rect(p.x, p.y, p.w, p.h)
+		nb.ps[pid] = Piece{pid, w, h, x, y}
+	}
+	nb.walls = make(map[Space]bool)
+	for s := range b.walls {
+		nb.walls[space(s)] = true
+	}
+	if b.oneway != nil {
+		nb.oneway = make(map[Space]DirSet)
+		for s, ds := range b.oneway {
+			var nds DirSet
+			for _, d := range Directions {
+				if ds.has(d) {
+					nds = nds.add(dir(d))
+				}
+			}
+			nb.oneway[space(s)] = nds
+		}
+	}
+	nb.goal = mapGoal(b.goal, func(c Condition) Condition {
+		w, h := c.w, c.h
+		if c.pid != "" {
+			w, h = b.ps[c.pid].w, b.ps[c.pid].h
+		}
+		x, y, nw, nh := rect(c.x, c.y, w, h)
+		if c.pid == "" {
+			c.w, c.h = nw, nh
+		}
+		c.x, c.y = x, y
+		return c
+	})
+	return &nb
+}
+
+// The letters given to pieces by relettered, in order.
+const pieceLetters = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+
+// Returns the board with its pieces lettered in reading order of their top
+// left squares.
+func (b *Board) relettered() (*Board, error) {
+	if len(b.ps) > len(pieceLetters) {
+		return nil, fmt.Errorf("too many pieces to reletter")
+	}
+	pids := b.pieceIDs()
+	sort.Slice(pids, func(i, j int) bool {
+		p, q := b.ps[pids[i]], b.ps[pids[j]]
+		return p.y < q.y || p.y == q.y && p.x < q.x
+	})
+	letter := map[string]string{}
+	for i, pid := range pids {
+		letter[pid] = pieceLetters[i : i+1]
+	}
+
+	nb := *b
+	nb.ps = make(map[string]Piece)
+	for pid, p := range b.ps {
+		p.id = letter[pid]
+		nb.ps[p.id] = p
+	}
+	if b.links != nil {
+		nb.links = make(map[string][]string)
+		for pid, g := range b.links {
+			ng := []string{}
+			for _, gpid := range g {
+				ng = append(ng, letter[gpid])
+			}
+			sort.Strings(ng)
+			nb.links[letter[pid]] = ng
+		}
+	}
+	nb.goal = mapGoal(b.goal, func(c Condition) Condition {
+		if c.pid != "" {
+			c.pid = letter[c.pid]
+		}
+		return c
+	})
+	return &nb, nil
+}
+
+// Returns the goal with f applied to each of its conditions. Goals other
+// than conditions, AllOf and AnyOf are returned as they are.
+func mapGoal(g Goal, f func(Condition) Condition) Goal {
+	switch g := g.(type) {
+	case Condition:
+		return f(g)
+	case AllOf:
+		ng := AllOf{}
+		for _, sg := range g {
+			ng = append(ng, mapGoal(sg, f))
+		}
+		return ng
+	case AnyOf:
+		ng := AnyOf{}
+		for _, sg := range g {
+			ng = append(ng, mapGoal(sg, f))
+		}
+		return ng
+	}
+	return g
+}
+
+// Returns the goal with the parts of each AllOf and AnyOf in a standard
+// order, so that goals differing only in the order they list them are
+// written alike.
+func sortedGoal(g Goal) Goal {
+	sortParts := func(gs []Goal) []Goal {
+		ns := []Goal{}
+		for _, sg := range gs {
+			ns = append(ns, sortedGoal(sg))
+		}
+		sort.SliceStable(ns, func(i, j int) bool {
+			ti, _ := goalText(ns[i])
+			tj, _ := goalText(ns[j])
+			return ti < tj
+		})
+		return ns
+	}
+	switch g := g.(type) {
+	case AllOf:
+		return AllOf(sortParts(g))
+	case AnyOf:
+		return AnyOf(sortParts(g))
+	}
+	return g
+}
+
+// Returns the board's canonical form, the same for every board isomorphic
+// to it.
+func (b *Board) canonical() (string, error) {
+	best := ""
+	for t := 0; t < 8; t++ {
+		nb, err := b.transformed(t&4 != 0, t&2 != 0, t&1 != 0).relettered()
+		if err != nil {
+			return "", err
+		}
+		nb.goal = sortedGoal(nb.goal)
+		text, err := nb.puzzleFile()
+		if err != nil {
+			return "", err
+		}
+		if b.wrap {
+			text = "torus\n" + text
+		}
+		if best == "" || text < best {
+			best = text
+		}
+	}
+	return best, nil
+}
+
+// Runs "dedupe <file or dir>...".
+func runDedupe(args []string) {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, "usage: squareroot dedupe [-n] <file or dir>...")
+		os.Exit(exitInvalid)
+	}
+	names := []string{}
+	listed := map[string]bool{}
+	for _, arg := range args {
+		files := []string{arg}
+		if fi, err := os.Stat(arg); err == nil && fi.IsDir() {
+			files = catalogFiles(arg)
+		}
+		for _, name := range files {
+			// A file named twice is not its own duplicate.
+			if !listed[filepath.Clean(name)] {
+				listed[filepath.Clean(name)] = true
+				names = append(names, name)
+			}
+		}
+	}
+
+	// The first file with each canonical form.
+	firsts := map[string]string{}
+	removed, failed := 0, false
+	for _, name := range names {
+		b, err := readBoardFile(name)
+		if err == nil {
+			b.wrap = *torus
+			var canon string
+			if canon, err = b.canonical(); err == nil {
+				first, ok := firsts[canon]
+				if !ok {
+					firsts[canon] = name
+					continue
+				}
+				fmt.Printf("%s duplicates %s\n", name, first)
+				removed++
+				if !*dedupeDryRun {
+					err = os.Remove(name)
+				}
+			}
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", name, err)
+			failed = true
+		}
+	}
+	verb := "Removed"
+	if *dedupeDryRun {
+		verb = "Found"
+	}
+	fmt.Printf("%s %s among %s.\n", verb, countOf(removed, "duplicate"), countOf(len(names), "puzzle"))
+	if failed {
+		os.Exit(exitError)
+	}
+}
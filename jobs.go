@@ -0,0 +1,260 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"runtime"
+	"sync/atomic"
+	"time"
+)
+
+// Server search jobs.
+//
+// The server answers queries about its own puzzle from the distance table,
+// which costs next to nothing, but a board of any other puzzle has to be
+// searched, and a search can take all the memory and time there is. So that
+// a public server can't be swamped by someone submitting a huge board, such
+// searches are jobs with limits:
+//
+//	-max-cells      boards with more cells are refused (413)
+//	-max-states     a search gives up after visiting this many configurations
+//	-job-timeout    a search gives up after this long, counting time queued
+//	-max-jobs       searches running at once
+//	-queue          searches waiting for one of those to finish; when the
+//	                queue is full, further searches are refused (503)
+//
+// A search that gives up is reported like an unsolvable one (422), with an
+// error saying which limit it reached. With -cache, searches look up and
+// save their results in the results cache, and a board found there isn't
+// searched again.
+
+var (
+	maxCells   = flag.Int("max-cells", 36, "Largest board, in cells, the server will search.")
+	maxStates  = flag.Int("max-states", 1000000, "Configurations a server search may visit before giving up.")
+	jobTimeout = flag.Duration("job-timeout", 10*time.Second, "Time a server search may take, including time queued.")
+	maxJobs    = flag.Int("max-jobs", runtime.NumCPU(), "Server searches to run at once.")
+	queueLen   = flag.Int("queue", 16, "Server searches that may wait to run before more are refused.")
+)
+
+// Errors of searches that couldn't be run, didn't finish, or proved there's
+// no solution.
+var (
+	errQueueFull  = errors.New("too many searches waiting; try again later")
+	errTooLarge   = errors.New("board too large to search")
+	errNoSolution = errors.New("no solution")
+)
+
+// A jobQueue limits how many searches run and wait at once.
+type jobQueue struct {
+	running chan bool // a value for each search running
+	waiting chan bool // a value for each search running or waiting
+}
+
+func newJobQueue(jobs, queued int) *jobQueue {
+	return &jobQueue{make(chan bool, jobs), make(chan bool, jobs+queued)}
+}
+
+// Joins the queue, or fails at once if it's full.
+func (q *jobQueue) enter() error {
+	select {
+	case q.waiting <- true:
+		return nil
+	default:
+		return errQueueFull
+	}
+}
+
+// Waits in the queue for a turn to run a search. If the context ends
+// first, leaves the queue and fails.
+func (q *jobQueue) wait(ctx context.Context) error {
+	select {
+	case q.running <- true:
+		return nil
+	case <-ctx.Done():
+		<-q.waiting
+		return ctx.Err()
+	}
+}
+
+// Leaves the queue at the end of a search.
+func (q *jobQueue) leave() {
+	<-q.running
+	<-q.waiting
+}
+
+// Returns how many searches are running and waiting.
+func (q *jobQueue) load() (running, queued int) {
+	running = len(q.running)
+	return running, len(q.waiting) - running
+}
+
+// How far a search has got, for reporting while it runs.
+type searchProgress struct {
+	running atomic.Bool  // whether it's out of the queue
+	configs atomic.Int64 // configurations seen
+	depth   atomic.Int64 // moves from the start of the positions expanded
+}
+
+// Searches for a shortest solution from the board within the server's
+// limits, giving up after the timeout, and returns its moves.
+func (s *server) search(ctx context.Context, b *Board, timeout time.Duration) ([]Move, error) {
+	if mvs, ok, err := s.cachedSolution(b); ok {
+		return mvs, err
+	}
+	if err := s.admit(b); err != nil {
+		return nil, err
+	}
+	return s.runSearch(ctx, b, timeout, nil)
+}
+
+// Looks up the solution from the board in the results cache, if -cache is
+// given, returning its moves, or an error if the cache records that there's
+// none. Reports whether the cache had the board.
+func (s *server) cachedSolution(b *Board) (mvs []Move, ok bool, err error) {
+	root := *b
+	root.mvs = []Move{}
+	end, stats, ok := lookupSolution(&root)
+	if !ok {
+		return nil, false, nil
+	}
+	s.metrics.countSearch("cached")
+	if end == nil {
+		return nil, true, fmt.Errorf("%w: searched all %d configurations reachable from the position", errNoSolution, stats.Configs)
+	}
+	return end.mvs, true, nil
+}
+
+// Returns an error if the board is too large to search.
+func checkSize(b *Board) error {
+	if b.w*b.h > *maxCells {
+		return fmt.Errorf("%w: %dx%d is more than %d cells", errTooLarge, b.w, b.h, *maxCells)
+	}
+	return nil
+}
+
+// Ranks the legal moves from a board of another puzzle than the server's
+// by searching from the board after each, all within -job-timeout.
+func (s *server) rankBySearch(ctx context.Context, b *Board) ([]RankedMove, error) {
+	ctx, cancel := context.WithTimeout(ctx, *jobTimeout)
+	defer cancel()
+	rms := []RankedMove{}
+	for _, m := range b.possibleMoves() {
+		mvs, err := s.search(ctx, b.move(m), *jobTimeout)
+		d := len(mvs)
+		switch {
+		case errors.Is(err, errNoSolution):
+			d = -1
+		case err != nil:
+			return nil, err
+		}
+		rms = append(rms, RankedMove{m, d})
+	}
+	sortRankedMoves(rms)
+	return rms, nil
+}
+
+// Checks that the board isn't too large to search and joins the queue.
+func (s *server) admit(b *Board) error {
+	if err := checkSize(b); err != nil {
+		s.metrics.countSearch("refused")
+		return err
+	}
+	if err := s.jobs.enter(); err != nil {
+		s.metrics.countSearch("refused")
+		return err
+	}
+	return nil
+}
+
+// Waits in the queue, which the search must have been admitted to, for a
+// turn, then searches for a shortest solution from the board, giving up
+// after the timeout, and returns its moves. The search reports its progress
+// to p, if it isn't nil, and with -cache, saves what it finds in the results
+// cache.
+func (s *server) runSearch(ctx context.Context, b *Board, timeout time.Duration, p *searchProgress) ([]Move, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	if err := s.jobs.wait(ctx); err != nil {
+		s.metrics.countSearch("refused")
+		return nil, fmt.Errorf("gave up after waiting %v for a turn to search", timeout)
+	}
+	defer s.jobs.leave()
+	root := *b
+	root.mvs = []Move{}
+	end, stats := solveWithin(ctx, &root, *maxStates, p)
+	if p != nil {
+		p.configs.Store(int64(stats.Configs))
+	}
+	s.metrics.addExpansions(stats.Expanded)
+	var err error
+	switch {
+	case end != nil:
+		storeSolution(&root, end, stats)
+		s.metrics.countSearch("solved")
+		return end.mvs, nil
+	case ctx.Err() != nil:
+		err = fmt.Errorf("gave up after %v (%d configurations searched)", timeout, stats.Configs)
+	case stats.Configs > *maxStates:
+		err = fmt.Errorf("gave up after searching %d configurations", *maxStates)
+	default:
+		storeSolution(&root, nil, stats)
+		err = fmt.Errorf("%w: searched all %d configurations reachable from the position", errNoSolution, stats.Configs)
+	}
+	s.metrics.countSearch("unsolved")
+	return nil, err
+}
+
+// Finds a shortest solution by breadth-first search, like solve, but gives
+// up when the context ends or after visiting more than maxStates
+// configurations, reporting its progress to p if it isn't nil. It leaves out
+// solve's instrumentation, which follows one search at a time.
+func solveWithin(ctx context.Context, start *Board, maxStates int, p *searchProgress) (*Board, Stats) {
+	if p != nil {
+		p.running.Store(true)
+	}
+	if start.goal.IsSatisfied(start) {
+		return start, Stats{1, 0, 0}
+	}
+	bs := []*Board{start}
+	seenBoards := map[string]bool{start.Config(): true}
+	numSkipped, numExpanded := 0, 0
+	for len(bs) > 0 && len(seenBoards) <= maxStates && ctx.Err() == nil {
+		b := bs[0]
+		bs = bs[1:]
+		numExpanded++
+		if p != nil {
+			p.configs.Store(int64(len(seenBoards)))
+			p.depth.Store(int64(len(b.mvs) - len(start.mvs)))
+		}
+		for _, m := range b.orderedMoves() {
+			nbConfig := b.configAfter(m)
+			if seenBoards[nbConfig] {
+				numSkipped++
+				continue
+			}
+			nb := b.move(m)
+			seenBoards[nbConfig] = true
+			if nb.goal.IsSatisfied(nb) {
+				return nb, Stats{len(seenBoards), numSkipped, numExpanded}
+			}
+			bs = append(bs, nb)
+		}
+	}
+	return nil, Stats{len(seenBoards), numSkipped, numExpanded}
+}
+
+// Responds to a request whose search failed.
+func writeSearchError(w http.ResponseWriter, err error) {
+	status := http.StatusUnprocessableEntity
+	switch {
+	case errors.Is(err, errTooLarge):
+		status = http.StatusRequestEntityTooLarge
+	case errors.Is(err, errQueueFull):
+		status = http.StatusServiceUnavailable
+		w.Header().Set("Retry-After", "1")
+	}
+	writeJSON(w, status, errorResponse{err.Error()})
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+)
+
+// Text layouts.
+//
+// In the text format, -layout stacked (the default) prints the board after
+// every move, one under another. -layout side-by-side prints each move with
+// the boards before and after it next to each other, the moved piece shown
+// in reverse video, which is far easier to follow for long solutions.
+
+var layout = flag.String("layout", "stacked",
+	"Text format layout: stacked (boards after every move) or side-by-side (boards before and after each move).")
+
+var render = flag.String("render", "all",
+	"Boards drawn in text output: all, first-last, or none.")
+
+var renderEvery = flag.Int("render-every", 1,
+	"With -render all, draw only every Nth board (and the last).")
+
+// Reports whether to draw the board after the n'th of total moves (the
+// starting board for n = 0).
+func renderBoardAfter(n, total int) bool {
+	switch *render {
+	case "none":
+		return false
+	case "first-last":
+		return n == 0 || n == total
+	}
+	return n%max(*renderEvery, 1) == 0 || n == total
+}
+
+// Reverse video, for highlighting the moved piece.
+const ansiReverse = "\x1b[7m"
+
+// Prints each move with the boards before and after it side by side.
+func printMovesSideBySide(b *Board, mvs []Move) {
+	for i, m := range mvs {
+		nb := b.move(m)
+		fmt.Printf("%d: %s  %s\n", i+1, m.String(), nb.stateID())
+		if !renderBoardAfter(i+1, len(mvs)) {
+			b = nb
+			continue
+		}
+		before, after := b.highlightedLines(m.pid), nb.highlightedLines(m.pid)
+		for j := range before {
+			sep := "      "
+			if j == len(before)/2 {
+				sep = "  =>  "
+			}
+			fmt.Println(strings.TrimRight(before[j]+sep+after[j], " "))
+		}
+		b = nb
+	}
+}
+
+// Draws the board like String, as lines, with the given piece in reverse
+// video.
+func (b *Board) highlightedLines(pid string) []string {
+	grid := b.grid()
+	lines := []string{" " + strings.Repeat("_", b.w) + " "}
+	for y := 0; y < b.h; y++ {
+		var sb strings.Builder
+		sb.WriteString("|")
+		for _, c := range []byte(grid.row(y)) {
+			if string(c) == pid {
+				sb.WriteString(ansiReverse + string(c) + ansiReset)
+			} else {
+				sb.WriteByte(c)
+			}
+		}
+		sb.WriteString("|")
+		lines = append(lines, sb.String())
+	}
+	return append(lines, " "+strings.Repeat("~", b.w)+" ")
+}
@@ -0,0 +1,431 @@
+package main
+
+import (
+	"fmt"
+	"math/big"
+	"os"
+	"strings"
+)
+
+// Maps of the optimal solutions.
+//
+// "squareroot lines" draws a map of the ways to solve a puzzle optimally.
+// The optimal solutions form a layered graph of positions, one layer per
+// move, and the map is built from it rather than from the solutions
+// themselves, of which there may be billions:
+//
+//   - Key positions are those every optimal solution passes through, the
+//     layers holding a single position. They split the solutions into
+//     stretches that can be learned independently.
+//   - Within each stretch, the lines fall into families: sets of lines
+//     joined by shared positions, while lines of different families never
+//     meet until the next key position. Mirror-image solutions, for
+//     example, form separate families.
+//   - Each family is shown by its most common line, following at each
+//     step the position with the most continuations, with its branch
+//     points: where other lines in the family leave it, and where they
+//     rejoin it.
+//
+// Lines are sequences of positions, so moving one of two identical pieces
+// instead of the other doesn't make a different line.
+
+// The layered graph of the positions on optimal solutions.
+type solutionMap struct {
+	g *moveGraph
+
+	// The optimal solution length.
+	length int
+
+	// The nodes of the positions on optimal solutions, by the number of
+	// moves taken to reach them.
+	layers [][]int32
+
+	// The layer of each node, or -1 if it isn't on an optimal solution.
+	layer []int
+
+	// The positions each node leads to on optimal solutions.
+	next [][]int32
+}
+
+// Builds the map of the optimal solutions of a puzzle from its move graph
+// and distances to the goal.
+func buildSolutionMap(g *moveGraph, dist []int) *solutionMap {
+	m := &solutionMap{g: g, length: dist[0], layer: make([]int, len(dist)), next: make([][]int32, len(dist))}
+	// Distances from the start.
+	from := make([]int, len(dist))
+	for n := range from {
+		from[n] = -1
+		m.layer[n] = -1
+	}
+	from[0] = 0
+	q := []int{0}
+	for len(q) > 0 {
+		n := q[0]
+		q = q[1:]
+		for _, nn := range g.succ[n] {
+			if from[nn] < 0 {
+				from[nn] = from[n] + 1
+				q = append(q, int(nn))
+			}
+		}
+	}
+
+	m.layers = make([][]int32, m.length+1)
+	for n, d := range dist {
+		if d >= 0 && from[n]+d == m.length {
+			m.layer[n] = from[n]
+			m.layers[from[n]] = append(m.layers[from[n]], int32(n))
+		}
+	}
+	for _, ns := range m.layers {
+		for _, n := range ns {
+			seen := map[int32]bool{}
+			for _, nn := range g.succ[n] {
+				if m.layer[nn] == m.layer[n]+1 && !seen[nn] {
+					seen[nn] = true
+					m.next[n] = append(m.next[n], nn)
+				}
+			}
+		}
+	}
+	return m
+}
+
+// A stretch of the optimal solutions between two key positions, or from
+// the last key position to the end.
+type stretch struct {
+	from, to int // layers; the to layer is a key position unless it's the last
+	families [][]int32
+}
+
+// Returns the stretches between key positions, with the families of the
+// positions strictly inside them, and those in the last layer for the last
+// stretch.
+func (m *solutionMap) stretches() []stretch {
+	keys := []int{}
+	for l, ns := range m.layers {
+		if len(ns) == 1 {
+			keys = append(keys, l)
+		}
+	}
+	if keys[len(keys)-1] != m.length {
+		keys = append(keys, m.length)
+	}
+
+	sts := []stretch{}
+	for i := 0; i+1 < len(keys); i++ {
+		st := stretch{from: keys[i], to: keys[i+1]}
+		inner := func(n int32) bool {
+			l := m.layer[n]
+			return l > st.from && (l < st.to || l == m.length && len(m.layers[l]) > 1)
+		}
+		// Join the inner positions into families by the moves between them.
+		family := map[int32]int{}
+		for l := st.from + 1; l <= st.to; l++ {
+			for _, n := range m.layers[l] {
+				if !inner(n) {
+					continue
+				}
+				if _, ok := family[n]; !ok {
+					family[n] = len(st.families)
+					st.families = append(st.families, nil)
+					m.collectFamily(n, inner, family)
+				}
+			}
+		}
+		for n, f := range family {
+			st.families[f] = append(st.families[f], n)
+		}
+		if k := len(sts) - 1; k >= 0 && len(sts[k].families) == 0 && len(st.families) == 0 {
+			// Join runs of forced moves.
+			sts[k].to = st.to
+			continue
+		}
+		sts = append(sts, st)
+	}
+	return sts
+}
+
+// Labels every inner position connected to n with n's family.
+func (m *solutionMap) collectFamily(n int32, inner func(int32) bool, family map[int32]int) {
+	stack := []int32{n}
+	for len(stack) > 0 {
+		n := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		neighbors := append([]int32{}, m.next[n]...)
+		for _, pn := range m.layers[m.layer[n]-1] {
+			for _, nn := range m.next[pn] {
+				if nn == n {
+					neighbors = append(neighbors, pn)
+				}
+			}
+		}
+		for _, nn := range neighbors {
+			if _, ok := family[nn]; !ok && inner(nn) {
+				family[nn] = family[n]
+				stack = append(stack, nn)
+			}
+		}
+	}
+}
+
+// Counts the lines through a family from the start of its stretch to its
+// end, returning the number of lines continuing from the stretch start and
+// from each of the family's positions.
+func (m *solutionMap) continuations(st stretch, fam []int32) map[int32]*big.Int {
+	in := map[int32]bool{m.layers[st.from][0]: true}
+	for _, n := range fam {
+		in[n] = true
+	}
+	if len(m.layers[st.to]) == 1 {
+		in[m.layers[st.to][0]] = true
+	}
+	ways := map[int32]*big.Int{}
+	for l := st.to; l >= st.from; l-- {
+		for _, n := range m.layers[l] {
+			if !in[n] {
+				continue
+			}
+			w := new(big.Int)
+			if l == st.to {
+				w.SetInt64(1)
+			}
+			for _, nn := range m.next[n] {
+				if in[nn] {
+					w.Add(w, ways[nn])
+				}
+			}
+			ways[n] = w
+		}
+	}
+	return ways
+}
+
+// Runs "lines".
+func runLines(start *Board) {
+	fmt.Fprintln(os.Stderr, "Building distance table...")
+	g := buildMoveGraph(start)
+	t := g.distanceTable(start)
+	if t.dist[0] < 0 {
+		fmt.Println("The puzzle can't be solved.")
+		os.Exit(exitUnsolvable)
+	}
+	m := buildSolutionMap(g, t.dist)
+	if m.length == 0 {
+		fmt.Println("The puzzle is already solved.")
+		return
+	}
+	sequences := g.countSolutions(t.dist, m.length)[m.length]
+
+	sts := m.stretches()
+	lines := big.NewInt(1)
+	for _, st := range sts {
+		total := big.NewInt(0)
+		for _, fam := range st.families {
+			total.Add(total, m.continuations(st, fam)[m.layers[st.from][0]])
+		}
+		if len(st.families) == 0 {
+			total.SetInt64(1)
+		}
+		lines.Mul(lines, total)
+	}
+	if sequences.IsInt64() && sequences.Int64() == 1 {
+		fmt.Printf("The only optimal solution takes %d moves.\n", m.length)
+	} else {
+		fmt.Printf("Optimal solutions take %d moves: %s move sequences along %s different lines.\n",
+			m.length, sequences, lines)
+	}
+	keyLayers := map[int32]int{}
+	for l := 1; l < m.length; l++ {
+		if len(m.layers[l]) == 1 {
+			keyLayers[m.layers[l][0]] = l
+		}
+	}
+	// Name each key position by its move and state id.
+	keyIDs := map[int]string{}
+	for config, n := range g.index {
+		if l, ok := keyLayers[int32(n)]; ok {
+			keyIDs[l] = hashStateID(hashConfig(config))
+		}
+	}
+	keys := []string{}
+	for l := 1; l < m.length; l++ {
+		if id, ok := keyIDs[l]; ok {
+			keys = append(keys, fmt.Sprintf("%d (%s)", l, id))
+		}
+	}
+	switch len(keys) {
+	case 0:
+		fmt.Println("No position besides the start is common to every optimal solution.")
+	case 1:
+		fmt.Printf("Every optimal solution passes through the key position after move %s.\n", keys[0])
+	default:
+		fmt.Printf("Every optimal solution passes through the key positions after moves %s.\n", strings.Join(keys, ", "))
+	}
+
+	b := start
+	for _, st := range sts {
+		fmt.Println()
+		if len(st.families) == 0 {
+			mvs := m.follow(b, st.to-st.from, func(ns []int32) int32 { return ns[0] })
+			fmt.Printf("%s: forced, %s\n", moveRange(st.from+1, st.to), moveCodes(mvs))
+			b = replay(b, mvs)
+			continue
+		}
+		families := "1 family"
+		if len(st.families) > 1 {
+			families = fmt.Sprintf("%d families", len(st.families))
+		}
+		fmt.Printf("%s: %s.\n", moveRange(st.from+1, st.to), families)
+		var first []Move
+		for i, fam := range st.families {
+			ways := m.continuations(st, fam)
+			// Follow the position with the most continuations.
+			mvs := m.follow(b, st.to-st.from, func(ns []int32) int32 {
+				best := int32(-1)
+				for _, nn := range ns {
+					if w := ways[nn]; w != nil && w.Sign() > 0 && (best < 0 || w.Cmp(ways[best]) > 0) {
+						best = nn
+					}
+				}
+				return best
+			})
+			lines := ways[m.layers[st.from][0]].String() + " lines"
+			if lines == "1 lines" {
+				lines = "1 line"
+			}
+			fmt.Printf("  Family %d, %s: %s\n", i+1, lines, moveCodes(mvs))
+			m.printBranches(b, st, mvs, ways)
+			if i == 0 {
+				first = mvs
+			}
+		}
+		// Every family reaches the next key position.
+		b = replay(b, first)
+	}
+}
+
+// Follows the optimal solutions for n moves from the board, choosing each
+// next position from those on them with choose, and returns the moves.
+func (m *solutionMap) follow(b *Board, n int, choose func([]int32) int32) []Move {
+	mvs := []Move{}
+	for ; n > 0; n-- {
+		mv, _ := m.moveTo(b, choose(m.next[m.g.index[b.Config()]]))
+		mvs = append(mvs, mv)
+		b = b.move(mv)
+	}
+	return mvs
+}
+
+// Returns a move from the board to the position with the given node.
+func (m *solutionMap) moveTo(b *Board, node int32) (Move, bool) {
+	for _, mv := range b.possibleMoves() {
+		if m.g.index[b.move(mv).Config()] == int(node) {
+			return mv, true
+		}
+	}
+	return Move{}, false
+}
+
+// Prints where the other lines of a family leave the line given by its
+// moves from the board at the stretch's start, and where they rejoin it.
+func (m *solutionMap) printBranches(b *Board, st stretch, mvs []Move, ways map[int32]*big.Int) {
+	boards := []*Board{b}
+	line := []int32{int32(m.g.index[b.Config()])}
+	for _, mv := range mvs {
+		b = b.move(mv)
+		boards = append(boards, b)
+		line = append(line, int32(m.g.index[b.Config()]))
+	}
+	// Consecutive branch points with the same alternatives and rejoining
+	// point, from a move that could be made at any of them, print as one.
+	type branch struct {
+		first, last int
+		alts, where string
+	}
+	branches := []branch{}
+	for i, n := range line[:len(line)-1] {
+		alts := []string{}
+		rejoin := -1
+		for _, nn := range m.next[n] {
+			if nn == line[i+1] || ways[nn] == nil || ways[nn].Sign() == 0 {
+				continue
+			}
+			mv, _ := m.moveTo(boards[i], nn)
+			alts = append(alts, mv.code())
+			if j := m.rejoins(nn, line, st.from, ways); j >= 0 && (rejoin < 0 || j < rejoin) {
+				rejoin = j
+			}
+		}
+		if len(alts) == 0 {
+			continue
+		}
+		where := "without rejoining"
+		if rejoin >= 0 {
+			where = fmt.Sprintf("rejoining after move %d", st.from+rejoin)
+		}
+		br := branch{st.from + i, st.from + i, strings.Join(alts, " or "), where}
+		if k := len(branches) - 1; k >= 0 && branches[k].last == br.first-1 && branches[k].alts == br.alts && branches[k].where == br.where {
+			branches[k].last = br.last
+			continue
+		}
+		branches = append(branches, br)
+	}
+	for _, br := range branches {
+		at := "at the start"
+		switch {
+		case br.first < br.last:
+			at = fmt.Sprintf("after moves %d-%d", br.first, br.last)
+		case br.first > 0:
+			at = fmt.Sprintf("after move %d", br.first)
+		}
+		fmt.Printf("    %s: or %s, %s\n", at, br.alts, br.where)
+	}
+}
+
+// Returns the index of the first position of the line, given by its nodes
+// from the stretch starting at layer from, that the family's lines through
+// node n reach, or -1 if they don't rejoin it.
+func (m *solutionMap) rejoins(n int32, line []int32, from int, ways map[int32]*big.Int) int {
+	cur := map[int32]bool{n: true}
+	for l := m.layer[n] - from; l < len(line) && len(cur) > 0; l++ {
+		if cur[line[l]] {
+			return l
+		}
+		next := map[int32]bool{}
+		for cn := range cur {
+			for _, nn := range m.next[cn] {
+				if w := ways[nn]; w != nil && w.Sign() > 0 {
+					next[nn] = true
+				}
+			}
+		}
+		cur = next
+	}
+	return -1
+}
+
+// Returns "Move n" or "Moves first-last".
+func moveRange(first, last int) string {
+	if first == last {
+		return fmt.Sprintf("Move %d", first)
+	}
+	return fmt.Sprintf("Moves %d-%d", first, last)
+}
+
+// Returns the moves' compact codes separated by spaces.
+func moveCodes(mvs []Move) string {
+	codes := []string{}
+	for _, m := range mvs {
+		codes = append(codes, m.code())
+	}
+	return strings.Join(codes, " ")
+}
+
+// Returns the board after the moves.
+func replay(b *Board, mvs []Move) *Board {
+	for _, m := range mvs {
+		b = b.move(m)
+	}
+	return b
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Linked pieces.
+//
+// Pieces in a linked group always move together: moving any of them slides
+// the whole group one space in the same direction. The move is legal only if
+// every space the group slides into is open (or is vacated by another piece
+// of the group). Puzzle files declare groups with a directive after the grid,
+// e.g.
+//
+//	link g h
+//
+// A group's moves are recorded against its first piece id.
+
+// Links the given pieces into a group that always moves together.
+func (b *Board) link(pids []string) error {
+	g := append([]string{}, pids...)
+	sort.Strings(g)
+	for i, pid := range g {
+		if _, ok := b.ps[pid]; !ok {
+			return fmt.Errorf("no piece %s to link", pid)
+		}
+		if i > 0 && g[i-1] == pid {
+			return fmt.Errorf("piece %s listed twice", pid)
+		}
+		if b.links[pid] != nil {
+			return fmt.Errorf("piece %s is already linked", pid)
+		}
+	}
+	if b.links == nil {
+		b.links = make(map[string][]string)
+	}
+	for _, pid := range g {
+		b.links[pid] = g
+	}
+	return nil
+}
+
+// Does the given move slide the given piece, either directly or because the
+// piece is linked to the moved piece.
+func (b *Board) movesPiece(m Move, pid string) bool {
+	if pid == m.pid {
+		return true
+	}
+	for _, gpid := range b.links[m.pid] {
+		if gpid == pid {
+			return true
+		}
+	}
+	return false
+}
+
+// Is the given group of linked pieces free to move in the given direction.
+func (b *Board) canMoveGroup(g []string, d Direction) bool {
+	for _, pid := range g {
+		for _, ts := range b.ps[pid].targetSpaces(d) {
+			if !b.canEnter(ts, d) {
+				return false
+			}
+			if !b.isOpen(ts) && !b.groupCovers(g, ts) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// Which on-board spaces not already covered by the group will be moved
+// into if the given group of linked pieces moves in the given direction.
+func (b *Board) groupTargetSpaces(g []string, d Direction) []Space {
+	tss := []Space{}
+	for _, pid := range g {
+		for _, ts := range b.ps[pid].targetSpaces(d) {
+			if b.wrap {
+				ts = b.wrapSpace(ts)
+			}
+			if !b.groupCovers(g, ts) {
+				tss = append(tss, ts)
+			}
+		}
+	}
+	return tss
+}
+
+// Does any piece of the given group cover the given space.
+func (b *Board) groupCovers(g []string, s Space) bool {
+	if b.wrap {
+		s = b.wrapSpace(s)
+	} else if s.x < 0 || s.y < 0 || s.x >= b.w || s.y >= b.h {
+		return false
+	}
+	for _, pid := range g {
+		if b.covers(b.ps[pid], s) {
+			return true
+		}
+	}
+	return false
+}
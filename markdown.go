@@ -0,0 +1,64 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+)
+
+// Markdown solution output.
+//
+// The markdown format writes the solution as a Markdown document, ready to
+// paste into a blog post or a puzzle pack's README: a summary, the moves in
+// the compact notation of the sbp format, and a storyboard of board diagrams
+// in fenced code blocks every -diagram-every moves, ending with the solved
+// board.
+
+var diagramEvery = flag.Int("diagram-every", 10,
+	"Moves between board diagrams in -format markdown.")
+
+// Prints a solution in the markdown format.
+func printMarkdownSolution(start, end *Board, stats Stats) {
+	groups := groupMoves(end.mvs)
+	fmt.Println("# Solution")
+	fmt.Println()
+	fmt.Printf("* **Moves:** %d (%d piece moves)\n", len(end.mvs), len(groups))
+	fmt.Printf("* **Goal:** %s\n", describeReached(end.goal, end))
+	if code, err := start.Encode(); err == nil {
+		fmt.Printf("* **Board code:** `%s`\n", code)
+	}
+	fmt.Printf("* **Start state:** `%s`\n", start.stateID())
+	fmt.Printf("* **Search:** %d configurations, %d skipped\n", stats.Configs, stats.Skipped)
+	fmt.Println()
+	fmt.Println("## Moves")
+	fmt.Println()
+	fmt.Println("```")
+	for i := 0; i < len(groups); i += 10 {
+		fmt.Println(strings.Join(groups[i:min(i+10, len(groups))], " "))
+	}
+	fmt.Println("```")
+	fmt.Println()
+	fmt.Println("## Storyboard")
+
+	every := max(*diagramEvery, 1)
+	b := start
+	diagram := func(title string) {
+		fmt.Println()
+		fmt.Printf("### %s `%s`\n\n```\n%s```\n", title, b.stateID(), b.String())
+	}
+	diagram("Start")
+	for i, m := range end.mvs {
+		b = b.move(m)
+		n := i + 1
+		if n == len(end.mvs) {
+			diagram(fmt.Sprintf("Solved after move %d", n))
+		} else if n%every == 0 {
+			first := n - every
+			codes := []string{}
+			for _, m := range end.mvs[first:n] {
+				codes = append(codes, m.code())
+			}
+			diagram(fmt.Sprintf("After move %d (%s)", n, strings.Join(codes, " ")))
+		}
+	}
+}
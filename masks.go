@@ -0,0 +1,118 @@
+package main
+
+import "sync"
+
+// Move masks.
+//
+// On a board of at most 64 spaces, each space is a bit of a spaceMask, bit
+// y*w+x, and the spaces a piece covers or would move into are masks too.
+// For each board size the masks of every shape at every position are
+// computed once, along with the spaces it would move into in each
+// direction, so that whether a piece can move comes down to ANDing its
+// target mask with the mask of spaces it can't enter, rather than building
+// the target spaces for every move tried. Larger boards check moves space
+// by space.
+
+// A set of spaces on a board of at most 64 spaces.
+type spaceMask uint64
+
+// The mask of a single space, which must be on the board: masks of walls
+// and one-way cells rely on validate rejecting those off it.
+func spaceBit(w int, s Space) spaceMask {
+	return 1 << uint(s.y*w+s.x)
+}
+
+// The masks for one board size.
+type moveMasks struct {
+	w, h int
+	// The spaces covered by a shape at a position, by shapeIndex and then
+	// space index.
+	cover [][]spaceMask
+	// The spaces moved into by a shape at a position moving in each
+	// direction, or 0 if the move would leave the board.
+	target [][][4]spaceMask
+}
+
+// The key of a board size's masks.
+type maskGeometry struct {
+	w, h int
+	wrap bool
+}
+
+// The masks of each board size seen, by maskGeometry.
+var moveMaskCache sync.Map
+
+// Returns the masks for the board's size, or nil if it's too big for them.
+func (b *Board) moveMasks() *moveMasks {
+	if b.w*b.h > 64 {
+		return nil
+	}
+	key := maskGeometry{b.w, b.h, b.wrap}
+	if mm, ok := moveMaskCache.Load(key); ok {
+		return mm.(*moveMasks)
+	}
+	mm, _ := moveMaskCache.LoadOrStore(key, newMoveMasks(b.w, b.h, b.wrap))
+	return mm.(*moveMasks)
+}
+
+func newMoveMasks(w, h int, wrap bool) *moveMasks {
+	mm := &moveMasks{w: w, h: h}
+	bits := func(ss []Space) (spaceMask, bool) {
+		m := spaceMask(0)
+		for _, s := range ss {
+			if wrap {
+				s = Space{mod(s.x, w), mod(s.y, h)}
+			} else if s.x < 0 || s.y < 0 || s.x >= w || s.y >= h {
+				return 0, false
+			}
+			m |= spaceBit(w, s)
+		}
+		return m, true
+	}
+	for ph := 1; ph <= h; ph++ {
+		for pw := 1; pw <= w; pw++ {
+			cover := make([]spaceMask, w*h)
+			target := make([][4]spaceMask, w*h)
+			for y := 0; y < h; y++ {
+				for x := 0; x < w; x++ {
+					p := Piece{w: pw, h: ph, x: x, y: y}
+					ss := []Space{}
+					for dy := 0; dy < ph; dy++ {
+						ss = append(ss, hSpaces(y+dy, x, x+pw-1)...)
+					}
+					cover[y*w+x], _ = bits(ss)
+					for _, d := range Directions {
+						if m, ok := bits(p.targetSpaces(d)); ok {
+							target[y*w+x][d] = m
+						}
+					}
+				}
+			}
+			mm.cover = append(mm.cover, cover)
+			mm.target = append(mm.target, target)
+		}
+	}
+	return mm
+}
+
+// The index of a piece's shape in the mask tables.
+func (mm *moveMasks) shapeIndex(p Piece) int {
+	return (p.h-1)*mm.w + p.w - 1
+}
+
+// Reports whether the tables cover the piece's shape: a piece on a toroidal
+// board may be longer than the board.
+func (mm *moveMasks) fits(p Piece) bool {
+	return p.w <= mm.w && p.h <= mm.h
+}
+
+// Returns the spaces a piece covers.
+func (mm *moveMasks) covers(p Piece) spaceMask {
+	return mm.cover[mm.shapeIndex(p)][p.y*mm.w+p.x]
+}
+
+// Returns the spaces a piece would move into in the given direction, or 0
+// if the move would leave the board.
+func (mm *moveMasks) targets(p Piece, d Direction) spaceMask {
+	return mm.target[mm.shapeIndex(p)][p.y*mm.w+p.x][d]
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"sync"
+	"time"
+)
+
+// Memory statistics.
+//
+// With -mem-stats, "solve" samples the Go runtime's memory statistics while
+// it searches and afterwards prints on standard error the most heap memory
+// in use at once above what was in use before the search, the total bytes
+// and objects allocated, and the number of garbage collections and their
+// total pause, to weigh changes meant to save memory. The heap is sampled
+// every few milliseconds, so a peak between samples can be missed, and
+// reading the statistics briefly stops the program, so searches run a
+// little slower. Such searches bypass the results cache.
+
+var memStatsFlag = flag.Bool("mem-stats", false,
+	"After the search, print its peak heap, allocations and garbage collection pauses.")
+
+// How often the heap is sampled.
+const memSampleInterval = 5 * time.Millisecond
+
+// A sampler of the memory statistics during a search, or nil if they're
+// off.
+type memSampler struct {
+	before, after runtime.MemStats
+	peak          uint64 // the most heap in use in any sample
+	done          chan bool
+	wg            sync.WaitGroup
+}
+
+// The sampler of the current run.
+var memStats *memSampler
+
+// Starts sampling if -mem-stats is given.
+func openMemStats() {
+	if !*memStatsFlag {
+		return
+	}
+	s := &memSampler{done: make(chan bool)}
+	runtime.GC()
+	runtime.ReadMemStats(&s.before)
+	s.peak = s.before.HeapAlloc
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		tick := time.NewTicker(memSampleInterval)
+		defer tick.Stop()
+		var m runtime.MemStats
+		for {
+			select {
+			case <-s.done:
+				return
+			case <-tick.C:
+				runtime.ReadMemStats(&m)
+				s.peak = max(s.peak, m.HeapAlloc)
+			}
+		}
+	}()
+	memStats = s
+}
+
+// Stops sampling at the end of the search.
+func (s *memSampler) stop() {
+	if s == nil || s.done == nil {
+		return
+	}
+	close(s.done)
+	s.wg.Wait()
+	s.done = nil
+	runtime.ReadMemStats(&s.after)
+	s.peak = max(s.peak, s.after.HeapAlloc)
+}
+
+// Prints the statistics, if they're on.
+func writeMemStats() {
+	memStats.stop()
+	memStats.write(os.Stderr)
+}
+
+// Prints the statistics.
+func (s *memSampler) write(out io.Writer) {
+	if s == nil {
+		return
+	}
+	fmt.Fprintf(out, "Peak heap:   %s above the start\n", formatMB(s.peak-s.before.HeapAlloc))
+	fmt.Fprintf(out, "Allocated:   %s in %d objects\n", formatMB(s.after.TotalAlloc-s.before.TotalAlloc),
+		s.after.Mallocs-s.before.Mallocs)
+	fmt.Fprintf(out, "Collections: %d, pausing %v in all\n", s.after.NumGC-s.before.NumGC,
+		time.Duration(s.after.PauseTotalNs-s.before.PauseTotalNs))
+}
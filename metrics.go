@@ -0,0 +1,190 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Server metrics, exposed at /metrics in the Prometheus text format.
+
+// Upper bounds in seconds of the request latency histogram buckets.
+var latencyBuckets = []float64{0.0001, 0.0005, 0.001, 0.005, 0.01, 0.05, 0.1, 0.5, 1, 5}
+
+type metrics struct {
+	mu sync.Mutex
+
+	// Requests by endpoint and status code.
+	requests map[[2]string]int
+
+	// Request latencies by endpoint.
+	latencies map[string]*histogram
+
+	// Distance table lookups by result: "hit" or "miss".
+	lookups map[string]int
+
+	// Positions answered by each kind of query.
+	solves, hints int
+
+	// Searches of other puzzles' boards by result: "solved", "unsolved"
+	// (no solution or gave up), "cached" (found in the results cache) or
+	// "refused", and the queue they run from.
+	searches map[string]int
+	jobs     *jobQueue
+
+	// Configurations expanded while building the distance table, and how
+	// long it took.
+	expansions   int
+	buildSeconds float64
+}
+
+type histogram struct {
+	counts []int // per bucket, not cumulative; the last is +Inf
+	sum    float64
+	n      int
+}
+
+func newMetrics() *metrics {
+	return &metrics{
+		requests:  make(map[[2]string]int),
+		latencies: make(map[string]*histogram),
+		lookups:   make(map[string]int),
+		searches:  make(map[string]int),
+	}
+}
+
+// Increments one of the metrics' counters.
+func (m *metrics) count(c *int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	*c++
+}
+
+func (m *metrics) countLookup(ok bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if ok {
+		m.lookups["hit"]++
+	} else {
+		m.lookups["miss"]++
+	}
+}
+
+func (m *metrics) countSearch(result string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.searches[result]++
+}
+
+func (m *metrics) addExpansions(n int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.expansions += n
+}
+
+// Wraps an HTTP handler to count its requests and time them.
+func (m *metrics) instrument(endpoint string, h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		t0 := time.Now()
+		sw := &statusWriter{w, http.StatusOK}
+		h(sw, r)
+		secs := time.Since(t0).Seconds()
+
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		m.requests[[2]string{endpoint, fmt.Sprint(sw.status)}]++
+		hg, ok := m.latencies[endpoint]
+		if !ok {
+			hg = &histogram{counts: make([]int, len(latencyBuckets)+1)}
+			m.latencies[endpoint] = hg
+		}
+		i := sort.SearchFloat64s(latencyBuckets, secs)
+		hg.counts[i]++
+		hg.sum += secs
+		hg.n++
+	}
+}
+
+// statusWriter records the status code written to a response.
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *statusWriter) WriteHeader(status int) {
+	w.status = status
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (m *metrics) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var sb strings.Builder
+	header := func(name, typ, help string) {
+		fmt.Fprintf(&sb, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+	}
+
+	header("squareroot_requests_total", "counter", "HTTP requests by endpoint and status code.")
+	rks := [][2]string{}
+	for k := range m.requests {
+		rks = append(rks, k)
+	}
+	sort.Slice(rks, func(i, j int) bool {
+		return rks[i][0] < rks[j][0] || rks[i][0] == rks[j][0] && rks[i][1] < rks[j][1]
+	})
+	for _, k := range rks {
+		fmt.Fprintf(&sb, "squareroot_requests_total{endpoint=%q,code=%q} %d\n", k[0], k[1], m.requests[k])
+	}
+
+	header("squareroot_solves_total", "counter", "Solutions returned.")
+	fmt.Fprintf(&sb, "squareroot_solves_total %d\n", m.solves)
+	header("squareroot_hints_total", "counter", "Hints returned.")
+	fmt.Fprintf(&sb, "squareroot_hints_total %d\n", m.hints)
+
+	header("squareroot_table_lookups_total", "counter",
+		"Distance table lookups by result (hit: position in the table).")
+	for _, res := range []string{"hit", "miss"} {
+		fmt.Fprintf(&sb, "squareroot_table_lookups_total{result=%q} %d\n", res, m.lookups[res])
+	}
+
+	header("squareroot_searches_total", "counter", "Searches of other puzzles' boards by result.")
+	for _, res := range []string{"solved", "unsolved", "cached", "refused"} {
+		fmt.Fprintf(&sb, "squareroot_searches_total{result=%q} %d\n", res, m.searches[res])
+	}
+	if m.jobs != nil {
+		running, queued := m.jobs.load()
+		header("squareroot_searches_running", "gauge", "Searches running.")
+		fmt.Fprintf(&sb, "squareroot_searches_running %d\n", running)
+		header("squareroot_searches_queued", "gauge", "Searches waiting to run.")
+		fmt.Fprintf(&sb, "squareroot_searches_queued %d\n", queued)
+	}
+
+	header("squareroot_expansions_total", "counter", "Configurations expanded by searches.")
+	fmt.Fprintf(&sb, "squareroot_expansions_total %d\n", m.expansions)
+	header("squareroot_table_build_seconds", "gauge", "Time taken to build the distance table.")
+	fmt.Fprintf(&sb, "squareroot_table_build_seconds %g\n", m.buildSeconds)
+
+	header("squareroot_request_duration_seconds", "histogram", "HTTP request latency by endpoint.")
+	eps := []string{}
+	for ep := range m.latencies {
+		eps = append(eps, ep)
+	}
+	sort.Strings(eps)
+	for _, ep := range eps {
+		hg := m.latencies[ep]
+		cum := 0
+		for i, le := range latencyBuckets {
+			cum += hg.counts[i]
+			fmt.Fprintf(&sb, "squareroot_request_duration_seconds_bucket{endpoint=%q,le=\"%g\"} %d\n", ep, le, cum)
+		}
+		fmt.Fprintf(&sb, "squareroot_request_duration_seconds_bucket{endpoint=%q,le=\"+Inf\"} %d\n", ep, hg.n)
+		fmt.Fprintf(&sb, "squareroot_request_duration_seconds_sum{endpoint=%q} %g\n", ep, hg.sum)
+		fmt.Fprintf(&sb, "squareroot_request_duration_seconds_count{endpoint=%q} %d\n", ep, hg.n)
+	}
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	fmt.Fprint(w, sb.String())
+}
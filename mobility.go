@@ -0,0 +1,128 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Position analysis.
+//
+// "squareroot analyze position [board]" takes a snapshot of one position,
+// the puzzle's start or the given puzzle file or board code with the same
+// pieces, for debugging a solver or teaching: for each piece, the moves it
+// can make, how many open cells it touches, and how far it is from where
+// it stands in the nearest solved position, with how many of that
+// solution's moves are its own. Pieces far from home that touch no open
+// cells are the ones holding the puzzle up.
+
+// A piece's part in a position.
+type pieceMobility struct {
+	pid      string
+	moves    []Direction
+	open     int // open cells next to the piece
+	distance int // spaces from its place in the nearest solved position, or -1
+	used     int // moves of it in the solution reaching that position
+}
+
+// Returns the mobility of each piece, in id order, and the solved board
+// nearest to this one, or nil if it can't be solved.
+func (b *Board) mobility() ([]pieceMobility, *Board) {
+	startDeadline()
+	end, _ := solve(b)
+	if b.goal.IsSatisfied(b) {
+		end = b
+	}
+	moves := b.possibleMoves()
+	ms := []pieceMobility{}
+	for _, pid := range b.pieceIDs() {
+		pm := pieceMobility{pid: pid, open: b.openNeighbors(b.ps[pid]), distance: -1}
+		// A linked piece moves with its leader.
+		for _, m := range moves {
+			if b.movesPiece(m, pid) {
+				pm.moves = append(pm.moves, m.dir)
+			}
+		}
+		if end != nil {
+			p, q := b.ps[pid], end.ps[pid]
+			pm.distance = b.distance(Space{p.x, p.y}, Space{q.x, q.y})
+			for _, m := range end.mvs[len(b.mvs):] {
+				if end.movesPiece(m, pid) {
+					pm.used++
+				}
+			}
+		}
+		ms = append(ms, pm)
+	}
+	return ms, end
+}
+
+// Returns the number of open cells orthogonally next to the piece.
+func (b *Board) openNeighbors(p Piece) int {
+	seen := map[Space]bool{}
+	look := func(x, y int) {
+		s := Space{x, y}
+		if b.wrap {
+			s = b.wrapSpace(s)
+		}
+		if b.isOpen(s) {
+			seen[s] = true
+		}
+	}
+	for x := p.x; x < p.x+p.w; x++ {
+		look(x, p.y-1)
+		look(x, p.y+p.h)
+	}
+	for y := p.y; y < p.y+p.h; y++ {
+		look(p.x-1, y)
+		look(p.x+p.w, y)
+	}
+	return len(seen)
+}
+
+// Runs "analyze position [board]".
+func runAnalyzePosition(start *Board, args []string) {
+	b := start
+	if len(args) == 1 {
+		pb, err := loadBoard(args[0])
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(exitInvalid)
+		}
+		if err := start.samePieces(pb); err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", args[0], err)
+			os.Exit(exitInvalid)
+		}
+		// The position under the puzzle's rules and goal.
+		b = start.clone()
+		b.ps = pb.ps
+	}
+	ms, end := b.mobility()
+	fmt.Print(b.display())
+	switch {
+	case end == nil && searchExpired():
+		fmt.Println("The search for the nearest solved position ran out of time.")
+	case end == nil:
+		fmt.Println("The position can't be solved.")
+	default:
+		fmt.Printf("The nearest solved position is %s away.\n", countOf(len(end.mvs)-len(b.mvs), "move"))
+	}
+	fmt.Printf("%-5s %-4s %-7s %-12s %4s %8s %8s\n", "piece", "size", "at", "can move", "open", "distance", "solution")
+	for _, m := range ms {
+		p := b.ps[m.pid]
+		dirs := []string{}
+		for _, d := range m.moves {
+			dirs = append(dirs, string(d.letter()))
+		}
+		can := strings.Join(dirs, " ")
+		if can == "" {
+			can = "-"
+		}
+		dist, used := "-", "-"
+		if m.distance >= 0 {
+			dist, used = fmt.Sprint(m.distance), fmt.Sprint(m.used)
+		}
+		fmt.Printf("%-5s %-4s %-7s %-12s %4d %8s %8s\n", m.pid, fmt.Sprintf("%dx%d", p.w, p.h),
+			fmt.Sprintf("%d,%d", p.x, p.y), can, m.open, dist, used)
+	}
+}
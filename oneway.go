@@ -0,0 +1,54 @@
+package main
+
+import "strings"
+
+// One-way cells.
+//
+// A one-way cell can only be entered by a piece moving in one of the
+// cell's allowed directions. Leaving the cell is unrestricted. Puzzle files
+// declare them with a directive after the grid, e.g.
+//
+//	oneway 1 4 down
+//
+// makes the space at column 1, row 4 enterable only by pieces sliding down.
+
+// DirSet is a set of directions.
+type DirSet uint8
+
+func (ds DirSet) has(d Direction) bool {
+	return ds&(1<<uint(d)) != 0
+}
+
+func (ds DirSet) add(d Direction) DirSet {
+	return ds | 1<<uint(d)
+}
+
+// The character used to draw an empty one-way cell: an arrow when only one
+// direction is allowed, otherwise '+'.
+func (ds DirSet) symbol() byte {
+	for _, d := range Directions {
+		if ds == DirSet(0).add(d) {
+			return "^v<>"[d]
+		}
+	}
+	return '+'
+}
+
+func (ds DirSet) String() string {
+	names := []string{}
+	for _, d := range Directions {
+		if ds.has(d) {
+			names = append(names, d.String())
+		}
+	}
+	return strings.Join(names, ",")
+}
+
+// Can a piece moving in the given direction enter the given space.
+func (b *Board) canEnter(s Space, d Direction) bool {
+	if b.wrap {
+		s = b.wrapSpace(s)
+	}
+	ds, ok := b.oneway[s]
+	return !ok || ds.has(d)
+}
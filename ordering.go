@@ -0,0 +1,128 @@
+package main
+
+import (
+	"flag"
+	"math/rand"
+	"slices"
+)
+
+// Move ordering.
+//
+// Searches try the moves from a position in the order they're generated,
+// and when several solutions are equally short, the order decides which
+// one a breadth-first search finds first; it also decides how A* breaks
+// ties between equally promising positions. -ordering chooses it:
+//
+//	goal-piece-first     moves of the goal's pieces first
+//	largest-piece-first  moves of bigger pieces first
+//	blank-proximity      moves opening a space nearest the goal's pieces
+//	                     first, keeping the open spaces around them
+//	random               a random order, the same for each position every
+//	                     run with the same -ordering-seed
+//
+// Ties keep the generated order, which scans the open spaces row by row.
+// Breadth-first (with or without -workers or -flat), A* and -piece-moves
+// searches follow the ordering, so a given ordering reproduces a given
+// solution, and such searches bypass the results cache.
+
+var (
+	ordering     = flag.String("ordering", "", "Order in which searches try moves: goal-piece-first, largest-piece-first, blank-proximity or random.")
+	orderingSeed = flag.Int64("ordering-seed", 1, "Random seed for -ordering random.")
+)
+
+var orderings = []string{"goal-piece-first", "largest-piece-first", "blank-proximity", "random"}
+
+// Reports whether the -ordering value names an ordering, or is empty.
+func validOrdering(o string) bool {
+	return o == "" || slices.Contains(orderings, o)
+}
+
+// Returns the legal moves from the board in the order selected by
+// -ordering.
+func (b *Board) orderedMoves() []Move {
+	mvs := b.possibleMoves()
+	switch *ordering {
+	case "goal-piece-first":
+		slices.SortStableFunc(mvs, func(m1, m2 Move) int {
+			return b.movesGoalPiece(m2) - b.movesGoalPiece(m1)
+		})
+	case "largest-piece-first":
+		slices.SortStableFunc(mvs, func(m1, m2 Move) int {
+			return b.movedArea(m2) - b.movedArea(m1)
+		})
+	case "blank-proximity":
+		gps := b.goalPieceList()
+		if len(gps) == 0 {
+			break
+		}
+		slices.SortStableFunc(mvs, func(m1, m2 Move) int {
+			return b.openedDistance(m1, gps) - b.openedDistance(m2, gps)
+		})
+	case "random":
+		r := rand.New(rand.NewSource(*orderingSeed ^ int64(configHash(b))))
+		r.Shuffle(len(mvs), func(i, j int) { mvs[i], mvs[j] = mvs[j], mvs[i] })
+	}
+	return mvs
+}
+
+// Returns 1 if the move slides one of the goal's pieces, otherwise 0.
+func (b *Board) movesGoalPiece(m Move) int {
+	for _, pid := range b.goalPieceList() {
+		if b.movesPiece(m, pid) {
+			return 1
+		}
+	}
+	return 0
+}
+
+// Returns the total area of the pieces the move slides.
+func (b *Board) movedArea(m Move) int {
+	area := 0
+	for pid, p := range b.ps {
+		if b.movesPiece(m, pid) {
+			area += p.w * p.h
+		}
+	}
+	return area
+}
+
+// Returns the pieces the goal names, if any.
+func (b *Board) goalPieceList() []string {
+	if gp, ok := b.goal.(GoalPieces); ok {
+		return gp.Pieces()
+	}
+	return nil
+}
+
+// Returns how far the nearest space the move opens is from the nearest of
+// the given pieces.
+func (b *Board) openedDistance(m Move, pids []string) int {
+	best := b.w + b.h
+	for pid, p := range b.ps {
+		if !b.movesPiece(m, pid) {
+			continue
+		}
+		// The spaces just behind the moved piece are the ones it leaves.
+		for _, s := range p.move(m.dir).targetSpaces(m.dir.reverse()) {
+			for _, gpid := range pids {
+				g, ok := b.ps[gpid]
+				if !ok {
+					continue
+				}
+				if b.movesPiece(m, gpid) {
+					g = g.move(m.dir)
+				}
+				best = min(best, spaceToPiece(s, g))
+			}
+		}
+	}
+	return best
+}
+
+// Returns the number of rows and columns between a space and the nearest
+// space of a piece.
+func spaceToPiece(s Space, p Piece) int {
+	dx := max(p.x-s.x, s.x-(p.x+p.w-1), 0)
+	dy := max(p.y-s.y, s.y-(p.y+p.h-1), 0)
+	return dx + dy
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"time"
+)
+
+// Solve outcomes.
+//
+// The exit status tells scripts how solving went without parsing the
+// output:
+//
+//	0  solved
+//	1  other errors, such as unwritable files
+//	2  the puzzle has no solution: the search covered every reachable
+//	   position without reaching the goal
+//	3  the search ran out of time (-timeout) before finishing
+//	4  invalid input: a puzzle, goal or move that can't be read, an
+//	   illegal move, or a bad flag or argument
+
+const (
+	exitSolved     = 0
+	exitError      = 1
+	exitUnsolvable = 2
+	exitBudget     = 3
+	exitInvalid    = 4
+)
+
+var timeout = flag.Duration("timeout", 0,
+	"Give up searching after this long, e.g. 30s; 0 searches until done.")
+
+// When the current search must stop, or the zero time if it needn't.
+var deadline time.Time
+
+// Starts the -timeout clock for the searches that follow.
+func startDeadline() {
+	if *timeout > 0 {
+		deadline = time.Now().Add(*timeout)
+	}
+}
+
+// Reports whether the search has run past the -timeout deadline.
+func searchExpired() bool {
+	return !deadline.IsZero() && time.Now().After(deadline)
+}
+
+// Reports a search that ended without a solution, and exits with the status
+// saying why. A search that didn't run out of time explored every reachable
+// configuration, which proves the puzzle unsolvable.
+func exitUnsolved(start *Board, stats Stats) {
+	verdict, status := "unsolvable", exitUnsolvable
+	if searchExpired() {
+		verdict, status = "timeout", exitBudget
+	}
+	events.emit(verdict, map[string]any{"configurations": stats.Configs, "skipped": stats.Skipped})
+	switch {
+	case *format == "json":
+		printJSONVerdict(start, verdict, stats)
+	case verdict == "unsolvable" && len(avoided) > 0:
+		fmt.Printf("No solution: searched all %d configurations reachable from the start\n", stats.Configs)
+		fmt.Println("without the avoided positions. Every solution passes through one of them.")
+	case verdict == "unsolvable":
+		fmt.Printf("No solution: searched all %d configurations reachable from the start\n", stats.Configs)
+		fmt.Println("without reaching the goal. The puzzle is proven unsolvable.")
+	}
+	if verdict == "timeout" {
+		fmt.Fprintf(os.Stderr, "Gave up after %v (%d configurations searched)\n", *timeout, stats.Configs)
+	}
+	os.Exit(status)
+}
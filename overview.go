@@ -0,0 +1,109 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"os"
+)
+
+// Solution overview.
+//
+// "squareroot overview <file.png>" draws the starting board once, faded,
+// with an arrow for each super-move (a run of moves of one piece) tracing
+// the piece's path, numbered in order and colored from red through to blue,
+// as a one-page summary of the whole solution. Arrows of consecutive
+// super-moves are offset slightly so that paths over the same spaces stay
+// apart.
+
+func runOverview(start *Board, args []string) {
+	if len(args) != 1 {
+		fmt.Fprintln(os.Stderr, "usage: squareroot overview [-cell pixels] <file.png>")
+		os.Exit(exitInvalid)
+	}
+	end, _ := findSolution(start)
+	if end == nil {
+		fmt.Fprintln(os.Stderr, "Couldn't find solution")
+		os.Exit(1)
+	}
+	if err := writePNG(args[0], renderOverview(start, end.mvs, max(*cellSize, 16))); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+// Draws the starting board with numbered arrows for the super-moves of a
+// solution.
+func renderOverview(start *Board, mvs []Move, cell int) *image.RGBA {
+	img := renderBoard(start, nil, cell)
+	draw.Draw(img, img.Bounds(), image.NewUniform(color.NRGBA{0xff, 0xff, 0xff, 0xa0}), image.Point{}, draw.Over)
+	margin := max(cell/4, 2)
+	thick := max(cell/24, 1)
+	scale := max(cell/32, 1)
+
+	sms := superMoves(mvs)
+	b := start
+	for i, sm := range sms {
+		c := rainbow(float64(i) / float64(max(len(sms)-1, 1)))
+		off := (i%5 - 2) * cell / 12
+		center := func(b *Board) (int, int) {
+			p := b.ps[sm[0].pid]
+			return margin + p.x*cell + p.w*cell/2 + off, margin + p.y*cell + p.h*cell/2 + off
+		}
+		x0, y0 := center(b)
+		sx, sy := x0, y0
+		for _, m := range sm {
+			nb := b.move(m)
+			x1, y1 := center(nb)
+			if b.wrap && (abs(x1-x0) > cell || abs(y1-y0) > cell) {
+				// Wrapped around the board: don't draw across it.
+				x0, y0 = x1, y1
+				b = nb
+				continue
+			}
+			line(img, x0, y0, x1, y1, thick, c)
+			x0, y0 = x1, y1
+			b = nb
+		}
+		dx, dy := sm[len(sm)-1].dir.delta()
+		arrow(img, x0+dx*cell/8, y0+dy*cell/8, dx, dy, cell/8, c)
+
+		label := fmt.Sprint(i + 1)
+		w, h := (len(label)*6+1)*scale, 9*scale
+		bg := image.Rect(sx-w/2, sy-h/2, sx-w/2+w, sy-h/2+h)
+		draw.Draw(img, bg, image.NewUniform(c), image.Point{}, draw.Src)
+		drawText(img, label, sx, sy, scale, labelColor(c))
+	}
+	return img
+}
+
+// Draws a line thick pixels wide between two points.
+func line(img *image.RGBA, x0, y0, x1, y1, thick int, c color.Color) {
+	src := image.NewUniform(c)
+	n := max(abs(x1-x0), abs(y1-y0), 1)
+	for i := 0; i <= n; i++ {
+		x, y := x0+(x1-x0)*i/n, y0+(y1-y0)*i/n
+		r := image.Rect(x-thick/2, y-thick/2, x-thick/2+thick, y-thick/2+thick)
+		draw.Draw(img, r, src, image.Point{}, draw.Src)
+	}
+}
+
+// Returns a color from red (t = 0) through yellow and green to blue (t = 1).
+func rainbow(t float64) color.RGBA {
+	h := t * 240 // hue in degrees
+	x := 1 - abs(float64(int(h/60)%2)+(h/60-float64(int(h/60)))-1)
+	var r, g, b float64
+	switch int(h / 60) {
+	case 0:
+		r, g = 1, x
+	case 1:
+		r, g = x, 1
+	case 2:
+		g, b = 1, x
+	default:
+		g, b = x, 1
+	}
+	// Darken a little so white labels stay readable.
+	return color.RGBA{uint8(r * 200), uint8(g * 200), uint8(b * 200), 0xff}
+}
@@ -0,0 +1,234 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Puzzle packs.
+//
+// A pack file lists puzzles to be played in order, one per line:
+//
+//	// Comments start with "//".
+//	name Starter pack
+//	puzzle corners.txt 43 Corners
+//	puzzle squareroot.txt 116 Square Root
+//
+// Each puzzle line gives a puzzle file (relative to the pack file, or a
+// built-in or installed puzzle) or board code, the par move count, and the
+// puzzle's name. "squareroot campaign <pack>", where the pack is a pack
+// file or the name of a built-in or installed one, lists the puzzles with
+// the player's progress and plays the first one not yet completed;
+// "squareroot campaign <pack> <n>" plays puzzle n.
+// Each puzzle is unlocked by completing the one before it, without hints.
+// Progress, the best move count for each completed puzzle, is saved in the
+// user config directory.
+
+type pack struct {
+	name    string
+	puzzles []packPuzzle
+}
+
+type packPuzzle struct {
+	name string
+	par  int
+	b    *Board
+}
+
+// Reads a pack file and the puzzles it lists.
+func readPack(path string) (*pack, error) {
+	data, err := readPuzzleData(path)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &pack{name: strings.TrimSuffix(catalogBase(path), filepath.Ext(path))}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "//") {
+			continue
+		}
+		args := strings.Fields(line)
+		switch {
+		case args[0] == "name" && len(args) > 1:
+			p.name = strings.Join(args[1:], " ")
+		case args[0] == "puzzle" && len(args) > 3:
+			src := args[1]
+			if rel := catalogPath(catalogDir(path), src); !filepath.IsAbs(src) && puzzleFileExists(rel) {
+				src = rel
+			} else if name, ok := findCatalogPuzzle(src); ok {
+				src = name
+			}
+			b, err := loadBoard(src)
+			if err != nil {
+				return nil, fmt.Errorf("%s:%d: %v", path, n, err)
+			}
+			par, err := strconv.Atoi(args[2])
+			if err != nil || par < 0 {
+				return nil, fmt.Errorf("%s:%d: invalid par %q", path, n, args[2])
+			}
+			p.puzzles = append(p.puzzles, packPuzzle{strings.Join(args[3:], " "), par, b})
+		default:
+			return nil, fmt.Errorf("%s:%d: want \"name <name>\" or \"puzzle <file or code> <par> <name>\"", path, n)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(p.puzzles) == 0 {
+		return nil, fmt.Errorf("%s: no puzzles", path)
+	}
+	return p, nil
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+// The player's best move count for each completed puzzle, by pack name and
+// then puzzle name.
+type progress map[string]map[string]int
+
+func progressPath() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "squareroot", "progress.json"), nil
+}
+
+// Reads the saved progress, which is empty if none has been saved.
+func loadProgress() progress {
+	pr := progress{}
+	path, err := progressPath()
+	if err != nil {
+		return pr
+	}
+	if data, err := os.ReadFile(path); err == nil {
+		json.Unmarshal(data, &pr)
+	}
+	return pr
+}
+
+func (pr progress) save() error {
+	path, err := progressPath()
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(pr, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o644)
+}
+
+// Returns the best move count for a puzzle in a pack, if it's been completed.
+func (pr progress) best(p *pack, i int) (int, bool) {
+	n, ok := pr[p.name][p.puzzles[i].name]
+	return n, ok
+}
+
+// Reports whether puzzle i of a pack can be played.
+func (pr progress) unlocked(p *pack, i int) bool {
+	if i == 0 {
+		return true
+	}
+	_, done := pr.best(p, i-1)
+	return done
+}
+
+// Records a completion of puzzle i of a pack, keeping the best move count.
+func (pr progress) complete(p *pack, i, moves int) {
+	if pr[p.name] == nil {
+		pr[p.name] = make(map[string]int)
+	}
+	if best, ok := pr.best(p, i); !ok || moves < best {
+		pr[p.name][p.puzzles[i].name] = moves
+	}
+}
+
+// Runs "campaign <pack> [n]".
+func runCampaign(args []string) {
+	if len(args) < 1 || len(args) > 2 {
+		fmt.Fprintln(os.Stderr, "usage: squareroot campaign <pack file> [puzzle number]")
+		os.Exit(exitInvalid)
+	}
+	path, ok := findInCatalog(args[0], ".pack")
+	if !ok {
+		path = args[0]
+	}
+	p, err := readPack(path)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(exitInvalid)
+	}
+	pr := loadProgress()
+	printPack(p, pr)
+
+	i := -1
+	if len(args) == 2 {
+		n, err := strconv.Atoi(args[1])
+		if err != nil || n < 1 || n > len(p.puzzles) {
+			fmt.Fprintf(os.Stderr, "invalid puzzle number %q\n", args[1])
+			os.Exit(exitInvalid)
+		}
+		if i = n - 1; !pr.unlocked(p, i) {
+			fmt.Fprintf(os.Stderr, "%s is locked: complete %s first\n", p.puzzles[i].name, p.puzzles[i-1].name)
+			os.Exit(1)
+		}
+	} else {
+		for j := range p.puzzles {
+			if _, done := pr.best(p, j); !done {
+				i = j
+				break
+			}
+		}
+		if i < 0 {
+			fmt.Println("All puzzles completed!")
+			return
+		}
+	}
+
+	pp := p.puzzles[i]
+	fmt.Printf("\nPuzzle %d: %s (par %d)\n", i+1, pp.name, pp.par)
+	g := newGame(pp.b)
+	g.noHelp = true
+	play(g, os.Stdin, os.Stdout)
+	moves, solved := g.bestSolved()
+	if !solved {
+		return
+	}
+	pr.complete(p, i, moves)
+	if err := pr.save(); err != nil {
+		fmt.Fprintf(os.Stderr, "Couldn't save progress: %v\n", err)
+	}
+	fmt.Printf("Completed %s in %d moves (par %d).\n", pp.name, moves, pp.par)
+	if i+1 < len(p.puzzles) {
+		fmt.Printf("Unlocked %s.\n", p.puzzles[i+1].name)
+	}
+}
+
+// Lists a pack's puzzles with the player's progress.
+func printPack(p *pack, pr progress) {
+	fmt.Println(p.name)
+	for i, pp := range p.puzzles {
+		status := "locked"
+		if best, done := pr.best(p, i); done {
+			status = fmt.Sprintf("completed in %d moves", best)
+		} else if pr.unlocked(p, i) {
+			status = "unlocked"
+		}
+		fmt.Printf("%3d. %-20s par %4d  %s\n", i+1, pp.name, pp.par, status)
+	}
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+)
+
+// Simultaneous moves variant.
+//
+// In this variant a single step may slide several pieces at once, as long as
+// the moves don't interact: each piece moves at most once, and each moving
+// piece slides only into spaces that are open before the step and that no
+// other piece in the step is sliding into. Solving this variant answers
+// "what is the minimum number of parallel steps?", which is a different
+// optimality criterion than the number of sequential moves.
+
+var parallel = flag.Bool("parallel", false,
+	"Allow several non-interacting pieces to move in a single step, and find the fewest steps.")
+
+// Step records a set of non-interacting moves made simultaneously.
+type Step []Move
+
+func (st Step) String() string {
+	ms := []string{}
+	for _, m := range st {
+		ms = append(ms, m.String())
+	}
+	return strings.Join(ms, ", ")
+}
+
+// Returns every non-empty set of moves that can be made simultaneously
+// on this board.
+func (b *Board) possibleSteps() []Step {
+	mvs := b.possibleMoves()
+	sts := []Step{}
+	var extend func(i int, st Step, taken map[Space]bool)
+	extend = func(i int, st Step, taken map[Space]bool) {
+		for j := i; j < len(mvs); j++ {
+			m := mvs[j]
+			if st.movesPiece(m.pid) {
+				continue
+			}
+			tss := b.targetSpaces(m)
+			if anyTaken(tss, taken) {
+				continue
+			}
+			nst := append(append(Step{}, st...), m)
+			sts = append(sts, nst)
+			for _, ts := range tss {
+				taken[ts] = true
+			}
+			extend(j+1, nst, taken)
+			for _, ts := range tss {
+				delete(taken, ts)
+			}
+		}
+	}
+	extend(0, Step{}, make(map[Space]bool))
+	return sts
+}
+
+// Does this step already include a move of the given piece.
+func (st Step) movesPiece(pid string) bool {
+	for _, m := range st {
+		if m.pid == pid {
+			return true
+		}
+	}
+	return false
+}
+
+func anyTaken(ss []Space, taken map[Space]bool) bool {
+	for _, s := range ss {
+		if taken[s] {
+			return true
+		}
+	}
+	return false
+}
+
+// Which on-board spaces will be moved into by the given move.
+func (b *Board) targetSpaces(m Move) []Space {
+	if g := b.links[m.pid]; g != nil {
+		return b.groupTargetSpaces(g, m.dir)
+	}
+	tss := b.ps[m.pid].targetSpaces(m.dir)
+	if b.wrap {
+		for i, ts := range tss {
+			tss[i] = b.wrapSpace(ts)
+		}
+	}
+	return tss
+}
+
+// Returns a new board the same as this one but with all moves of the given
+// step applied. The moves don't interact, so they can be applied in any order.
+func (b *Board) step(st Step) *Board {
+	for _, m := range st {
+		b = b.move(m)
+	}
+	return b
+}
+
+// Searches breadth-first for the solution with the fewest steps, where each
+// step may move several non-interacting pieces at once. It returns the
+// solved board and the steps reaching it, or a nil board if there's no
+// solution.
+func searchParallel(start *Board) (*Board, []Step, Stats) {
+	type node struct {
+		b   *Board
+		sts []Step
+	}
+	ns := []node{{start, []Step{}}}
+	seenBoards := map[string]bool{start.Config(): true}
+	numSkipped, numExpanded := 0, 0
+	depth := 0
+	for len(ns) > 0 && !searchExpired() {
+		n := ns[0]
+		if len(n.sts) > depth {
+			events.depth(depth, len(seenBoards), len(ns), numSkipped)
+			depth = len(n.sts)
+		}
+		events.snapshot(len(seenBoards), len(ns), numSkipped)
+		ns = ns[1:]
+		numExpanded++
+		for _, st := range n.b.possibleSteps() {
+			nb := n.b.step(st)
+			nbConfig := nb.Config()
+			if seenBoards[nbConfig] || avoided[nbConfig] {
+				numSkipped++
+				continue
+			}
+			seenBoards[nbConfig] = true
+			nsts := append(append([]Step{}, n.sts...), st)
+			if nb.goal.IsSatisfied(nb) {
+				return nb, nsts, Stats{len(seenBoards), numSkipped, numExpanded}
+			}
+			ns = append(ns, node{nb, nsts})
+		}
+	}
+	return nil, nil, Stats{len(seenBoards), numSkipped, numExpanded}
+}
+
+// Solves with parallel steps and prints the solution.
+func solveParallel(start *Board) {
+	end, sts, stats := searchParallel(start)
+	memStats.stop()
+	if end == nil {
+		writeMemStats()
+		exitUnsolved(start, stats)
+	}
+	defer writeMemStats()
+	events.emit("solution", map[string]any{"length": len(end.mvs), "steps": len(sts),
+		"configurations": stats.Configs, "skipped": stats.Skipped})
+	if *format == "sbp" {
+		printSBPSolution(start, end, stats, sts)
+		return
+	}
+	if *format != "text" {
+		reportSolution(start, end, stats)
+		return
+	}
+	fmt.Printf("Found solution (%d steps, %d moves, %d configurations, %d skipped):\n",
+		len(sts), len(end.mvs), stats.Configs, stats.Skipped)
+	fmt.Printf("Reached goal: %s\n", describeReached(end.goal, end))
+	printBoardCode(start)
+	printSteps(start, sts)
+}
+
+func printSteps(b *Board, sts []Step) {
+	if renderBoardAfter(0, len(sts)) {
+		fmt.Print(b.display())
+	}
+	for i, st := range sts {
+		fmt.Printf("%d: %s\n", i+1, st.String())
+		b = b.step(st)
+		if renderBoardAfter(i+1, len(sts)) {
+			fmt.Print(b.display())
+		}
+	}
+}
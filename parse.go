@@ -0,0 +1,597 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Puzzle files.
+//
+// A puzzle file draws the starting board the same way Board.String() does,
+// optionally followed by directive lines that add rules to the board:
+//
+//	 ____
+//	|abbc|
+//	|abbc|
+//	|deef|
+//	|dghf|
+//	|i  j|
+//	 ~~~~
+//	goal b 1 3 or 2x2 0 3 and a 0 0
+//	oneway 1 4 down
+//	link g h
+//	rails
+//	constraint moved(a) == 0
+//	rule norepeat
+//
+// Each letter or digit is a piece occupying a rectangle of cells. Spaces and
+// '.' are open cells, and '#' cells are walls that never move. The top and
+// bottom borders are optional. Blank lines and lines starting with "//" are
+// ignored.
+
+// Loads a starting board from the named puzzle file or, if there is no such
+// file, from a board code. "-" reads a puzzle file or board code from
+// standard input.
+func loadBoard(arg string) (*Board, error) {
+	if arg == "-" {
+		return readBoardStdin()
+	}
+	b, err := readBoardFile(arg)
+	if !os.IsNotExist(err) {
+		return b, err
+	}
+	if b, err = Decode(arg); err != nil {
+		return nil, fmt.Errorf("%q is neither a puzzle file nor a board code", arg)
+	}
+	if err := b.validate(); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// Returns the board described by a board code, checking that it's valid.
+func decodeValid(code string) (*Board, error) {
+	b, err := Decode(code)
+	if err != nil {
+		return nil, err
+	}
+	if err := b.validate(); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// Checks that the pieces, walls and one-way cells of a board lie on it, the
+// pieces without overlapping, that its linked and frozen pieces are on it,
+// that it has a goal naming only pieces on the board, and that it satisfies
+// its constraint. Code that indexes cells by position relies on boards having
+// been validated.
+func (b *Board) validate() error {
+	onBoard := func(s Space) bool { return s.x >= 0 && s.y >= 0 && s.x < b.w && s.y < b.h }
+	for s := range b.walls {
+		if !onBoard(s) {
+			return fmt.Errorf("wall at %d,%d is off the board", s.x, s.y)
+		}
+	}
+	for s := range b.oneway {
+		if !onBoard(s) {
+			return fmt.Errorf("one-way cell at %d,%d is off the board", s.x, s.y)
+		}
+	}
+	for pid := range b.links {
+		if _, ok := b.ps[pid]; !ok {
+			return fmt.Errorf("no piece %s to link", pid)
+		}
+	}
+	for pid := range b.frozen {
+		if _, ok := b.ps[pid]; !ok {
+			return fmt.Errorf("no piece %s to freeze", pid)
+		}
+	}
+	if err := b.checkPieces(); err != nil {
+		return err
+	}
+	if b.goal == nil {
+		return fmt.Errorf("no goal")
+	}
+	if err := b.checkGoalPieces(b.goal); err != nil {
+		return err
+	}
+	if b.constraint != nil {
+		if err := b.checkGoalPieces(b.constraint); err != nil {
+			return fmt.Errorf("constraint: %v", err)
+		}
+	}
+	if b.constraint != nil && !b.constraint.IsSatisfied(b) {
+		return fmt.Errorf("the starting position breaks the constraint %v", b.constraint)
+	}
+	return nil
+}
+
+// Checks that the pieces lie on the board without overlapping each other or
+// walls.
+func (b *Board) checkPieces() error {
+	covered := make(map[Space]string)
+	for _, pid := range b.pieceIDs() {
+		p := b.ps[pid]
+		if p.w < 1 || p.h < 1 || p.x < 0 || p.y < 0 || p.x >= b.w || p.y >= b.h ||
+			!b.wrap && (p.x+p.w > b.w || p.y+p.h > b.h) {
+			return fmt.Errorf("piece %s doesn't fit on the board", pid)
+		}
+		for y := p.y; y < p.y+p.h; y++ {
+			for x := p.x; x < p.x+p.w; x++ {
+				s := b.wrapSpace(Space{x, y})
+				if b.walls[s] {
+					return fmt.Errorf("piece %s overlaps a wall", pid)
+				}
+				if other, ok := covered[s]; ok {
+					return fmt.Errorf("pieces %s and %s overlap", other, pid)
+				}
+				covered[s] = pid
+			}
+		}
+	}
+	return nil
+}
+
+// Checks that the pieces and shapes a goal names, in any of its conditions,
+// are on the board. Goals of other types are taken as they are.
+func (b *Board) checkGoalPieces(g Goal) error {
+	switch g := g.(type) {
+	case Condition:
+		return b.checkCondition(g)
+	case AllOf:
+		for _, sg := range g {
+			if err := b.checkGoalPieces(sg); err != nil {
+				return err
+			}
+		}
+	case AnyOf:
+		for _, sg := range g {
+			if err := b.checkGoalPieces(sg); err != nil {
+				return err
+			}
+		}
+	case Expr:
+		var err error
+		g.root.walk(func(n exprNode) {
+			if err != nil {
+				return
+			}
+			switch n := n.(type) {
+			case atNode:
+				err = b.checkCondition(n.c)
+			case coordNode:
+				err = b.checkCondition(Condition{pid: n.pid})
+			case movedNode:
+				if n.pid != "" {
+					err = b.checkCondition(Condition{pid: n.pid})
+				}
+			}
+		})
+		return err
+	}
+	return nil
+}
+
+// Checks that the piece or shape a condition names is on the board.
+func (b *Board) checkCondition(c Condition) error {
+	if c.pid == "" {
+		if !b.hasShape(c.w, c.h) {
+			return fmt.Errorf("no %dx%d goal piece", c.w, c.h)
+		}
+	} else if _, ok := b.ps[c.pid]; !ok {
+		return fmt.Errorf("no goal piece %s", c.pid)
+	}
+	return nil
+}
+
+// Reads a starting board from the named puzzle file, which may be in this
+// tool's own format or in the SBP text format.
+func readBoardFile(name string) (*Board, error) {
+	data, err := readPuzzleData(name)
+	if err != nil {
+		return nil, err
+	}
+	return parseBoardData(data)
+}
+
+// Reads a puzzle file, in either format, or a board code from standard
+// input.
+func readBoardStdin() (*Board, error) {
+	data, err := readInput("-")
+	if err != nil {
+		return nil, err
+	}
+	if fs := strings.Fields(string(data)); len(fs) == 1 {
+		if b, err := Decode(fs[0]); err == nil {
+			return b, b.validate()
+		}
+	}
+	return parseBoardData(data)
+}
+
+// Parses a puzzle file in this tool's own format or in the SBP text format.
+func parseBoardData(data []byte) (*Board, error) {
+	if isSBP(data) {
+		return parseSBP(bytes.NewReader(data))
+	}
+	return parseBoard(bytes.NewReader(data))
+}
+
+// Parses a starting board from a puzzle file.
+func parseBoard(r io.Reader) (*Board, error) {
+	rows := []string{}
+	directives := [][]string{}
+	lineNums := []int{}
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimRight(scanner.Text(), " \t\r")
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case trimmed == "" || strings.HasPrefix(trimmed, "//"):
+		case strings.Trim(trimmed, "_") == "" || strings.Trim(trimmed, "~") == "":
+			// Top or bottom border.
+		case strings.HasPrefix(line, "|"):
+			if len(directives) > 0 {
+				return nil, fmt.Errorf("line %d: board row after directives", n)
+			}
+			if len(line) < 2 || !strings.HasSuffix(line, "|") {
+				return nil, fmt.Errorf("line %d: board row must be enclosed in '|'", n)
+			}
+			rows = append(rows, line[1:len(line)-1])
+		default:
+			directives = append(directives, strings.Fields(trimmed))
+			lineNums = append(lineNums, n)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	b, err := parseGrid(rows)
+	if err != nil {
+		return nil, err
+	}
+	for i, d := range directives {
+		if err := b.applyDirective(d); err != nil {
+			return nil, fmt.Errorf("line %d: %v", lineNums[i], err)
+		}
+	}
+	if b.goal == nil {
+		return nil, fmt.Errorf("no goal directive")
+	}
+	if b.constraint != nil && !b.constraint.IsSatisfied(b) {
+		return nil, fmt.Errorf("the starting position breaks the constraint %v", b.constraint)
+	}
+	return b, nil
+}
+
+// Builds a board from the rows of a board drawing.
+func parseGrid(rows []string) (*Board, error) {
+	if len(rows) == 0 {
+		return nil, fmt.Errorf("no board rows")
+	}
+	w, h := len(rows[0]), len(rows)
+	type extent struct {
+		x1, y1, x2, y2, n int
+	}
+	es := make(map[string]*extent)
+	ids := []string{}
+	var walls map[Space]bool
+	for y, row := range rows {
+		if len(row) != w {
+			return nil, fmt.Errorf("row %d has width %d, want %d", y, len(row), w)
+		}
+		for x := 0; x < w; x++ {
+			c := row[x]
+			if c == ' ' || c == '.' {
+				continue
+			}
+			if c == '#' {
+				if walls == nil {
+					walls = make(map[Space]bool)
+				}
+				walls[Space{x, y}] = true
+				continue
+			}
+			if !isPieceID(c) {
+				return nil, fmt.Errorf("invalid character %q at %d,%d", c, x, y)
+			}
+			id := string(c)
+			e, ok := es[id]
+			if !ok {
+				e = &extent{x, y, x, y, 0}
+				es[id] = e
+				ids = append(ids, id)
+			}
+			e.x1, e.y1 = min(e.x1, x), min(e.y1, y)
+			e.x2, e.y2 = max(e.x2, x), max(e.y2, y)
+			e.n++
+		}
+	}
+	pm := make(map[string]Piece)
+	for _, id := range ids {
+		e := es[id]
+		p := Piece{id, e.x2 - e.x1 + 1, e.y2 - e.y1 + 1, e.x1, e.y1}
+		if p.w*p.h != e.n {
+			return nil, fmt.Errorf("piece %s is not a rectangle", id)
+		}
+		pm[id] = p
+	}
+	return &Board{w: w, h: h, ps: pm, mvs: []Move{}, walls: walls}, nil
+}
+
+func isPieceID(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9'
+}
+
+// Applies a directive line from a puzzle file to the board.
+func (b *Board) applyDirective(args []string) error {
+	switch args[0] {
+	case "goal":
+		// goal <condition> [and|or <condition>]...
+		// goal <expression>
+		g, err := b.parseGoal(args[1:])
+		if err != nil {
+			return err
+		}
+		b.goal = allOf(b.goal, g)
+		return nil
+	case "oneway":
+		// oneway <x> <y> <direction>...
+		if len(args) < 4 {
+			return fmt.Errorf("usage: oneway <x> <y> <direction>...")
+		}
+		s, err := b.parseSpace(args[1], args[2])
+		if err != nil {
+			return err
+		}
+		var ds DirSet
+		for _, a := range args[3:] {
+			d, err := parseDirection(a)
+			if err != nil {
+				return err
+			}
+			ds = ds.add(d)
+		}
+		if b.oneway == nil {
+			b.oneway = make(map[Space]DirSet)
+		}
+		b.oneway[s] = ds
+		return nil
+	case "link":
+		// link <piece> <piece>...
+		if len(args) < 3 {
+			return fmt.Errorf("usage: link <piece> <piece>...")
+		}
+		return b.link(args[1:])
+	case "rails":
+		// rails
+		if len(args) != 1 {
+			return fmt.Errorf("usage: rails")
+		}
+		b.rails = true
+		return nil
+	case "constraint":
+		// constraint <expression>
+		if len(args) < 2 {
+			return fmt.Errorf("usage: constraint <expression>")
+		}
+		e, err := b.parseExpr(strings.Join(args[1:], " "))
+		if err != nil {
+			return err
+		}
+		b.constraint = allOf(b.constraint, e)
+		return nil
+	case "rule":
+		// rule norepeat | cooldown <n> | zone <piece> <x1> <y1> <x2> <y2>
+		f, err := b.parseRule(args[1:])
+		if err != nil {
+			return err
+		}
+		b.filters = append(b.filters, f)
+		return nil
+	}
+	return fmt.Errorf("unknown directive %q", args[0])
+}
+
+// Parses a goal: "<piece> <x> <y>" conditions combined with "and" and "or",
+// where "and" binds more loosely. The piece is either a piece id or a shape
+// such as "2x2", which is satisfied by any piece of that shape. Goals using
+// expression syntax are parsed as expressions.
+func (b *Board) parseGoal(args []string) (Goal, error) {
+	if s := strings.Join(args, " "); isExpr(s) {
+		return b.parseExpr(s)
+	}
+	all := AllOf{}
+	for _, cargs := range splitArgs(args, "and") {
+		any := AnyOf{}
+		for _, cargs := range splitArgs(cargs, "or") {
+			c, err := b.parseCondition(cargs)
+			if err != nil {
+				return nil, err
+			}
+			any = append(any, c)
+		}
+		if len(any) == 1 {
+			all = append(all, any[0])
+		} else {
+			all = append(all, any)
+		}
+	}
+	if len(all) == 1 {
+		return all[0], nil
+	}
+	return all, nil
+}
+
+// Parses a single "<piece> <x> <y>" goal condition.
+func (b *Board) parseCondition(args []string) (Condition, error) {
+	if len(args) != 3 {
+		return Condition{}, fmt.Errorf("goal condition must be <piece> <x> <y>, got %q",
+			strings.Join(args, " "))
+	}
+	s, err := b.parseSpace(args[1], args[2])
+	if err != nil {
+		return Condition{}, err
+	}
+	c := Condition{x: s.x, y: s.y}
+	if _, err := fmt.Sscanf(args[0], "%dx%d", &c.w, &c.h); err == nil {
+		if !b.hasShape(c.w, c.h) {
+			return Condition{}, fmt.Errorf("no %s goal piece", args[0])
+		}
+	} else if _, ok := b.ps[args[0]]; ok {
+		c.pid = args[0]
+	} else {
+		return Condition{}, fmt.Errorf("no goal piece %s", args[0])
+	}
+	return c, nil
+}
+
+// Splits args into the runs separated by the given word.
+func splitArgs(args []string, sep string) [][]string {
+	runs := [][]string{{}}
+	for _, a := range args {
+		if a == sep {
+			runs = append(runs, []string{})
+			continue
+		}
+		runs[len(runs)-1] = append(runs[len(runs)-1], a)
+	}
+	return runs
+}
+
+// Parses a move in compact notation, e.g. "bD".
+func parseMove(s string) (Move, error) {
+	if len(s) < 2 {
+		return Move{}, fmt.Errorf("invalid move %q", s)
+	}
+	d, err := parseDirection(s[1:])
+	if err != nil {
+		return Move{}, fmt.Errorf("invalid move %q", s)
+	}
+	return Move{s[:1], d}, nil
+}
+
+// Parses the coordinates of a space on this board.
+func (b *Board) parseSpace(xs, ys string) (Space, error) {
+	x, err := strconv.Atoi(xs)
+	if err != nil {
+		return Space{}, fmt.Errorf("invalid x coordinate %q", xs)
+	}
+	y, err := strconv.Atoi(ys)
+	if err != nil {
+		return Space{}, fmt.Errorf("invalid y coordinate %q", ys)
+	}
+	if x < 0 || y < 0 || x >= b.w || y >= b.h {
+		return Space{}, fmt.Errorf("space %d,%d is off the board", x, y)
+	}
+	return Space{x, y}, nil
+}
+
+// Parses a direction name such as "up" or "Left", or its letter.
+func parseDirection(s string) (Direction, error) {
+	for _, d := range Directions {
+		if strings.EqualFold(s, d.String()) || strings.EqualFold(s, string(d.letter())) {
+			return d, nil
+		}
+	}
+	return 0, fmt.Errorf("invalid direction %q", s)
+}
+
+// Returns the board as a puzzle file that parseBoard reads back. It fails
+// for goals that puzzle files can't express: custom goals, and "or" goals
+// with an "and" inside.
+func (b *Board) puzzleFile() (string, error) {
+	goal, err := goalText(b.goal)
+	if err != nil {
+		return "", err
+	}
+	grid := b.grid()
+	var sb strings.Builder
+	fmt.Fprintf(&sb, " %s\n", strings.Repeat("_", b.w))
+	for y := 0; y < b.h; y++ {
+		row := []byte(grid.row(y))
+		for s := range b.oneway {
+			// One-way cells are given by directives, not drawn.
+			if s.y == y && !isPieceID(row[s.x]) {
+				row[s.x] = ' '
+			}
+		}
+		fmt.Fprintf(&sb, "|%s|\n", row)
+	}
+	fmt.Fprintf(&sb, " %s\n", strings.Repeat("~", b.w))
+	fmt.Fprintf(&sb, "goal %s\n", goal)
+	oneway := map[Space]bool{}
+	for s := range b.oneway {
+		oneway[s] = true
+	}
+	for _, s := range sortedSpaces(oneway) {
+		ds := strings.ReplaceAll(b.oneway[s].String(), ",", " ")
+		fmt.Fprintf(&sb, "oneway %d %d %s\n", s.x, s.y, strings.ToLower(ds))
+	}
+	for _, pid := range b.pieceIDs() {
+		if g := b.links[pid]; len(g) > 0 && g[0] == pid {
+			fmt.Fprintf(&sb, "link %s\n", strings.Join(g, " "))
+		}
+	}
+	if b.rails {
+		sb.WriteString("rails\n")
+	}
+	if b.constraint != nil {
+		c, err := exprText(b.constraint)
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(&sb, "constraint %s\n", c)
+	}
+	for _, f := range b.filters {
+		r, err := ruleText(f)
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(&sb, "rule %s\n", r)
+	}
+	return sb.String(), nil
+}
+
+// Returns a goal in the syntax parseGoal reads.
+func goalText(g Goal) (string, error) {
+	if hasExpr(g) {
+		return exprText(g)
+	}
+	switch g := g.(type) {
+	case Condition:
+		if g.pid == "" {
+			return fmt.Sprintf("%dx%d %d %d", g.w, g.h, g.x, g.y), nil
+		}
+		return fmt.Sprintf("%s %d %d", g.pid, g.x, g.y), nil
+	case AllOf:
+		return joinGoalTexts(g, " and ")
+	case AnyOf:
+		s, err := joinGoalTexts(g, " or ")
+		if err == nil && strings.Contains(s, " and ") {
+			err = fmt.Errorf("can't write goal %v: \"and\" inside \"or\"", g)
+		}
+		return s, err
+	}
+	return "", fmt.Errorf("can't write goal %v", g)
+}
+
+func joinGoalTexts(gs []Goal, sep string) (string, error) {
+	ts := []string{}
+	for _, g := range gs {
+		t, err := goalText(g)
+		if err != nil {
+			return "", err
+		}
+		ts = append(ts, t)
+	}
+	return strings.Join(ts, sep), nil
+}
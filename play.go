@@ -0,0 +1,293 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Terminal play.
+//
+// "squareroot play" shows the board and reads commands, one per line:
+//
+//	bD, b down      move piece b down (several moves may be given on a line)
+//	u, undo         undo the last move
+//	r, redo         redo the last undone move
+//	restart         return to the starting board (can be undone)
+//	a, assist       toggle showing which pieces can move, and where
+//	h, hint         show the next move of an optimal solution
+//	h <k>, hint <k> rank the moves from here and show the best k, each
+//	                marked best, neutral or harmful
+//	s, solve        play out an optimal solution from the current board
+//	save <file>     save the game in progress
+//	load <file>     resume a saved game
+//	export <file>   export the session as a solution file, or as an
+//	                asciinema cast if the file name ends in ".cast"
+//	?, help         list the commands
+//	q, quit         stop playing
+//
+// Undo and redo are unlimited, and the move counter always shows the number
+// of moves from the start to the current board. "squareroot play <file>"
+// resumes a saved game. Hints and solutions come from the puzzle's distance
+// table, built the first time one is asked for, or with -book, hints come
+// from the book for the positions in it (see book.go). With -challenge, play
+// allows only a limited number of moves (see challenge.go).
+
+// How long each move of a solution is shown.
+const playStep = 300 * time.Millisecond
+
+const playHelp = `Commands:
+  bD, b down   move piece b down (several moves may be given on a line)
+  u, undo      undo the last move
+  r, redo      redo the last undone move
+  restart      return to the starting board
+  a, assist    toggle showing which pieces can move, and where
+  h, hint      show the next move of an optimal solution
+  hint <k>     show the best k moves from here, and how good each is
+  s, solve     play out an optimal solution from here
+  save <file>  save the game in progress
+  load <file>  resume a saved game
+  export <file>
+               export the session's moves with their times, or a replay
+               of the session if the file name ends in .cast
+  ?, help      list the commands
+  q, quit      stop playing
+`
+
+func runPlay(start *Board, args []string) {
+	g := newGame(start)
+	if len(args) > 1 {
+		fmt.Fprintln(os.Stderr, "usage: squareroot play [saved game]")
+		os.Exit(exitInvalid)
+	}
+	if len(args) == 1 {
+		var err error
+		if g, err = loadGame(args[0]); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(exitInvalid)
+		}
+	}
+	if *bookFile != "" {
+		var err error
+		if g.book, err = readBook(*bookFile, g.start); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(exitInvalid)
+		}
+	}
+	if *challenge != "" {
+		fmt.Println("Finding the optimal solution length...")
+		if err := g.startChallenge(*challenge); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(exitInvalid)
+		}
+		fmt.Printf("Challenge: solve the puzzle in at most %d moves.\n", g.budget)
+	}
+	play(g, os.Stdin, os.Stdout)
+}
+
+// Plays a game, reading commands from in and writing boards to out.
+func play(g *game, in io.Reader, out io.Writer) {
+	scanner := bufio.NewScanner(in)
+	fmt.Fprint(out, playHelp)
+	fmt.Fprintf(out, "Goal: %v\n", g.b.goal)
+	assist := false
+	for {
+		fmt.Fprint(out, g.b.display())
+		if assist {
+			printMovable(g, out)
+		}
+		fmt.Fprintf(out, "Moves: %d", len(g.b.mvs))
+		if g.budget > 0 {
+			fmt.Fprintf(out, " of %d", g.budget)
+		}
+		if g.solved() {
+			fmt.Fprint(out, "  Solved!")
+		}
+		fmt.Fprintln(out)
+		if g.overBudget() {
+			fmt.Fprintln(out, g.status()+".")
+			return
+		}
+		fmt.Fprint(out, "> ")
+		if !scanner.Scan() {
+			fmt.Fprintln(out)
+			return
+		}
+		args := strings.Fields(scanner.Text())
+		if len(args) == 1 && (args[0] == "a" || strings.EqualFold(args[0], "assist")) {
+			assist = !assist
+			continue
+		}
+		if quit := command(&g, args, out); quit {
+			return
+		}
+	}
+}
+
+// Lists the pieces that can move, with arrows showing their directions.
+func printMovable(g *game, out io.Writer) {
+	dirs := g.movable()
+	pids := []string{}
+	for pid := range dirs {
+		pids = append(pids, pid)
+	}
+	sort.Strings(pids)
+	fmt.Fprint(out, "Can move:")
+	for _, pid := range pids {
+		fmt.Fprintf(out, "  %s ", pid)
+		for _, d := range dirs[pid] {
+			fmt.Fprint(out, d.arrow())
+		}
+	}
+	fmt.Fprintln(out)
+}
+
+// Carries out a play command, reporting whether the player quit. Loading a
+// saved game replaces *gp.
+func command(gp **game, args []string, out io.Writer) bool {
+	if len(args) == 0 {
+		return false
+	}
+	g := *gp
+	cmd := strings.ToLower(args[0])
+	if g.noHelp && (cmd == "h" || cmd == "hint" || cmd == "s" || cmd == "solve" || cmd == "load") {
+		fmt.Fprintln(out, "Hints, solving and loading are off in this mode.")
+		return false
+	}
+	switch cmd {
+	case "q", "quit", "exit":
+		return true
+	case "?", "help":
+		fmt.Fprint(out, playHelp)
+	case "u", "undo":
+		if !g.undo() {
+			fmt.Fprintln(out, "Nothing to undo.")
+		}
+	case "r", "redo":
+		if !g.redo() {
+			fmt.Fprintln(out, "Nothing to redo.")
+		}
+	case "restart":
+		g.restart()
+	case "h", "hint":
+		top := 0
+		if len(args) == 2 {
+			top, _ = strconv.Atoi(args[1])
+		}
+		if len(args) > 2 || len(args) == 2 && top < 1 {
+			fmt.Fprintln(out, "usage: hint [number of moves]")
+			return false
+		}
+		if _, _, ok := g.book.lookup(g.b); g.table == nil && (top > 0 || !ok) {
+			fmt.Fprintln(out, "Thinking...")
+		}
+		m, left, err := g.hint()
+		if err != nil {
+			fmt.Fprintf(out, "%v.\n", err)
+			return false
+		}
+		if top == 0 {
+			fmt.Fprintf(out, "Try %s (%d moves from the goal).\n", m.code(), left)
+			break
+		}
+		g.ensureTable()
+		rms, _ := g.table.RankMoves(g.b)
+		fmt.Fprintf(out, "%d moves from the goal. The best moves:\n", left)
+		for _, r := range rms[:min(top, len(rms))] {
+			after := "goal out of reach"
+			if r.Distance >= 0 {
+				after = countOf(r.Distance, "move") + " left"
+			}
+			fmt.Fprintf(out, "  %-3s %-8s %s\n", r.Move.code(), r.Quality(left), after)
+		}
+	case "s", "solve":
+		if g.table == nil {
+			fmt.Fprintln(out, "Thinking...")
+		}
+		mvs, err := g.solution()
+		if err != nil {
+			fmt.Fprintf(out, "%v.\n", err)
+			return false
+		}
+		for i, m := range mvs {
+			if i > 0 {
+				fmt.Fprint(out, g.b.display())
+				fmt.Fprintf(out, "Moves: %d\n", len(g.b.mvs))
+				time.Sleep(playStep)
+			}
+			g.move(m)
+		}
+	case "export":
+		if len(args) != 2 {
+			fmt.Fprintln(out, "usage: export <file>")
+			return false
+		}
+		if err := g.export(args[1]); err != nil {
+			fmt.Fprintf(out, "Couldn't export: %v\n", err)
+		} else {
+			fmt.Fprintf(out, "Exported to %s\n", args[1])
+		}
+	case "save", "load":
+		if len(args) != 2 {
+			fmt.Fprintf(out, "usage: %s <file>\n", args[0])
+			return false
+		}
+		if cmd == "save" {
+			if err := g.save(args[1]); err != nil {
+				fmt.Fprintf(out, "Couldn't save: %v\n", err)
+			} else {
+				fmt.Fprintf(out, "Saved to %s\n", args[1])
+			}
+			return false
+		}
+		lg, err := loadGame(args[1])
+		if err != nil {
+			fmt.Fprintf(out, "Couldn't load: %v\n", err)
+			return false
+		}
+		if g.book != nil && g.start.samePuzzle(lg.start) {
+			lg.book = g.book
+		}
+		*gp = lg
+	default:
+		mvs, err := parseMoves(args)
+		if err != nil {
+			fmt.Fprintf(out, "%v. Type ? for help.\n", err)
+			return false
+		}
+		for _, m := range mvs {
+			if !g.checkBlunder(m, out) {
+				break
+			}
+			if err := g.move(m); err != nil {
+				fmt.Fprintf(out, "%v.\n", err)
+				break
+			}
+		}
+	}
+	return false
+}
+
+// Parses moves given either in compact notation ("bD fL") or as a piece and
+// direction ("b down").
+func parseMoves(args []string) ([]Move, error) {
+	if len(args) == 2 && len(args[0]) == 1 {
+		if d, err := parseDirection(args[1]); err == nil {
+			return []Move{{args[0], d}}, nil
+		}
+	}
+	mvs := []Move{}
+	for _, a := range args {
+		m, err := parseMove(a)
+		if err != nil {
+			return nil, err
+		}
+		mvs = append(mvs, m)
+	}
+	return mvs, nil
+}
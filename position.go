@@ -0,0 +1,200 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Immutable positions.
+//
+// A Position is a board's pieces held by value in a fixed-size array, with
+// no maps and no moves, so it can be copied, compared with == and shared
+// between goroutines without locks. Its size, rules and goal live in a
+// board that every position derived from it shares and nothing ever
+// changes. Positions are slower to move than boards, which are built for a
+// single search, but goroutines can hand them to each other freely.
+//
+// With -workers n, breadth-first searches expand each level of the search
+// n positions at a time on separate goroutines.
+
+var workers = flag.Int("workers", 0,
+	"Search breadth-first on this many goroutines at once.")
+
+// A piece in a position. Ids are single characters and sizes and places fit
+// in a byte, as in board codes.
+type positionPiece struct {
+	id         byte
+	w, h, x, y uint8
+}
+
+// Position is an immutable board position.
+type Position struct {
+	// The board giving the size, rules and goal, with no pieces. It's never
+	// changed once the position is made.
+	rules *Board
+
+	n      int
+	pieces [len(pieceLetters)]positionPiece // the first n, sorted by id
+}
+
+// Position returns the board's position, or an error if the board is too
+// big or has too many pieces to hold in one, or if its move rules, goal or
+// constraint depend on the moves made, which a position doesn't keep.
+// Searches on positions fall back to solve then.
+func (b *Board) Position() (Position, error) {
+	if b.w > 255 || b.h > 255 {
+		return Position{}, fmt.Errorf("a %dx%d board is too big for a position", b.w, b.h)
+	}
+	if len(b.ps) > len(pieceLetters) {
+		return Position{}, fmt.Errorf("%d pieces are too many for a position", len(b.ps))
+	}
+	for _, f := range b.filters {
+		if _, ok := f.(MoveFilterState); ok {
+			return Position{}, fmt.Errorf("move rule %v depends on earlier moves, which positions don't keep", f)
+		}
+	}
+	if b.moveCounts() != nil {
+		return Position{}, fmt.Errorf("the goal or constraint counts moves, which positions don't keep")
+	}
+	rules := b.Clone()
+	rules.ps, rules.mvs = nil, nil
+	p := Position{rules: rules}
+	for _, pid := range b.pieceIDs() {
+		if len(pid) != 1 {
+			return Position{}, fmt.Errorf("piece id %q is too long for a position", pid)
+		}
+		q := b.ps[pid]
+		p.pieces[p.n] = positionPiece{pid[0], uint8(q.w), uint8(q.h), uint8(q.x), uint8(q.y)}
+		p.n++
+	}
+	return p, nil
+}
+
+// Board returns a board with the position's pieces and no moves.
+func (p Position) Board() *Board {
+	b := *p.rules
+	b.ps = make(map[string]Piece, p.n)
+	for _, q := range p.pieces[:p.n] {
+		id := string(q.id)
+		b.ps[id] = Piece{id, int(q.w), int(q.h), int(q.x), int(q.y)}
+	}
+	b.mvs = []Move{}
+	return &b
+}
+
+// LegalMoves returns the moves that can be made from the position.
+func (p Position) LegalMoves() []Move {
+	return p.Board().possibleMoves()
+}
+
+// ApplyMove returns the position after the given move, or an error saying
+// why the move can't be made.
+func (p Position) ApplyMove(m Move) (Position, error) {
+	b, err := p.Board().ApplyMove(m)
+	if err != nil {
+		return Position{}, err
+	}
+	return p.with(b), nil
+}
+
+// Returns the position with the pieces of a board made from it.
+func (p Position) with(b *Board) Position {
+	for i := range p.pieces[:p.n] {
+		q := b.ps[string(p.pieces[i].id)]
+		p.pieces[i].x, p.pieces[i].y = uint8(q.x), uint8(q.y)
+	}
+	return p
+}
+
+// Solved reports whether the position satisfies the goal.
+func (p Position) Solved() bool {
+	b := p.Board()
+	return b.goal.IsSatisfied(b)
+}
+
+// Config returns the position's configuration, the same as its board's.
+func (p Position) Config() string {
+	pcs := []string{}
+	for _, q := range p.pieces[:p.n] {
+		id := string(q.id)
+		c := fmt.Sprintf("%dx%d-%d,%d", q.w, q.h, q.x, q.y)
+		if g := p.rules.links[id]; g != nil {
+			c += "@" + g[0]
+		} else if p.rules.isGoalPiece(id) {
+			c += "@" + id
+		}
+		pcs = append(pcs, c)
+	}
+	sort.Strings(pcs)
+	return strings.Join(pcs, ";")
+}
+
+// Searches breadth-first like solve, expanding each level of the search on
+// -workers goroutines, which share the level's positions, kept in a
+// flatFrontier. It returns the solved board with the moves reaching it, or
+// nil if there's no solution.
+func solveConcurrent(start *Board) (*Board, Stats) {
+	startPos, err := start.Position()
+	if err != nil {
+		return solve(start)
+	}
+	type successor struct {
+		p      Position
+		config string
+		m      Move
+	}
+	f := newFlatFrontier(startPos)
+	seen := map[string]bool{startPos.Config(): true}
+	stats := Stats{Configs: 1}
+	level := []int{0}
+	depth := 0
+	n := max(*workers, 1)
+	for len(level) > 0 && !searchExpired() {
+		// Expand the level, each goroutine taking every nth position.
+		succs := make([][]successor, len(level))
+		var wg sync.WaitGroup
+		for w := 0; w < n; w++ {
+			wg.Add(1)
+			go func(w int) {
+				defer wg.Done()
+				for i := w; i < len(level); i += n {
+					p := f.position(level[i])
+					b := p.Board()
+					for _, m := range b.orderedMoves() {
+						np := p.with(b.move(m))
+						succs[i] = append(succs[i], successor{np, np.Config(), m})
+					}
+				}
+			}(w)
+		}
+		wg.Wait()
+
+		// Keep the new positions, in the order a sequential search would.
+		next := []int{}
+		for i, ss := range succs {
+			stats.Expanded++
+			depthStats.expand(depth)
+			for _, s := range ss {
+				dup := seen[s.config] || avoided[s.config]
+				depthStats.generate(depth, dup)
+				if dup {
+					stats.Skipped++
+					continue
+				}
+				seen[s.config] = true
+				stats.Configs++
+				j := f.add(s.p, level[i], s.m)
+				if s.p.Solved() {
+					return f.board(start, j), stats
+				}
+				next = append(next, j)
+			}
+		}
+		level = next
+		depth++
+	}
+	return nil, stats
+}
@@ -0,0 +1,256 @@
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+// Solvability pre-checks.
+//
+// Before searching, "solve" runs cheap static checks that settle some
+// puzzles at once:
+//
+//	solved       the starting board already satisfies the goal
+//	goal-fit     no goal piece can fit where the goal wants it: the piece or
+//	             shape is missing, or its target runs off the board or into
+//	             a wall
+//	goal-overlap two pieces the goal needs at once would overlap
+//	blank-count  a piece that must move can't: there are fewer open spaces
+//	             than the piece's narrower side
+//	no-moves     nothing can move at all
+//	parity       in an n-puzzle (1x1 tiles, one blank, every tile's target
+//	             given), the tiles' permutation has the wrong parity for
+//	             the blank's target
+//
+// The reports name the check that fired.
+
+// The outcome of a pre-check that fired.
+type precheckResult struct {
+	check   string
+	solved  bool // whether the check found the puzzle solved, not unsolvable
+	message string
+}
+
+// Runs the pre-checks, returning the first that fires, or nil if the puzzle
+// needs a search.
+func precheck(b *Board) *precheckResult {
+	if b.goal.IsSatisfied(b) {
+		return &precheckResult{"solved", true, "the starting board already satisfies the goal"}
+	}
+	if check, msg := b.goalBlocked(b.goal); check != "" {
+		return &precheckResult{check, false, msg}
+	}
+	if len(b.possibleMoves()) == 0 {
+		return &precheckResult{"no-moves", false, "no piece can move"}
+	}
+	if msg := b.parityCheck(); msg != "" {
+		return &precheckResult{"parity", false, msg}
+	}
+	return nil
+}
+
+// Returns "1 thing" or "n things".
+func countOf(n int, thing string) string {
+	if n == 1 {
+		return "1 " + thing
+	}
+	return fmt.Sprintf("%d %ss", n, thing)
+}
+
+// Returns the number of open spaces on the board.
+func (b *Board) openSpaces() int {
+	n := b.w*b.h - len(b.walls)
+	for _, p := range b.ps {
+		n -= p.w * p.h
+	}
+	return n
+}
+
+// Reports whether a goal can't be reached for static reasons, returning the
+// check that shows it and why, or empty strings.
+func (b *Board) goalBlocked(g Goal) (string, string) {
+	switch g := g.(type) {
+	case Condition:
+		return b.conditionBlocked(g)
+	case AllOf:
+		for _, sg := range g {
+			if check, msg := b.goalBlocked(sg); check != "" {
+				return check, msg
+			}
+		}
+		return b.targetsOverlap(g)
+	case AnyOf:
+		check, msg := "", ""
+		for _, sg := range g {
+			c, m := b.goalBlocked(sg)
+			if c == "" {
+				return "", ""
+			}
+			if check == "" {
+				check, msg = c, m
+			}
+		}
+		return check, msg
+	}
+	return "", ""
+}
+
+// Reports whether a single goal condition can't be reached.
+func (b *Board) conditionBlocked(c Condition) (string, string) {
+	w, h := c.w, c.h
+	name := fmt.Sprintf("no %dx%d piece", w, h)
+	if c.pid != "" {
+		p, ok := b.ps[c.pid]
+		if !ok {
+			return "goal-fit", fmt.Sprintf("the goal names piece %s, which isn't on the board", c.pid)
+		}
+		w, h, name = p.w, p.h, "piece "+c.pid
+	} else if !b.hasShape(w, h) {
+		return "goal-fit", fmt.Sprintf("the goal wants a %dx%d piece and there is none", w, h)
+	}
+	if !b.wrap && (c.x < 0 || c.y < 0 || c.x+w > b.w || c.y+h > b.h) {
+		return "goal-fit", fmt.Sprintf("%s would run off the board at %d,%d", name, c.x, c.y)
+	}
+	for y := c.y; y < c.y+h; y++ {
+		for x := c.x; x < c.x+w; x++ {
+			if b.walls[b.wrapSpace(Space{x, y})] {
+				return "goal-fit", fmt.Sprintf("%s would overlap the wall at %d,%d", name, x, y)
+			}
+		}
+	}
+	if !b.wrap && !c.IsSatisfied(b) && b.openSpaces() < min(w, h) {
+		return "blank-count", fmt.Sprintf("%s must move, but it needs %s to slide and the board has %d",
+			name, countOf(min(w, h), "open space"), b.openSpaces())
+	}
+	return "", ""
+}
+
+// Reports whether two named pieces that a goal needs at the same time would
+// overlap.
+func (b *Board) targetsOverlap(gs AllOf) (string, string) {
+	covered := map[Space]string{}
+	for _, g := range gs {
+		c, ok := g.(Condition)
+		if !ok || c.pid == "" {
+			continue
+		}
+		p := b.ps[c.pid]
+		for y := c.y; y < c.y+p.h; y++ {
+			for x := c.x; x < c.x+p.w; x++ {
+				s := b.wrapSpace(Space{x, y})
+				if other, ok := covered[s]; ok && other != c.pid {
+					return "goal-overlap", fmt.Sprintf("the goal puts pieces %s and %s both on %d,%d", other, c.pid, s.x, s.y)
+				}
+				covered[s] = c.pid
+			}
+		}
+	}
+	return "", ""
+}
+
+// Checks the permutation parity of an n-puzzle: a board of 1x1 tiles with a
+// single blank and no other rules, whose goal places every tile. Each move
+// swaps the blank with a tile, flipping the permutation's parity and the
+// parity of the blank's distance from its target, so the two must agree.
+// Returns why the puzzle is unsolvable, or "" if the check passes or
+// doesn't apply.
+func (b *Board) parityCheck() string {
+	if b.wrap || len(b.walls) > 0 || len(b.oneway) > 0 || len(b.links) > 0 || b.openSpaces() != 1 {
+		return ""
+	}
+	for _, p := range b.ps {
+		if p.w != 1 || p.h != 1 {
+			return ""
+		}
+	}
+	conds := []Condition{}
+	switch g := b.goal.(type) {
+	case Condition:
+		conds = append(conds, g)
+	case AllOf:
+		for _, sg := range g {
+			c, ok := sg.(Condition)
+			if !ok {
+				return ""
+			}
+			conds = append(conds, c)
+		}
+	default:
+		return ""
+	}
+
+	// The target index of each cell's occupant, with the blank last.
+	index := func(x, y int) int { return y*b.w + x }
+	target := map[string]int{}
+	used := map[int]bool{}
+	for _, c := range conds {
+		if c.pid == "" || target[c.pid] != 0 {
+			return ""
+		}
+		target[c.pid] = index(c.x, c.y) + 1
+		used[index(c.x, c.y)] = true
+	}
+	if len(target) != len(b.ps) {
+		return ""
+	}
+	blankTarget := -1
+	for i := 0; i < b.w*b.h; i++ {
+		if !used[i] {
+			blankTarget = i
+		}
+	}
+	perm := make([]int, b.w*b.h)
+	filled := make([]bool, b.w*b.h)
+	for pid, p := range b.ps {
+		i := index(p.x, p.y)
+		perm[i] = target[pid] - 1
+		filled[i] = true
+	}
+	blank := -1
+	for i, f := range filled {
+		if !f {
+			blank = i
+			perm[i] = blankTarget
+		}
+	}
+
+	// The parity of a permutation is that of its length minus its number
+	// of cycles.
+	cycles := 0
+	seen := make([]bool, len(perm))
+	for i := range perm {
+		if !seen[i] {
+			cycles++
+			for j := i; !seen[j]; j = perm[j] {
+				seen[j] = true
+			}
+		}
+	}
+	permParity := (len(perm) - cycles) % 2
+	dist := abs(blank%b.w-blankTarget%b.w) + abs(blank/b.w-blankTarget/b.w)
+	if permParity != dist%2 {
+		return "the tiles' permutation has the wrong parity for the blank's target position"
+	}
+	return ""
+}
+
+// Reports a puzzle settled by a pre-check, and exits if it's unsolvable. If
+// the puzzle is already solved it just notes the check on standard error, so
+// the caller can report the empty solution.
+func reportPrecheck(start *Board, r *precheckResult) {
+	events.emit("precheck", map[string]any{"check": r.check, "solved": r.solved})
+	if r.solved {
+		fmt.Fprintf(os.Stderr, "Pre-check %s: %s\n", r.check, r.message)
+		return
+	}
+	events.emit("unsolvable", map[string]any{"check": r.check})
+	if *format == "json" {
+		s := jsonSolution{Verdict: "unsolvable", Check: r.check}
+		s.Code, _ = start.Encode()
+		writeJSONSolution(s)
+	} else {
+		fmt.Printf("No solution: pre-check %s fired: %s.\n", r.check, r.message)
+		fmt.Println("The puzzle is proven unsolvable.")
+	}
+	os.Exit(exitUnsolvable)
+}
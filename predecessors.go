@@ -0,0 +1,72 @@
+package main
+
+// Predecessors.
+//
+// Searching backward, from the goal toward the start, needs the moves that
+// could have led to a position rather than those leading from it: to find
+// the positions farthest from a solved one, to meet a forward search in the
+// middle, or to work out which positions lose. Most moves can be undone by
+// the opposite move, but not all: a one-way cell can be entered only one
+// way, so undoing a move into it may be possible when the move itself
+// isn't, and a constraint or move rule may allow a move and not its undo or
+// the reverse. Predecessors answers the question directly, under the same
+// rules LegalMoves follows.
+
+// Predecessor is a board one move before another, with the move that leads
+// from it.
+type Predecessor struct {
+	Board *Board // the earlier board, with no moves
+	Move  Move   // the move from it
+}
+
+// Predecessors returns the boards from which one legal move leads to this
+// board's position, each with its move, in the order of the pieces' ids
+// and then of Directions. Move rules that depend on earlier moves, such as
+// norepeat, are checked as though the earlier boards had been reached
+// with no moves.
+func (b *Board) Predecessors() []Predecessor {
+	ps := []Predecessor{}
+	root := *b
+	root.mvs = []Move{}
+	for _, pid := range b.pieceIDs() {
+		if b.ps[pid].held(b) {
+			continue
+		}
+		for _, d := range Directions {
+			back := Move{pid, d.reverse()}
+			if !root.canUndo(back) {
+				continue
+			}
+			prev := root.move(back)
+			prev.mvs = []Move{}
+			if b.constraint != nil && !b.constraint.IsSatisfied(prev) {
+				continue
+			}
+			if m := (Move{pid, d}); prev.isLegal(m) {
+				ps = append(ps, Predecessor{prev, m})
+			}
+		}
+	}
+	return ps
+}
+
+// Reports whether the pieces the move slides could have come from where it
+// takes them: whether the spaces it moves into are open. Unlike a move, an
+// undo pays no attention to one-way cells, which restrict only the move
+// being undone.
+func (b *Board) canUndo(m Move) bool {
+	if g := b.links[m.pid]; g != nil {
+		for _, s := range b.groupTargetSpaces(g, m.dir) {
+			if !b.isOpen(s) {
+				return false
+			}
+		}
+		return true
+	}
+	for _, s := range b.ps[m.pid].targetSpaces(m.dir) {
+		if !b.isOpen(s) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Stored puzzles.
+//
+// A front end where users make their own puzzles would otherwise send the
+// whole board with every request. POST /puzzles, with board=<code> and
+// optionally name=<name>, stores a puzzle and answers with its id, GET
+// /puzzles/{id} returns it, and puzzle=<id> in place of board=<code> refers
+// to it in /hint, /solve and /jobs, with any moves made from its board. Ids
+// are random, so one user's puzzles are out of reach of others who haven't
+// been given their ids. With -store, puzzles are saved in that directory,
+// one JSON file each, and outlive the server; otherwise they're kept in
+// memory. Puzzles larger than -max-cells are refused, as their searches
+// would be, and with -cache, searches look up and save solutions in the
+// results cache, so a stored puzzle is solved once however often it's
+// asked about.
+
+var storeDir = flag.String("store", "",
+	"Directory where the server saves submitted puzzles; if empty, they're kept in memory.")
+
+// The longest name a stored puzzle may have.
+const maxPuzzleName = 100
+
+// A puzzle stored with POST /puzzles.
+type storedPuzzle struct {
+	ID      string    `json:"id"`
+	Name    string    `json:"name,omitempty"`
+	Code    string    `json:"code"`
+	Created time.Time `json:"created"`
+}
+
+// The stored puzzles, by id.
+type puzzleStore struct {
+	dir     string // where they're saved, or "" to keep them in memory
+	mu      sync.Mutex
+	puzzles map[string]*storedPuzzle
+}
+
+// Opens the store in the given directory, reading the puzzles saved there,
+// or with an empty directory, an empty store kept in memory.
+func openPuzzleStore(dir string) (*puzzleStore, error) {
+	s := &puzzleStore{dir: dir, puzzles: make(map[string]*storedPuzzle)}
+	if dir == "" {
+		return s, nil
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range files {
+		data, err := os.ReadFile(f)
+		if err != nil {
+			return nil, err
+		}
+		var p storedPuzzle
+		if err := json.Unmarshal(data, &p); err != nil || p.ID == "" {
+			return nil, fmt.Errorf("%s: not a stored puzzle", f)
+		}
+		s.puzzles[p.ID] = &p
+	}
+	return s, nil
+}
+
+// Stores a puzzle, saving it if the store has a directory.
+func (s *puzzleStore) add(name, code string) (*storedPuzzle, error) {
+	id := make([]byte, 8)
+	rand.Read(id)
+	p := &storedPuzzle{hex.EncodeToString(id), name, code, time.Now().UTC()}
+	if s.dir != "" {
+		data, err := json.MarshalIndent(p, "", "  ")
+		if err == nil {
+			err = os.WriteFile(filepath.Join(s.dir, p.ID+".json"), data, 0o644)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.puzzles[p.ID] = p
+	return p, nil
+}
+
+func (s *puzzleStore) get(id string) *storedPuzzle {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.puzzles[id]
+}
+
+func (s *puzzleStore) len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.puzzles)
+}
+
+type storedPuzzleResponse struct {
+	ID      string    `json:"id"`
+	Name    string    `json:"name,omitempty"`
+	Code    string    `json:"code"`
+	Width   int       `json:"width"`
+	Height  int       `json:"height"`
+	Board   string    `json:"board"`
+	Goal    string    `json:"goal"`
+	Created time.Time `json:"created"`
+}
+
+func (p *storedPuzzle) response(b *Board) storedPuzzleResponse {
+	return storedPuzzleResponse{p.ID, p.Name, p.Code, b.w, b.h, b.String(), fmt.Sprint(b.goal), p.Created}
+}
+
+func (s *server) handleAddPuzzle(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		writeJSON(w, http.StatusMethodNotAllowed, errorResponse{"puzzles are stored with POST"})
+		return
+	}
+	code, name := r.FormValue("board"), r.FormValue("name")
+	if code == "" {
+		writeJSON(w, http.StatusBadRequest, errorResponse{"no board"})
+		return
+	}
+	if len(name) > maxPuzzleName {
+		writeJSON(w, http.StatusBadRequest, errorResponse{fmt.Sprintf("name longer than %d bytes", maxPuzzleName)})
+		return
+	}
+	b, err := Decode(code)
+	if err == nil {
+		err = b.validate()
+	}
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{err.Error()})
+		return
+	}
+	if err := checkSize(b); err != nil {
+		writeSearchError(w, err)
+		return
+	}
+	p, err := s.puzzles.add(name, code)
+	if err != nil {
+		log.Printf("Storing puzzle: %v", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"couldn't store the puzzle"})
+		return
+	}
+	w.Header().Set("Location", "/puzzles/"+p.ID)
+	writeJSON(w, http.StatusCreated, p.response(b))
+}
+
+func (s *server) handleStoredPuzzle(w http.ResponseWriter, r *http.Request) {
+	p := s.puzzles.get(strings.TrimPrefix(r.URL.Path, "/puzzles/"))
+	if p == nil {
+		writeJSON(w, http.StatusNotFound, errorResponse{"no such puzzle"})
+		return
+	}
+	b, err := Decode(p.Code)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, errorResponse{err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, p.response(b))
+}
@@ -0,0 +1,150 @@
+"""Python bindings for the squareroot sliding block puzzle solver.
+
+A thin wrapper over the solver's C shared library (see "C library" in the
+project's README). Build the library with
+
+    go build -tags capi -buildmode=c-shared -o libsquareroot.so .
+
+and put it next to this package, on the system's library path, or at the
+path in the SQUAREROOT_LIB environment variable. Then:
+
+    import squareroot
+
+    b = squareroot.Board("AgMDAAhhAQEC...")
+    sol = b.solve()
+    print(sol.length, sol.moves)
+    print(b.move("eD").hint())
+
+Boards are given by board code, as "squareroot -format json" prints them,
+and moves in compact notation, such as "eD".
+"""
+
+import ctypes
+import ctypes.util
+import json
+import os
+from dataclasses import dataclass
+from typing import List, Optional, Sequence
+
+__all__ = ["Board", "Solution", "Hint", "SolverError", "solve", "hint"]
+
+# The library ABI these bindings use.
+ABI_VERSION = 1
+
+
+class SolverError(Exception):
+    """A bad request, or a search that found no solution."""
+
+
+@dataclass(frozen=True)
+class Solution:
+    """A shortest solution: its length and its moves."""
+
+    length: int
+    moves: List[str]
+
+
+@dataclass(frozen=True)
+class Hint:
+    """The distance to the goal and an optimal next move, with the board
+    code after it; move and board are None once solved."""
+
+    distance: int
+    move: Optional[str]
+    board: Optional[str]
+
+
+def _load():
+    paths = []
+    if os.environ.get("SQUAREROOT_LIB"):
+        paths.append(os.environ["SQUAREROOT_LIB"])
+    here = os.path.dirname(os.path.abspath(__file__))
+    for name in ("libsquareroot.so", "libsquareroot.dylib", "squareroot.dll"):
+        paths.append(os.path.join(here, name))
+    found = ctypes.util.find_library("squareroot")
+    if found:
+        paths.append(found)
+    for path in paths:
+        try:
+            lib = ctypes.CDLL(path)
+        except OSError:
+            continue
+        version = lib.squareroot_abi_version()
+        if version != ABI_VERSION:
+            raise ImportError(
+                f"{path} has ABI version {version}; these bindings need {ABI_VERSION}")
+        for fn in (lib.squareroot_solve, lib.squareroot_hint):
+            fn.argtypes = [ctypes.c_char_p]
+            # A pointer rather than c_char_p, so that it can be freed.
+            fn.restype = ctypes.c_void_p
+        lib.squareroot_free.argtypes = [ctypes.c_void_p]
+        lib.squareroot_free.restype = None
+        return lib
+    raise ImportError(
+        "libsquareroot not found; build it with "
+        "go build -tags capi -buildmode=c-shared -o libsquareroot.so . "
+        "and set SQUAREROOT_LIB to its path")
+
+
+_lib = _load()
+
+
+def _call(fn, code: str, moves: Sequence[str], timeout: Optional[float],
+          max_states: Optional[int]) -> dict:
+    req = {"board": code, "moves": list(moves)}
+    if timeout is not None:
+        req["timeout_ms"] = int(timeout * 1000)
+    if max_states is not None:
+        req["max_states"] = max_states
+    ptr = fn(json.dumps(req).encode())
+    try:
+        resp = json.loads(ctypes.string_at(ptr).decode())
+    finally:
+        _lib.squareroot_free(ptr)
+    if "error" in resp:
+        raise SolverError(resp["error"])
+    return resp
+
+
+def solve(code: str, moves: Sequence[str] = (), timeout: Optional[float] = None,
+          max_states: Optional[int] = None) -> Solution:
+    """Returns a shortest solution from the board with the given code, after
+    the given moves. The search gives up, raising SolverError, after
+    timeout seconds (default 10) or after visiting max_states
+    configurations (default 1000000); 0 means no limit."""
+    resp = _call(_lib.squareroot_solve, code, moves, timeout, max_states)
+    return Solution(resp["length"], resp["moves"])
+
+
+def hint(code: str, moves: Sequence[str] = (), timeout: Optional[float] = None,
+         max_states: Optional[int] = None) -> Hint:
+    """Returns an optimal next move from the board with the given code,
+    after the given moves, with the same limits as solve."""
+    resp = _call(_lib.squareroot_hint, code, moves, timeout, max_states)
+    return Hint(resp["distance"], resp.get("move"), resp.get("board"))
+
+
+class Board:
+    """A position: a board code and moves made from it."""
+
+    def __init__(self, code: str, moves: Sequence[str] = ()):
+        self.code = code
+        self.moves = tuple(moves)
+
+    def move(self, *moves: str) -> "Board":
+        """Returns the position after the given moves. They're checked when
+        the position is solved."""
+        return Board(self.code, self.moves + moves)
+
+    def solve(self, timeout: Optional[float] = None,
+              max_states: Optional[int] = None) -> Solution:
+        return solve(self.code, self.moves, timeout, max_states)
+
+    def hint(self, timeout: Optional[float] = None,
+             max_states: Optional[int] = None) -> Hint:
+        return hint(self.code, self.moves, timeout, max_states)
+
+    def __repr__(self):
+        if self.moves:
+            return f"Board({self.code!r}, {list(self.moves)!r})"
+        return f"Board({self.code!r})"
@@ -0,0 +1,21 @@
+package main
+
+// Rails.
+//
+// On a board with rails, as in Rush Hour, each piece slides only along its
+// length: a piece wider than it is tall moves left and right, one taller
+// than it is wide moves up and down, and a square piece moves either way.
+// Puzzle files turn rails on with a directive after the grid:
+//
+//	rails
+
+// Can this piece slide in the given direction when held to its rail.
+func (p Piece) slidesAlong(d Direction) bool {
+	switch {
+	case p.w > p.h:
+		return d == Left || d == Right
+	case p.h > p.w:
+		return d == Up || d == Down
+	}
+	return true
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Puzzle remixes.
+//
+// "squareroot remix [dir]" makes variations of the puzzle, each one or more
+// random changes away from it:
+//
+//	swap    two pieces trade places
+//	resize  a piece grows or shrinks by a row or column
+//	goal    a goal piece's target moves somewhere else
+//
+// A variation is kept only if it's a valid board, it can still be solved
+// and it isn't isomorphic (see isomorphism.go) to the original or another
+// variation, so the remixes form a family of related but distinct puzzles.
+// Each is printed as a puzzle file with a comment saying what changed, or
+// written to the directory as remix-1.txt, remix-2.txt and so on.
+
+var remixFlags = flag.NewFlagSet("remix", flag.ContinueOnError)
+
+var (
+	remixCount    = remixFlags.Int("n", 5, "Number of remixes to make.")
+	remixChanges  = remixFlags.Int("changes", 1, "Random changes in each remix.")
+	remixAttempts = remixFlags.Int("attempts", 200, "Candidates to try before giving up.")
+	remixSeed     = remixFlags.Int64("seed", 0, "Random seed; 0 picks one from the clock.")
+)
+
+// Runs "remix [dir]".
+func runRemix(start *Board, args []string) {
+	if len(args) > 1 {
+		fmt.Fprintln(os.Stderr, "usage: squareroot remix [flags] [dir]")
+		os.Exit(exitInvalid)
+	}
+	seed := *remixSeed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	rng := rand.New(rand.NewSource(seed))
+	seen := map[string]bool{}
+	if canon, err := start.canonical(); err == nil {
+		seen[canon] = true
+	}
+
+	made := 0
+	for i := 0; i < *remixAttempts && made < *remixCount; i++ {
+		b := start.clone()
+		changes := []string{}
+		for try := 0; try < 100 && len(changes) < max(*remixChanges, 1); try++ {
+			if change := b.remixChange(rng); change != "" {
+				changes = append(changes, change)
+			}
+		}
+		if len(changes) == 0 {
+			continue
+		}
+		canon, err := b.canonical()
+		if err != nil || seen[canon] {
+			continue
+		}
+		seen[canon] = true
+		moves, ok := remixLength(b)
+		if !ok {
+			continue
+		}
+		text, err := b.puzzleFile()
+		if err != nil {
+			continue
+		}
+		made++
+		header := fmt.Sprintf("// Remix %d, seed %d: %s. Best possible: %d moves.\n", made, seed, joinChanges(changes), moves)
+		if len(args) == 0 {
+			if made > 1 {
+				fmt.Println()
+			}
+			fmt.Print(header + text)
+			continue
+		}
+		name := filepath.Join(args[0], fmt.Sprintf("remix-%d.txt", made))
+		if err := os.WriteFile(name, []byte(header+text), 0644); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(exitError)
+		}
+		fmt.Printf("%s: %s, %d moves\n", name, joinChanges(changes), moves)
+	}
+	if made < *remixCount {
+		fmt.Fprintf(os.Stderr, "Made %s in %d attempts.\n", countOf(made, "remix"), *remixAttempts)
+		if made == 0 {
+			os.Exit(exitError)
+		}
+	}
+}
+
+// Makes one random change to the board, returning a description of it, or
+// "" if the change it tried left the board invalid, in which case the board
+// is unchanged.
+func (b *Board) remixChange(rng *rand.Rand) string {
+	pids := b.pieceIDs()
+	nb := b.clone()
+	change := ""
+	switch rng.Intn(3) {
+	case 0:
+		p, q := nb.ps[pids[rng.Intn(len(pids))]], nb.ps[pids[rng.Intn(len(pids))]]
+		if p.id == q.id || p.w == q.w && p.h == q.h {
+			return ""
+		}
+		p.x, p.y, q.x, q.y = q.x, q.y, p.x, p.y
+		nb.ps[p.id], nb.ps[q.id] = p, q
+		change = fmt.Sprintf("swapped %s and %s", p.id, q.id)
+	case 1:
+		p := nb.ps[pids[rng.Intn(len(pids))]]
+		dw, dh := 0, 0
+		if rng.Intn(2) == 0 {
+			dw = 2*rng.Intn(2) - 1
+		} else {
+			dh = 2*rng.Intn(2) - 1
+		}
+		p.w, p.h = p.w+dw, p.h+dh
+		nb.ps[p.id] = p
+		change = fmt.Sprintf("resized %s to %dx%d", p.id, p.w, p.h)
+	case 2:
+		moved := false
+		x, y := rng.Intn(b.w), rng.Intn(b.h)
+		nb.goal = mapGoal(nb.goal, func(c Condition) Condition {
+			if !moved && rng.Intn(2) == 0 {
+				c.x, c.y, moved = x, y, true
+				change = fmt.Sprintf("moved the goal of %s to %d,%d", goalPieceName(c), x, y)
+			}
+			return c
+		})
+		if !moved {
+			return ""
+		}
+	}
+	if nb.validate() != nil {
+		return ""
+	}
+	*b = *nb
+	return change
+}
+
+// Names the piece a goal condition is about.
+func goalPieceName(c Condition) string {
+	if c.pid != "" {
+		return c.pid
+	}
+	return fmt.Sprintf("%dx%d", c.w, c.h)
+}
+
+// Returns the optimal solution length of a remix, and whether it can be
+// solved without already being solved.
+func remixLength(b *Board) (int, bool) {
+	if precheck(b) != nil {
+		return 0, false
+	}
+	end, _ := solve(b)
+	if end == nil {
+		return 0, false
+	}
+	return len(end.mvs), true
+}
+
+// Joins descriptions of changes with commas and "and".
+func joinChanges(changes []string) string {
+	s := ""
+	for i, c := range changes {
+		switch {
+		case i == 0:
+		case i == len(changes)-1:
+			s += " and "
+		default:
+			s += ", "
+		}
+		s += c
+	}
+	return s
+}
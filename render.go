@@ -0,0 +1,150 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"os"
+)
+
+// Image rendering.
+//
+// renderBoard draws a board as an image in the -theme colors, with each
+// piece a labeled block, optionally highlighting a move: the piece is
+// outlined and an arrow shows where it goes. "squareroot render <file.png>
+// [move]" writes the puzzle's board (from -puzzle, which also takes board
+// codes) as a PNG, and image exporters use renderBoard for their frames.
+
+var cellSize = flag.Int("cell", 64, "Pixels per board space in rendered images.")
+
+var highlightColor = rgb(0xffd700)
+
+// Draws a board, highlighting the given move if it isn't nil.
+func renderBoard(b *Board, hl *Move, cell int) *image.RGBA {
+	margin := max(cell/4, 2)
+	img := image.NewRGBA(image.Rect(0, 0, b.w*cell+2*margin, b.h*cell+2*margin))
+	fill := func(r image.Rectangle, c color.Color) {
+		draw.Draw(img, r, image.NewUniform(c), image.Point{}, draw.Src)
+	}
+	space := func(s Space) image.Rectangle {
+		x, y := margin+s.x*cell, margin+s.y*cell
+		return image.Rect(x, y, x+cell, y+cell)
+	}
+	t := activeTheme
+	fill(img.Bounds(), t.frame)
+	fill(image.Rect(margin, margin, margin+b.w*cell, margin+b.h*cell), t.open)
+	for s := range b.walls {
+		fill(space(s), t.wall)
+	}
+
+	inset := max(cell/16, 1)
+	for _, pid := range b.pieceIDs() {
+		p := b.ps[pid]
+		c := t.pieceColor(b, pid)
+		if b.wrap {
+			// A piece may wrap around the edges, so draw each space, inset
+			// only along the piece's own edges.
+			for dy := 0; dy < p.h; dy++ {
+				for dx := 0; dx < p.w; dx++ {
+					r := space(b.wrapSpace(Space{p.x + dx, p.y + dy}))
+					if dx == 0 {
+						r.Min.X += inset
+					}
+					if dx == p.w-1 {
+						r.Max.X -= inset
+					}
+					if dy == 0 {
+						r.Min.Y += inset
+					}
+					if dy == p.h-1 {
+						r.Max.Y -= inset
+					}
+					fill(r, c)
+				}
+			}
+		} else {
+			fill(space(Space{p.x, p.y}).Union(space(Space{p.x + p.w - 1, p.y + p.h - 1})).Inset(inset), c)
+		}
+		cx, cy := margin+p.x*cell+p.w*cell/2, margin+p.y*cell+p.h*cell/2
+		drawText(img, pid, cx, cy, max(cell/16, 1), labelColor(c))
+	}
+
+	if hl != nil {
+		if p, ok := b.ps[hl.pid]; ok {
+			r := space(Space{p.x, p.y}).Union(space(Space{p.x + p.w - 1, p.y + p.h - 1}))
+			outline(img, r, inset*2, highlightColor)
+			cx, cy := (r.Min.X+r.Max.X)/2, (r.Min.Y+r.Max.Y)/2
+			dx, dy := hl.dir.delta()
+			// The arrow points from the piece's label toward the edge it moves to.
+			ax, ay := cx+dx*(r.Dx()/2-3*inset), cy+dy*(r.Dy()/2-3*inset)
+			arrow(img, ax, ay, dx, dy, cell/5, highlightColor)
+		}
+	}
+	return img
+}
+
+// Draws the outline of a rectangle, thick pixels wide, inside it.
+func outline(img *image.RGBA, r image.Rectangle, thick int, c color.Color) {
+	src := image.NewUniform(c)
+	for _, e := range []image.Rectangle{
+		image.Rect(r.Min.X, r.Min.Y, r.Max.X, r.Min.Y+thick),
+		image.Rect(r.Min.X, r.Max.Y-thick, r.Max.X, r.Max.Y),
+		image.Rect(r.Min.X, r.Min.Y, r.Min.X+thick, r.Max.Y),
+		image.Rect(r.Max.X-thick, r.Min.Y, r.Max.X, r.Max.Y),
+	} {
+		draw.Draw(img, e, src, image.Point{}, draw.Src)
+	}
+}
+
+// Draws a filled triangular arrowhead with its tip at x, y pointing in the
+// direction dx, dy (one of which is 0), size pixels long.
+func arrow(img *image.RGBA, x, y, dx, dy, size int, c color.Color) {
+	for i := 0; i <= size; i++ {
+		// The row i pixels back from the tip is 2i+1 pixels wide.
+		rx, ry := x-dx*i, y-dy*i
+		for j := -i; j <= i; j++ {
+			img.Set(rx+dy*dy*j, ry+dx*dx*j, c)
+		}
+	}
+}
+
+// Writes an image as a PNG file.
+func writePNG(path string, img image.Image) error {
+	f, err := createOutput(path)
+	if err != nil {
+		return err
+	}
+	if err := png.Encode(f, img); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Runs "render <file.png> [move]".
+func runRender(b *Board, args []string) {
+	if len(args) < 1 || len(args) > 2 {
+		fmt.Fprintln(os.Stderr, "usage: squareroot render [-puzzle file or code] [-cell pixels] <file.png> [move]")
+		os.Exit(exitInvalid)
+	}
+	var hl *Move
+	if len(args) == 2 {
+		m, err := parseMove(args[1])
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(exitInvalid)
+		}
+		if !b.isLegal(m) {
+			fmt.Fprintf(os.Stderr, "%s can't move %s\n", m.pid, m.dir)
+			os.Exit(exitInvalid)
+		}
+		hl = &m
+	}
+	if err := writePNG(args[0], renderBoard(b, hl, max(*cellSize, 8))); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,235 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"math/rand"
+	"os"
+	"time"
+)
+
+// Solution sampling.
+//
+// "squareroot sample" picks -k solutions that differ from each other as much
+// as possible, so a puzzle book can show genuinely different ways to solve
+// a puzzle rather than one line and its trivial reorderings. It draws a
+// pool of solutions uniformly at random from all optimal ones, or from all
+// up to -extra moves longer, then chooses greedily: each pick is the
+// candidate farthest from those already chosen.
+//
+// Two solutions are compared by the positions they pass through rather
+// than their moves, so that moving one of two identical pieces instead of
+// the other doesn't count as a different line: the distance is the edit
+// distance between the two sequences of positions.
+
+var sampleFlags = flag.NewFlagSet("sample", flag.ContinueOnError)
+
+var (
+	sampleK     = sampleFlags.Int("k", 3, "Number of solutions to pick.")
+	sampleExtra = sampleFlags.Int("extra", 0, "How many moves longer than the optimal a solution may be.")
+	samplePool  = sampleFlags.Int("pool", 200, "Number of random solutions to choose from.")
+	sampleSeed  = sampleFlags.Int64("seed", 0, "Random seed; 0 picks one from the clock.")
+)
+
+// Runs "sample".
+func runSample(start *Board) {
+	if *sampleK < 1 || *samplePool < *sampleK || *sampleExtra < 0 {
+		fmt.Fprintln(os.Stderr, "-k must be positive, -pool at least -k, and -extra not negative")
+		os.Exit(exitInvalid)
+	}
+	seed := *sampleSeed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	fmt.Fprintln(os.Stderr, "Building distance table...")
+	g := buildMoveGraph(start)
+	t := g.distanceTable(start)
+	optimal := t.dist[0]
+	if optimal < 0 {
+		fmt.Println("The puzzle can't be solved.")
+		os.Exit(exitUnsolvable)
+	}
+
+	s := newSolutionSampler(g, t.dist, *sampleExtra)
+	r := rand.New(rand.NewSource(seed))
+	pool := []sampledLine{}
+	for i := 0; i < *samplePool; i++ {
+		pool = append(pool, s.sample(start, r))
+	}
+	chosen, gaps := pickDiverse(pool, *sampleK)
+
+	fmt.Printf("Optimal solution: %d moves. Sampled %d solutions, seed %d.\n", optimal, len(pool), seed)
+	for i, l := range chosen {
+		fmt.Printf("\nLine %d (%d moves", i+1, len(l.mvs))
+		if i > 0 {
+			fmt.Printf(", %d positions from the nearest line above", gaps[i])
+		}
+		fmt.Println("):")
+		fmt.Println(moveCodes(l.mvs))
+	}
+	if len(chosen) < *sampleK {
+		fmt.Printf("\nThe pool held only %s.\n", countOf(len(chosen), "different line"))
+	}
+}
+
+// A sampled solution: its moves and the nodes of the positions it passes
+// through, the start first.
+type sampledLine struct {
+	mvs   []Move
+	nodes []int32
+}
+
+// Draws solutions uniformly at random from those of length up to the
+// optimal plus extra.
+type solutionSampler struct {
+	g     *moveGraph
+	dist  []int
+	extra int
+
+	// ways[n][i] is the number of solutions from node n taking dist[n]+i
+	// moves, as a float since it may be astronomically large and only the
+	// ratios matter.
+	ways [][]float64
+}
+
+// Counts the solutions of each length from every node.
+func newSolutionSampler(g *moveGraph, dist []int, extra int) *solutionSampler {
+	s := &solutionSampler{g, dist, extra, make([][]float64, len(dist))}
+	farthest := 0
+	for n, d := range dist {
+		if d >= 0 {
+			s.ways[n] = make([]float64, extra+1)
+			farthest = max(farthest, d)
+		}
+	}
+	for r := 0; r <= farthest+extra; r++ {
+		for n, d := range dist {
+			if d < 0 || r < d || r > d+extra {
+				continue
+			}
+			if g.solved[n] {
+				if r == 0 {
+					s.ways[n][0] = 1
+				}
+				continue
+			}
+			for _, nn := range g.succ[n] {
+				s.ways[n][r-d] += s.count(int(nn), r-1)
+			}
+		}
+	}
+	return s
+}
+
+// The number of solutions from node n taking exactly r moves.
+func (s *solutionSampler) count(n, r int) float64 {
+	d := s.dist[n]
+	if d < 0 || r < d || r > d+s.extra {
+		return 0
+	}
+	return s.ways[n][r-d]
+}
+
+// Draws a random solution from the start: first its length, in proportion
+// to the number of solutions of each length, then each move in proportion
+// to the number of solutions that continue with it.
+func (s *solutionSampler) sample(start *Board, rnd *rand.Rand) sampledLine {
+	r := s.dist[0] + pickWeighted(rnd, s.ways[0])
+	b := start
+	l := sampledLine{nodes: []int32{0}}
+	for ; r > 0; r-- {
+		mvs := b.possibleMoves()
+		nodes := make([]int, len(mvs))
+		weights := make([]float64, len(mvs))
+		for i, m := range mvs {
+			nodes[i] = s.g.index[b.move(m).Config()]
+			weights[i] = s.count(nodes[i], r-1)
+		}
+		i := pickWeighted(rnd, weights)
+		b = b.move(mvs[i])
+		l.mvs = append(l.mvs, mvs[i])
+		l.nodes = append(l.nodes, int32(nodes[i]))
+	}
+	return l
+}
+
+// Returns a random index into weights, chosen in proportion to them.
+func pickWeighted(rnd *rand.Rand, weights []float64) int {
+	total := 0.0
+	for _, w := range weights {
+		total += w
+	}
+	x := rnd.Float64() * total
+	last := 0
+	for i, w := range weights {
+		if w == 0 {
+			continue
+		}
+		if x < w {
+			return i
+		}
+		x -= w
+		last = i
+	}
+	return last
+}
+
+// Chooses k lines from the pool greedily, each the farthest from those
+// already chosen, starting with the shortest, and stopping early if the
+// pool runs out of different lines. It returns the lines and the distance of
+// each from its nearest predecessor.
+func pickDiverse(pool []sampledLine, k int) ([]sampledLine, []int) {
+	first := 0
+	for i, l := range pool {
+		if len(l.mvs) < len(pool[first].mvs) {
+			first = i
+		}
+	}
+	chosen := []sampledLine{pool[first]}
+	gaps := []int{0}
+	// The distance from each candidate to its nearest chosen line.
+	nearest := make([]int, len(pool))
+	for i := range pool {
+		nearest[i] = editDistance(pool[i].nodes, pool[first].nodes)
+	}
+	for len(chosen) < k {
+		best := 0
+		for i := range pool {
+			if nearest[i] > nearest[best] {
+				best = i
+			}
+		}
+		if nearest[best] == 0 {
+			// Every candidate repeats a chosen line.
+			break
+		}
+		chosen = append(chosen, pool[best])
+		gaps = append(gaps, nearest[best])
+		for i := range pool {
+			nearest[i] = min(nearest[i], editDistance(pool[i].nodes, pool[best].nodes))
+		}
+	}
+	return chosen, gaps
+}
+
+// Returns the edit distance between two sequences: the fewest insertions,
+// deletions and substitutions turning one into the other.
+func editDistance(a, b []int32) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+)
+
+// Saved games.
+//
+// A game in progress is saved as a JSON file holding the starting board's
+// code and the moves from the start to the current board in compact
+// notation, as in the results cache. Loading replays the moves, so undo can
+// step back through them as if they had just been played.
+
+type savedGame struct {
+	Code    string    `json:"code"`
+	Moves   []string  `json:"moves"`
+	SavedAt time.Time `json:"saved_at"`
+}
+
+// Saves the starting board and the moves to the current board to a file.
+func (g *game) save(path string) error {
+	code, err := g.start.Encode()
+	if err != nil {
+		return err
+	}
+	s := savedGame{code, []string{}, time.Now().UTC()}
+	for _, m := range g.b.mvs {
+		s.Moves = append(s.Moves, m.code())
+	}
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o644)
+}
+
+// Reads a game saved with save.
+func loadGame(path string) (*game, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	// A solution file loads as a game that played its moves.
+	start, moves, ok, err := readSolutionFile(data)
+	if !ok {
+		var s savedGame
+		if err := json.Unmarshal(data, &s); err != nil {
+			return nil, fmt.Errorf("%s: %v", path, err)
+		}
+		start, err = Decode(s.Code)
+		moves = s.Moves
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	if err := start.validate(); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	g := newGame(start)
+	for i, c := range moves {
+		m, err := parseMove(c)
+		if err == nil {
+			err = g.move(m)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: move %d: %v", path, i+1, err)
+		}
+	}
+	// The session starts now, at the saved board.
+	g.session = nil
+	g.record(g.b)
+	return g, nil
+}
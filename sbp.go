@@ -0,0 +1,171 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// SBP puzzle files.
+//
+// Many published sliding block puzzle collections (e.g. those distributed
+// for Jimslide and SBPSolver-style solvers) describe a puzzle as two plain
+// character grids: the start position, then a blank line, then the goal
+// position.
+//
+//	; Lines starting with ';' are comments.
+//	######
+//	#ABBC#
+//	#ABBC#
+//	#DEEF#
+//	#DGHF#
+//	#I..J#
+//	######
+//
+//	######
+//	#....#
+//	#....#
+//	#....#
+//	#.BB.#
+//	#.BB.#
+//	######
+//
+// '#' cells are walls and '.' or ' ' cells are open. Each other character is
+// a piece occupying a rectangle of cells. Rows and columns made up entirely of
+// walls around the edge are dropped, since the board has its own frame.
+//
+// Only the goal-piece positions matter in the goal grid. A goal piece with the
+// same letter and shape as a start piece must end up exactly there; otherwise
+// any start piece of the same shape satisfies it.
+//
+// The grids may be followed by a solution's moves in compact notation, as
+// -format sbp writes them, which are ignored.
+
+// Does this puzzle file look like an SBP file rather than this tool's format.
+func isSBP(data []byte) bool {
+	for _, line := range strings.Split(string(data), "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "//") || strings.HasPrefix(trimmed, ";") {
+			continue
+		}
+		return !strings.HasPrefix(trimmed, "|") && strings.Trim(trimmed, "_") != ""
+	}
+	return false
+}
+
+// Parses a starting board from an SBP puzzle file.
+func parseSBP(r io.Reader) (*Board, error) {
+	grids := [][]string{{}}
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), " \t\r")
+		switch {
+		case strings.HasPrefix(strings.TrimSpace(line), ";"):
+		case line == "":
+			if len(grids[len(grids)-1]) > 0 {
+				grids = append(grids, []string{})
+			}
+		default:
+			grids[len(grids)-1] = append(grids[len(grids)-1], line)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(grids[len(grids)-1]) == 0 {
+		grids = grids[:len(grids)-1]
+	}
+	if len(grids) == 3 {
+		if _, err := parseMoveList(moveTokens(strings.Join(grids[2], "\n"))); err == nil {
+			grids = grids[:2]
+		}
+	}
+	if len(grids) != 2 {
+		return nil, fmt.Errorf("SBP file must have a start grid and a goal grid, found %d grids", len(grids))
+	}
+	start, goal := padRows(grids[0]), padRows(grids[1])
+	if len(start) != len(goal) || len(start[0]) != len(goal[0]) {
+		return nil, fmt.Errorf("start and goal grids have different sizes")
+	}
+	start, goal = trimWalls(start, goal)
+
+	b, err := parseGrid(start)
+	if err != nil {
+		return nil, fmt.Errorf("start grid: %v", err)
+	}
+	gb, err := parseGrid(goal)
+	if err != nil {
+		return nil, fmt.Errorf("goal grid: %v", err)
+	}
+	all := AllOf{}
+	for _, pid := range gb.pieceIDs() {
+		gp := gb.ps[pid]
+		c := Condition{x: gp.x, y: gp.y}
+		if p, ok := b.ps[pid]; ok && p.w == gp.w && p.h == gp.h {
+			c.pid = pid
+		} else if b.hasShape(gp.w, gp.h) {
+			c.w, c.h = gp.w, gp.h
+		} else {
+			return nil, fmt.Errorf("goal piece %s matches no start piece", pid)
+		}
+		all = append(all, c)
+	}
+	switch len(all) {
+	case 0:
+		return nil, fmt.Errorf("goal grid has no pieces")
+	case 1:
+		b.goal = all[0]
+	default:
+		b.goal = all
+	}
+	return b, b.validate()
+}
+
+// Pads rows with open cells to the width of the widest row.
+func padRows(rows []string) []string {
+	w := 0
+	for _, row := range rows {
+		w = max(w, len(row))
+	}
+	prs := []string{}
+	for _, row := range rows {
+		prs = append(prs, row+strings.Repeat(".", w-len(row)))
+	}
+	return prs
+}
+
+// Drops the edge rows and columns of the start grid that are entirely walls,
+// and the same rows and columns of the goal grid.
+func trimWalls(start, goal []string) ([]string, []string) {
+	allWalls := func(s string) bool { return strings.Trim(s, "#") == "" }
+	column := func(x int) string {
+		var sb strings.Builder
+		for _, row := range start {
+			sb.WriteByte(row[x])
+		}
+		return sb.String()
+	}
+	y1, y2 := 0, len(start)
+	for y1 < y2 && allWalls(start[y1]) {
+		y1++
+	}
+	for y2 > y1 && allWalls(start[y2-1]) {
+		y2--
+	}
+	x1, x2 := 0, len(start[0])
+	for x1 < x2 && allWalls(column(x1)) {
+		x1++
+	}
+	for x2 > x1 && allWalls(column(x2-1)) {
+		x2--
+	}
+	trim := func(rows []string) []string {
+		trs := []string{}
+		for _, row := range rows[y1:y2] {
+			trs = append(trs, row[x1:x2])
+		}
+		return trs
+	}
+	return trim(start), trim(goal)
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+)
+
+// Self-check.
+//
+// "squareroot selfcheck [name...]" solves the built-in puzzles whose files
+// give their known optimal lengths, in comments like "Best possible: 116
+// moves, or 81 piece moves.", and checks that the solvers still find
+// exactly those, to catch regressions in the search code. The counts are
+// published ones where there are any, so the check doesn't just repeat the
+// solvers' own answers: squareroot.txt is the classic Klotski layout, 81
+// piece moves, pennant.txt the Pennant Puzzle, 59 piece moves, and
+// eight.txt one of the two 8-puzzle positions that take 31 moves. Piece
+// moves are checked with -piece-moves' search.
+// The names default to every built-in puzzle with a known length; the
+// command exits with status 1 if any count differs or a search times out.
+
+// Matches an optimal piece move count given in a puzzle file's comment.
+var bestPieceMoves = regexp.MustCompile(`Best possible: .*?(\d+) piece moves`)
+
+// A count to check: a puzzle's optimal length in one metric.
+type knownCount struct {
+	name   string
+	metric string // "moves" or "piece moves"
+	want   int
+}
+
+// Returns the known counts given in a built-in puzzle's comment.
+func knownCounts(name string) []knownCount {
+	comment := puzzleComment(name)
+	ks := []knownCount{}
+	if m := bestPossible.FindStringSubmatch(comment); m != nil {
+		n, _ := strconv.Atoi(m[1])
+		ks = append(ks, knownCount{name, "moves", n})
+	}
+	if m := bestPieceMoves.FindStringSubmatch(comment); m != nil {
+		n, _ := strconv.Atoi(m[1])
+		ks = append(ks, knownCount{name, "piece moves", n})
+	}
+	return ks
+}
+
+// Runs "selfcheck [name...]".
+func runSelfcheck(args []string) {
+	names := builtinFiles("*.txt")
+	if len(args) > 0 {
+		names = nil
+		for _, arg := range args {
+			name, ok := findCatalogPuzzle(arg)
+			if !ok {
+				fmt.Fprintf(os.Stderr, "No puzzle %q\n", arg)
+				os.Exit(exitInvalid)
+			}
+			names = append(names, name)
+		}
+	}
+	checks := []knownCount{}
+	for _, name := range names {
+		checks = append(checks, knownCounts(name)...)
+	}
+	if len(checks) == 0 {
+		fmt.Fprintln(os.Stderr, "No known optimal lengths to check.")
+		os.Exit(exitInvalid)
+	}
+
+	failed := 0
+	fmt.Printf("%-16s %-12s %6s %6s  %s\n", "puzzle", "metric", "known", "found", "result")
+	for _, k := range checks {
+		b, err := readBoardFile(k.name)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", k.name, err)
+			os.Exit(exitInvalid)
+		}
+		startDeadline()
+		var end *Board
+		if k.metric == "moves" {
+			end, _ = solve(b)
+		} else {
+			end, _ = solvePieceMoves(b)
+		}
+		found, result := "-", "ok"
+		switch {
+		case end == nil && searchExpired():
+			result = "timed out"
+		case end == nil:
+			result = "UNSOLVED"
+		default:
+			n := len(end.mvs)
+			if k.metric != "moves" {
+				n = len(superMoves(end.mvs))
+			}
+			found = strconv.Itoa(n)
+			if n != k.want {
+				result = "MISMATCH"
+			}
+		}
+		if result != "ok" {
+			failed++
+		}
+		fmt.Printf("%-16s %-12s %6d %6s  %s\n", catalogBase(k.name), k.metric, k.want, found, result)
+	}
+	if failed > 0 {
+		fmt.Printf("%s failed.\n", countOf(failed, "check"))
+		os.Exit(exitError)
+	}
+	fmt.Printf("All %s passed.\n", countOf(len(checks), "check"))
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"math/rand"
+	"os"
+	"time"
+)
+
+// Self-test.
+//
+// "squareroot selftest" is a stress test of the search code: it generates
+// -n random small puzzles, like gen's but from 2x3 up to -max-width by
+// -max-height, and solves each with two independent solvers, breadth-first
+// search and IDA* (see ida.go). Both solutions must be legal, reach the
+// goal, and have the same length. Puzzle i is generated from seed -seed+i,
+// so a divergence is reported with the seed that reproduces it on its own,
+// "squareroot selftest -seed <seed> -n 1", and the puzzle's board code.
+// Puzzles breadth-first search finds unsolvable, or either solver runs out
+// of time on, are counted but not compared; each search gets -timeout, or
+// five seconds without it, since IDA* takes minutes on the odd 4x4 puzzle.
+// The command exits with status 1 if any puzzle's solvers diverge.
+
+var selftestFlags = flag.NewFlagSet("selftest", flag.ContinueOnError)
+
+var (
+	selftestN         = selftestFlags.Int("n", 100, "Number of random puzzles to solve.")
+	selftestSeed      = selftestFlags.Int64("seed", 0, "Seed of the first puzzle; 0 picks one from the clock.")
+	selftestMaxWidth  = selftestFlags.Int("max-width", 4, "Widest board to generate.")
+	selftestMaxHeight = selftestFlags.Int("max-height", 4, "Tallest board to generate.")
+)
+
+// Runs "selftest".
+func runSelftest() {
+	maxW, maxH := *selftestMaxWidth, *selftestMaxHeight
+	if *selftestN < 1 || maxW < 2 || maxH < 2 || maxW*maxH < 6 {
+		fmt.Fprintln(os.Stderr, "usage: squareroot selftest [-n puzzles] [-seed n] [-max-width w] [-max-height h], with room for a 2x3 board")
+		os.Exit(exitInvalid)
+	}
+	if *timeout == 0 {
+		*timeout = 5 * time.Second
+	}
+	seed := *selftestSeed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	fmt.Printf("Solving %s from seed %d with breadth-first search and IDA*.\n", countOf(*selftestN, "random puzzle"), seed)
+
+	agreed, unsolvable, timedOut, diverged := 0, 0, 0, 0
+	for i := 0; i < *selftestN; i++ {
+		caseSeed := seed + int64(i)
+		b := selftestPuzzle(caseSeed, maxW, maxH)
+		problem := ""
+		startDeadline()
+		bfsEnd, _ := solve(b)
+		switch {
+		case bfsEnd == nil && searchExpired():
+			timedOut++
+			continue
+		case bfsEnd == nil:
+			unsolvable++
+			continue
+		}
+		startDeadline()
+		idaEnd, _ := solveIDAStar(b)
+		switch {
+		case idaEnd == nil && searchExpired():
+			timedOut++
+			continue
+		case idaEnd == nil:
+			problem = fmt.Sprintf("breadth-first search took %s; IDA* found no solution",
+				countOf(len(bfsEnd.mvs), "move"))
+		default:
+			if err := checkSolution(b, bfsEnd.mvs); err != nil {
+				problem = "breadth-first search's solution " + err.Error()
+			} else if err := checkSolution(b, idaEnd.mvs); err != nil {
+				problem = "IDA*'s solution " + err.Error()
+			} else if len(bfsEnd.mvs) != len(idaEnd.mvs) {
+				problem = fmt.Sprintf("breadth-first search took %s, IDA* %d",
+					countOf(len(bfsEnd.mvs), "move"), len(idaEnd.mvs))
+			}
+		}
+		if problem == "" {
+			agreed++
+			continue
+		}
+		diverged++
+		code, _ := b.Encode()
+		fmt.Printf("Puzzle %d (%dx%d, seed %d): %s.\n", i+1, b.w, b.h, caseSeed, problem)
+		fmt.Printf("  Reproduce with: squareroot selftest -seed %d -n 1 -max-width %d -max-height %d\n", caseSeed, maxW, maxH)
+		fmt.Printf("  Board code: %s\n", code)
+	}
+
+	fmt.Printf("%d agreed, %d unsolvable, %d timed out, %d diverged.\n", agreed, unsolvable, timedOut, diverged)
+	if diverged > 0 {
+		os.Exit(exitError)
+	}
+}
+
+// Generates the self-test's puzzle for a seed: a random board of random
+// size up to the given one, with at least six spaces, that isn't already
+// solved.
+func selftestPuzzle(seed int64, maxW, maxH int) *Board {
+	rng := rand.New(rand.NewSource(seed))
+	for {
+		w, h := 2+rng.Intn(maxW-1), 2+rng.Intn(maxH-1)
+		if w*h < 6 {
+			continue
+		}
+		if b := randomBoard(rng, w, h); !b.goal.IsSatisfied(b) {
+			return b
+		}
+	}
+}
+
+// Checks that the moves are legal from the start and reach the goal.
+func checkSolution(start *Board, mvs []Move) error {
+	end, err := start.ApplyMoves(mvs)
+	if err != nil {
+		return fmt.Errorf("is illegal: %v", err)
+	}
+	if !end.goal.IsSatisfied(end) {
+		return fmt.Errorf("doesn't reach the goal")
+	}
+	return nil
+}
@@ -0,0 +1,307 @@
+package main
+
+import (
+	"embed"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/fs"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Server mode.
+//
+// "squareroot serve" builds the distance table for the puzzle once and then
+// answers HTTP queries from memory:
+//
+//	GET /puzzle                  the puzzle's starting board
+//	GET /hint?board=<code>       an optimal next move from a position
+//	GET /solve?board=<code>      an optimal solution from a position
+//	POST /puzzles?board=<code>   store a puzzle, for reference by id
+//	GET /puzzles/{id}            a stored puzzle
+//	POST /jobs?board=<code>      start solving a position in the background
+//	GET /jobs/{id}               a background job's progress and solution
+//	GET /metrics                 Prometheus metrics
+//	GET /openapi.json            OpenAPI document describing the API
+//
+// A position is given as a board code (board=...), as moves from the
+// starting board in compact notation (moves=jL,fD,...), or both, the moves
+// then made from the board. With neither, the starting board is used.
+// A stored puzzle's board can be given by id (puzzle=...) instead of by
+// code. Responses are JSON. With boards=true, /solve also draws the
+// position and the board after each move. With top=K, /hint also ranks the
+// legal moves by the distance to the goal each leaves and lists the best
+// K, each marked best, neutral or harmful, for coaching. Boards of other
+// puzzles are searched, within limits (see jobs.go); ranking their moves
+// takes a search from the board after each.
+//
+// With -ui the server also serves a web playground at / (the files in ui,
+// built into the program), where the puzzle can be played by clicking
+// pieces and arrows, a board code pasted, and the solution from the
+// position shown animated, all through the endpoints above.
+
+// The OpenAPI document describing the server's API. The client package is a
+// Go client for it.
+//
+//go:embed api/openapi.json
+var openAPISpec []byte
+
+// The web playground.
+//
+//go:embed ui
+var uiFS embed.FS
+
+var (
+	addr    = flag.String("addr", "localhost:8080", "Address for the server to listen on.")
+	serveUI = flag.Bool("ui", false, "Also serve a web playground for the puzzle at /.")
+)
+
+type server struct {
+	table   *DistanceTable
+	metrics *metrics
+	jobs    *jobQueue // searches of other puzzles' boards
+	async   *jobStore // jobs started with POST /jobs
+	puzzles *puzzleStore
+}
+
+// Builds the distance table and serves queries until the process is killed.
+func runServer(start *Board) {
+	puzzles, err := openPuzzleStore(*storeDir)
+	if err != nil {
+		log.Fatalf("Opening puzzle store: %v", err)
+	}
+	if *storeDir != "" {
+		log.Printf("Loaded %d stored puzzles from %s", puzzles.len(), *storeDir)
+	}
+	t0 := time.Now()
+	log.Printf("Building distance table...")
+	s := &server{buildDistanceTable(start), newMetrics(), newJobQueue(max(*maxJobs, 1), max(*queueLen, 0)),
+		newJobStore(), puzzles}
+	s.metrics.expansions = s.table.Size()
+	s.metrics.jobs = s.jobs
+	s.metrics.buildSeconds = time.Since(t0).Seconds()
+	d, _ := s.table.Distance(start)
+	log.Printf("Built distance table of %d configurations in %v; start is %d moves from the goal",
+		s.table.Size(), time.Since(t0).Round(time.Millisecond), d)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/puzzle", s.metrics.instrument("/puzzle", s.handlePuzzle))
+	mux.HandleFunc("/hint", s.metrics.instrument("/hint", s.handleHint))
+	mux.HandleFunc("/solve", s.metrics.instrument("/solve", s.handleSolve))
+	mux.HandleFunc("/puzzles", s.metrics.instrument("/puzzles", s.handleAddPuzzle))
+	mux.HandleFunc("/puzzles/", s.metrics.instrument("/puzzles/{id}", s.handleStoredPuzzle))
+	mux.HandleFunc("/jobs", s.metrics.instrument("/jobs", s.handleStartJob))
+	mux.HandleFunc("/jobs/", s.metrics.instrument("/jobs/{id}", s.handleJob))
+	mux.HandleFunc("/metrics", s.metrics.handleMetrics)
+	mux.HandleFunc("/openapi.json", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(openAPISpec)
+	})
+	if *serveUI {
+		ui, _ := fs.Sub(uiFS, "ui")
+		mux.Handle("/", http.FileServer(http.FS(ui)))
+		log.Printf("Serving the playground at http://%s/", *addr)
+	}
+	log.Printf("Listening on %s", *addr)
+	log.Fatal(http.ListenAndServe(*addr, mux))
+}
+
+type puzzleResponse struct {
+	Code           string `json:"code"`
+	Width          int    `json:"width"`
+	Height         int    `json:"height"`
+	Board          string `json:"board"`
+	Goal           string `json:"goal"`
+	Distance       int    `json:"distance"`
+	Configurations int    `json:"configurations"`
+}
+
+type hintResponse struct {
+	Distance int                  `json:"distance"`
+	Move     string               `json:"move,omitempty"`
+	Board    string               `json:"board"`
+	Moves    []rankedMoveResponse `json:"moves,omitempty"` // with top=K, the K best moves
+}
+
+type rankedMoveResponse struct {
+	Move     string `json:"move"`
+	Distance int    `json:"distance"`
+	Quality  string `json:"quality"`
+	Board    string `json:"board"`
+}
+
+// Returns the first top of the ranked moves from a board at distance d.
+func rankedMovesResponse(b *Board, d int, rms []RankedMove, top int) []rankedMoveResponse {
+	resp := []rankedMoveResponse{}
+	for _, r := range rms[:min(top, len(rms))] {
+		resp = append(resp, rankedMoveResponse{r.Move.code(), r.Distance, r.Quality(d), b.move(r.Move).String()})
+	}
+	return resp
+}
+
+type solveResponse struct {
+	Length int      `json:"length"`
+	Moves  []string `json:"moves"`
+	Boards []string `json:"boards,omitempty"` // the position and the board after each move
+}
+
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+func (s *server) handlePuzzle(w http.ResponseWriter, r *http.Request) {
+	b := s.table.start
+	code, _ := b.Encode()
+	d, _ := s.table.Distance(b)
+	writeJSON(w, http.StatusOK, puzzleResponse{
+		code, b.w, b.h, b.String(), fmt.Sprint(b.goal), d, s.table.Size()})
+}
+
+func (s *server) handleHint(w http.ResponseWriter, r *http.Request) {
+	b, err := s.position(r)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{err.Error()})
+		return
+	}
+	top := 0
+	if v := r.FormValue("top"); v != "" {
+		if top, err = strconv.Atoi(v); err != nil || top < 1 {
+			writeJSON(w, http.StatusBadRequest, errorResponse{fmt.Sprintf("top %q isn't a positive number", v)})
+			return
+		}
+	}
+	if !s.table.start.samePuzzle(b) {
+		mvs, err := s.search(r.Context(), b, *jobTimeout)
+		switch {
+		case err != nil:
+			writeSearchError(w, err)
+			return
+		case len(mvs) == 0:
+			writeJSON(w, http.StatusOK, hintResponse{0, "", b.String(), nil})
+			return
+		}
+		resp := hintResponse{len(mvs), mvs[0].code(), b.move(mvs[0]).String(), nil}
+		if top > 0 {
+			rms, err := s.rankBySearch(r.Context(), b)
+			if err != nil {
+				writeSearchError(w, err)
+				return
+			}
+			resp.Moves = rankedMovesResponse(b, resp.Distance, rms, top)
+		}
+		s.metrics.count(&s.metrics.hints)
+		writeJSON(w, http.StatusOK, resp)
+		return
+	}
+	d, err := s.table.Distance(b)
+	s.metrics.countLookup(err == nil)
+	if err == nil && d == 0 {
+		writeJSON(w, http.StatusOK, hintResponse{0, "", b.String(), nil})
+		return
+	}
+	m, d, err := s.table.Hint(b)
+	if err != nil {
+		writeJSON(w, http.StatusUnprocessableEntity, errorResponse{err.Error()})
+		return
+	}
+	resp := hintResponse{d, m.code(), b.move(m).String(), nil}
+	if top > 0 {
+		rms, _ := s.table.RankMoves(b)
+		resp.Moves = rankedMovesResponse(b, d, rms, top)
+	}
+	s.metrics.count(&s.metrics.hints)
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (s *server) handleSolve(w http.ResponseWriter, r *http.Request) {
+	b, err := s.position(r)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{err.Error()})
+		return
+	}
+	var mvs []Move
+	if s.table.start.samePuzzle(b) {
+		_, err = s.table.Distance(b)
+		s.metrics.countLookup(err == nil)
+		if mvs, err = s.table.Solve(b); err != nil {
+			writeJSON(w, http.StatusUnprocessableEntity, errorResponse{err.Error()})
+			return
+		}
+	} else if mvs, err = s.search(r.Context(), b, *jobTimeout); err != nil {
+		writeSearchError(w, err)
+		return
+	}
+	s.metrics.count(&s.metrics.solves)
+	resp := solveResponse{len(mvs), []string{}, nil}
+	for _, m := range mvs {
+		resp.Moves = append(resp.Moves, m.code())
+	}
+	if r.FormValue("boards") == "true" {
+		resp.Boards = append(resp.Boards, b.String())
+		for _, m := range mvs {
+			b = b.move(m)
+			resp.Boards = append(resp.Boards, b.String())
+		}
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// Returns the position a request asks about.
+func (s *server) position(r *http.Request) (*Board, error) {
+	b := s.table.start
+	code := r.FormValue("board")
+	if id := r.FormValue("puzzle"); id != "" {
+		p := s.puzzles.get(id)
+		switch {
+		case code != "":
+			return nil, fmt.Errorf("give either board or puzzle, not both")
+		case p == nil:
+			return nil, fmt.Errorf("no stored puzzle %q", id)
+		}
+		code = p.Code
+	}
+	if code != "" {
+		var err error
+		if b, err = Decode(code); err != nil {
+			return nil, err
+		}
+		if err := b.validate(); err != nil {
+			return nil, err
+		}
+	}
+	if mvs := r.FormValue("moves"); mvs != "" {
+		var err error
+		if b, err = b.replay(strings.Split(mvs, ",")); err != nil {
+			return nil, err
+		}
+	}
+	return b, nil
+}
+
+// Does the given board have the same size, pieces and rules as this one,
+// possibly in a different position.
+func (b *Board) samePuzzle(ob *Board) bool {
+	code := func(b *Board) string {
+		c := *b
+		c.ps = make(map[string]Piece)
+		for pid, p := range b.ps {
+			p.x, p.y = 0, 0
+			c.ps[pid] = p
+		}
+		s, _ := c.Encode()
+		return s
+	}
+	return code(b) == code(ob)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("Writing response: %v", err)
+	}
+}
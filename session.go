@@ -0,0 +1,145 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// Play sessions.
+//
+// A game records each board it shows, with the time it was reached, so the
+// session can be exported once play is over: as a solution file listing the
+// moves to the current board in compact notation, each with the time since
+// the session started that it was played, or as an asciinema cast replaying
+// every move, undo and redo at the pace it was made. Solution files can be
+// graded with "squareroot grade".
+
+type sessionFrame struct {
+	at time.Time
+	b  *Board
+}
+
+// Records that the game now shows the given board.
+func (g *game) record(b *Board) {
+	g.session = append(g.session, sessionFrame{time.Now(), b})
+}
+
+// Writes the session to a file, as a cast if the name ends in ".cast" and a
+// solution file otherwise.
+func (g *game) export(path string) error {
+	var text string
+	if strings.HasSuffix(path, ".cast") {
+		text = g.cast()
+	} else {
+		text = g.solutionFile()
+	}
+	return os.WriteFile(path, []byte(text), 0o644)
+}
+
+// Formats the moves to the current board as a solution file.
+func (g *game) solutionFile() string {
+	var sb strings.Builder
+	t0 := g.session[0].at
+	fmt.Fprintf(&sb, "; Squareroot play session %s\n", t0.Format(time.RFC3339))
+	if code, err := g.start.Encode(); err == nil {
+		fmt.Fprintf(&sb, "; Puzzle: %s\n", code)
+	}
+	status := "unsolved"
+	if g.solved() {
+		status = "solved"
+	}
+	fmt.Fprintf(&sb, "; %d moves, %s, %v\n", len(g.b.mvs), status,
+		g.session[len(g.session)-1].at.Sub(t0).Round(time.Second))
+	for i, m := range g.b.mvs {
+		if at, ok := g.playedAt(i); ok {
+			fmt.Fprintf(&sb, "%-4s ; %s\n", m.code(), formatElapsed(at.Sub(t0)))
+		} else {
+			fmt.Fprintln(&sb, m.code())
+		}
+	}
+	return sb.String()
+}
+
+// Returns when the i'th move to the current board was last played, if it was
+// played in this session.
+func (g *game) playedAt(i int) (time.Time, bool) {
+	for j := len(g.session) - 1; j >= 0; j-- {
+		mvs := g.session[j].b.mvs
+		if len(mvs) == i+1 && sameMoves(mvs, g.b.mvs[:i+1]) {
+			return g.session[j].at, true
+		}
+	}
+	return time.Time{}, false
+}
+
+func sameMoves(a, b []Move) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// Returns the fewest moves with which the puzzle was solved during the
+// session, if it was.
+func (g *game) bestSolved() (int, bool) {
+	best, solved := 0, false
+	for _, f := range g.session {
+		if f.b.goal.IsSatisfied(f.b) && (!solved || len(f.b.mvs) < best) {
+			best, solved = len(f.b.mvs), true
+		}
+	}
+	return best, solved
+}
+
+// Formats a duration as minutes and seconds, e.g. "2:05.3".
+func formatElapsed(d time.Duration) string {
+	return fmt.Sprintf("%d:%04.1f", int(d.Minutes()), d.Seconds()-60*float64(int(d.Minutes())))
+}
+
+// Formats the session as an asciinema cast.
+func (g *game) cast() string {
+	return castOf(g.session, func(i int, b *Board) string {
+		return fmt.Sprintf("Moves: %d", len(b.mvs))
+	})
+}
+
+// Formats frames as an asciinema v2 cast: a JSON header line followed by a
+// line per frame, each showing the frame's board and caption at the frame's
+// time since the first.
+func castOf(frames []sessionFrame, caption func(i int, b *Board) string) string {
+	texts := []string{}
+	width, height := 0, 0
+	for i, f := range frames {
+		lines := strings.Split(strings.TrimRight(f.b.String(), "\n"), "\n")
+		lines = append(lines, caption(i, f.b))
+		for _, l := range lines {
+			width = max(width, len(l))
+		}
+		height = max(height, len(lines))
+		texts = append(texts, "\x1b[2J\x1b[H"+strings.Join(lines, "\r\n")+"\r\n")
+	}
+	var sb strings.Builder
+	header, _ := json.Marshal(map[string]any{
+		"version":   2,
+		"width":     max(width, 20),
+		"height":    height + 1,
+		"timestamp": frames[0].at.Unix(),
+		"title":     "Squareroot",
+	})
+	sb.Write(header)
+	sb.WriteByte('\n')
+	for i, f := range frames {
+		event, _ := json.Marshal([]any{f.at.Sub(frames[0].at).Seconds(), "o", texts[i]})
+		sb.Write(event)
+		sb.WriteByte('\n')
+	}
+	return sb.String()
+}
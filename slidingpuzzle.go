@@ -17,7 +17,9 @@ package main
 // Find a sequence of moves gets piece b to the bottom middle.
 
 import (
+	"flag"
 	"fmt"
+	"os"
 	"sort"
 	"strings"
 )
@@ -29,79 +31,278 @@ import (
 // For the first board on the queue:
 //   Collect all legal moves
 //   For each move:
+//     If we've seen the configuration the move leads to before, skip it
 //     Apply the move to the current board -> nextBoard (move piece, record new move)
-//     If we've seen nextBoard before, skip it
 //     Mark nextBoard as seen
 //     If nextBoard is a winning configuration, print it, and we're done.
 //     Add nextBoard to the queue of boards to consider
-func main() {
-	bs := []*Board{makeStartingBoard()}
-	seenBoards := make(map[string]bool)
-	numSkipped := 0
+func solve(start *Board) (*Board, Stats) {
+	bs := []*Board{start}
+	seenBoards := map[string]bool{start.Config(): true}
+	numSkipped, numExpanded := 0, 0
+	depth := len(start.mvs)
 	for {
-		if len(bs) == 0 {
-			fmt.Print("Couldn't find solution\n")
-			return
+		if len(bs) == 0 || searchExpired() {
+			return nil, Stats{len(seenBoards), numSkipped, numExpanded}
 		}
 		b := bs[0]
+		if len(b.mvs) > depth {
+			events.depth(depth, len(seenBoards), len(bs), numSkipped)
+			depth = len(b.mvs)
+		}
+		events.snapshot(len(seenBoards), len(bs), numSkipped)
 		bs = bs[1:]
-		for _, m := range b.possibleMoves() {
-			nb := b.move(m)
-			nbConfig := nb.Config()
-			if seenBoards[nbConfig] {
+		numExpanded++
+		tracer.expand(b)
+		depthStats.expand(len(b.mvs))
+		for _, m := range b.orderedMoves() {
+			nbConfig := b.configAfter(m)
+			dup := seenBoards[nbConfig] || avoided[nbConfig]
+			depthStats.generate(len(b.mvs), dup)
+			if dup {
 				numSkipped++
 				continue
 			}
+			nb := b.move(m)
 			seenBoards[nbConfig] = true
-			if b.isWin() {
-				fmt.Printf("Found solution (%d moves, %d configurations, %d skipped):\n",
-					len(b.mvs), len(seenBoards), numSkipped)
-				printMoves(b.mvs)
-				return
+			if nb.goal.IsSatisfied(nb) {
+				return nb, Stats{len(seenBoards), numSkipped, numExpanded}
 			}
 			bs = append(bs, nb)
 		}
 	}
 }
 
-func printMoves(mvs []Move) {
-	b := makeStartingBoard()
-	fmt.Print(b.String())
+// Stats records how much work a search did.
+type Stats struct {
+	Configs  int // distinct configurations seen
+	Skipped  int // moves that led back to an already-seen configuration
+	Expanded int // configurations whose moves were explored
+}
+
+func main() {
+	flag.Usage = usage
+	flag.CommandLine.Init(os.Args[0], flag.ContinueOnError)
+	if err := loadConfig(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(exitInvalid)
+	}
+	exitOnFlagError(flag.CommandLine.Parse(os.Args[1:]))
+	if *cacheFormat != "json" && *cacheFormat != "binary" {
+		fmt.Fprintf(os.Stderr, "Unknown -cache-format %q\n", *cacheFormat)
+		os.Exit(exitInvalid)
+	}
+	if *blunders != "" && *blunders != "warn" && *blunders != "confirm" {
+		fmt.Fprintf(os.Stderr, "Unknown -blunders %q\n", *blunders)
+		os.Exit(exitInvalid)
+	}
+	runCommand(flag.Args())
+}
+
+// Runs "solve": finds a shortest solution and prints it in the selected
+// format.
+func runSolve(start *Board, _ []string) {
+	if !validFormat(*format) {
+		fmt.Fprintf(os.Stderr, "Unknown -format %q\n", *format)
+		os.Exit(exitInvalid)
+	}
+	if *layout != "stacked" && *layout != "side-by-side" {
+		fmt.Fprintf(os.Stderr, "Unknown -layout %q\n", *layout)
+		os.Exit(exitInvalid)
+	}
+	if *render != "all" && *render != "first-last" && *render != "none" {
+		fmt.Fprintf(os.Stderr, "Unknown -render %q\n", *render)
+		os.Exit(exitInvalid)
+	}
+	if !validOrdering(*ordering) {
+		fmt.Fprintf(os.Stderr, "Unknown -ordering %q\n", *ordering)
+		os.Exit(exitInvalid)
+	}
+	if err := openEvents(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(exitInvalid)
+	}
+	algorithm := "bfs"
+	if *parallel {
+		algorithm = "parallel"
+	} else if *pieceMoves {
+		algorithm = "piece-moves"
+	} else if *astar {
+		algorithm = "astar"
+	}
+	if err := loadAvoided(start); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(exitInvalid)
+	}
+	if *certify && *pieceMoves {
+		fmt.Fprintln(os.Stderr, "-certify proves move counts, not -piece-moves counts")
+		os.Exit(exitInvalid)
+	}
+	if *certify && len(avoided) > 0 {
+		fmt.Fprintln(os.Stderr, "-certify proves the puzzle's optimal length, which -avoid may not reach")
+		os.Exit(exitInvalid)
+	}
+	code, _ := start.Encode()
+	events.emit("start", map[string]any{"algorithm": algorithm, "puzzle": code})
+
+	if r := precheck(start); r != nil {
+		reportPrecheck(start, r)
+		if *certify {
+			writeCertificate(start, start)
+		}
+		if *sbsOut != "" {
+			writeSolutionFile(start, start, algorithm, Stats{})
+		}
+		reportSolution(start, start, Stats{})
+		return
+	}
+
+	startDeadline()
+	openMemStats()
+	if *parallel {
+		if *certify {
+			fmt.Fprintln(os.Stderr, "-certify proves move counts, not -parallel step counts")
+			os.Exit(exitInvalid)
+		}
+		if *sbsOut != "" {
+			fmt.Fprintln(os.Stderr, "-sbs-out saves move lists, not -parallel steps")
+			os.Exit(exitInvalid)
+		}
+		solveParallel(start)
+		return
+	}
+	openDepthStats()
+	if err := openTrace(start); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(exitInvalid)
+	}
+	end, stats := findSolution(start)
+	memStats.stop()
+	if err := tracer.close(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(exitError)
+	}
+	if end == nil {
+		writeDepthStats()
+		writeMemStats()
+		exitUnsolved(start, stats)
+	}
+	if *certify {
+		writeCertificate(start, end)
+	}
+	if *sbsOut != "" {
+		writeSolutionFile(start, end, algorithm, stats)
+	}
+	events.emit("solution", map[string]any{"length": len(end.mvs),
+		"configurations": stats.Configs, "skipped": stats.Skipped})
+	reportSolution(start, end, stats)
+	writeDepthStats()
+	writeMemStats()
+}
+
+// Finds a shortest solution, from the results cache if it's there and
+// otherwise by searching with the selected algorithm. It returns a nil board
+// if there's no solution; unless the search ran out of time, the stats then
+// count every configuration reachable from the start.
+func findSolution(start *Board) (*Board, Stats) {
+	if *pieceMoves {
+		// The cache holds solutions with the fewest moves.
+		return solvePieceMoves(start)
+	}
+	end, stats, ok := lookupSolution(start)
+	if !ok {
+		if *astar {
+			end, stats = solveAStar(start)
+		} else {
+			end, stats = breadthFirstSolver().Solve(start)
+		}
+		if end != nil || !searchExpired() {
+			storeSolution(start, end, stats)
+		}
+	}
+	return end, stats
+}
+
+var puzzleFile = flag.String("puzzle", "",
+	"Read the starting board from this puzzle file or board code instead of solving Square Root.")
+
+var goalFlag = flag.String("goal", "",
+	"Solve for this goal instead of the puzzle's own, e.g. \"b 0 3 or b 2 3\".")
+
+var torus = flag.Bool("torus", false,
+	"Pieces sliding off one edge of the board reappear on the opposite edge.")
+
+// Prints the solution that reached the end board from the start board,
+// in the selected output format.
+func reportSolution(start, end *Board, stats Stats) {
+	switch *format {
+	case "sbp":
+		printSBPSolution(start, end, stats, nil)
+		return
+	case "words":
+		printWordsSolution(start, end)
+		return
+	case "markdown":
+		printMarkdownSolution(start, end, stats)
+		return
+	case "cast":
+		printCastSolution(start, end)
+		return
+	case "json":
+		printJSONSolution(start, end, stats)
+		return
+	case "deltas":
+		printDeltasSolution(start, end)
+		return
+	case "tikz", "tikz-panels":
+		printTikZSolution(start, end, *format == "tikz-panels")
+		return
+	}
+	if *pieceMoves {
+		fmt.Printf("Found solution (%d piece moves, %d moves, %d configurations, %d skipped):\n",
+			len(superMoves(end.mvs)), len(end.mvs), stats.Configs, stats.Skipped)
+	} else {
+		fmt.Printf("Found solution (%d moves, %d configurations, %d skipped):\n",
+			len(end.mvs), stats.Configs, stats.Skipped)
+	}
+	fmt.Printf("Reached goal: %s\n", describeReached(end.goal, end))
+	printBoardCode(start)
+	fmt.Printf("Start state: %s\n", start.stateID())
+	if *layout == "side-by-side" {
+		printMovesSideBySide(start, end.mvs)
+		return
+	}
+	printMoves(start, end.mvs)
+}
+
+// Prints the board code of the given board, so the puzzle can be shared.
+func printBoardCode(b *Board) {
+	if code, err := b.Encode(); err == nil {
+		fmt.Printf("Board code: %s\n", code)
+	}
+}
+
+func printMoves(b *Board, mvs []Move) {
+	if renderBoardAfter(0, len(mvs)) {
+		fmt.Print(b.display())
+	}
 	for i, m := range mvs {
-		fmt.Printf("%d: %s\n", i+1, m.String())
 		b = b.move(m)
-		fmt.Print(b.String())
+		fmt.Printf("%d: %s  %s\n", i+1, m.String(), b.stateID())
+		if renderBoardAfter(i+1, len(mvs)) {
+			fmt.Print(b.display())
+		}
 	}
 }
 
-// Returns the starting board configuration.
+// Returns the starting board configuration: the Square Root puzzle, from
+// the built-in puzzles.
 func makeStartingBoard() *Board {
-	//    0123
-	//    ____
-	// 0 |abbc|
-	// 1 |abbc|
-	// 2 |deef|
-	// 3 |dghf|
-	// 4 |i  j|
-	//    ~~~~
-	ps := []Piece{
-		Piece{"a", 1, 2, 0, 0},
-		Piece{"b", 2, 2, 1, 0},
-		Piece{"c", 1, 2, 3, 0},
-		Piece{"d", 1, 2, 0, 2},
-		Piece{"e", 2, 1, 1, 2},
-		Piece{"f", 1, 2, 3, 2},
-		Piece{"g", 1, 1, 1, 3},
-		Piece{"h", 1, 1, 2, 3},
-		Piece{"i", 1, 1, 0, 4},
-		Piece{"j", 1, 1, 3, 4},
-	}
-	pm := make(map[string]Piece)
-	for _, p := range ps {
-		pm[p.id] = p
-	}
-
-	return &Board{4, 5, pm, []Move{}}
+	b, err := readBoardFile(builtinPrefix + "squareroot.txt")
+	if err != nil {
+		panic(err)
+	}
+	return b
 }
 
 // Records the configuration of a board and how it got there (set of moves).
@@ -114,27 +315,90 @@ type Board struct {
 
 	// The moves used to get the pieces where they are.
 	mvs []Move
+
+	// Whether the board is toroidal: a piece sliding off one edge
+	// reappears on the opposite edge.
+	wrap bool
+
+	// One-way cells, with the directions in which a piece may enter them.
+	oneway map[Space]DirSet
+
+	// Linked pieces, mapping each linked piece id to the sorted ids of
+	// all pieces in its group.
+	links map[string][]string
+
+	// The condition under which the board is solved.
+	goal Goal
+
+	// Fixed wall cells that no piece can enter.
+	walls map[Space]bool
+
+	// Pieces held in place, which can't move or be moved.
+	frozen map[string]bool
+
+	// Whether pieces slide only along their length, like the cars of Rush
+	// Hour (see rails.go).
+	rails bool
+
+	// A condition every position must satisfy, or nil (see expr.go).
+	constraint Goal
+
+	// Extra rules deciding which moves are allowed (see filter.go).
+	filters []MoveFilter
 }
 
 // Is the given space unoccupied by a piece on this board.
 func (b *Board) isOpen(s Space) bool {
-	if s.x < 0 || s.y < 0 || s.x >= b.w || s.y >= b.h {
+	if b.wrap {
+		s = b.wrapSpace(s)
+	} else if s.x < 0 || s.y < 0 || s.x >= b.w || s.y >= b.h {
+		return false
+	}
+	if b.walls[s] {
 		return false
 	}
 	for _, p := range b.ps {
-		if p.covers(s) {
+		if b.covers(p, s) {
 			return false
 		}
 	}
 	return true
 }
 
+// Does the given piece cover the given (on-board) space?
+// On a toroidal board a piece may straddle an edge, so its extent is
+// measured modulo the board size.
+func (b *Board) covers(p Piece, s Space) bool {
+	if !b.wrap {
+		return p.covers(s)
+	}
+	dx := mod(s.x-p.x, b.w)
+	dy := mod(s.y-p.y, b.h)
+	return dx < p.w && dy < p.h
+}
+
+// wrapSpace returns the on-board space equivalent to s on a toroidal board.
+func (b *Board) wrapSpace(s Space) Space {
+	return Space{mod(s.x, b.w), mod(s.y, b.h)}
+}
+
+// mod returns a modulo n in the range [0, n).
+func mod(a, n int) int {
+	return ((a % n) + n) % n
+}
+
 // Returns the set of legal moves of pieces given this board configuration.
 func (b *Board) possibleMoves() []Move {
-	mvs := []Move{}
-	for _, p := range b.ps {
-		pmvs := p.possibleMoves(b)
-		mvs = append(mvs, pmvs...)
+	mvs := b.blankMoves()
+	if b.constraint != nil || len(b.filters) > 0 {
+		// Drop the moves that would break the constraint or a move rule.
+		kept := []Move{}
+		for _, m := range mvs {
+			if b.filtersAllow(m) && (b.constraint == nil || b.constraint.IsSatisfied(b.move(m))) {
+				kept = append(kept, m)
+			}
+		}
+		mvs = kept
 	}
 	return mvs
 }
@@ -144,8 +408,13 @@ func (b *Board) move(m Move) *Board {
 	// The new pieces are the old pieces with one piece moved.
 	nps := make(map[string]Piece)
 	for pid, p := range b.ps {
-		if pid == m.pid {
-			nps[pid] = p.move(m.dir)
+		if b.movesPiece(m, pid) {
+			np := p.move(m.dir)
+			if b.wrap {
+				s := b.wrapSpace(Space{np.x, np.y})
+				np.x, np.y = s.x, s.y
+			}
+			nps[pid] = np
 		} else {
 			nps[pid] = p
 		}
@@ -157,24 +426,57 @@ func (b *Board) move(m Move) *Board {
 	}
 	nmvs = append(nmvs, m)
 
-	return &Board{b.w, b.h, nps, nmvs}
-}
-
-// Is the current board position a winning configuration.
-func (b *Board) isWin() bool {
-	pb := b.ps["b"]
-	return pb.x == 1 && pb.y == 3
+	nb := *b
+	nb.ps = nps
+	nb.mvs = nmvs
+	return &nb
 }
 
 // Config returns the configuration of the pieces on the given board.
 // We use this to record which configurations we've already considered
 // so that we don't consider them again.
 func (b *Board) Config() string {
-	pcs := []string{}
+	return b.configAfter(Move{})
+}
+
+// Returns the configuration the board would have after the given move,
+// without making the board, so that searches can skip a duplicate before
+// paying to build it. A move of no piece leaves the board as it is.
+func (b *Board) configAfter(m Move) string {
+	if m.pid != "" && (len(b.filters) > 0 || b.moveCountState() != "") {
+		// Move rules and move counts keep state from the moves made.
+		return b.move(m).Config()
+	}
+	pcs := make([]string, 0, len(b.ps))
 	for _, p := range b.ps {
+		if b.movesPiece(m, p.id) {
+			p = p.move(m.dir)
+			if b.wrap {
+				s := b.wrapSpace(Space{p.x, p.y})
+				p.x, p.y = s.x, s.y
+			}
+		}
+		if g := b.links[p.id]; g != nil {
+			// Linked pieces aren't interchangeable with unlinked ones.
+			pcs = append(pcs, p.Config()+"@"+g[0])
+			continue
+		}
+		if b.isGoalPiece(p.id) {
+			// Goal pieces aren't interchangeable with others of the same shape.
+			pcs = append(pcs, p.Config()+"@"+p.id)
+			continue
+		}
 		pcs = append(pcs, p.Config())
 	}
 	sort.Strings(pcs)
+	if s := b.filterState(); s != "" {
+		// Move rules can tell apart positions reached by different moves.
+		pcs = append(pcs, "|"+s)
+	}
+	if s := b.moveCountState(); s != "" {
+		// So can goals and constraints counting moves.
+		pcs = append(pcs, "#"+s)
+	}
 	return strings.Join(pcs, ";")
 }
 
@@ -187,10 +489,7 @@ func (b *Board) Config() string {
 // |i  j|
 //  ~~~~
 func (b *Board) String() string {
-	grid := makeGrid(b.w, b.h)
-	for _, p := range b.ps {
-		p.drawInto(grid)
-	}
+	grid := b.grid()
 
 	var sb strings.Builder
 	sb.WriteString(" ")
@@ -210,6 +509,22 @@ func (b *Board) String() string {
 	return sb.String()
 }
 
+// Draws the board's cells, walls and pieces into a grid.
+func (b *Board) grid() *Grid {
+	grid := makeGrid(b.w, b.h)
+	grid.wrap = b.wrap
+	for s, ds := range b.oneway {
+		grid.set(s.x, s.y, ds.symbol())
+	}
+	for s := range b.walls {
+		grid.set(s.x, s.y, '#')
+	}
+	for _, p := range b.ps {
+		p.drawInto(grid)
+	}
+	return grid
+}
+
 // Piece records the id and configuration of a piece.
 type Piece struct {
 	id   string
@@ -233,25 +548,22 @@ func (p Piece) drawInto(grid *Grid) {
 	}
 }
 
-// Is this piece free to move in the given direction on this board.
-func (p Piece) canMove(b *Board, d Direction) bool {
-	for _, ts := range p.targetSpaces(d) {
-		if !b.isOpen(ts) {
-			return false
-		}
+// Is this piece kept from making moves of its own on this board.
+func (p Piece) held(b *Board) bool {
+	if g := b.links[p.id]; g != nil && g[0] != p.id {
+		// Linked pieces move as a group, led by its first piece.
+		return true
 	}
-	return true
-}
-
-// What are all of the possible legal moves this piece can move on this board.
-func (p Piece) possibleMoves(b *Board) []Move {
-	mvs := []Move{}
-	for _, d := range Directions {
-		if p.canMove(b, d) {
-			mvs = append(mvs, Move{p.id, d})
+	if b.frozen[p.id] {
+		return true
+	}
+	for _, gpid := range b.links[p.id] {
+		if b.frozen[gpid] {
+			// A frozen piece holds its whole group in place.
+			return true
 		}
 	}
-	return mvs
+	return false
 }
 
 // Returns this piece moved in the given direction.
@@ -322,6 +634,12 @@ func (m Move) String() string {
 	return fmt.Sprintf("%s -> %s", m.pid, m.dir)
 }
 
+// The compact notation for the move: the piece id followed by the direction
+// letter, e.g. "bD".
+func (m Move) code() string {
+	return m.pid + string(m.dir.letter())
+}
+
 type Direction int
 
 const (
@@ -337,10 +655,26 @@ func (d Direction) String() string {
 	return []string{"Up", "Down", "Left", "Right"}[d]
 }
 
+// The one-letter abbreviation of the direction: U, D, L or R.
+func (d Direction) letter() byte {
+	return "UDLR"[d]
+}
+
+// The change in x and y of a move in the direction.
+func (d Direction) delta() (int, int) {
+	return [...]int{0, 0, -1, 1}[d], [...]int{-1, 1, 0, 0}[d]
+}
+
+// An arrow pointing in the direction.
+func (d Direction) arrow() string {
+	return [...]string{"↑", "↓", "←", "→"}[d]
+}
+
 // Grid holds a visual representation of a Board.
 type Grid struct {
 	w, h int
 	c    [][]byte
+	wrap bool // Wrap out-of-range coordinates rather than dropping them.
 }
 
 func makeGrid(w, h int) *Grid {
@@ -352,10 +686,13 @@ func makeGrid(w, h int) *Grid {
 		}
 		c = append(c, row)
 	}
-	return &Grid{w, h, c}
+	return &Grid{w, h, c, false}
 }
 
 func (g *Grid) set(x, y int, c byte) {
+	if g.wrap {
+		x, y = mod(x, g.w), mod(y, g.h)
+	}
 	if x < 0 || y < 0 || x >= g.w || y >= g.h {
 		return
 	}
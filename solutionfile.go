@@ -0,0 +1,136 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"runtime/debug"
+	"time"
+)
+
+// Solution files.
+//
+// With -sbs-out, "solve" also writes the solution to a .sbs solution file,
+// a JSON document that carries everything needed to check it later without
+// the terminal output it came from:
+//
+//	{
+//	  "format": "squareroot-solution",
+//	  "version": 1,
+//	  "puzzle": "AgQF...",
+//	  "hash": "daf0e08c...",
+//	  "solver": "squareroot v1.4.0",
+//	  "algorithm": "astar",
+//	  "length": 116,
+//	  "moves": ["iR", "dD", ...],
+//	  "configurations": 24037,
+//	  "skipped": 53799,
+//	  "expanded": 11029,
+//	  "solved": "2026-10-17T06:52:00Z",
+//	  "checksum": "9f2c..."
+//	}
+//
+// The hash is the puzzle's results cache key, the SHA-256 of its board code,
+// and the checksum is the SHA-256 of the rest of the file, as in -certify
+// certificates, which catches files that have been edited or damaged.
+// "verify", "grade" and "play" read solution files, checking both, and take
+// the puzzle from them, so a solution file is all they need.
+
+var sbsOut = flag.String("sbs-out", "",
+	"Also write the solution, with its puzzle, solver and stats, to this .sbs solution file.")
+
+// The format name and version of solution files.
+const (
+	sbsFormat  = "squareroot-solution"
+	sbsVersion = 1
+)
+
+type solutionFile struct {
+	Format    string    `json:"format"`
+	Version   int       `json:"version"`
+	Puzzle    string    `json:"puzzle"`
+	Hash      string    `json:"hash"`
+	Solver    string    `json:"solver"`
+	Algorithm string    `json:"algorithm"`
+	Length    int       `json:"length"`
+	Moves     []string  `json:"moves"`
+	Configs   int       `json:"configurations"`
+	Skipped   int       `json:"skipped"`
+	Expanded  int       `json:"expanded"`
+	Solved    time.Time `json:"solved"`
+	Checksum  string    `json:"checksum"`
+}
+
+// Returns the SHA-256 of the file's contents other than its checksum.
+func (f solutionFile) checksum() string {
+	f.Checksum = ""
+	data, _ := json.Marshal(f)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// Returns the name and version of this squareroot, from its build info.
+func solverVersion() string {
+	v := "(devel)"
+	if info, ok := debug.ReadBuildInfo(); ok && info.Main.Version != "" {
+		v = info.Main.Version
+	}
+	return "squareroot " + v
+}
+
+// Writes the solution found by the given algorithm to -sbs-out.
+func writeSolutionFile(start, end *Board, algorithm string, stats Stats) {
+	hash, code := puzzleHash(start)
+	if hash == "" {
+		fmt.Fprintln(os.Stderr, "can't write a solution file for a puzzle with move rules")
+		os.Exit(exitError)
+	}
+	sf := solutionFile{sbsFormat, sbsVersion, code, hash, solverVersion(), algorithm, len(end.mvs), []string{},
+		stats.Configs, stats.Skipped, stats.Expanded, time.Now().UTC().Truncate(time.Second), ""}
+	for _, m := range end.mvs {
+		sf.Moves = append(sf.Moves, m.code())
+	}
+	sf.Checksum = sf.checksum()
+	f, err := createOutput(*sbsOut)
+	if err == nil {
+		enc := json.NewEncoder(f)
+		enc.SetIndent("", "  ")
+		if err = enc.Encode(sf); err == nil {
+			err = f.Close()
+		}
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(exitError)
+	}
+}
+
+// Reads a solution file, returning its puzzle and moves. It reports false
+// if the data isn't a solution file, and an error if it's one that doesn't
+// check out.
+func readSolutionFile(data []byte) (*Board, []string, bool, error) {
+	var sf solutionFile
+	if json.Unmarshal(data, &sf) != nil || sf.Format != sbsFormat {
+		return nil, nil, false, nil
+	}
+	if sf.Version < 1 || sf.Version > sbsVersion {
+		return nil, nil, true, fmt.Errorf("solution file version %d is newer than this squareroot reads", sf.Version)
+	}
+	if sf.Checksum != sf.checksum() {
+		return nil, nil, true, fmt.Errorf("checksum mismatch: the solution file has been changed")
+	}
+	start, err := decodeValid(sf.Puzzle)
+	if err != nil {
+		return nil, nil, true, err
+	}
+	if hash, _ := puzzleHash(start); hash != sf.Hash {
+		return nil, nil, true, fmt.Errorf("the puzzle doesn't match its hash")
+	}
+	if len(sf.Moves) != sf.Length {
+		return nil, nil, true, fmt.Errorf("solution file claims %d moves but lists %d", sf.Length, len(sf.Moves))
+	}
+	return start, sf.Moves, true, nil
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// State ids.
+//
+// Every position has a short id, "#" and the first eight hex digits of the
+// hash of its configuration, the same hash search traces record (see
+// trace.go). Positions the same but for which of two identical pieces is
+// where share an id, as they do a configuration, and the id doesn't depend
+// on the moves that led to a position or the output it's printed in. The
+// solution formats print the id of the start and of the position after each
+// move, "tutorial" of each position it shows, "lines" of its key positions
+// and "trace explore" of each expansion and child, so a position can be
+// followed from one to another. In "trace explore", "#<id>" goes to the
+// expansion of a position.
+
+// Returns the board's state id.
+func (b *Board) stateID() string {
+	return hashStateID(configHash(b))
+}
+
+// Returns the state id of a configuration hash.
+func hashStateID(h uint64) string {
+	return fmt.Sprintf("#%08x", h>>32)
+}
+
+// Returns the state ids of the start and of the board after each of the
+// moves.
+func stateIDs(start *Board, mvs []Move) []string {
+	ids := []string{start.stateID()}
+	b := start
+	for _, m := range mvs {
+		b = b.move(m)
+		ids = append(ids, b.stateID())
+	}
+	return ids
+}
+
+// Reports whether s looks like a state id.
+func isStateID(s string) bool {
+	h, ok := strings.CutPrefix(s, "#")
+	if !ok || len(h) != 8 {
+		return false
+	}
+	for _, c := range h {
+		if !strings.ContainsRune("0123456789abcdef", c) {
+			return false
+		}
+	}
+	return true
+}
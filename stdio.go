@@ -0,0 +1,39 @@
+package main
+
+import (
+	"io"
+	"os"
+)
+
+// Standard input and output.
+//
+// Wherever a command reads or writes a named file, "-" names standard input
+// or output instead, so commands compose in pipelines:
+//
+//	squareroot gen | squareroot solve -puzzle - -format json | jq .length
+//
+// Results go to standard output and progress messages and other diagnostics
+// to standard error, so they don't end up in the pipe.
+
+// Reads the named file, or standard input if the name is "-".
+func readInput(name string) ([]byte, error) {
+	if name == "-" {
+		return io.ReadAll(os.Stdin)
+	}
+	return os.ReadFile(name)
+}
+
+// Creates the named file, or returns standard output if the name is "-".
+// Closing standard output this way leaves it open.
+func createOutput(name string) (io.WriteCloser, error) {
+	if name == "-" {
+		return nopCloser{os.Stdout}, nil
+	}
+	return os.Create(name)
+}
+
+type nopCloser struct {
+	io.Writer
+}
+
+func (nopCloser) Close() error { return nil }
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"container/heap"
+	"flag"
+)
+
+// Fewest piece moves.
+//
+// Published Klotski solutions count piece moves: a run of moves of one
+// piece, however far and however many turns it takes, is one move (see
+// superMoves). The usual search counts single-space moves, and its 116-move
+// Square Root solution takes 100 piece moves where 81 will do. With
+// -piece-moves, solve finds the solution with the fewest piece moves and,
+// among those, the fewest single-space moves, and reports both counts.
+//
+// Whether a move starts a new piece move depends on the piece moved last,
+// so the search tells apart positions by that piece too, and expands them
+// in order of piece moves and then single-space moves, Dijkstra-style.
+
+var pieceMoves = flag.Bool("piece-moves", false,
+	"Find the solution with the fewest piece moves (runs of moves of one piece, "+
+		"as Klotski solutions are counted), then the fewest moves.")
+
+// Searches for the solution with the fewest piece moves and then the fewest
+// moves. It returns nil if there's no solution.
+func solvePieceMoves(start *Board) (*Board, Stats) {
+	// Costs are piece moves in the high bits and moves in the low ones, so
+	// the queue orders by piece moves first.
+	cost := func(pieceMoves, moves int) int { return pieceMoves<<32 | moves }
+	key := func(b *Board) string {
+		if len(b.mvs) == 0 {
+			return b.Config()
+		}
+		return b.Config() + "@" + b.mvs[len(b.mvs)-1].pid
+	}
+	q := &boardQueue{}
+	heap.Push(q, astarNode{start, 0})
+	best := map[string]int{key(start): 0}
+	seen := map[string]bool{start.Config(): true}
+	numSkipped, numExpanded := 0, 0
+	for q.Len() > 0 && !searchExpired() {
+		n := heap.Pop(q).(astarNode)
+		b := n.b
+		events.snapshot(len(seen), q.Len(), numSkipped)
+		if n.f > best[key(b)] {
+			// Superseded by a cheaper path to the same state.
+			continue
+		}
+		if b.goal.IsSatisfied(b) {
+			return b, Stats{len(seen), numSkipped, numExpanded}
+		}
+		numExpanded++
+		tracer.expand(b)
+		depthStats.expand(len(b.mvs))
+		for _, m := range b.orderedMoves() {
+			f := n.f + 1
+			if len(b.mvs) == 0 || b.mvs[len(b.mvs)-1].pid != m.pid {
+				f += cost(1, 0)
+			}
+			nbConfig := b.configAfter(m)
+			nbKey := nbConfig + "@" + m.pid
+			depthStats.generate(len(b.mvs), seen[nbConfig] || avoided[nbConfig])
+			if c, ok := best[nbKey]; ok && c <= f || avoided[nbConfig] {
+				numSkipped++
+				continue
+			}
+			nb := b.move(m)
+			best[nbKey] = f
+			seen[nbConfig] = true
+			heap.Push(q, astarNode{nb, f})
+		}
+	}
+	return nil, Stats{len(seen), numSkipped, numExpanded}
+}
@@ -0,0 +1,236 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"image/color"
+	"os"
+	"strings"
+)
+
+// Color themes.
+//
+// The GUIs, and the terminal with -color, draw boards in the colors of the
+// theme chosen with -theme: one of the built-in themes or a theme file. A
+// theme file has lines of the form
+//
+//	theme colorblind     start from a built-in theme
+//	goal #d55e00         color of goal pieces
+//	piece #56b4e9        color of other pieces
+//	open #f0f0f0         color of open spaces
+//	wall #000000         color of walls
+//	frame #808080        color of the board's frame
+//	a #cc79a7            color of piece a
+//
+// with "//" comments.
+
+var themeFlag = flag.String("theme", "classic",
+	"Board colors: classic, colorblind, mono, or a theme file.")
+
+var colorFlag = flag.Bool("color", false,
+	"Draw boards in the terminal in the -theme colors.")
+
+type theme struct {
+	frame, open, wall color.RGBA
+	goal, piece       color.RGBA
+
+	// If set, pieces other than goal pieces take these colors in turn.
+	palette []color.RGBA
+
+	// Colors of particular pieces, overriding the others.
+	pieces map[string]color.RGBA
+}
+
+var themes = map[string]theme{
+	"classic": {
+		frame: rgb(0x806040),
+		open:  rgb(0xe0d8c8),
+		wall:  rgb(0x504030),
+		goal:  rgb(0xc04040),
+		piece: rgb(0x4070b0),
+	},
+	// The Okabe-Ito palette, which stays distinguishable with the common
+	// kinds of color blindness.
+	"colorblind": {
+		frame:   rgb(0x404040),
+		open:    rgb(0xf0f0f0),
+		wall:    rgb(0x000000),
+		goal:    rgb(0xd55e00),
+		piece:   rgb(0x0072b2),
+		palette: []color.RGBA{rgb(0x0072b2), rgb(0x56b4e9), rgb(0x009e73), rgb(0xcc79a7), rgb(0xf0e442)},
+	},
+	"mono": {
+		frame: rgb(0x000000),
+		open:  rgb(0xffffff),
+		wall:  rgb(0x000000),
+		goal:  rgb(0x404040),
+		piece: rgb(0xa0a0a0),
+	},
+}
+
+// The theme chosen with -theme, set by loadTheme.
+var activeTheme = themes["classic"]
+
+func rgb(c uint32) color.RGBA {
+	return color.RGBA{uint8(c >> 16), uint8(c >> 8), uint8(c), 0xff}
+}
+
+// Sets the active theme from the name of a built-in theme or a theme file.
+func loadTheme(arg string) error {
+	if t, ok := themes[arg]; ok {
+		activeTheme = t
+		return nil
+	}
+	f, err := os.Open(arg)
+	if err != nil {
+		return fmt.Errorf("%q is neither a theme nor a theme file", arg)
+	}
+	defer f.Close()
+	t := themes["classic"]
+	t.pieces = make(map[string]color.RGBA)
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "//") {
+			continue
+		}
+		args := strings.Fields(line)
+		if len(args) != 2 {
+			return fmt.Errorf("%s:%d: want \"<name> <color>\"", arg, n)
+		}
+		if args[0] == "theme" {
+			base, ok := themes[args[1]]
+			if !ok {
+				return fmt.Errorf("%s:%d: unknown theme %q", arg, n, args[1])
+			}
+			base.pieces = t.pieces
+			t = base
+			continue
+		}
+		c, err := parseColor(args[1])
+		if err != nil {
+			return fmt.Errorf("%s:%d: %v", arg, n, err)
+		}
+		switch args[0] {
+		case "frame":
+			t.frame = c
+		case "open":
+			t.open = c
+		case "wall":
+			t.wall = c
+		case "goal":
+			t.goal = c
+		case "piece":
+			t.piece, t.palette = c, nil
+		default:
+			if len(args[0]) != 1 || !isPieceID(args[0][0]) {
+				return fmt.Errorf("%s:%d: unknown name %q", arg, n, args[0])
+			}
+			t.pieces[args[0]] = c
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	activeTheme = t
+	return nil
+}
+
+// Parses a color written as #rrggbb.
+func parseColor(s string) (color.RGBA, error) {
+	var c uint32
+	if len(s) != 7 || s[0] != '#' {
+		return color.RGBA{}, fmt.Errorf("invalid color %q: want #rrggbb", s)
+	}
+	if _, err := fmt.Sscanf(s[1:], "%06x", &c); err != nil {
+		return color.RGBA{}, fmt.Errorf("invalid color %q: want #rrggbb", s)
+	}
+	return rgb(c), nil
+}
+
+// Returns the color of a piece on a board.
+func (t theme) pieceColor(b *Board, pid string) color.RGBA {
+	if c, ok := t.pieces[pid]; ok {
+		return c
+	}
+	if b.isGoalPiece(pid) {
+		return t.goal
+	}
+	if len(t.palette) == 0 {
+		return t.piece
+	}
+	i := 0
+	for _, id := range b.pieceIDs() {
+		if id == pid {
+			break
+		}
+		if !b.isGoalPiece(id) {
+			i++
+		}
+	}
+	return t.palette[i%len(t.palette)]
+}
+
+// Returns a color that shows up on the given background.
+func labelColor(bg color.RGBA) color.RGBA {
+	if 299*int(bg.R)+587*int(bg.G)+114*int(bg.B) > 150000 {
+		return rgb(0x000000)
+	}
+	return rgb(0xffffff)
+}
+
+// Draws the board like String, with ANSI escapes coloring each space in the
+// active theme.
+func (b *Board) ansiString() string {
+	t := activeTheme
+	grid := b.grid()
+	cells, width := b.cellTexts()
+	colors := make(map[byte]color.RGBA)
+	for pid := range b.ps {
+		colors[pid[0]] = t.pieceColor(b, pid)
+	}
+	colors['#'] = t.wall
+
+	var sb strings.Builder
+	frame := func(s string) {
+		sb.WriteString(ansiColors(labelColor(t.open), t.frame) + s + ansiReset)
+	}
+	frame(" " + strings.Repeat(" ", b.w*width) + " ")
+	sb.WriteString("\n")
+	for y := 0; y < b.h; y++ {
+		frame(" ")
+		for _, c := range []byte(grid.row(y)) {
+			bg, ok := colors[c]
+			if !ok {
+				bg = t.open
+			}
+			sb.WriteString(ansiColors(labelColor(bg), bg) + cells[c])
+		}
+		sb.WriteString(ansiReset)
+		frame(" ")
+		sb.WriteString("\n")
+	}
+	frame(" " + strings.Repeat(" ", b.w*width) + " ")
+	sb.WriteString("\n")
+	return sb.String()
+}
+
+const ansiReset = "\x1b[0m"
+
+// Returns the ANSI escape setting 24-bit foreground and background colors.
+func ansiColors(fg, bg color.RGBA) string {
+	return fmt.Sprintf("\x1b[38;2;%d;%d;%dm\x1b[48;2;%d;%d;%dm", fg.R, fg.G, fg.B, bg.R, bg.G, bg.B)
+}
+
+// Draws the board for the terminal: in color with -color, with -glyphs
+// glyphs, and as plain text otherwise.
+func (b *Board) display() string {
+	if *colorFlag {
+		return b.ansiString()
+	}
+	if activeGlyphs != nil {
+		return b.glyphString()
+	}
+	return b.String()
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"fmt"
+	"image/color"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// TikZ solution output.
+//
+// The tikz format writes a LaTeX TikZ picture of the starting board and of
+// the board after each super-move (a run of moves of one piece), each
+// preceded by a comment naming the step, for articles and puzzle books. The
+// tikz-panels format draws the same steps as panels of a single picture, six
+// to a row, each labeled with its step. Pictures use the -theme colors and
+// need \usepackage{tikz}.
+
+// Steps per row of the tikz-panels picture.
+const tikzPanelsPerRow = 6
+
+// Prints a solution in the tikz or tikz-panels format.
+func printTikZSolution(start, end *Board, panels bool) {
+	type step struct {
+		label string
+		b     *Board
+	}
+	steps := []step{{"Start", start}}
+	b := start
+	for i, sm := range superMoves(end.mvs) {
+		for _, m := range sm {
+			b = b.move(m)
+		}
+		steps = append(steps, step{fmt.Sprintf("%d. %s", i+1, superMoveCode(sm)), b})
+	}
+
+	fmt.Printf("%% Solution in %d moves (%d piece moves): %s\n", len(end.mvs), len(steps)-1,
+		describeReached(end.goal, end))
+	fmt.Print(start.tikzColors())
+	if !panels {
+		for _, s := range steps {
+			fmt.Printf("%% %s %s\n", s.label, s.b.stateID())
+			fmt.Println(`\begin{tikzpicture}[scale=0.5]`)
+			fmt.Print(s.b.tikz(0, 0))
+			fmt.Println(`\end{tikzpicture}`)
+		}
+		return
+	}
+	fmt.Println(`\begin{tikzpicture}[scale=0.3]`)
+	for i, s := range steps {
+		ox := float64((i % tikzPanelsPerRow) * (start.w + 2))
+		oy := -float64((i / tikzPanelsPerRow) * (start.h + 3))
+		fmt.Printf("%% %s %s\n", s.label, s.b.stateID())
+		fmt.Print(s.b.tikz(ox, oy))
+		fmt.Printf("\\node[below] at (%s,%s) {\\scriptsize %s};\n",
+			tikzNum(ox+float64(start.w)/2), tikzNum(oy-float64(start.h)-0.2), s.label)
+	}
+	fmt.Println(`\end{tikzpicture}`)
+}
+
+// Defines the colors pictures of the board use, from the active theme: the
+// frame, open spaces, walls, and each piece (as srpiece<id>, with the color
+// of its label as srlabel<id>).
+func (b *Board) tikzColors() string {
+	var sb strings.Builder
+	define := func(name string, c color.RGBA) {
+		fmt.Fprintf(&sb, "\\definecolor{%s}{RGB}{%d,%d,%d}\n", name, c.R, c.G, c.B)
+	}
+	define("srframe", activeTheme.frame)
+	define("sropen", activeTheme.open)
+	define("srwall", activeTheme.wall)
+	for _, pid := range b.pieceIDs() {
+		c := activeTheme.pieceColor(b, pid)
+		define("srpiece"+pid, c)
+		define("srlabel"+pid, labelColor(c))
+	}
+	return sb.String()
+}
+
+// Returns TikZ commands drawing the board with its top left corner at ox, oy.
+// Rows go down the page, so row y is drawn at oy-y.
+func (b *Board) tikz(ox, oy float64) string {
+	var sb strings.Builder
+	rect := func(x, y, w, h float64, style string) {
+		fmt.Fprintf(&sb, "\\fill[%s] (%s,%s) rectangle (%s,%s);\n", style,
+			tikzNum(ox+x), tikzNum(oy-y), tikzNum(ox+x+w), tikzNum(oy-y-h))
+	}
+	rect(-0.15, -0.15, float64(b.w)+0.3, float64(b.h)+0.3, "srframe")
+	rect(0, 0, float64(b.w), float64(b.h), "sropen")
+	for _, s := range sortedSpaces(b.walls) {
+		rect(float64(s.x), float64(s.y), 1, 1, "srwall")
+	}
+	for _, pid := range b.pieceIDs() {
+		p := b.ps[pid]
+		fill := "srpiece" + pid + ", rounded corners=1pt"
+		if b.wrap {
+			// A piece may wrap around the edges, so draw each space.
+			for dy := 0; dy < p.h; dy++ {
+				for dx := 0; dx < p.w; dx++ {
+					s := b.wrapSpace(Space{p.x + dx, p.y + dy})
+					rect(float64(s.x)+0.05, float64(s.y)+0.05, 0.9, 0.9, fill)
+				}
+			}
+		} else {
+			rect(float64(p.x)+0.05, float64(p.y)+0.05, float64(p.w)-0.1, float64(p.h)-0.1, fill)
+		}
+		fmt.Fprintf(&sb, "\\node[srlabel%s] at (%s,%s) {\\small\\sffamily %s};\n", pid,
+			tikzNum(ox+float64(p.x)+float64(p.w)/2), tikzNum(oy-float64(p.y)-float64(p.h)/2), pid)
+	}
+	return sb.String()
+}
+
+// Formats a coordinate, to two decimal places at most.
+func tikzNum(f float64) string {
+	r := math.Round(f*100) / 100
+	if r == 0 {
+		r = 0 // not -0
+	}
+	return strconv.FormatFloat(r, 'f', -1, 64)
+}
+
+// Returns the spaces of a set in reading order.
+func sortedSpaces(set map[Space]bool) []Space {
+	ss := []Space{}
+	for s := range set {
+		ss = append(ss, s)
+	}
+	sortSpaces(ss)
+	return ss
+}
@@ -0,0 +1,201 @@
+//go:build touch
+
+package main
+
+// Touch UI, built with Fyne (go build -tags touch). The same build packaged
+// with "fyne package -tags touch -os android" (or ios) runs on phones, where
+// it starts straight into the UI since there is no command line.
+//
+// Drag a piece with a finger or the mouse to slide it; it follows along one
+// axis as far as it can legally slide and snaps to the nearest space when
+// released. Hint makes the next move of an optimal solution, Solve animates
+// the rest of one, and Restart returns to the starting board.
+
+import (
+	"image/color"
+	"runtime"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/app"
+	"fyne.io/fyne/v2/canvas"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/widget"
+)
+
+// Phones have no command line, so start the touch UI directly there.
+var touchByDefault = runtime.GOOS == "android" || runtime.GOOS == "ios"
+
+const touchAnimationDelay = 250 * time.Millisecond
+
+func runTouchUI(start *Board) {
+	a := app.New()
+	w := a.NewWindow("Square Root")
+
+	status := widget.NewLabel("Drag pieces to move them.")
+	bw := newBoardWidget(newGame(start))
+	bw.onMove = func() { status.SetText(bw.g.status()) }
+
+	animating := false
+	hint := widget.NewButton("Hint", func() {
+		if animating {
+			return
+		}
+		m, _, err := bw.g.hint()
+		if err != nil {
+			status.SetText(err.Error())
+			return
+		}
+		bw.g.move(m)
+		bw.Refresh()
+		bw.onMove()
+	})
+	solve := widget.NewButton("Solve", func() {
+		if animating {
+			return
+		}
+		mvs, err := bw.g.solution()
+		if err != nil {
+			status.SetText(err.Error())
+			return
+		}
+		animating = true
+		go func() {
+			for _, m := range mvs {
+				time.Sleep(touchAnimationDelay)
+				fyne.Do(func() {
+					bw.g.move(m)
+					bw.Refresh()
+					bw.onMove()
+				})
+			}
+			fyne.Do(func() { animating = false })
+		}()
+	})
+	restart := widget.NewButton("Restart", func() {
+		if animating {
+			return
+		}
+		bw.g.restart()
+		bw.Refresh()
+		bw.onMove()
+	})
+
+	controls := container.NewVBox(status, container.NewGridWithColumns(3, hint, solve, restart))
+	w.SetContent(container.NewBorder(nil, controls, nil, nil, bw))
+	w.Resize(fyne.NewSize(float32(start.w*80+40), float32(start.h*80+120)))
+	w.ShowAndRun()
+}
+
+// boardWidget draws a game's board, scaled to fit, and lets pieces be dragged.
+type boardWidget struct {
+	widget.BaseWidget
+	g *game
+
+	// Called after the user moves a piece.
+	onMove func()
+
+	// The piece being dragged, where the drag started, and its offset.
+	dragPID        string
+	dragX, dragY   float32
+	dragDX, dragDY float32
+}
+
+func newBoardWidget(g *game) *boardWidget {
+	bw := &boardWidget{g: g}
+	bw.ExtendBaseWidget(bw)
+	return bw
+}
+
+// The size of a board space and the position of the board's upper-left
+// corner when drawn centered in the widget.
+func (bw *boardWidget) geometry() (float32, fyne.Position) {
+	b := bw.g.b
+	size := bw.Size()
+	cell := min(size.Width/float32(b.w), size.Height/float32(b.h))
+	return cell, fyne.NewPos((size.Width-cell*float32(b.w))/2, (size.Height-cell*float32(b.h))/2)
+}
+
+func (bw *boardWidget) Dragged(e *fyne.DragEvent) {
+	cell, origin := bw.geometry()
+	if bw.dragPID == "" {
+		bw.dragX, bw.dragY = e.Position.X-e.Dragged.DX, e.Position.Y-e.Dragged.DY
+		x, y := (bw.dragX-origin.X)/cell, (bw.dragY-origin.Y)/cell
+		if x < 0 || y < 0 {
+			return
+		}
+		if bw.dragPID = bw.g.pieceAt(Space{int(x), int(y)}); bw.dragPID == "" {
+			return
+		}
+	}
+	dx, dy := bw.g.clampDrag(bw.dragPID,
+		float64(e.Position.X-bw.dragX), float64(e.Position.Y-bw.dragY), float64(cell))
+	bw.dragDX, bw.dragDY = float32(dx), float32(dy)
+	bw.Refresh()
+}
+
+func (bw *boardWidget) DragEnd() {
+	if bw.dragPID == "" {
+		return
+	}
+	cell, _ := bw.geometry()
+	bw.g.drop(bw.dragPID, float64(bw.dragDX), float64(bw.dragDY), float64(cell))
+	bw.dragPID, bw.dragDX, bw.dragDY = "", 0, 0
+	bw.Refresh()
+	if bw.onMove != nil {
+		bw.onMove()
+	}
+}
+
+func (bw *boardWidget) CreateRenderer() fyne.WidgetRenderer {
+	return &boardRenderer{bw: bw}
+}
+
+type boardRenderer struct {
+	bw   *boardWidget
+	objs []fyne.CanvasObject
+}
+
+func (r *boardRenderer) Layout(size fyne.Size) { r.Refresh() }
+
+func (r *boardRenderer) MinSize() fyne.Size {
+	b := r.bw.g.b
+	return fyne.NewSize(float32(b.w*32), float32(b.h*32))
+}
+
+// Rebuilds the drawing of the board.
+func (r *boardRenderer) Refresh() {
+	bw := r.bw
+	b := bw.g.b
+	cell, origin := bw.geometry()
+	r.objs = nil
+	rect := func(x, y, w, h float32, c color.Color) {
+		cr := canvas.NewRectangle(c)
+		cr.Move(fyne.NewPos(x, y))
+		cr.Resize(fyne.NewSize(w, h))
+		r.objs = append(r.objs, cr)
+	}
+	rect(origin.X-4, origin.Y-4, cell*float32(b.w)+8, cell*float32(b.h)+8, activeTheme.frame)
+	rect(origin.X, origin.Y, cell*float32(b.w), cell*float32(b.h), activeTheme.open)
+	for s := range b.walls {
+		rect(origin.X+cell*float32(s.x), origin.Y+cell*float32(s.y), cell, cell, activeTheme.wall)
+	}
+	for _, pid := range b.pieceIDs() {
+		p := b.ps[pid]
+		px, py := origin.X+cell*float32(p.x), origin.Y+cell*float32(p.y)
+		if pid == bw.dragPID {
+			px, py = px+bw.dragDX, py+bw.dragDY
+		}
+		c := activeTheme.pieceColor(b, pid)
+		rect(px+2, py+2, cell*float32(p.w)-4, cell*float32(p.h)-4, c)
+		label := canvas.NewText(pid, labelColor(c))
+		label.TextSize = cell / 3
+		label.Move(fyne.NewPos(px+cell/3, py+cell/4))
+		r.objs = append(r.objs, label)
+	}
+	canvas.Refresh(bw)
+}
+
+func (r *boardRenderer) Objects() []fyne.CanvasObject { return r.objs }
+
+func (r *boardRenderer) Destroy() {}
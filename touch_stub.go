@@ -0,0 +1,18 @@
+//go:build !touch
+
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+// Builds without the touch UI never start it by default.
+const touchByDefault = false
+
+// The touch UI needs Fyne, so it's only included in builds with the "touch"
+// tag (go build -tags touch, or fyne package -tags touch for phones).
+func runTouchUI(start *Board) {
+	fmt.Fprintln(os.Stderr, "This squareroot was built without the touch UI. Rebuild with: go build -tags touch")
+	os.Exit(1)
+}
@@ -0,0 +1,256 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"flag"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"os"
+	"strconv"
+)
+
+// Search traces.
+//
+// With -trace <file>, "solve" records the order in which the search
+// expands positions, for seeing how a solver explored the space when
+// debugging a new heuristic or pruning rule. "squareroot trace inspect
+// <file>" summarizes a trace: how many positions were expanded at each
+// depth and how many were expanded more than once. "squareroot trace
+// replay <file> [first [last]]" steps through the expansions, showing each
+// position, its depth and the expansion it was reached from, and
+// "squareroot trace explore <file>" browses them as a tree (see explore.go).
+//
+// A trace is the magic line "SRTRACE1\n", the length of the puzzle as a
+// 4-byte little-endian number and the puzzle in the puzzle file format,
+// followed by one 16-byte record per expansion:
+//
+//	hash    8 bytes  hash of the position's configuration
+//	parent  4 bytes  index of the expansion it was reached from, or 2^32-1
+//	depth   2 bytes  moves from the start
+//	piece   1 byte   id of the piece moved last, or 0 at the start
+//	dir     1 byte   direction it moved, or 255 at the start
+//
+// The breadth-first, A* and -piece-moves searches are traced; -workers and
+// -flat searches aren't.
+
+var traceFile = flag.String("trace", "",
+	"Record the order in which the search expands positions in this file.")
+
+const traceMagic = "SRTRACE1\n"
+
+// The parent of expansions with none.
+const traceNoParent = 1<<32 - 1
+
+// An expansion in a trace.
+type traceRecord struct {
+	Hash   uint64
+	Parent uint32
+	Depth  uint16
+	Piece  byte
+	Dir    byte
+}
+
+// A trace being written, or nil if tracing is off.
+type searchTrace struct {
+	f     io.WriteCloser
+	w     *bufio.Writer
+	index map[uint64]uint32 // the first expansion of each configuration
+	n     uint32
+}
+
+// The trace of the current run.
+var tracer *searchTrace
+
+// Opens the trace selected by -trace for a search of the given puzzle.
+func openTrace(start *Board) error {
+	if *traceFile == "" {
+		return nil
+	}
+	text, err := start.puzzleFile()
+	if err != nil {
+		return fmt.Errorf("can't trace this puzzle: %v", err)
+	}
+	f, err := createOutput(*traceFile)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	w.WriteString(traceMagic)
+	binary.Write(w, binary.LittleEndian, uint32(len(text)))
+	w.WriteString(text)
+	tracer = &searchTrace{f: f, w: w, index: make(map[uint64]uint32)}
+	return nil
+}
+
+// Records the expansion of a board.
+func (t *searchTrace) expand(b *Board) {
+	if t == nil {
+		return
+	}
+	r := traceRecord{Hash: configHash(b), Parent: traceNoParent, Depth: uint16(len(b.mvs)), Dir: 255}
+	if len(b.mvs) > 0 {
+		m := b.mvs[len(b.mvs)-1]
+		r.Piece, r.Dir = m.pid[0], byte(m.dir)
+		// The parent is the board with the last move undone.
+		parent := b.move(Move{m.pid, m.dir.reverse()})
+		parent.mvs = b.mvs[:len(b.mvs)-1]
+		if i, ok := t.index[configHash(parent)]; ok {
+			r.Parent = i
+		}
+	}
+	if _, ok := t.index[r.Hash]; !ok {
+		t.index[r.Hash] = t.n
+	}
+	t.n++
+	binary.Write(t.w, binary.LittleEndian, r)
+}
+
+// Finishes writing the trace.
+func (t *searchTrace) close() error {
+	if t == nil {
+		return nil
+	}
+	if err := t.w.Flush(); err != nil {
+		return err
+	}
+	return t.f.Close()
+}
+
+// Returns a hash of a board's configuration.
+func configHash(b *Board) uint64 {
+	return hashConfig(b.Config())
+}
+
+// Returns the hash of a configuration.
+func hashConfig(config string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(config))
+	return h.Sum64()
+}
+
+// The opposite direction.
+func (d Direction) reverse() Direction {
+	return d ^ 1
+}
+
+// Reads a trace, returning its puzzle and expansions.
+func readTrace(name string) (*Board, []traceRecord, error) {
+	data, err := readInput(name)
+	if err != nil {
+		return nil, nil, err
+	}
+	rest, ok := bytes.CutPrefix(data, []byte(traceMagic))
+	if !ok || len(rest) < 4 {
+		return nil, nil, fmt.Errorf("%s is not a search trace", name)
+	}
+	n := int(binary.LittleEndian.Uint32(rest))
+	rest = rest[4:]
+	if n > len(rest) {
+		return nil, nil, fmt.Errorf("%s: trace cut short", name)
+	}
+	start, err := parseBoard(bytes.NewReader(rest[:n]))
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s: %v", name, err)
+	}
+	rest = rest[n:]
+	rs := make([]traceRecord, len(rest)/16)
+	if err := binary.Read(bytes.NewReader(rest), binary.LittleEndian, rs); err != nil {
+		return nil, nil, fmt.Errorf("%s: %v", name, err)
+	}
+	return start, rs, nil
+}
+
+// Returns the board expanded by record i, rebuilt by following its parents
+// back to the start.
+func traceBoard(start *Board, rs []traceRecord, i int) *Board {
+	mvs := []Move{}
+	for j := i; j >= 0 && j < len(rs) && rs[j].Dir != 255; j = int(rs[j].Parent) {
+		mvs = append(mvs, Move{string(rs[j].Piece), Direction(rs[j].Dir)})
+	}
+	b := start
+	for k := len(mvs) - 1; k >= 0; k-- {
+		b = b.move(mvs[k])
+	}
+	return b
+}
+
+// Runs "trace inspect <file>", "trace replay <file> [first [last]]" or
+// "trace explore <file>".
+func runTrace(args []string) {
+	if len(args) < 2 || args[0] != "inspect" && args[0] != "replay" && args[0] != "explore" ||
+		args[0] != "replay" && len(args) != 2 || len(args) > 4 {
+		fmt.Fprintln(os.Stderr, "usage: squareroot trace inspect <file> | replay <file> [first [last]] | explore <file>")
+		os.Exit(exitInvalid)
+	}
+	start, rs, err := readTrace(args[1])
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(exitInvalid)
+	}
+	switch args[0] {
+	case "inspect":
+		inspectTrace(start, rs)
+		return
+	case "explore":
+		explore(newSearchTree(start, rs), os.Stdin, os.Stdout)
+		return
+	}
+
+	first, last := 0, len(rs)-1
+	if len(args) > 2 {
+		if first, err = strconv.Atoi(args[2]); err != nil || first < 0 || first >= len(rs) {
+			fmt.Fprintf(os.Stderr, "No expansion %q: the trace has %s\n", args[2], countOf(len(rs), "expansion"))
+			os.Exit(exitInvalid)
+		}
+		last = first
+	}
+	if len(args) > 3 {
+		if last, err = strconv.Atoi(args[3]); err != nil || last < first {
+			fmt.Fprintf(os.Stderr, "Invalid last expansion %q\n", args[3])
+			os.Exit(exitInvalid)
+		}
+		last = min(last, len(rs)-1)
+	}
+	for i := first; i <= last; i++ {
+		r := rs[i]
+		fmt.Printf("Expansion %d %s: depth %d", i, hashStateID(r.Hash), r.Depth)
+		if r.Dir != 255 {
+			fmt.Printf(", %s", Move{string(r.Piece), Direction(r.Dir)})
+			if r.Parent != traceNoParent {
+				fmt.Printf(" from expansion %d", r.Parent)
+			}
+		}
+		fmt.Println()
+		fmt.Print(traceBoard(start, rs, i).display())
+	}
+}
+
+// Prints a summary of a trace.
+func inspectTrace(start *Board, rs []traceRecord) {
+	fmt.Printf("Puzzle: %dx%d, goal %v\n", start.w, start.h, start.goal)
+	fmt.Printf("Expansions: %d\n", len(rs))
+	byDepth := map[int]int{}
+	seen := map[uint64]bool{}
+	deepest, repeats := 0, 0
+	for _, r := range rs {
+		byDepth[int(r.Depth)]++
+		deepest = max(deepest, int(r.Depth))
+		if seen[r.Hash] {
+			repeats++
+		}
+		seen[r.Hash] = true
+	}
+	fmt.Printf("Distinct positions: %d\n", len(seen))
+	fmt.Printf("Repeat expansions: %d\n", repeats)
+	if len(rs) == 0 {
+		return
+	}
+	fmt.Printf("Deepest: %d\n", deepest)
+	fmt.Printf("%5s %10s\n", "depth", "expanded")
+	for d := 0; d <= deepest; d++ {
+		fmt.Printf("%5d %10d\n", d, byDepth[d])
+	}
+}
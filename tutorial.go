@@ -0,0 +1,65 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// Tutorial.
+//
+// "squareroot tutorial" walks through an optimal solution of the puzzle one
+// step at a time. Each step shows the board and an explanation of the next
+// move, and the player has to make that move to go on. "skip" makes the move
+// for them and "q" quits.
+
+func runTutorial(start *Board) {
+	fmt.Println("Finding an optimal solution...")
+	end, _ := solve(start)
+	if end == nil {
+		fmt.Println("The puzzle can't be solved.")
+		return
+	}
+	tutorial(start, end.mvs, os.Stdin, os.Stdout)
+}
+
+// Teaches the given solution, reading moves from in.
+func tutorial(start *Board, mvs []Move, in io.Reader, out io.Writer) {
+	scanner := bufio.NewScanner(in)
+	fmt.Fprintf(out, "Goal: %v. The solution takes %d moves.\n", start.goal, len(mvs))
+	fmt.Fprintln(out, `Make each move as shown (e.g. "bD" or "b down"), or type "skip" or "q".`)
+	b := start
+	for i := 0; i < len(mvs); {
+		m := mvs[i]
+		fmt.Fprint(out, b.display())
+		fmt.Fprintf(out, "Step %d of %d (%s) at %s: %s\n> ", i+1, len(mvs), m.code(), b.stateID(), b.explainMove(m))
+		if !scanner.Scan() {
+			fmt.Fprintln(out)
+			return
+		}
+		args := strings.Fields(scanner.Text())
+		switch {
+		case len(args) == 0:
+			continue
+		case args[0] == "q" || args[0] == "quit":
+			return
+		case args[0] == "skip":
+		default:
+			played, err := parseMoves(args)
+			if err != nil || len(played) != 1 {
+				fmt.Fprintln(out, "Make one move, e.g. "+m.code()+".")
+				continue
+			}
+			if played[0] != m {
+				fmt.Fprintf(out, "Not quite: the move to make is %s.\n", m.code())
+				continue
+			}
+		}
+		b = b.move(m)
+		i++
+	}
+	fmt.Fprint(out, b.display())
+	fmt.Fprintf(out, "Solved in %d moves!\n", len(mvs))
+}
@@ -0,0 +1,292 @@
+// The squareroot playground: plays the served puzzle in the browser, using
+// only the server's REST endpoints (see api/openapi.json). A position is a
+// board code, or none for the starting board, and the moves played from it;
+// the server checks each move and draws each position.
+
+'use strict';
+
+const cellSize = 48;
+const colors = ['#f4a261', '#2a9d8f', '#e9c46a', '#8ab17d', '#e76f51', '#90be6d',
+  '#a8dadc', '#f28482', '#b5838d', '#84a59d', '#ffb703', '#8ecae6'];
+
+const boardEl = document.getElementById('board');
+const statusEl = document.getElementById('status');
+const movesEl = document.getElementById('moves');
+const codeEl = document.getElementById('code');
+
+let base = '';      // board code the moves are played from, or '' for the start
+let played = [];    // moves played, in compact notation
+let board = null;   // the parsed board shown
+let pieceEls = [];  // elements of the pieces shown, with their places
+let selected = '';  // letter of the selected piece
+let busy = false;   // whether a solution is playing
+
+// Parses a board drawn as text into its size, walls, marks and pieces. Each
+// piece is a connected run of squares with the same letter.
+function parseBoard(text) {
+  const rows = text.split('\n').filter(l => l.startsWith('|')).map(l => l.slice(1, -1));
+  const h = rows.length, w = h ? rows[0].length : 0;
+  const b = {w, h, walls: [], marks: [], pieces: []};
+  const seen = rows.map(r => Array(r.length).fill(false));
+  for (let y = 0; y < h; y++) {
+    for (let x = 0; x < w; x++) {
+      const c = rows[y][x];
+      if (c === '#') {
+        b.walls.push({x, y});
+      } else if ('^v<>+'.includes(c)) {
+        b.marks.push({x, y, c});
+      } else if (/[A-Za-z0-9]/.test(c) && !seen[y][x]) {
+        b.pieces.push(fill(rows, seen, x, y, c));
+      }
+    }
+  }
+  return b;
+}
+
+// Returns the bounding box of the run of squares with letter c at x, y,
+// marking them seen.
+function fill(rows, seen, x, y, c) {
+  const p = {letter: c, x0: x, y0: y, x1: x, y1: y};
+  const todo = [[x, y]];
+  seen[y][x] = true;
+  while (todo.length) {
+    const [cx, cy] = todo.pop();
+    p.x0 = Math.min(p.x0, cx); p.x1 = Math.max(p.x1, cx);
+    p.y0 = Math.min(p.y0, cy); p.y1 = Math.max(p.y1, cy);
+    for (const [nx, ny] of [[cx + 1, cy], [cx - 1, cy], [cx, cy + 1], [cx, cy - 1]]) {
+      if (ny >= 0 && ny < rows.length && nx >= 0 && nx < rows[ny].length &&
+          !seen[ny][nx] && rows[ny][nx] === c) {
+        seen[ny][nx] = true;
+        todo.push([nx, ny]);
+      }
+    }
+  }
+  return {letter: c, x: p.x0, y: p.y0, w: p.x1 - p.x0 + 1, h: p.y1 - p.y0 + 1};
+}
+
+function colorOf(letter) {
+  return colors[letter.charCodeAt(0) % colors.length];
+}
+
+// Draws a board. Pieces already shown slide to their new places, each
+// matched to the nearest new piece with its letter.
+function draw(text) {
+  const b = parseBoard(text);
+  if (!board || board.w !== b.w || board.h !== b.h ||
+      JSON.stringify(board.walls) !== JSON.stringify(b.walls)) {
+    drawCells(b);
+  }
+  board = b;
+  const old = pieceEls;
+  pieceEls = [];
+  for (const p of b.pieces) {
+    let best = -1;
+    old.forEach((o, i) => {
+      if (o && o.letter === p.letter &&
+          (best < 0 || distance(o, p) < distance(old[best], p))) {
+        best = i;
+      }
+    });
+    let el;
+    if (best >= 0) {
+      el = old[best].el;
+      old[best] = null;
+    } else {
+      el = document.createElement('div');
+      el.className = 'piece';
+      el.textContent = p.letter;
+      el.style.background = colorOf(p.letter);
+      el.addEventListener('click', e => {
+        e.stopPropagation();
+        select(p.letter);
+      });
+      boardEl.appendChild(el);
+    }
+    el.style.left = p.x * cellSize + 'px';
+    el.style.top = p.y * cellSize + 'px';
+    el.style.width = p.w * cellSize + 'px';
+    el.style.height = p.h * cellSize + 'px';
+    el.classList.toggle('selected', p.letter === selected);
+    pieceEls.push({...p, el});
+  }
+  for (const o of old) {
+    if (o) {
+      o.el.remove();
+    }
+  }
+}
+
+function distance(p, q) {
+  return Math.abs(p.x - q.x) + Math.abs(p.y - q.y);
+}
+
+// Draws the empty board: its cells, walls and one-way marks.
+function drawCells(b) {
+  boardEl.replaceChildren();
+  pieceEls = [];
+  boardEl.style.width = b.w * cellSize + 'px';
+  boardEl.style.height = b.h * cellSize + 'px';
+  const marks = new Map(b.marks.map(m => [m.x + ',' + m.y, m.c]));
+  const walls = new Set(b.walls.map(s => s.x + ',' + s.y));
+  for (let y = 0; y < b.h; y++) {
+    for (let x = 0; x < b.w; x++) {
+      const el = document.createElement('div');
+      el.className = walls.has(x + ',' + y) ? 'cell wall' : 'cell';
+      el.textContent = marks.get(x + ',' + y) || '';
+      el.style.left = x * cellSize + 'px';
+      el.style.top = y * cellSize + 'px';
+      el.style.width = el.style.height = el.style.lineHeight = cellSize + 'px';
+      el.addEventListener('click', () => clickCell(x, y));
+      boardEl.appendChild(el);
+    }
+  }
+}
+
+function select(letter) {
+  selected = selected === letter ? '' : letter;
+  for (const p of pieceEls) {
+    p.el.classList.toggle('selected', p.letter === selected);
+  }
+  boardEl.focus();
+}
+
+// Moves the selected piece toward an open space beside it.
+function clickCell(x, y) {
+  const p = pieceEls.find(p => p.letter === selected);
+  if (!p) {
+    return;
+  }
+  const inCols = x >= p.x && x < p.x + p.w;
+  const inRows = y >= p.y && y < p.y + p.h;
+  if (inCols && y === p.y - 1) {
+    play('U');
+  } else if (inCols && y === p.y + p.h) {
+    play('D');
+  } else if (inRows && x === p.x - 1) {
+    play('L');
+  } else if (inRows && x === p.x + p.w) {
+    play('R');
+  }
+}
+
+// Returns the query selecting the position after the given moves.
+function query(moves) {
+  const q = new URLSearchParams();
+  if (base) {
+    q.set('board', base);
+  }
+  if (moves.length) {
+    q.set('moves', moves.join(','));
+  }
+  return q;
+}
+
+async function get(path, q) {
+  const resp = await fetch(path + '?' + q);
+  const body = await resp.json();
+  if (!resp.ok) {
+    throw new Error(body.error || resp.statusText);
+  }
+  return body;
+}
+
+function show(msg, error) {
+  statusEl.textContent = msg;
+  statusEl.classList.toggle('error', !!error);
+}
+
+// Shows the position after the given moves, and how far it is from the
+// goal, and makes them the moves played. Reports whether the server
+// accepted them.
+async function goTo(moves) {
+  const q = query(moves);
+  q.set('boards', 'true');
+  try {
+    const sol = await get('/solve', q);
+    played = moves;
+    draw(sol.boards[0]);
+    show(sol.length === 0 ? 'Solved!' : sol.length + ' moves to go');
+    movesEl.textContent = played.join(' ');
+    return true;
+  } catch (err) {
+    show(err.message, true);
+    return false;
+  }
+}
+
+function play(dir) {
+  if (selected && !busy) {
+    goTo(played.concat(selected + dir));
+  }
+}
+
+async function hint() {
+  try {
+    const h = await get('/hint', query(played));
+    if (h.move) {
+      selected = h.move.slice(0, -1);
+      await goTo(played.concat(h.move));
+    }
+  } catch (err) {
+    show(err.message, true);
+  }
+}
+
+// Plays an optimal solution from the position shown, one move at a time.
+async function solve() {
+  const q = query(played);
+  q.set('boards', 'true');
+  let sol;
+  try {
+    sol = await get('/solve', q);
+  } catch (err) {
+    show(err.message, true);
+    return;
+  }
+  busy = true;
+  for (let i = 1; i < sol.boards.length; i++) {
+    await new Promise(r => setTimeout(r, 400));
+    selected = sol.moves[i - 1].slice(0, -1);
+    draw(sol.boards[i]);
+    played = played.concat(sol.moves[i - 1]);
+    movesEl.textContent = played.join(' ');
+    show((sol.length - i) + ' moves to go');
+  }
+  busy = false;
+  show('Solved in ' + sol.length + ' moves from there.');
+}
+
+async function load() {
+  const old = base;
+  base = codeEl.value.trim();
+  if (!await goTo([])) {
+    base = old;
+  }
+}
+
+document.addEventListener('keydown', e => {
+  const dir = {ArrowUp: 'U', ArrowDown: 'D', ArrowLeft: 'L', ArrowRight: 'R'}[e.key];
+  if (dir && document.activeElement !== codeEl) {
+    e.preventDefault();
+    play(dir);
+  }
+});
+for (const b of document.querySelectorAll('.arrows button')) {
+  b.addEventListener('click', () => play(b.dataset.dir));
+}
+document.getElementById('solve').addEventListener('click', () => busy || solve());
+document.getElementById('hint').addEventListener('click', () => busy || hint());
+document.getElementById('undo').addEventListener('click', () => busy || goTo(played.slice(0, -1)));
+document.getElementById('reset').addEventListener('click', () => busy || goTo([]));
+document.getElementById('load').addEventListener('click', () => busy || load());
+
+(async () => {
+  try {
+    const p = await get('/puzzle', new URLSearchParams());
+    document.getElementById('goal').textContent = 'Goal: ' + p.goal;
+    codeEl.value = p.code;
+    await goTo([]);
+  } catch (err) {
+    show(err.message, true);
+  }
+})();
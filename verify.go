@@ -0,0 +1,39 @@
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+// Solution checking.
+//
+// "squareroot verify" replays a solution, given as moves on the command line
+// or as a file in any form "grade" reads, and checks that every move is legal
+// and that the last one reaches the goal. It exits with a nonzero status if
+// the solution doesn't hold up, so it can check solutions in scripts.
+
+// Runs "verify <file> | <move>...".
+func runVerify(start *Board, args []string) {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, "usage: squareroot verify [-puzzle file] <file> | <move>...")
+		os.Exit(exitInvalid)
+	}
+	start, mvs, err := readGradedMoves(start, args)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(exitInvalid)
+	}
+	b := start
+	for i, m := range mvs {
+		if b, err = b.ApplyMove(m); err != nil {
+			fmt.Printf("Move %d (%s) is illegal: %v.\n", i+1, m.code(), err)
+			os.Exit(exitInvalid)
+		}
+	}
+	if !b.goal.IsSatisfied(b) {
+		fmt.Printf("The %d moves don't reach the goal.\n", len(mvs))
+		os.Exit(exitError)
+	}
+	fmt.Printf("Valid solution (%d moves)\n", len(mvs))
+	fmt.Printf("Reached goal: %s\n", describeReached(b.goal, b))
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// Video export.
+//
+// "squareroot video <out>" renders a frame for each move of the solution
+// (the board with the next move highlighted, then the solved board) as
+// numbered PNG files. If <out> ends in .mp4 or .webm and ffmpeg is
+// installed, the frames are written to a temporary directory and encoded
+// into that video; otherwise <out> is the directory the frames are written
+// to. -fps sets the frame rate and -cell the resolution.
+
+var fps = flag.Int("fps", 4, "Moves per second in videos and casts.")
+
+func runVideo(start *Board, args []string) {
+	if len(args) != 1 {
+		fmt.Fprintln(os.Stderr, "usage: squareroot video [-fps n] [-cell pixels] <file.mp4 | file.webm | frame directory>")
+		os.Exit(exitInvalid)
+	}
+	out := args[0]
+	ext := strings.ToLower(filepath.Ext(out))
+	encode := ext == ".mp4" || ext == ".webm"
+	ffmpeg, err := exec.LookPath("ffmpeg")
+	if encode && err != nil {
+		fmt.Fprintln(os.Stderr, "Writing frames only: ffmpeg isn't installed")
+		encode = false
+		out = strings.TrimSuffix(out, filepath.Ext(out)) + "-frames"
+	}
+
+	end, _ := findSolution(start)
+	if end == nil {
+		fmt.Fprintln(os.Stderr, "Couldn't find solution")
+		os.Exit(1)
+	}
+
+	dir := out
+	if encode {
+		if dir, err = os.MkdirTemp("", "squareroot-frames"); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		defer os.RemoveAll(dir)
+	}
+	if err := writeFrames(dir, start, end.mvs); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	if !encode {
+		fmt.Printf("Wrote %d frames to %s\n", len(end.mvs)+1, dir)
+		return
+	}
+
+	codec := []string{"-c:v", "libx264", "-pix_fmt", "yuv420p"}
+	if ext == ".webm" {
+		codec = []string{"-c:v", "libvpx-vp9", "-b:v", "0", "-crf", "32"}
+	}
+	cmd := exec.Command(ffmpeg, append(append([]string{
+		"-y", "-loglevel", "error",
+		"-framerate", fmt.Sprint(max(*fps, 1)),
+		"-i", filepath.Join(dir, "frame%04d.png"),
+		// Encoders want even dimensions.
+		"-vf", "pad=ceil(iw/2)*2:ceil(ih/2)*2",
+	}, codec...), out)...)
+	cmd.Stdout, cmd.Stderr = os.Stdout, os.Stderr
+	if err := cmd.Run(); err != nil {
+		fmt.Fprintf(os.Stderr, "ffmpeg failed: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Wrote %s (%d frames at %d fps)\n", out, len(end.mvs)+1, max(*fps, 1))
+}
+
+// Writes frame0000.png, frame0001.png, ... to dir: the board before each
+// move with the move highlighted, and then the final board.
+func writeFrames(dir string, start *Board, mvs []Move) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	cell := max(*cellSize, 8)
+	b := start
+	for i := 0; i <= len(mvs); i++ {
+		var hl *Move
+		if i < len(mvs) {
+			hl = &mvs[i]
+		}
+		if err := writePNG(filepath.Join(dir, fmt.Sprintf("frame%04d.png", i)), renderBoard(b, hl, cell)); err != nil {
+			return err
+		}
+		if hl != nil {
+			b = b.move(*hl)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Plain-language solution output.
+//
+// The words format describes the starting board and each move in sentences
+// rather than grids, for screen readers and text-to-speech, e.g. "The tall
+// piece a, in the top left corner at row 1 column 1, moves down one square." Consecutive moves
+// of a piece in the same direction are described together. Columns and rows
+// are counted from 1 at the top left.
+
+// Prints a solution in the words format.
+func printWordsSolution(start, end *Board) {
+	fmt.Println(start.describe())
+	fmt.Printf("The starting position is %s.\n", start.stateID())
+	fmt.Printf("Solution in %d moves.\n", len(end.mvs))
+	b := start
+	mvs := end.mvs
+	for i := 0; i < len(mvs); {
+		m := mvs[i]
+		n := 1
+		for i+n < len(mvs) && mvs[i+n] == m {
+			n++
+		}
+		p := b.ps[m.pid]
+		squares := "one square"
+		if n > 1 {
+			squares = fmt.Sprintf("%s squares", numberWord(n))
+		}
+		place := b.placeOf(p)
+		for j := 0; j < n; j++ {
+			b = b.move(m)
+		}
+		fmt.Printf("Move %d: %s, %s, moves %s %s, to position %s.\n", i+1, capitalize(b.pieceName(m.pid)),
+			place, strings.ToLower(m.dir.String()), squares, b.stateID())
+		i += n
+	}
+	fmt.Printf("Solved in %d moves.\n", len(end.mvs))
+}
+
+// Describes a board in sentences.
+func (b *Board) describe() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "The board is %d squares wide and %d squares tall", b.w, b.h)
+	if b.wrap {
+		sb.WriteString(", and pieces sliding off one edge come back on the opposite edge")
+	}
+	fmt.Fprintf(&sb, ". It has %d pieces.\n", len(b.ps))
+	for _, pid := range b.pieceIDs() {
+		p := b.ps[pid]
+		fmt.Fprintf(&sb, "%s is %s.\n", capitalize(b.pieceName(pid)), b.placeOf(p))
+	}
+	open := []string{}
+	walls := []string{}
+	for y := 0; y < b.h; y++ {
+		for x := 0; x < b.w; x++ {
+			s := Space{x, y}
+			if b.walls[s] {
+				walls = append(walls, squareWords(s))
+			} else if b.isOpen(s) {
+				open = append(open, squareWords(s))
+			}
+		}
+	}
+	fmt.Fprintf(&sb, "The open squares are %s.\n", joinWords(open))
+	if len(walls) > 0 {
+		fmt.Fprintf(&sb, "There are walls at %s.\n", joinWords(walls))
+	}
+	spaces := []Space{}
+	for s := range b.oneway {
+		spaces = append(spaces, s)
+	}
+	sortSpaces(spaces)
+	for _, s := range spaces {
+		dirs := []string{}
+		for _, d := range Directions {
+			if b.oneway[s].has(d) {
+				dirs = append(dirs, strings.ToLower(d.String()))
+			}
+		}
+		fmt.Fprintf(&sb, "Pieces can only enter %s moving %s.\n", squareWords(s), strings.Join(dirs, " or "))
+	}
+	for pid, ls := range b.links {
+		if len(ls) > 0 {
+			sort.Strings(ls)
+			fmt.Fprintf(&sb, "Piece %s always moves together with %s.\n", pid, joinWords(ls))
+		}
+	}
+	if b.rails {
+		sb.WriteString("Pieces only slide along their length, and square pieces either way.\n")
+	}
+	if b.constraint != nil {
+		fmt.Fprintf(&sb, "Every position must satisfy %v.\n", b.constraint)
+	}
+	for _, f := range b.filters {
+		fmt.Fprintf(&sb, "Extra rule: %v.\n", f)
+	}
+	if hasExpr(b.goal) {
+		g, _ := exprText(b.goal)
+		fmt.Fprintf(&sb, "The goal is a position satisfying %s.", g)
+	} else {
+		fmt.Fprintf(&sb, "The goal is to get %s.", goalWords(b.goal, b))
+	}
+	return sb.String()
+}
+
+// Describes where a piece is, e.g. "in the top left corner".
+func (b *Board) placeOf(p Piece) string {
+	h := "middle"
+	switch {
+	case p.w == b.w:
+		h = ""
+	case p.x == 0:
+		h = "left"
+	case p.x+p.w == b.w:
+		h = "right"
+	}
+	v := "middle"
+	switch {
+	case p.h == b.h:
+		v = ""
+	case p.y == 0:
+		v = "top"
+	case p.y+p.h == b.h:
+		v = "bottom"
+	}
+	var where string
+	switch {
+	case v != "middle" && v != "" && h != "middle" && h != "":
+		where = fmt.Sprintf("in the %s %s corner", v, h)
+	case v != "middle" && v != "":
+		where = fmt.Sprintf("at the %s", strings.TrimSpace(v+" "+h))
+	case h != "middle" && h != "":
+		where = fmt.Sprintf("on the %s side", h)
+	default:
+		where = "in the middle"
+	}
+	return fmt.Sprintf("%s at %s", where, squareWords(Space{p.x, p.y}))
+}
+
+// Names a square, e.g. "row 5 column 2".
+func squareWords(s Space) string {
+	return fmt.Sprintf("row %d column %d", s.y+1, s.x+1)
+}
+
+// Describes a goal, e.g. "the big square piece b at the bottom middle at row
+// 4 column 2".
+func goalWords(g Goal, b *Board) string {
+	switch g := g.(type) {
+	case AllOf:
+		ws := []string{}
+		for _, sg := range g {
+			ws = append(ws, goalWords(sg, b))
+		}
+		return strings.Join(ws, ", and ")
+	case AnyOf:
+		ws := []string{}
+		for _, sg := range g {
+			ws = append(ws, goalWords(sg, b))
+		}
+		return "either " + strings.Join(ws, ", or ")
+	case Condition:
+		if g.pid == "" {
+			p := Piece{"", g.w, g.h, g.x, g.y}
+			return fmt.Sprintf("any %dx%d piece %s", g.w, g.h, b.placeOf(p))
+		}
+		p := b.ps[g.pid]
+		p.x, p.y = g.x, g.y
+		return fmt.Sprintf("%s %s", b.pieceName(g.pid), b.placeOf(p))
+	}
+	return fmt.Sprint(g)
+}
+
+func capitalize(s string) string {
+	if s == "" {
+		return s
+	}
+	return strings.ToUpper(s[:1]) + s[1:]
+}
+
+// Spells out small numbers.
+func numberWord(n int) string {
+	words := []string{"zero", "one", "two", "three", "four", "five", "six", "seven", "eight", "nine", "ten"}
+	if n < len(words) {
+		return words[n]
+	}
+	return fmt.Sprint(n)
+}